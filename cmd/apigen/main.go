@@ -0,0 +1,23 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/dliluashvili/cowatchit/internal/openapi"
+)
+
+func main() {
+	out := flag.String("out", "pkg/apiclient/client_gen.go", "where to write the client")
+	flag.Parse()
+
+	source, err := openapi.GenerateClient("apiclient")
+	if err != nil {
+		log.Fatal("Failed to generate client: ", err)
+	}
+
+	if err := os.WriteFile(*out, source, 0o644); err != nil {
+		log.Fatal("Failed to write client: ", err)
+	}
+}
@@ -1,16 +1,36 @@
 package main
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/dliluashvili/cowatchit/db"
 	"github.com/dliluashvili/cowatchit/db/migrations"
+	"github.com/dliluashvili/cowatchit/internal/config"
 )
 
 func main() {
-	dbconnection := db.New(".env.dev")
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	dbconnection, err := db.New(cfg.Postgres)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
+	migrations.CreateCountriesTable(dbconnection)
 	migrations.CreateUserTable(dbconnection)
 	migrations.CreateSessionTable(dbconnection)
+	migrations.CreateLibraryItemTable(dbconnection)
+	migrations.CreateMediaJobTable(dbconnection)
 	migrations.CreateRoomTable(dbconnection)
 	migrations.CreateRoomUserTable(dbconnection)
 	migrations.CreateRoomMessageTable(dbconnection)
+	migrations.CreateAPITokenTable(dbconnection)
+	migrations.CreateUserIdentityTable(dbconnection)
+	migrations.CreateRecoveryCodeTable(dbconnection)
 }
@@ -0,0 +1,310 @@
+// Command mockoidc is an OpenID Connect provider for local development
+// and tests. Every sign in shows a form to pick the identity returned, so
+// first sign ins, linking by email and domain allowlists are easy to try.
+//
+//	go run ./cmd/mockoidc -addr :9000
+//
+// and point the server at it with OIDC_ISSUER=http://localhost:9000,
+// OIDC_CLIENT_ID=cowatchit and OIDC_REDIRECT_URL=http://localhost:8080/auth/oidc/callback.
+// Keys live in memory, restarting it invalidates issued tokens.
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+const keyID = "mockoidc"
+
+// Codes are single use and expire quickly, like a real provider's
+const codeTTL = time.Minute
+
+type authorization struct {
+	clientID      string
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	claims        map[string]any
+	expiresAt     time.Time
+}
+
+type provider struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	key          *rsa.PrivateKey
+	signer       jose.Signer
+
+	mu    sync.Mutex
+	codes map[string]*authorization
+}
+
+func main() {
+	addr := flag.String("addr", ":9000", "listen address")
+	issuer := flag.String("issuer", "http://localhost:9000", "issuer URL, must match how clients reach this server")
+	clientID := flag.String("client-id", "cowatchit", "the only client allowed")
+	clientSecret := flag.String("client-secret", "", "required from the client when set")
+	flag.Parse()
+
+	p, err := newProvider(*issuer, *clientID, *clientSecret)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("mock oidc provider %s listening on %s", p.issuer, *addr)
+	log.Fatal(http.ListenAndServe(*addr, p.routes()))
+}
+
+func newProvider(issuer, clientID, clientSecret string) (*provider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: key, KeyID: keyID}},
+		(&jose.SignerOptions{}).WithType("JWT"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create signer: %w", err)
+	}
+
+	return &provider{
+		issuer:       issuer,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		key:          key,
+		signer:       signer,
+		codes:        map[string]*authorization{},
+	}, nil
+}
+
+func (p *provider) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("GET /jwks", p.jwks)
+	mux.HandleFunc("GET /authorize", p.authorizeForm)
+	mux.HandleFunc("POST /authorize", p.authorize)
+	mux.HandleFunc("POST /token", p.token)
+
+	return mux
+}
+
+func (p *provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                p.issuer,
+		"authorization_endpoint":                p.issuer + "/authorize",
+		"token_endpoint":                        p.issuer + "/token",
+		"jwks_uri":                              p.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"scopes_supported":                      []string{"openid", "profile", "email"},
+	})
+}
+
+func (p *provider) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{
+		Key:       &p.key.PublicKey,
+		KeyID:     keyID,
+		Algorithm: string(jose.RS256),
+		Use:       "sig",
+	}}})
+}
+
+var authorizeTemplate = template.Must(template.New("authorize").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Mock OIDC sign in</title></head>
+<body style="font-family: sans-serif; max-width: 28rem; margin: 4rem auto">
+	<h1>Mock OIDC sign in</h1>
+	<form method="post" action="/authorize">
+		{{range $name, $value := .Query}}<input type="hidden" name="{{$name}}" value="{{index $value 0}}">
+		{{end}}
+		<p><label>Subject<br><input name="sub" value="user-1" required></label></p>
+		<p><label>Email<br><input name="email" value="alice@example.com"></label></p>
+		<p><label><input type="checkbox" name="email_verified" value="true" checked> Email verified</label></p>
+		<p><label>Preferred username<br><input name="preferred_username" value="alice"></label></p>
+		<p><label>Name<br><input name="name" value="Alice Example"></label></p>
+		<button type="submit" name="decision" value="allow">Sign in</button>
+		<button type="submit" name="decision" value="deny">Deny</button>
+	</form>
+</body>
+</html>`))
+
+// The provider's login page
+func (p *provider) authorizeForm(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if query.Get("client_id") != p.clientID || query.Get("response_type") != "code" || query.Get("redirect_uri") == "" {
+		http.Error(w, "unknown client, bad response_type or missing redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	authorizeTemplate.Execute(w, map[string]any{"Query": query})
+}
+
+func (p *provider) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	form := r.PostForm
+
+	redirect, err := url.Parse(form.Get("redirect_uri"))
+	if err != nil || form.Get("client_id") != p.clientID {
+		http.Error(w, "bad redirect_uri or client_id", http.StatusBadRequest)
+		return
+	}
+
+	params := redirect.Query()
+	params.Set("state", form.Get("state"))
+
+	if form.Get("decision") == "deny" {
+		params.Set("error", "access_denied")
+		redirect.RawQuery = params.Encode()
+		http.Redirect(w, r, redirect.String(), http.StatusFound)
+		return
+	}
+
+	claims := map[string]any{
+		"sub":            form.Get("sub"),
+		"email_verified": form.Get("email_verified") == "true",
+	}
+	for _, name := range []string{"email", "preferred_username", "name"} {
+		if value := form.Get(name); value != "" {
+			claims[name] = value
+		}
+	}
+
+	code := randomString()
+
+	p.mu.Lock()
+	p.codes[code] = &authorization{
+		clientID:      form.Get("client_id"),
+		redirectURI:   form.Get("redirect_uri"),
+		nonce:         form.Get("nonce"),
+		codeChallenge: form.Get("code_challenge"),
+		claims:        claims,
+		expiresAt:     time.Now().Add(codeTTL),
+	}
+	p.mu.Unlock()
+
+	params.Set("code", code)
+	redirect.RawQuery = params.Encode()
+
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (p *provider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request")
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	if clientID != p.clientID || (p.clientSecret != "" && subtle.ConstantTimeCompare([]byte(clientSecret), []byte(p.clientSecret)) != 1) {
+		tokenError(w, "invalid_client")
+		return
+	}
+
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, "unsupported_grant_type")
+		return
+	}
+
+	p.mu.Lock()
+	auth, exists := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	if !exists || time.Now().After(auth.expiresAt) || auth.clientID != clientID || auth.redirectURI != r.PostForm.Get("redirect_uri") {
+		tokenError(w, "invalid_grant")
+		return
+	}
+
+	if auth.codeChallenge != "" {
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(sum[:]) != auth.codeChallenge {
+			tokenError(w, "invalid_grant")
+			return
+		}
+	}
+
+	now := time.Now()
+
+	claims := map[string]any{
+		"iss": p.issuer,
+		"aud": clientID,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	if auth.nonce != "" {
+		claims["nonce"] = auth.nonce
+	}
+	for name, value := range auth.claims {
+		claims[name] = value
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		tokenError(w, "server_error")
+		return
+	}
+
+	signed, err := p.signer.Sign(payload)
+	if err != nil {
+		tokenError(w, "server_error")
+		return
+	}
+
+	idToken, err := signed.CompactSerialize()
+	if err != nil {
+		tokenError(w, "server_error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func tokenError(w http.ResponseWriter, code string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func randomString() string {
+	bytes := make([]byte, 16)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
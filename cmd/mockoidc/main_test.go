@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+const testRedirectURL = "http://localhost:8080/auth/oidc/callback"
+
+type testClient struct {
+	server   *httptest.Server
+	provider *oidc.Provider
+	config   *oauth2.Config
+}
+
+// Starts the mock provider and discovers it the way the server does
+func newTestClient(t *testing.T, clientSecret string) *testClient {
+	t.Helper()
+
+	var handler http.Handler
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	p, err := newProvider(server.URL, "cowatchit", clientSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler = p.routes()
+
+	provider, err := oidc.NewProvider(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("discovery: %v", err)
+	}
+
+	return &testClient{
+		server:   server,
+		provider: provider,
+		config: &oauth2.Config{
+			ClientID:     "cowatchit",
+			ClientSecret: clientSecret,
+			RedirectURL:  testRedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       []string{oidc.ScopeOpenID, "profile", "email"},
+		},
+	}
+}
+
+// Submits the sign in form for the authorization URL and returns where
+// the provider sends the browser back to
+func (c *testClient) signIn(t *testing.T, authURL, decision string) url.Values {
+	t.Helper()
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	form, err := http.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	form.Body.Close()
+
+	if form.StatusCode != http.StatusOK {
+		t.Fatalf("sign in form: got %d", form.StatusCode)
+	}
+
+	values := parsed.Query()
+	values.Set("sub", "user-1")
+	values.Set("email", "alice@example.com")
+	values.Set("email_verified", "true")
+	values.Set("preferred_username", "alice")
+	values.Set("decision", decision)
+
+	noRedirects := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	resp, err := noRedirects.Post(c.server.URL+"/authorize", "application/x-www-form-urlencoded", strings.NewReader(values.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("sign in: got %d", resp.StatusCode)
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := location.Scheme + "://" + location.Host + location.Path; got != testRedirectURL {
+		t.Fatalf("redirected to %s, want %s", got, testRedirectURL)
+	}
+
+	return location.Query()
+}
+
+func TestCallbackFlow(t *testing.T) {
+	c := newTestClient(t, "secret")
+	ctx := context.Background()
+
+	verifier := oauth2.GenerateVerifier()
+	authURL := c.config.AuthCodeURL("state-1", oidc.Nonce("nonce-1"), oauth2.S256ChallengeOption(verifier))
+
+	callback := c.signIn(t, authURL, "allow")
+
+	if callback.Get("state") != "state-1" {
+		t.Errorf("state = %q, want state-1", callback.Get("state"))
+	}
+
+	token, err := c.config.Exchange(ctx, callback.Get("code"), oauth2.VerifierOption(verifier))
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+
+	rawIDToken, _ := token.Extra("id_token").(string)
+	idToken, err := c.provider.Verifier(&oidc.Config{ClientID: "cowatchit"}).Verify(ctx, rawIDToken)
+	if err != nil {
+		t.Fatalf("verify id token: %v", err)
+	}
+
+	if idToken.Nonce != "nonce-1" || idToken.Subject != "user-1" {
+		t.Errorf("nonce %q and subject %q, want nonce-1 and user-1", idToken.Nonce, idToken.Subject)
+	}
+
+	var claims struct {
+		Email             string `json:"email"`
+		EmailVerified     bool   `json:"email_verified"`
+		PreferredUsername string `json:"preferred_username"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		t.Fatal(err)
+	}
+
+	if claims.Email != "alice@example.com" || !claims.EmailVerified || claims.PreferredUsername != "alice" {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	// Codes are single use
+	if _, err := c.config.Exchange(ctx, callback.Get("code"), oauth2.VerifierOption(verifier)); err == nil {
+		t.Error("a code was redeemed twice")
+	}
+}
+
+func TestCallbackRejectsWrongVerifier(t *testing.T) {
+	c := newTestClient(t, "")
+
+	authURL := c.config.AuthCodeURL("state-1", oauth2.S256ChallengeOption(oauth2.GenerateVerifier()))
+	callback := c.signIn(t, authURL, "allow")
+
+	_, err := c.config.Exchange(context.Background(), callback.Get("code"), oauth2.VerifierOption(oauth2.GenerateVerifier()))
+
+	var retrieveErr *oauth2.RetrieveError
+	if !errors.As(err, &retrieveErr) || retrieveErr.ErrorCode != "invalid_grant" {
+		t.Errorf("err = %v, want invalid_grant", err)
+	}
+}
+
+func TestCallbackRejectsWrongSecret(t *testing.T) {
+	c := newTestClient(t, "secret")
+	c.config.ClientSecret = "guess"
+
+	verifier := oauth2.GenerateVerifier()
+	callback := c.signIn(t, c.config.AuthCodeURL("state-1", oauth2.S256ChallengeOption(verifier)), "allow")
+
+	_, err := c.config.Exchange(context.Background(), callback.Get("code"), oauth2.VerifierOption(verifier))
+
+	var retrieveErr *oauth2.RetrieveError
+	if !errors.As(err, &retrieveErr) || retrieveErr.ErrorCode != "invalid_client" {
+		t.Errorf("err = %v, want invalid_client", err)
+	}
+}
+
+func TestCallbackDenied(t *testing.T) {
+	c := newTestClient(t, "")
+
+	callback := c.signIn(t, c.config.AuthCodeURL("state-1"), "deny")
+
+	if callback.Get("error") != "access_denied" || callback.Has("code") || callback.Get("state") != "state-1" {
+		t.Errorf("callback = %v, want access_denied with the state and no code", callback)
+	}
+}
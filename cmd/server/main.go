@@ -1,128 +1,385 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
-	"reflect"
-	"strconv"
-	"sync"
+	"os/signal"
+	"syscall"
 
 	"github.com/dliluashvili/cowatchit/db"
+	"github.com/dliluashvili/cowatchit/internal/config"
 	"github.com/dliluashvili/cowatchit/internal/dtos"
 	"github.com/dliluashvili/cowatchit/internal/handlers"
 	"github.com/dliluashvili/cowatchit/internal/interceptors"
+	"github.com/dliluashvili/cowatchit/internal/logging"
+	"github.com/dliluashvili/cowatchit/internal/metrics"
 	"github.com/dliluashvili/cowatchit/internal/middlewares"
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/dliluashvili/cowatchit/internal/openapi"
 	"github.com/dliluashvili/cowatchit/internal/repositories"
 	"github.com/dliluashvili/cowatchit/internal/services"
 	"github.com/dliluashvili/cowatchit/internal/shared/validators"
+	"github.com/dliluashvili/cowatchit/internal/storage"
+	"github.com/dliluashvili/cowatchit/internal/tracing"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
-	"github.com/go-playground/validator/v10"
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"golang.org/x/time/rate"
+	gormtracing "gorm.io/plugin/opentelemetry/tracing"
 )
 
-var (
-	visitors = make(map[string]*rate.Limiter)
-	mu       sync.Mutex
-)
-
-func getVisitor(ip string) *rate.Limiter {
-	mu.Lock()
-	defer mu.Unlock()
-
-	limiter, exists := visitors[ip]
-	if !exists {
-		limiter = rate.NewLimiter(5, 20)
-		visitors[ip] = limiter
+func main() {
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
 
-	return limiter
-}
-
-func limit(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := r.RemoteAddr // Ideally, parse real IP behind proxies
-		limiter := getVisitor(ip)
+	logger := logging.New(logging.Options{
+		Level:  cfg.Log.Level,
+		Format: cfg.Log.Format,
+	})
+	slog.SetDefault(logger)
 
-		if !limiter.Allow() {
-			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
-			return
-		}
+	db, err := db.New(cfg.Postgres)
+	if err != nil {
+		slog.Error("failed to open database", "err", err)
+		os.Exit(1)
+	}
 
-		next.ServeHTTP(w, r)
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:    cfg.Tracing.Exporter,
+		ServiceName: cfg.Tracing.ServiceName,
+		SampleRatio: cfg.Tracing.SampleRatio,
 	})
-}
-
-func main() {
-	db := db.New(".env.dev")
+	if err != nil {
+		slog.Error("failed to set up tracing", "err", err)
+		os.Exit(1)
+	}
 
-	redisHost := os.Getenv("REDIS_HOST")
-	redisPort, err := strconv.Atoi(os.Getenv("REDIS_PORT"))
+	if err := db.Use(metrics.GormPlugin{}); err != nil {
+		slog.Error("failed to register db metrics", "err", err)
+	}
 
-	if err != nil {
-		fmt.Println("Incorrect redis port")
+	if err := db.Use(gormtracing.NewPlugin(gormtracing.WithoutMetrics())); err != nil {
+		slog.Error("failed to register db tracing", "err", err)
 	}
 
 	redisClient := redis.NewClient(&redis.Options{
-		Addr: fmt.Sprintf("%s:%d", redisHost, redisPort),
+		Addr:     fmt.Sprintf("%s:%d", cfg.Redis.Host, cfg.Redis.Port),
+		Username: cfg.Redis.Username,
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+		PoolSize: cfg.Redis.PoolSize,
 	})
 
-	var validate = validator.New(validator.WithRequiredStructEnabled())
+	redisClient.AddHook(metrics.RedisHook{})
 
-	validate.RegisterTagNameFunc(func(fld reflect.StructField) string {
-		return fld.Tag.Get("json")
-	})
+	if err := redisotel.InstrumentTracing(redisClient); err != nil {
+		slog.Error("failed to register redis tracing", "err", err)
+	}
 
-	sessionService := services.NewSessionService(redisClient)
+	store, err := storage.New(cfg.Storage)
+	if err != nil {
+		slog.Error("failed to open storage", "err", err)
+		os.Exit(1)
+	}
+
+	sessionService := services.NewSessionService(redisClient, cfg.Session)
 	roomRedisService := services.NewRoomRedisService(redisClient)
 
-	userRepository := repositories.NewUserRepository(db)
-	roomRepository := repositories.NewRoomRepository(db)
+	userRepository := repositories.NewUserRepository(db, cfg.Postgres.QueryTimeout)
+	roomRepository := repositories.NewRoomRepository(db, cfg.Postgres.QueryTimeout)
+	roomMessageRepository := repositories.NewRoomMessageRepository(db, cfg.Postgres.QueryTimeout)
+	roomUserRepository := repositories.NewRoomUserRepository(db, cfg.Postgres.QueryTimeout)
+	libraryRepository := repositories.NewLibraryRepository(db, cfg.Postgres.QueryTimeout)
+	mediaJobRepository := repositories.NewMediaJobRepository(db, cfg.Postgres.QueryTimeout)
+	countryRepository := repositories.NewCountryRepository(db, cfg.Postgres.QueryTimeout)
+	apiTokenRepository := repositories.NewAPITokenRepository(db, cfg.Postgres.QueryTimeout)
+	userIdentityRepository := repositories.NewUserIdentityRepository(db, cfg.Postgres.QueryTimeout)
+	recoveryCodeRepository := repositories.NewRecoveryCodeRepository(db, cfg.Postgres.QueryTimeout)
+	transactor := repositories.NewTransactor(db)
+
+	validate := validators.New(userRepository)
 
 	userService := services.NewUserService(userRepository)
 	authService := services.NewAuthService(sessionService, userService)
-	roomService := services.NewRoomService(roomRepository, userService, roomRedisService)
+	apiTokenService := services.NewAPITokenService(apiTokenRepository, userService)
+	twoFactorService := services.NewTwoFactorService(redisClient, recoveryCodeRepository, transactor, userService, sessionService)
+	oidcService := services.NewOIDCService(cfg.OIDC, redisClient, userIdentityRepository, transactor, userService, authService)
+	imageService := services.NewImageService(store, cfg.Limits.MaxImageUploadKB)
+	libraryService := services.NewLibraryService(libraryRepository, mediaJobRepository, store, cfg.Library)
+	mediaService := services.NewMediaService(mediaJobRepository, libraryRepository, store, cfg.Media)
+	roomService := services.NewRoomService(roomRepository, roomMessageRepository, roomUserRepository, transactor, userService, roomRedisService, imageService, libraryService, storage.NewSigner([]byte(cfg.Storage.SigningKey)), cfg.Library.LinkExpiry)
+	profileService := services.NewProfileService(countryRepository, roomRepository, roomMessageRepository, transactor, userService, imageService)
 
-	authHandler := handlers.NewAuthHandler(authService)
+	landingHandler := handlers.NewLandingHandler(cfg.OIDC)
+	authHandler := handlers.NewAuthHandler(authService, cfg.Session)
+	oidcHandler := handlers.NewOIDCHandler(oidcService, validate, cfg.Session)
+	twoFactorHandler := handlers.NewTwoFactorHandler(sessionService, twoFactorService, cfg.Session)
 	userHandler := handlers.NewUserHandler(userService)
-	roomHandler := handlers.NewRoomHandler(roomService)
+	profileHandler := handlers.NewProfileHandler(validate, profileService, userService, sessionService, imageService)
+	roomHandler := handlers.NewRoomHandler(roomService, imageService, libraryService)
+	libraryHandler := handlers.NewLibraryHandler(libraryService)
+	settingsHandler := handlers.NewSettingsHandler(validate, apiTokenService, twoFactorService)
 
-	roomMessageRepository := repositories.NewRoomMessageRepository(db)
 	roomMessageService := services.NewRoomMessageService(roomMessageRepository)
 
-	webSocketManagerService := services.NewWebSocketManagerService()
+	slowConsumerPolicy, err := services.ParseSlowConsumerPolicy(cfg.WS.SlowConsumer)
+	if err != nil {
+		slog.Error("invalid websocket settings", "err", err)
+		os.Exit(1)
+	}
+
+	webSocketManagerOptions := services.WebSocketManagerOptions{
+		MaxSocketsPerUser: cfg.Limits.MaxSocketsPerUser,
+		Writer: services.SocketWriterOptions{
+			QueueSize:    cfg.WS.QueueSize,
+			WriteTimeout: cfg.WS.WriteTimeout,
+			Policy:       slowConsumerPolicy,
+		},
+		Keepalive: services.KeepaliveOptions{
+			PingInterval: cfg.WS.PingInterval,
+			PongTimeout:  cfg.WS.PongTimeout,
+			IdleTimeout:  cfg.WS.IdleTimeout,
+		},
+		Resume: services.ResumeOptions{
+			Window:           cfg.WS.ResumeWindow,
+			ReplayBufferSize: cfg.WS.ReplayBufferSize,
+		},
+	}
 
-	webSocketHandler := handlers.NewWebSocketHandler(validate, webSocketManagerService, sessionService, roomService, roomMessageService)
+	webSocketManagerService := services.NewWebSocketManagerService(webSocketManagerOptions)
+	metrics.RegisterRooms(webSocketManagerService)
 
-	validate.RegisterValidation("unique", validators.Unique(userRepository))
-	validate.RegisterValidation("gender", validators.Gender)
-	validate.RegisterValidation("date", validators.Date)
-	validate.RegisterValidation("dob", validators.Dob)
-	validate.RegisterValidation("username", validators.Username)
-	validate.RegisterValidation("roomtitle", validators.RoomTitle)
+	mediaHandler := handlers.NewMediaHandler(roomService, webSocketManagerService)
+
+	webSocketHandler := handlers.NewWebSocketHandler(validate, webSocketManagerService, sessionService, apiTokenService, roomService, roomMessageService, cfg.Security.AllowedOrigins)
+	apiHandler := handlers.NewAPIHandler(
+		authService,
+		sessionService,
+		apiTokenService,
+		twoFactorService,
+		userService,
+		roomService,
+		roomMessageService,
+		webSocketManagerService,
+		cfg.Session,
+		cfg.OIDC.PasswordLogin,
+	)
+	healthHandler := handlers.NewHealthHandler(db, redisClient)
+
+	mediaService.OnReady(webSocketHandler.HandleMediaReady)
 
 	r := chi.NewRouter()
 
-	r.Use(middleware.Logger)
+	r.Use(middlewares.RequestID)
+	r.Use(middlewares.RequestLogger)
+	r.Use(middlewares.TraceRoute)
 	r.Use(middleware.Recoverer)
+	r.Use(middlewares.SecurityHeaders(cfg.Security))
+	r.Use(middlewares.SameOrigin(cfg.Security.AllowedOrigins))
 
 	r.Handle("/static/*", http.StripPrefix("/static/", http.FileServer(http.Dir("./static"))))
 
-	r.Get("/", handlers.HandleLanding)
-	r.With(interceptors.ValidateBody[dtos.SignUpDto](validate)).Post("/auth/sign-up", authHandler.SignUp)
-	r.With(interceptors.ValidateBody[dtos.SignInDto](validate)).Post("/auth/sign-in", authHandler.SignIn)
-	r.With(middlewares.AuthSession(sessionService)).Get("/user/me", userHandler.Me)
-	r.With(middlewares.AuthSession(sessionService)).Get("/rooms", roomHandler.HandleRoomsPage)
-	r.With(middlewares.AuthSession(sessionService)).Get("/rooms/{id}", roomHandler.HandleRoomPage)
-	r.With(middlewares.AuthSession(sessionService)).Get("/create-room", roomHandler.HandleCreateRoomPage)
-	r.With(middlewares.AuthSession(sessionService)).With(interceptors.ValidateBody[dtos.CreateRoomDto](validate)).Post("/create-room", roomHandler.Create)
+	// Buckets serve their own files
+	if files, ok := store.(http.Handler); ok {
+		r.Handle(cfg.Storage.LocalURL+"/*", http.StripPrefix(cfg.Storage.LocalURL, files))
+	}
+
+	// Participants' signed links open room media, membership is checked per request
+	r.Get(services.MediaBaseURL+"/rooms/{roomID}/{name}", mediaHandler.Serve)
+
+	// Pages and their forms ride on the session cookie, the API and socket check origins only
+	r.Group(func(r chi.Router) {
+		r.Use(middlewares.CSRF(cfg.Session))
+
+		r.Get("/", landingHandler.HandleLanding)
+
+		if cfg.OIDC.PasswordLogin {
+			r.With(interceptors.ValidateBody[dtos.SignUpDto](validate)).Post("/auth/sign-up", authHandler.SignUp)
+			r.With(interceptors.ValidateBody[dtos.SignInDto](validate)).Post("/auth/sign-in", authHandler.SignIn)
+		}
+
+		if cfg.OIDC.Enabled() {
+			r.Get("/auth/oidc/login", oidcHandler.Login)
+			r.Get("/auth/oidc/callback", oidcHandler.Callback)
+			r.Get("/auth/oidc/username", oidcHandler.HandlePickUsernamePage)
+			r.Post("/auth/oidc/username", oidcHandler.PickUsername)
+		}
+
+		// Sign ins of users with two-factor on end here until the code is in
+		r.Get("/auth/two-factor", twoFactorHandler.HandleVerifyPage)
+		r.Post("/auth/two-factor", twoFactorHandler.Verify)
+
+		auth := middlewares.AuthSession(sessionService, apiTokenService)
+
+		r.With(auth).Get("/user/me", userHandler.Me)
+		r.With(auth, middlewares.RequireScope(models.ScopeRoomsRead)).Get("/u/{username}", profileHandler.HandlePublicPage)
+		r.With(auth, middlewares.RequireScope(models.ScopeRoomsRead)).Get("/rooms", roomHandler.HandleRoomsPage)
+		r.With(auth, middlewares.RequireScope(models.ScopeRoomsRead)).Get("/rooms/{id}", roomHandler.HandleRoomPage)
+		r.With(auth, middlewares.RequireScope(models.ScopeRoomsWrite)).Get("/create-room", roomHandler.HandleCreateRoomPage)
+		r.With(auth, middlewares.RequireScope(models.ScopeRoomsWrite)).With(interceptors.ValidateBody[dtos.CreateRoomDto](validate)).Post("/create-room", roomHandler.Create)
+		r.With(auth, middlewares.RequireScope(models.ScopeRoomsWrite)).Post("/rooms/preview-poster", roomHandler.PreviewPoster)
+		r.With(auth, middlewares.RequireScope(models.ScopeRoomsWrite)).Post("/rooms/{id}/poster", roomHandler.UploadPoster)
+
+		r.Route("/library", func(r chi.Router) {
+			r.Use(auth, middlewares.RequireScope(models.ScopeRoomsWrite))
+
+			r.Get("/", libraryHandler.HandleLibraryPage)
+			r.Get("/items", libraryHandler.Items)
+			r.With(interceptors.ValidateBody[dtos.CreateLibraryItemDto](validate)).Post("/", libraryHandler.Create)
+			r.Delete("/{id}", libraryHandler.Delete)
+			r.Post("/{id}/transcode", libraryHandler.Transcode)
+			r.Get("/{id}/upload", libraryHandler.UploadStatus)
+			r.Patch("/{id}/upload", libraryHandler.Upload)
+		})
+
+		r.Route("/profile", func(r chi.Router) {
+			r.Use(auth, middlewares.RequirePasswordSession)
+
+			r.Get("/", profileHandler.HandleEditPage)
+			r.Post("/", profileHandler.Update)
+			r.Post("/avatar", profileHandler.UploadAvatar)
+		})
+
+		// Tokens can't mint or revoke tokens, nor change two-factor
+		r.Route("/settings", func(r chi.Router) {
+			r.Use(auth, middlewares.RequirePasswordSession)
+
+			r.Get("/", settingsHandler.HandleSettingsPage)
+			r.Post("/tokens", settingsHandler.CreateAPIToken)
+			r.Delete("/tokens/{id}", settingsHandler.RevokeAPIToken)
+			r.Post("/two-factor", settingsHandler.EnrollTwoFactor)
+			r.Post("/two-factor/confirm", settingsHandler.ConfirmTwoFactor)
+			r.Post("/two-factor/disable", settingsHandler.DisableTwoFactor)
+		})
+	})
+
 	r.Get("/ws", webSocketHandler.Handle)
 
-	if err := http.ListenAndServe(":8080", limit(r)); err != nil {
-		fmt.Printf("failed: %v\n", err)
-	} else {
-		fmt.Println("all good !")
+	r.Mount(openapi.BasePath, apiHandler.Routes(validate, middlewares.APIAuthSession(sessionService, apiTokenService)))
+	r.Get(openapi.SpecPath, handlers.OpenAPISpec)
+
+	// The websocket upgrade is traced by the handler, a span per connection would never end
+	rateLimit := middlewares.RateLimit(rate.Limit(cfg.Limits.HTTPRate), cfg.Limits.HTTPBurst)
+
+	// Probes are answered before the limiter, a busy load balancer IP must not fail them
+	root := http.NewServeMux()
+	root.HandleFunc("GET /healthz", healthHandler.Healthz)
+	root.HandleFunc("GET /readyz", healthHandler.Readyz)
+	root.Handle("/", rateLimit(r))
+
+	handler := otelhttp.NewHandler(root, "http",
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			switch r.URL.Path {
+			case "/ws", "/healthz", "/readyz":
+				return false
+			}
+			return true
+		}),
+	)
+
+	server := &http.Server{
+		Addr:    cfg.HTTP.Addr,
+		Handler: handler,
+	}
+
+	// Scrapers reach metrics on their own listener, the site never serves them
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", metrics.Handler())
+
+	metricsServer := &http.Server{
+		Addr:    cfg.HTTP.MetricsAddr,
+		Handler: metricsMux,
+	}
+
+	signalCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+
+	// Transcoding runs apart from requests, a job cut short goes back to the queue
+	mediaCtx, stopMedia := context.WithCancel(context.Background())
+	mediaDone := make(chan struct{})
+
+	go func() {
+		defer close(mediaDone)
+		mediaService.Run(mediaCtx)
+	}()
+
+	go func() {
+		slog.Info("server listening", "addr", server.Addr, "tls", cfg.HTTP.TLSEnabled(), "env", cfg.Env)
+
+		if cfg.HTTP.TLSEnabled() {
+			serverErr <- server.ListenAndServeTLS(cfg.HTTP.TLSCertFile, cfg.HTTP.TLSKeyFile)
+			return
+		}
+
+		serverErr <- server.ListenAndServe()
+	}()
+
+	if metricsServer.Addr != "" {
+		go func() {
+			slog.Info("metrics listening", "addr", metricsServer.Addr)
+			serverErr <- metricsServer.ListenAndServe()
+		}()
 	}
+
+	select {
+	case err := <-serverErr:
+		slog.Error("server failed", "err", err)
+		os.Exit(1)
+	case <-signalCtx.Done():
+	}
+
+	stop()
+	slog.Info("shutting down", "timeout", cfg.HTTP.ShutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	defer cancel()
+
+	healthHandler.SetDraining()
+
+	// Sockets are hijacked connections, server.Shutdown doesn't wait for them
+	if err := webSocketManagerService.Shutdown(shutdownCtx, cfg.HTTP.ReconnectAfter); err != nil {
+		slog.Warn("websocket shutdown incomplete", "err", err)
+	}
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("http shutdown incomplete", "err", err)
+	}
+
+	if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("metrics shutdown incomplete", "err", err)
+	}
+
+	stopMedia()
+
+	select {
+	case <-mediaDone:
+	case <-shutdownCtx.Done():
+		slog.Warn("media workers did not stop in time")
+	}
+
+	if sqlDB, err := db.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			slog.Warn("failed to close database", "err", err)
+		}
+	}
+
+	if err := redisClient.Close(); err != nil {
+		slog.Warn("failed to close redis", "err", err)
+	}
+
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Warn("failed to flush traces", "err", err)
+	}
+
+	slog.Info("shutdown complete")
 }
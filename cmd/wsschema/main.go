@@ -0,0 +1,24 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/dliluashvili/cowatchit/internal/protocol"
+)
+
+func main() {
+	out := flag.String("out", "static/src/protocol.schema.json", "where to write the schema")
+	flag.Parse()
+
+	data, err := json.MarshalIndent(protocol.Schema(), "", "    ")
+	if err != nil {
+		log.Fatal("Failed to build schema: ", err)
+	}
+
+	if err := os.WriteFile(*out, append(data, '\n'), 0o644); err != nil {
+		log.Fatal("Failed to write schema: ", err)
+	}
+}
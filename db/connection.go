@@ -2,61 +2,98 @@ package db
 
 import (
 	"fmt"
-	"log"
-	"os"
+	"net"
 	"strconv"
+	"strings"
 
-	"github.com/joho/godotenv"
+	"github.com/dliluashvili/cowatchit/internal/config"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
 )
 
-func New(envPath string) *gorm.DB {
-	err := godotenv.Load(envPath)
+// Name of the resolver holding the read replicas
+const ReplicaResolver = "replicas"
+
+// New connects to the primary and, when configured, registers the read
+// replicas. Every query runs on the primary unless it opts into the
+// replicas with dbresolver.Use(ReplicaResolver) and dbresolver.Read.
+func New(cfg config.PostgresConfig) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(dsn(cfg, cfg.Host, cfg.Port)), &gorm.Config{})
 
 	if err != nil {
-		log.Fatal("Error loading environment file", err)
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	port, err := strconv.Atoi(os.Getenv("POSTGRES_PORT"))
+	sqlDB, err := db.DB()
 
 	if err != nil {
-		fmt.Println("Invalid POSTGRES_PORT:", os.Getenv("POSTGRES_PORT"))
+		return nil, fmt.Errorf("failed to get database handle: %w", err)
 	}
 
-	var dsn string
-
-	env := os.Getenv("APP_ENV")
-
-	switch env {
-	case "prod", "dev":
-		dsn = fmt.Sprintf(
-			"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-			os.Getenv("POSTGRES_HOST"),
-			port,
-			os.Getenv("POSTGRES_USER"),
-			os.Getenv("POSTGRES_PASSWORD"),
-			os.Getenv("POSTGRES_DB"),
-		)
-	case "test":
-		dsn = fmt.Sprintf(
-			"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-			os.Getenv("POSTGRES_HOST"),
-			port,
-			os.Getenv("POSTGRES_USER"),
-			os.Getenv("POSTGRES_PASSWORD"),
-			os.Getenv("POSTGRES_TEST_DB"),
-		)
-	default:
-		log.Fatalf("unsupported environment: %s", env)
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	if len(cfg.ReplicaHosts) == 0 {
+		return db, nil
 	}
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	replicas := make([]gorm.Dialector, 0, len(cfg.ReplicaHosts))
 
-	if err != nil {
-		fmt.Println("Failed to connect to database:", err)
-		panic("failed to connect database")
+	for _, replica := range cfg.ReplicaHosts {
+		host, portStr, err := net.SplitHostPort(replica)
+		if err != nil {
+			return nil, fmt.Errorf("invalid replica %s: %w", replica, err)
+		}
+
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid replica port %s: %w", replica, err)
+		}
+
+		replicas = append(replicas, postgres.Open(dsn(cfg, host, port)))
 	}
 
-	return db
+	resolver := dbresolver.Register(dbresolver.Config{
+		Replicas: replicas,
+		Policy:   dbresolver.RandomPolicy{},
+	}, ReplicaResolver).
+		SetMaxOpenConns(cfg.MaxOpenConns).
+		SetMaxIdleConns(cfg.MaxIdleConns).
+		SetConnMaxLifetime(cfg.ConnMaxLifetime).
+		SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	if err := db.Use(resolver); err != nil {
+		return nil, fmt.Errorf("failed to register read replicas: %w", err)
+	}
+
+	return db, nil
+}
+
+func dsn(cfg config.PostgresConfig, host string, port int) string {
+	params := []string{
+		"host=" + quote(host),
+		"port=" + strconv.Itoa(port),
+		"user=" + quote(cfg.User),
+		"password=" + quote(cfg.Password),
+		"dbname=" + quote(cfg.DB),
+		"sslmode=" + quote(cfg.SSLMode),
+	}
+
+	// Unknown keys are sent to the server as runtime parameters
+	if cfg.StatementTimeout > 0 {
+		params = append(params, "statement_timeout="+strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10))
+	}
+
+	return strings.Join(params, " ")
+}
+
+// Quote a keyword/value connection string value so spaces and quotes survive
+func quote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+
+	return "'" + value + "'"
 }
@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+type APIToken struct {
+	ID         uuid.UUID      `gorm:"type:uuid;primaryKey"`
+	UserID     uuid.UUID      `gorm:"type:uuid;not null;index"`
+	User       User           `gorm:"foreignKey:UserID"`
+	Name       string         `gorm:"type:varchar(64);not null"`
+	Prefix     string         `gorm:"type:varchar(16);not null"`
+	Hash       string         `gorm:"type:varchar(64);not null;uniqueIndex"`
+	Scopes     pq.StringArray `gorm:"type:text[];not null"`
+	LastUsedAt *time.Time
+	ExpiresAt  *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+}
+
+func CreateAPITokenTable(db *gorm.DB) {
+	db.AutoMigrate(&APIToken{})
+}
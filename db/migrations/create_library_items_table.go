@@ -0,0 +1,33 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type LibraryItem struct {
+	ID              uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID          uuid.UUID `gorm:"type:uuid;not null;index"`
+	User            User      `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;foreignKey:UserID;references:ID"`
+	Title           string    `gorm:"type:varchar(100);not null"`
+	Filename        string    `gorm:"type:varchar(255);not null"`
+	ContentType     string    `gorm:"type:varchar(100);not null"`
+	Size            int64     `gorm:"not null"`
+	Received        int64     `gorm:"not null;default:0"`
+	Key             string    `gorm:"type:varchar(500)"`
+	Status          string    `gorm:"type:varchar(16);not null"`
+	DurationSeconds float64   `gorm:"not null;default:0"`
+	Width           int       `gorm:"not null;default:0"`
+	Height          int       `gorm:"not null;default:0"`
+	VideoCodec      string    `gorm:"type:varchar(32)"`
+	AudioCodec      string    `gorm:"type:varchar(32)"`
+	ManifestKey     string    `gorm:"type:varchar(500)"`
+	CreatedAt       time.Time `gorm:"autoCreateTime"`
+	UpdatedAt       time.Time `gorm:"autoUpdateTime"`
+}
+
+func CreateLibraryItemTable(dbconnection *gorm.DB) {
+	dbconnection.AutoMigrate(&LibraryItem{})
+}
@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type MediaJob struct {
+	ID            uuid.UUID   `gorm:"type:uuid;primaryKey"`
+	LibraryItemID uuid.UUID   `gorm:"type:uuid;not null;uniqueIndex"`
+	LibraryItem   LibraryItem `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;foreignKey:LibraryItemID;references:ID"`
+	// Workers claim the oldest claimable job
+	Status     string `gorm:"type:varchar(16);not null;index:idx_media_jobs_claim,priority:1"`
+	Stage      string `gorm:"type:varchar(16)"`
+	Progress   int    `gorm:"not null;default:0"`
+	Attempts   int    `gorm:"not null;default:0"`
+	Error      string `gorm:"type:text"`
+	LeaseUntil *time.Time
+	CreatedAt  time.Time `gorm:"autoCreateTime;index:idx_media_jobs_claim,priority:2"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime"`
+}
+
+func CreateMediaJobTable(dbconnection *gorm.DB) {
+	dbconnection.AutoMigrate(&MediaJob{})
+}
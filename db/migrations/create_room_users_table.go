@@ -9,9 +9,9 @@ import (
 
 type RoomUser struct {
 	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
-	RoomID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	RoomID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_room_users_room_user"`
 	Room      Room      `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;foreignKey:RoomID;references:ID"`
-	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_room_users_room_user;index"`
 	User      User      `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;foreignKey:UserID;references:ID"`
 	CreatedAt time.Time `gorm:"autoCreateTime"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime"`
@@ -16,12 +16,15 @@ type Room struct {
 	Capacity     int
 	Description  string `gorm:"type:varchar(800)"`
 	Src          string `gorm:"type:varchar(500);not null"`
-	Poster       string `gorm:"type:varchar(500)"`
-	Private      bool
-	Hidden       bool
-	Password     string    `gorm:"type:varchar(30)"`
-	CreatedAt    time.Time `gorm:"autoCreateTime"`
-	UpdatedAt    time.Time `gorm:"autoUpdateTime"`
+	// Set when the room plays a video from the host's library instead of Src
+	LibraryItemID *uuid.UUID   `gorm:"type:uuid;index"`
+	LibraryItem   *LibraryItem `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;foreignKey:LibraryItemID;references:ID"`
+	Poster        string       `gorm:"type:varchar(500)"`
+	Private       bool
+	Hidden        bool
+	Password      string    `gorm:"type:varchar(30)"`
+	CreatedAt     time.Time `gorm:"autoCreateTime"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime"`
 }
 
 func CreateRoomTable(dbconnection *gorm.DB) {
@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type UserIdentity struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	User      User      `gorm:"foreignKey:UserID"`
+	Issuer    string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_user_identities_issuer_subject"`
+	Subject   string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_user_identities_issuer_subject"`
+	Email     string    `gorm:"type:varchar(255)"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+func CreateUserIdentityTable(db *gorm.DB) {
+	db.AutoMigrate(&UserIdentity{})
+}
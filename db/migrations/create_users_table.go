@@ -8,16 +8,24 @@ import (
 )
 
 type User struct {
-	ID          uuid.UUID `gorm:"type:uuid;primaryKey"`
-	Username    string    `gorm:"type:varchar(255);uniqueIndex"`
-	Email       string    `gorm:"type:varchar(255);uniqueIndex"`
-	Password    string    `gorm:"type:varchar(255)"`
-	Gender      string    `gorm:"type:varchar(1);index"`
-	DateOfBirth time.Time
-	Age         uint8
-	DeletedAt   time.Time
-	CreatedAt   time.Time `gorm:"autoCreateTime"`
-	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+	ID            uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Username      string    `gorm:"type:varchar(255);uniqueIndex"`
+	Email         string    `gorm:"type:varchar(255);uniqueIndex"`
+	Password      string    `gorm:"type:varchar(255)"`
+	Gender        string    `gorm:"type:varchar(1);index"`
+	DateOfBirth   time.Time
+	Age           uint8
+	IsAdmin       bool   `gorm:"not null;default:false"`
+	TOTPSecret    string `gorm:"type:varchar(64)"`
+	TOTPEnabledAt *time.Time
+	DisplayName   string     `gorm:"type:varchar(50)"`
+	Bio           string     `gorm:"type:varchar(500)"`
+	Avatar        string     `gorm:"type:varchar(500)"`
+	CountryID     *uuid.UUID `gorm:"type:uuid;index"`
+	Country       *Country   `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;foreignKey:CountryID;references:ID"`
+	DeletedAt     time.Time
+	CreatedAt     time.Time `gorm:"autoCreateTime"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime"`
 }
 
 func CreateUserTable(dbconnection *gorm.DB) {
@@ -0,0 +1,343 @@
+// Package config loads the server's settings from defaults, an optional
+// env file, the environment and command line flags, in that order
+package config
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	EnvDev  = "dev"
+	EnvProd = "prod"
+	EnvTest = "test"
+)
+
+type Config struct {
+	Env      string
+	HTTP     HTTPConfig
+	Postgres PostgresConfig
+	Redis    RedisConfig
+	Session  SessionConfig
+	Security SecurityConfig
+	OIDC     OIDCConfig
+	Storage  StorageConfig
+	Library  LibraryConfig
+	Media    MediaConfig
+	Limits   LimitsConfig
+	WS       WebSocketConfig
+	Log      LogConfig
+	Tracing  TracingConfig
+}
+
+type HTTPConfig struct {
+	Addr string
+	// Serve HTTPS when both are set
+	TLSCertFile string
+	TLSKeyFile  string
+	// How long in-flight requests and sockets get to finish on SIGTERM
+	ShutdownTimeout time.Duration
+	// Reconnect hint sent to clients in SERVER_RESTARTING
+	ReconnectAfter time.Duration
+	// Where /metrics is served, apart from the site; empty turns it off
+	MetricsAddr string
+}
+
+func (c HTTPConfig) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+type PostgresConfig struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	DB       string
+	// disable, allow, prefer, require, verify-ca or verify-full
+	SSLMode         string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	// Queries running longer are cancelled by the server, zero disables it
+	StatementTimeout time.Duration
+	// Deadline of a single repository call, zero disables it
+	QueryTimeout time.Duration
+	// host:port of read replicas, they share the primary's credentials
+	ReplicaHosts []string
+}
+
+type RedisConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	DB       int
+	PoolSize int
+}
+
+type SessionConfig struct {
+	Duration     time.Duration
+	CookieName   string
+	CookieDomain string
+	CookieSecure bool
+	// lax, strict or none
+	CookieSameSite string
+	// Prefix cookie names with __Host-, browsers then only take them
+	// Secure, host-only and for the whole site, so a sibling subdomain
+	// can't plant one
+	CookieHostPrefix bool
+}
+
+// Cookie is the name a cookie is set and read under
+func (c SessionConfig) Cookie(name string) string {
+	if c.CookieHostPrefix {
+		return "__Host-" + name
+	}
+
+	return name
+}
+
+func (c SessionConfig) SessionCookieName() string {
+	return c.Cookie(c.CookieName)
+}
+
+// SameSite maps CookieSameSite onto net/http's constant
+func (c SessionConfig) SameSite() http.SameSite {
+	switch c.CookieSameSite {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+type SecurityConfig struct {
+	// Origins besides the server's own allowed to open websockets and send
+	// state-changing requests, as host patterns like app.example.com or
+	// *.example.com, or scheme://host to pin the scheme
+	AllowedOrigins []string
+	// Sent on every response, empty sends none
+	ContentSecurityPolicy string
+	// Strict-Transport-Security lifetime sent over HTTPS, zero sends none
+	HSTSMaxAge time.Duration
+}
+
+// Sources the pages load from: fonts, icons and the video player come
+// from CDNs, posters and videos from wherever rooms point, and the player
+// fetches HLS segments from storage
+const DefaultContentSecurityPolicy = "default-src 'self'; " +
+	"script-src 'self' https://unpkg.com https://vjs.zencdn.net; " +
+	"style-src 'self' 'unsafe-inline' https://fonts.googleapis.com https://vjs.zencdn.net; " +
+	"font-src 'self' data: https://fonts.gstatic.com; " +
+	"img-src 'self' data: https:; " +
+	"media-src 'self' blob: https:; " +
+	"connect-src 'self' https:; " +
+	"object-src 'none'; " +
+	"base-uri 'self'; " +
+	"form-action 'self'; " +
+	"frame-ancestors 'none'"
+
+type OIDCConfig struct {
+	// Single sign-on is off while unset
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// Where the provider sends users back, ends in /auth/oidc/callback
+	RedirectURL string
+	Scopes      []string
+	// Shown on the sign in button
+	ProviderName string
+	// Email domains allowed to sign in, any when empty
+	AllowedDomains []string
+	// Whether username and password sign in and sign up stay available
+	PasswordLogin bool
+}
+
+func (c OIDCConfig) Enabled() bool {
+	return c.Issuer != ""
+}
+
+const (
+	StorageLocal  = "local"
+	StorageS3     = "s3"
+	StorageMemory = "memory"
+)
+
+type StorageConfig struct {
+	// local, s3 or memory, the last loses everything on restart
+	Driver string
+	// Directory uploads are written to and the path local and memory
+	// objects are served under
+	LocalDir string
+	LocalURL string
+	// Signs URLs to local and memory objects, random per process when empty
+	SigningKey string
+	// Any S3-compatible service: AWS, Backblaze B2, MinIO...
+	S3Endpoint        string
+	S3Region          string
+	S3Bucket          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	S3UseSSL          bool
+	// Base URL objects are loaded from, defaults to the bucket on the endpoint
+	S3PublicURL string
+}
+
+type LibraryConfig struct {
+	// Where unfinished uploads are kept, every instance taking uploads
+	// must see the same directory
+	PartialDir string
+	// Bytes each user may keep, unfinished uploads included
+	QuotaMB int64
+	// Largest single video
+	MaxFileMB int64
+	// How long a participant's link to a room's library video works, their
+	// socket asks for a new one before it runs out
+	LinkExpiry time.Duration
+}
+
+type MediaConfig struct {
+	FFmpegPath  string
+	FFprobePath string
+	// Transcoding jobs this instance runs at once, 0 leaves them to others
+	Workers int
+	// Where jobs keep their files while they run, the system temp dir when empty
+	WorkDir string
+	// How often an idle worker looks for a queued job
+	PollInterval time.Duration
+	// Tries a failing job gets before it is given up
+	MaxAttempts int
+}
+
+type LimitsConfig struct {
+	// Requests per second per client IP
+	HTTPRate  float64
+	HTTPBurst int
+	// Open websockets per user
+	MaxSocketsPerUser int
+	// Largest avatar or poster accepted, before processing
+	MaxImageUploadKB int
+}
+
+type WebSocketConfig struct {
+	// How often the server pings each socket
+	PingInterval time.Duration
+	// How long a ping may wait for its pong
+	PongTimeout time.Duration
+	// How long a socket may go without a pong or a message
+	IdleTimeout time.Duration
+	// Outbound messages a socket may have waiting before SlowConsumer applies
+	QueueSize    int
+	WriteTimeout time.Duration
+	// drop, coalesce or disconnect
+	SlowConsumer string
+	// How long a dropped socket keeps its participant slot
+	ResumeWindow time.Duration
+	// Room events kept for replay on resume
+	ReplayBufferSize int
+}
+
+type LogConfig struct {
+	Level  slog.Level
+	Format string
+}
+
+type TracingConfig struct {
+	// none, otlp or stdout
+	Exporter    string
+	ServiceName string
+	SampleRatio float64
+}
+
+// Default is the configuration before any source is applied
+func Default() *Config {
+	return &Config{
+		Env: EnvDev,
+		HTTP: HTTPConfig{
+			Addr:            ":8080",
+			ShutdownTimeout: 30 * time.Second,
+			ReconnectAfter:  3 * time.Second,
+			MetricsAddr:     "127.0.0.1:9090",
+		},
+		Postgres: PostgresConfig{
+			Host:             "localhost",
+			Port:             5432,
+			User:             "postgres",
+			DB:               "cowatchit",
+			SSLMode:          "disable",
+			MaxOpenConns:     25,
+			MaxIdleConns:     10,
+			ConnMaxLifetime:  30 * time.Minute,
+			ConnMaxIdleTime:  5 * time.Minute,
+			StatementTimeout: 30 * time.Second,
+			QueryTimeout:     5 * time.Second,
+		},
+		Redis: RedisConfig{
+			Host:     "localhost",
+			Port:     6379,
+			PoolSize: 10,
+		},
+		Session: SessionConfig{
+			Duration:       24 * time.Hour,
+			CookieName:     "sessionId",
+			CookieSameSite: "lax",
+		},
+		Security: SecurityConfig{
+			ContentSecurityPolicy: DefaultContentSecurityPolicy,
+			HSTSMaxAge:            365 * 24 * time.Hour,
+		},
+		OIDC: OIDCConfig{
+			Scopes:        []string{"openid", "profile", "email"},
+			ProviderName:  "SSO",
+			PasswordLogin: true,
+		},
+		Storage: StorageConfig{
+			Driver:   StorageLocal,
+			LocalDir: "./uploads",
+			LocalURL: "/uploads",
+			S3UseSSL: true,
+		},
+		Library: LibraryConfig{
+			PartialDir: "./uploads-partial",
+			QuotaMB:    10 * 1024,
+			MaxFileMB:  4 * 1024,
+			LinkExpiry: 15 * time.Minute,
+		},
+		Media: MediaConfig{
+			FFmpegPath:   "ffmpeg",
+			FFprobePath:  "ffprobe",
+			Workers:      1,
+			PollInterval: 5 * time.Second,
+			MaxAttempts:  3,
+		},
+		Limits: LimitsConfig{
+			HTTPRate:          5,
+			HTTPBurst:         20,
+			MaxSocketsPerUser: 5,
+			MaxImageUploadKB:  5 * 1024,
+		},
+		WS: WebSocketConfig{
+			PingInterval:     20 * time.Second,
+			PongTimeout:      10 * time.Second,
+			IdleTimeout:      60 * time.Second,
+			QueueSize:        64,
+			WriteTimeout:     10 * time.Second,
+			SlowConsumer:     "coalesce",
+			ResumeWindow:     30 * time.Second,
+			ReplayBufferSize: 256,
+		},
+		Log: LogConfig{
+			Level:  slog.LevelInfo,
+			Format: "text",
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			ServiceName: "cowatchit",
+			SampleRatio: 1,
+		},
+	}
+}
@@ -0,0 +1,306 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Env file read when neither -config nor CONFIG_FILE is given, it may be missing
+const DefaultFile = ".env.dev"
+
+// Load builds the configuration from defaults, the env file, the
+// environment and args (usually os.Args[1:]), then validates it.
+// Every problem is reported, not just the first.
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+
+	flags := flag.NewFlagSet("cowatchit", flag.ContinueOnError)
+	file := flags.String("config", "", "env file to load (default $CONFIG_FILE or "+DefaultFile+")")
+	addr := flags.String("addr", "", "listen address, overrides HTTP_ADDR")
+	logLevel := flags.String("log-level", "", "debug, info, warn or error, overrides LOG_LEVEL")
+	logFormat := flags.String("log-format", "", "text or json, overrides LOG_FORMAT")
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	path, required := *file, true
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path == "" {
+		path, required = DefaultFile, false
+	}
+
+	fileValues, err := godotenv.Read(path)
+	if err != nil {
+		if required || !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("error reading config file %s: %w", path, err)
+		}
+		fileValues = map[string]string{}
+	}
+
+	l := &loader{file: fileValues}
+
+	l.string("APP_ENV", &cfg.Env)
+
+	// Prod logs JSON unless told otherwise
+	if cfg.Env == EnvProd {
+		cfg.Log.Format = "json"
+	}
+
+	l.string("HTTP_ADDR", &cfg.HTTP.Addr)
+	l.string("TLS_CERT_FILE", &cfg.HTTP.TLSCertFile)
+	l.string("TLS_KEY_FILE", &cfg.HTTP.TLSKeyFile)
+	l.duration("SHUTDOWN_TIMEOUT", &cfg.HTTP.ShutdownTimeout)
+	l.duration("RECONNECT_AFTER", &cfg.HTTP.ReconnectAfter)
+	l.string("METRICS_ADDR", &cfg.HTTP.MetricsAddr)
+
+	l.string("POSTGRES_HOST", &cfg.Postgres.Host)
+	l.int("POSTGRES_PORT", &cfg.Postgres.Port)
+	l.string("POSTGRES_USER", &cfg.Postgres.User)
+	l.string("POSTGRES_PASSWORD", &cfg.Postgres.Password)
+	if cfg.Env == EnvTest {
+		l.string("POSTGRES_TEST_DB", &cfg.Postgres.DB)
+	} else {
+		l.string("POSTGRES_DB", &cfg.Postgres.DB)
+	}
+	l.string("POSTGRES_SSL_MODE", &cfg.Postgres.SSLMode)
+	l.int("POSTGRES_MAX_OPEN_CONNS", &cfg.Postgres.MaxOpenConns)
+	l.int("POSTGRES_MAX_IDLE_CONNS", &cfg.Postgres.MaxIdleConns)
+	l.duration("POSTGRES_CONN_MAX_LIFETIME", &cfg.Postgres.ConnMaxLifetime)
+	l.duration("POSTGRES_CONN_MAX_IDLE_TIME", &cfg.Postgres.ConnMaxIdleTime)
+	l.duration("POSTGRES_STATEMENT_TIMEOUT", &cfg.Postgres.StatementTimeout)
+	l.duration("POSTGRES_QUERY_TIMEOUT", &cfg.Postgres.QueryTimeout)
+	l.list("POSTGRES_REPLICA_HOSTS", &cfg.Postgres.ReplicaHosts)
+
+	l.string("REDIS_HOST", &cfg.Redis.Host)
+	l.int("REDIS_PORT", &cfg.Redis.Port)
+	l.string("REDIS_USERNAME", &cfg.Redis.Username)
+	l.string("REDIS_PASSWORD", &cfg.Redis.Password)
+	l.int("REDIS_DB", &cfg.Redis.DB)
+	l.int("REDIS_POOL_SIZE", &cfg.Redis.PoolSize)
+
+	l.duration("SESSION_DURATION", &cfg.Session.Duration)
+	l.string("SESSION_COOKIE_NAME", &cfg.Session.CookieName)
+	l.string("SESSION_COOKIE_DOMAIN", &cfg.Session.CookieDomain)
+	cfg.Session.CookieSecure = cfg.Env == EnvProd || cfg.HTTP.TLSEnabled()
+	l.bool("SESSION_COOKIE_SECURE", &cfg.Session.CookieSecure)
+	l.string("SESSION_COOKIE_SAMESITE", &cfg.Session.CookieSameSite)
+	cfg.Session.CookieHostPrefix = cfg.HTTP.TLSEnabled() && cfg.Session.CookieDomain == ""
+	l.bool("SESSION_COOKIE_HOST_PREFIX", &cfg.Session.CookieHostPrefix)
+
+	l.list("ALLOWED_ORIGINS", &cfg.Security.AllowedOrigins)
+	l.string("CONTENT_SECURITY_POLICY", &cfg.Security.ContentSecurityPolicy)
+	l.duration("HSTS_MAX_AGE", &cfg.Security.HSTSMaxAge)
+
+	l.string("OIDC_ISSUER", &cfg.OIDC.Issuer)
+	l.string("OIDC_CLIENT_ID", &cfg.OIDC.ClientID)
+	l.string("OIDC_CLIENT_SECRET", &cfg.OIDC.ClientSecret)
+	l.string("OIDC_REDIRECT_URL", &cfg.OIDC.RedirectURL)
+	l.list("OIDC_SCOPES", &cfg.OIDC.Scopes)
+	l.string("OIDC_PROVIDER_NAME", &cfg.OIDC.ProviderName)
+	l.list("OIDC_ALLOWED_DOMAINS", &cfg.OIDC.AllowedDomains)
+	l.bool("PASSWORD_LOGIN", &cfg.OIDC.PasswordLogin)
+
+	l.string("STORAGE_DRIVER", &cfg.Storage.Driver)
+	l.string("STORAGE_LOCAL_DIR", &cfg.Storage.LocalDir)
+	l.string("STORAGE_LOCAL_URL", &cfg.Storage.LocalURL)
+	l.string("STORAGE_SIGNING_KEY", &cfg.Storage.SigningKey)
+	l.string("S3_ENDPOINT", &cfg.Storage.S3Endpoint)
+	l.string("S3_REGION", &cfg.Storage.S3Region)
+	l.string("S3_BUCKET", &cfg.Storage.S3Bucket)
+	l.string("S3_ACCESS_KEY_ID", &cfg.Storage.S3AccessKeyID)
+	l.string("S3_SECRET_ACCESS_KEY", &cfg.Storage.S3SecretAccessKey)
+	l.bool("S3_USE_SSL", &cfg.Storage.S3UseSSL)
+	l.string("S3_PUBLIC_URL", &cfg.Storage.S3PublicURL)
+
+	l.string("LIBRARY_PARTIAL_DIR", &cfg.Library.PartialDir)
+	l.int64("LIBRARY_QUOTA_MB", &cfg.Library.QuotaMB)
+	l.int64("LIBRARY_MAX_FILE_MB", &cfg.Library.MaxFileMB)
+	l.duration("LIBRARY_LINK_EXPIRY", &cfg.Library.LinkExpiry)
+
+	l.string("MEDIA_FFMPEG_PATH", &cfg.Media.FFmpegPath)
+	l.string("MEDIA_FFPROBE_PATH", &cfg.Media.FFprobePath)
+	l.int("MEDIA_WORKERS", &cfg.Media.Workers)
+	l.string("MEDIA_WORK_DIR", &cfg.Media.WorkDir)
+	l.duration("MEDIA_POLL_INTERVAL", &cfg.Media.PollInterval)
+	l.int("MEDIA_MAX_ATTEMPTS", &cfg.Media.MaxAttempts)
+
+	l.float("HTTP_RATE_LIMIT", &cfg.Limits.HTTPRate)
+	l.int("HTTP_RATE_BURST", &cfg.Limits.HTTPBurst)
+	l.int("MAX_SOCKETS_PER_USER", &cfg.Limits.MaxSocketsPerUser)
+	l.int("MAX_IMAGE_UPLOAD_KB", &cfg.Limits.MaxImageUploadKB)
+
+	l.duration("WS_PING_INTERVAL", &cfg.WS.PingInterval)
+	l.duration("WS_PONG_TIMEOUT", &cfg.WS.PongTimeout)
+	l.duration("WS_IDLE_TIMEOUT", &cfg.WS.IdleTimeout)
+	l.int("WS_QUEUE_SIZE", &cfg.WS.QueueSize)
+	l.duration("WS_WRITE_TIMEOUT", &cfg.WS.WriteTimeout)
+	l.string("WS_SLOW_CONSUMER", &cfg.WS.SlowConsumer)
+	l.duration("WS_RESUME_WINDOW", &cfg.WS.ResumeWindow)
+	l.int("WS_REPLAY_BUFFER", &cfg.WS.ReplayBufferSize)
+
+	l.level("LOG_LEVEL", &cfg.Log.Level)
+	l.string("LOG_FORMAT", &cfg.Log.Format)
+
+	l.string("OTEL_TRACES_EXPORTER", &cfg.Tracing.Exporter)
+	l.string("OTEL_SERVICE_NAME", &cfg.Tracing.ServiceName)
+	l.float("OTEL_TRACES_SAMPLER_ARG", &cfg.Tracing.SampleRatio)
+
+	// Flags win over everything else
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			cfg.HTTP.Addr = *addr
+		case "log-level":
+			l.parseLevel("-log-level", *logLevel, &cfg.Log.Level)
+		case "log-format":
+			cfg.Log.Format = *logFormat
+		}
+	})
+
+	if err := errors.Join(append(l.errs, cfg.Validate())...); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	return cfg, nil
+}
+
+// loader reads keys from the environment first, then the env file,
+// collecting parse errors instead of stopping at the first one
+type loader struct {
+	file map[string]string
+	errs []error
+}
+
+func (l *loader) lookup(key string) (string, bool) {
+	if value, ok := os.LookupEnv(key); ok {
+		return value, true
+	}
+
+	value, ok := l.file[key]
+	return value, ok
+}
+
+func (l *loader) fail(key, value, expected string) {
+	l.errs = append(l.errs, fmt.Errorf("%s: %q is not %s", key, value, expected))
+}
+
+func (l *loader) string(key string, dst *string) {
+	if value, ok := l.lookup(key); ok {
+		*dst = value
+	}
+}
+
+// Comma separated, blanks dropped
+func (l *loader) list(key string, dst *[]string) {
+	value, ok := l.lookup(key)
+	if !ok {
+		return
+	}
+
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	*dst = items
+}
+
+func (l *loader) int(key string, dst *int) {
+	value, ok := l.lookup(key)
+	if !ok {
+		return
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		l.fail(key, value, "an integer")
+		return
+	}
+
+	*dst = n
+}
+
+func (l *loader) int64(key string, dst *int64) {
+	value, ok := l.lookup(key)
+	if !ok {
+		return
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		l.fail(key, value, "an integer")
+		return
+	}
+
+	*dst = n
+}
+
+func (l *loader) float(key string, dst *float64) {
+	value, ok := l.lookup(key)
+	if !ok {
+		return
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		l.fail(key, value, "a number")
+		return
+	}
+
+	*dst = n
+}
+
+func (l *loader) bool(key string, dst *bool) {
+	value, ok := l.lookup(key)
+	if !ok {
+		return
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		l.fail(key, value, "a boolean")
+		return
+	}
+
+	*dst = b
+}
+
+func (l *loader) duration(key string, dst *time.Duration) {
+	value, ok := l.lookup(key)
+	if !ok {
+		return
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		l.fail(key, value, "a duration like 30s or 24h")
+		return
+	}
+
+	*dst = d
+}
+
+func (l *loader) level(key string, dst *slog.Level) {
+	if value, ok := l.lookup(key); ok {
+		l.parseLevel(key, value, dst)
+	}
+}
+
+func (l *loader) parseLevel(key, value string, dst *slog.Level) {
+	if err := dst.UnmarshalText([]byte(value)); err != nil {
+		l.fail(key, value, "one of debug, info, warn, error")
+	}
+}
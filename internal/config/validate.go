@@ -0,0 +1,146 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	envs          = []string{EnvDev, EnvProd, EnvTest}
+	sslModes      = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+	sameSiteModes = []string{"lax", "strict", "none"}
+	logFormats    = []string{"text", "json"}
+	exporters     = []string{"none", "otlp", "stdout"}
+	storages      = []string{StorageLocal, StorageS3, StorageMemory}
+	slowConsumers = []string{"drop", "coalesce", "disconnect"}
+)
+
+// Validate checks values that parsed but make no sense together
+func (c *Config) Validate() error {
+	var errs []error
+
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(slices.Contains(envs, c.Env), "APP_ENV: %q must be one of %v", c.Env, envs)
+
+	check(c.HTTP.Addr != "", "HTTP_ADDR: must not be empty")
+	check((c.HTTP.TLSCertFile == "") == (c.HTTP.TLSKeyFile == ""), "TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	check(c.HTTP.ShutdownTimeout > 0, "SHUTDOWN_TIMEOUT: must be positive")
+	check(c.HTTP.ReconnectAfter >= 0, "RECONNECT_AFTER: must not be negative")
+	check(c.HTTP.MetricsAddr != c.HTTP.Addr, "METRICS_ADDR: must differ from HTTP_ADDR")
+
+	check(c.Postgres.Host != "", "POSTGRES_HOST: must not be empty")
+	check(validPort(c.Postgres.Port), "POSTGRES_PORT: %d is not a valid port", c.Postgres.Port)
+	check(c.Postgres.User != "", "POSTGRES_USER: must not be empty")
+	check(c.Postgres.DB != "", "POSTGRES_DB: must not be empty")
+	check(slices.Contains(sslModes, c.Postgres.SSLMode), "POSTGRES_SSL_MODE: %q must be one of %v", c.Postgres.SSLMode, sslModes)
+	check(c.Postgres.MaxOpenConns > 0, "POSTGRES_MAX_OPEN_CONNS: must be positive")
+	check(c.Postgres.StatementTimeout >= 0, "POSTGRES_STATEMENT_TIMEOUT: must not be negative")
+	check(c.Postgres.QueryTimeout >= 0, "POSTGRES_QUERY_TIMEOUT: must not be negative")
+	for _, replica := range c.Postgres.ReplicaHosts {
+		_, port, err := net.SplitHostPort(replica)
+		n, _ := strconv.Atoi(port)
+		check(err == nil && validPort(n), "POSTGRES_REPLICA_HOSTS: %q is not host:port", replica)
+	}
+	check(c.Postgres.MaxIdleConns >= 0 && c.Postgres.MaxIdleConns <= c.Postgres.MaxOpenConns,
+		"POSTGRES_MAX_IDLE_CONNS: must be between 0 and POSTGRES_MAX_OPEN_CONNS (%d)", c.Postgres.MaxOpenConns)
+
+	check(c.Redis.Host != "", "REDIS_HOST: must not be empty")
+	check(validPort(c.Redis.Port), "REDIS_PORT: %d is not a valid port", c.Redis.Port)
+	check(c.Redis.DB >= 0, "REDIS_DB: must not be negative")
+	check(c.Redis.PoolSize > 0, "REDIS_POOL_SIZE: must be positive")
+
+	check(c.Session.Duration > 0, "SESSION_DURATION: must be positive")
+	check(c.Session.CookieName != "", "SESSION_COOKIE_NAME: must not be empty")
+	check(slices.Contains(sameSiteModes, c.Session.CookieSameSite),
+		"SESSION_COOKIE_SAMESITE: %q must be one of %v", c.Session.CookieSameSite, sameSiteModes)
+	check(c.Session.CookieSameSite != "none" || c.Session.CookieSecure,
+		"SESSION_COOKIE_SAMESITE: none requires SESSION_COOKIE_SECURE=true")
+	check(c.Session.CookieSecure || !c.HTTP.TLSEnabled(), "SESSION_COOKIE_SECURE: must be true when TLS is on")
+	check(!c.Session.CookieHostPrefix || (c.Session.CookieSecure && c.Session.CookieDomain == ""),
+		"SESSION_COOKIE_HOST_PREFIX: requires SESSION_COOKIE_SECURE=true and no SESSION_COOKIE_DOMAIN")
+
+	for _, origin := range c.Security.AllowedOrigins {
+		_, err := path.Match(origin, "")
+		check(err == nil && origin != "*", "ALLOWED_ORIGINS: %q is not a host pattern, * would allow any site", origin)
+	}
+	check(c.Security.HSTSMaxAge >= 0, "HSTS_MAX_AGE: must not be negative")
+
+	if c.OIDC.Enabled() {
+		issuer, err := url.Parse(c.OIDC.Issuer)
+		check(err == nil && issuer.Scheme != "" && issuer.Host != "", "OIDC_ISSUER: %q is not a URL", c.OIDC.Issuer)
+		check(c.OIDC.ClientID != "", "OIDC_CLIENT_ID: required with OIDC_ISSUER")
+		redirect, err := url.Parse(c.OIDC.RedirectURL)
+		check(err == nil && redirect.Scheme != "" && redirect.Host != "", "OIDC_REDIRECT_URL: required with OIDC_ISSUER, %q is not a URL", c.OIDC.RedirectURL)
+		check(slices.Contains(c.OIDC.Scopes, "openid"), "OIDC_SCOPES: must include openid")
+	}
+	check(c.OIDC.PasswordLogin || c.OIDC.Enabled(), "PASSWORD_LOGIN: false requires OIDC_ISSUER, nobody could sign in")
+
+	check(slices.Contains(storages, c.Storage.Driver), "STORAGE_DRIVER: %q must be one of %v", c.Storage.Driver, storages)
+	switch c.Storage.Driver {
+	case StorageLocal, StorageMemory:
+		check(c.Storage.Driver != StorageLocal || c.Storage.LocalDir != "", "STORAGE_LOCAL_DIR: must not be empty")
+		check(strings.HasPrefix(c.Storage.LocalURL, "/") && c.Storage.LocalURL != "/",
+			"STORAGE_LOCAL_URL: %q must be a path like /uploads", c.Storage.LocalURL)
+		check(c.Storage.SigningKey == "" || len(c.Storage.SigningKey) >= 32, "STORAGE_SIGNING_KEY: must be at least 32 characters")
+	case StorageS3:
+		check(c.Storage.S3Endpoint != "" && !strings.Contains(c.Storage.S3Endpoint, "://"),
+			"S3_ENDPOINT: %q must be a host like s3.us-west-004.backblazeb2.com", c.Storage.S3Endpoint)
+		check(c.Storage.S3Bucket != "", "S3_BUCKET: required with STORAGE_DRIVER=s3")
+		check(c.Storage.S3AccessKeyID != "" && c.Storage.S3SecretAccessKey != "",
+			"S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY: required with STORAGE_DRIVER=s3")
+		if c.Storage.S3PublicURL != "" {
+			public, err := url.Parse(c.Storage.S3PublicURL)
+			check(err == nil && public.Scheme != "" && public.Host != "", "S3_PUBLIC_URL: %q is not a URL", c.Storage.S3PublicURL)
+		}
+	}
+
+	check(c.Library.PartialDir != "", "LIBRARY_PARTIAL_DIR: must not be empty")
+	check(c.Library.QuotaMB > 0, "LIBRARY_QUOTA_MB: must be positive")
+	check(c.Library.MaxFileMB > 0, "LIBRARY_MAX_FILE_MB: must be positive")
+	check(c.Library.LinkExpiry >= time.Minute, "LIBRARY_LINK_EXPIRY: must be at least 1m")
+
+	check(c.Media.Workers >= 0, "MEDIA_WORKERS: must not be negative")
+	if c.Media.Workers > 0 {
+		check(c.Media.FFmpegPath != "", "MEDIA_FFMPEG_PATH: must not be empty")
+		check(c.Media.FFprobePath != "", "MEDIA_FFPROBE_PATH: must not be empty")
+	}
+	check(c.Media.PollInterval > 0, "MEDIA_POLL_INTERVAL: must be positive")
+	check(c.Media.MaxAttempts > 0, "MEDIA_MAX_ATTEMPTS: must be positive")
+
+	check(c.Limits.HTTPRate > 0, "HTTP_RATE_LIMIT: must be positive")
+	check(c.Limits.HTTPBurst > 0, "HTTP_RATE_BURST: must be positive")
+	check(c.Limits.MaxSocketsPerUser > 0, "MAX_SOCKETS_PER_USER: must be positive")
+	check(c.Limits.MaxImageUploadKB > 0, "MAX_IMAGE_UPLOAD_KB: must be positive")
+
+	check(c.WS.PingInterval > 0, "WS_PING_INTERVAL: must be positive")
+	check(c.WS.PongTimeout > 0 && c.WS.PongTimeout < c.WS.PingInterval, "WS_PONG_TIMEOUT: must be positive and below WS_PING_INTERVAL")
+	check(c.WS.IdleTimeout > c.WS.PingInterval, "WS_IDLE_TIMEOUT: must be above WS_PING_INTERVAL")
+	check(c.WS.QueueSize > 0, "WS_QUEUE_SIZE: must be positive")
+	check(c.WS.WriteTimeout > 0, "WS_WRITE_TIMEOUT: must be positive")
+	check(slices.Contains(slowConsumers, c.WS.SlowConsumer), "WS_SLOW_CONSUMER: %q must be one of %v", c.WS.SlowConsumer, slowConsumers)
+	check(c.WS.ResumeWindow > 0, "WS_RESUME_WINDOW: must be positive")
+	check(c.WS.ReplayBufferSize > 0, "WS_REPLAY_BUFFER: must be positive")
+
+	check(slices.Contains(logFormats, c.Log.Format), "LOG_FORMAT: %q must be one of %v", c.Log.Format, logFormats)
+
+	check(slices.Contains(exporters, c.Tracing.Exporter), "OTEL_TRACES_EXPORTER: %q must be one of %v", c.Tracing.Exporter, exporters)
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "OTEL_TRACES_SAMPLER_ARG: must be between 0 and 1")
+
+	return errors.Join(errs...)
+}
+
+func validPort(port int) bool {
+	return port > 0 && port <= 65535
+}
@@ -0,0 +1,140 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/google/uuid"
+)
+
+// Shapes returned by /api/v1, models are never encoded directly so
+// password hashes and room passwords can't leak
+
+type RoomResponse struct {
+	ID           uuid.UUID `json:"id"`
+	HostID       uuid.UUID `json:"host_id"`
+	HostUsername string    `json:"host_username"`
+	Title        string    `json:"title"`
+	Description  string    `json:"description"`
+	Capacity     int       `json:"capacity"`
+	// Empty on private rooms the viewer can't manage, they get it by joining
+	Src string `json:"src"`
+	// Set instead of Src when the room plays a video from the host's library
+	LibraryItemID *uuid.UUID `json:"library_item_id,omitempty"`
+	Poster        string     `json:"poster"`
+	Private       bool       `json:"private"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// NewRoomResponse is room as viewer sees it, only the host and admins see
+// what a private room plays
+func NewRoomResponse(room *models.Room, viewer *models.User) *RoomResponse {
+	response := &RoomResponse{
+		ID:            room.ID,
+		HostID:        room.HostID,
+		HostUsername:  room.HostUsername,
+		Title:         room.Title,
+		Description:   room.Description,
+		Capacity:      room.Capacity,
+		Src:           room.Src,
+		LibraryItemID: room.LibraryItemID,
+		Poster:        room.Poster,
+		Private:       room.Private,
+		CreatedAt:     room.CreatedAt,
+		UpdatedAt:     room.UpdatedAt,
+	}
+
+	if room.Private && !viewer.IsAdmin && viewer.ID != room.HostID {
+		response.Src = ""
+		response.LibraryItemID = nil
+	}
+
+	return response
+}
+
+// Public view of a user
+type UserResponse struct {
+	ID       uuid.UUID `json:"id"`
+	Username string    `json:"username"`
+	Gender   string    `json:"gender"`
+	// Unset on the user embedded in a session
+	CreatedAt time.Time `json:"created_at,omitzero"`
+}
+
+func NewUserResponse(user *models.User) *UserResponse {
+	return &UserResponse{
+		ID:        user.ID,
+		Username:  user.Username,
+		Gender:    user.Gender,
+		CreatedAt: user.CreatedAt,
+	}
+}
+
+// The authenticated user's own view
+type MeResponse struct {
+	ID               uuid.UUID `json:"id"`
+	Username         string    `json:"username"`
+	Email            string    `json:"email"`
+	Gender           string    `json:"gender"`
+	Age              uint8     `json:"age"`
+	DateOfBirth      time.Time `json:"date_of_birth"`
+	IsAdmin          bool      `json:"is_admin"`
+	CreatedAt        time.Time `json:"created_at"`
+	TwoFactorEnabled bool      `json:"two_factor_enabled"`
+}
+
+func NewMeResponse(user *models.User) *MeResponse {
+	return &MeResponse{
+		ID:               user.ID,
+		Username:         user.Username,
+		Email:            user.Email,
+		Gender:           user.Gender,
+		Age:              user.Age,
+		DateOfBirth:      user.DateOfBirth,
+		IsAdmin:          user.IsAdmin,
+		CreatedAt:        user.CreatedAt,
+		TwoFactorEnabled: user.TwoFactorEnabled(),
+	}
+}
+
+type RoomMessageResponse struct {
+	ID             uuid.UUID `json:"id"`
+	RoomID         uuid.UUID `json:"room_id"`
+	SenderID       uuid.UUID `json:"sender_id"`
+	SenderUsername string    `json:"sender_username"`
+	IsHost         bool      `json:"is_host"`
+	Content        string    `json:"content"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func NewRoomMessageResponse(message *models.RoomMessage) *RoomMessageResponse {
+	return &RoomMessageResponse{
+		ID:             message.ID,
+		RoomID:         message.RoomID,
+		SenderID:       message.SenderID,
+		SenderUsername: message.SenderUsername,
+		IsHost:         message.IsHost,
+		Content:        message.Content,
+		CreatedAt:      message.CreatedAt,
+	}
+}
+
+// Returned on sign in, Token can be sent as "Authorization: Bearer <token>"
+// by clients that don't keep cookies
+type SessionResponse struct {
+	Token     string        `json:"token"`
+	ExpiresAt time.Time     `json:"expires_at"`
+	User      *UserResponse `json:"user"`
+	// The token is only good for VerifyTwoFactor until the code is in
+	TwoFactorRequired bool `json:"two_factor_required"`
+}
+
+func NewSessionResponse(session *models.Session) *SessionResponse {
+	return &SessionResponse{
+		Token:             session.SessionID,
+		ExpiresAt:         session.ExpiresAt,
+		User:              NewUserResponse(session.User),
+		TwoFactorRequired: session.PendingTwoFactor,
+	}
+}
@@ -0,0 +1,23 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type CreateAPITokenDto struct {
+	Name   string   `json:"name" validate:"required,max=64"`
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,oneof=rooms:read rooms:write chat:read chat:write"`
+	// Zero keeps the token until it is revoked
+	ExpiresInDays int `json:"expires_in_days" validate:"omitempty,oneof=7 30 90 365"`
+}
+
+type CreateAPITokenRepoDto struct {
+	UserID    uuid.UUID
+	Name      string
+	Prefix    string
+	Hash      string
+	Scopes    []string
+	ExpiresAt *time.Time
+}
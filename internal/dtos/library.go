@@ -0,0 +1,25 @@
+package dtos
+
+import "github.com/google/uuid"
+
+type CreateLibraryItemDto struct {
+	Title       string `json:"title" validate:"required,max=100"`
+	Filename    string `json:"filename" validate:"required,max=255"`
+	ContentType string `json:"content_type" validate:"required,startswith=video/,max=100"`
+	Size        int64  `json:"size" validate:"required,min=1"`
+}
+
+type CreateLibraryItemRepoDto struct {
+	UserID uuid.UUID
+	// Bytes the user may keep, this item included
+	Quota int64
+	*CreateLibraryItemDto
+}
+
+type LibraryItemProbeDto struct {
+	DurationSeconds float64
+	Width           int
+	Height          int
+	VideoCodec      string
+	AudioCodec      string
+}
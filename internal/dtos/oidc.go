@@ -0,0 +1,15 @@
+package dtos
+
+import "github.com/google/uuid"
+
+// Username chosen by a user signing in through single sign-on for the first time
+type PickUsernameDto struct {
+	Username string `json:"username" validate:"required,username,unique"`
+}
+
+type CreateUserIdentityDto struct {
+	UserID  uuid.UUID
+	Issuer  string
+	Subject string
+	Email   string
+}
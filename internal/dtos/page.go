@@ -0,0 +1,7 @@
+package dtos
+
+// Window of a list query, a zero Limit returns everything
+type PageDto struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
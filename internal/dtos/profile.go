@@ -0,0 +1,9 @@
+package dtos
+
+type UpdateProfileDto struct {
+	Username    string `json:"username" validate:"required,username"`
+	DisplayName string `json:"display_name" validate:"max=50"`
+	Bio         string `json:"bio" validate:"max=500"`
+	Gender      string `json:"gender" validate:"omitempty,gender"`
+	CountryID   string `json:"country_id" validate:"omitempty,uuid"`
+}
@@ -6,9 +6,11 @@ type CreateRoomDto struct {
 	Title       string `json:"title" validate:"required,roomtitle"`
 	Capacity    int    `json:"capacity" validate:"required,min=2,max=10"`
 	Description string `json:"description" validate:"required"`
-	Src         string `json:"src" validate:"required,url,max=500"`
+	Src         string `json:"src" validate:"excluded_with=LibraryItemID,omitempty,url,max=500"`
 	Private     bool   `json:"private"`
 	Password    string `json:"password" validate:"required_if=Private true,omitempty,min=3,max=20"`
+	// A ready video from the host's library, used instead of Src
+	LibraryItemID string `json:"library_item_id" validate:"omitempty,uuid"`
 }
 
 type CreateRoomServiceDto struct {
@@ -17,7 +19,8 @@ type CreateRoomServiceDto struct {
 }
 
 type CreateRoomRepoDto struct {
-	HostUsername string `json:"host_username"`
+	HostUsername  string     `json:"host_username"`
+	LibraryItemID *uuid.UUID `json:"library_item_id"`
 	*CreateRoomServiceDto
 }
 
@@ -27,4 +30,16 @@ type FindRoomDto struct {
 	ID         *uuid.UUID `json:"id"`
 	My         *bool      `json:"my"`
 	AuthUserID *uuid.UUID `json:"auth_user_id"`
+	HostID     *uuid.UUID `json:"host_id"`
+	Page       PageDto    `json:"page"`
+}
+
+// Fields left nil are not changed
+type UpdateRoomDto struct {
+	Title       *string `json:"title" validate:"omitempty,roomtitle"`
+	Capacity    *int    `json:"capacity" validate:"omitempty,min=2,max=10"`
+	Description *string `json:"description" validate:"omitempty,min=1"`
+	Src         *string `json:"src" validate:"omitempty,url,max=500"`
+	Private     *bool   `json:"private"`
+	Password    *string `json:"password" validate:"omitempty,min=3,max=20"`
 }
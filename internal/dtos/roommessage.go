@@ -9,3 +9,7 @@ type CreateRoomMessageDto struct {
 	SenderUsername string    `json:"sender_username"`
 	IsHost         bool      `json:"is_host"`
 }
+
+type CreateRoomMessageRequestDto struct {
+	Content string `json:"content" validate:"required,max=250"`
+}
@@ -0,0 +1,25 @@
+package dtos
+
+type TwoFactorCodeDto struct {
+	// A code from the authenticator app or a recovery code
+	Code string `json:"code" validate:"required,max=16"`
+}
+
+type VerifyTwoFactorDto struct {
+	// The pending token returned by CreateSession
+	Token string `json:"token" validate:"required,max=128"`
+	Code  string `json:"code" validate:"required,max=16"`
+}
+
+type TwoFactorEnrollmentResponse struct {
+	Secret string `json:"secret"`
+	// otpauth:// URI for authenticator apps
+	ProvisioningURI string `json:"provisioning_uri"`
+	// Base64 PNG of the provisioning URI
+	QRCode string `json:"qr_code"`
+}
+
+type RecoveryCodesResponse struct {
+	// Shown once, each signs in a single time in place of a code
+	RecoveryCodes []string `json:"recovery_codes"`
+}
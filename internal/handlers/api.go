@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dliluashvili/cowatchit/internal/config"
+	"github.com/dliluashvili/cowatchit/internal/dtos"
+	"github.com/dliluashvili/cowatchit/internal/helpers"
+	"github.com/dliluashvili/cowatchit/internal/interceptors"
+	"github.com/dliluashvili/cowatchit/internal/logging"
+	"github.com/dliluashvili/cowatchit/internal/middlewares"
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/dliluashvili/cowatchit/internal/services"
+	"github.com/dliluashvili/cowatchit/internal/shared/constants"
+	"github.com/go-chi/chi"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+// APIHandler serves the JSON API under /api/v1, its endpoints live in
+// feature files such as apirooms.go
+type APIHandler struct {
+	authService             *services.AuthService
+	sessionService          *services.SessionService
+	apiTokenService         *services.APITokenService
+	twoFactorService        *services.TwoFactorService
+	userService             *services.UserService
+	roomService             *services.RoomService
+	roomMessageService      *services.RoomMessageService
+	websocketManagerService *services.WebSocketManagerService
+	sessionConfig           config.SessionConfig
+	// Off when users may only sign in through single sign-on
+	passwordLogin bool
+}
+
+func NewAPIHandler(
+	as *services.AuthService,
+	ss *services.SessionService,
+	ats *services.APITokenService,
+	tfs *services.TwoFactorService,
+	us *services.UserService,
+	rs *services.RoomService,
+	rms *services.RoomMessageService,
+	wsms *services.WebSocketManagerService,
+	sessionConfig config.SessionConfig,
+	passwordLogin bool,
+) *APIHandler {
+	return &APIHandler{
+		authService:             as,
+		sessionService:          ss,
+		apiTokenService:         ats,
+		twoFactorService:        tfs,
+		userService:             us,
+		roomService:             rs,
+		roomMessageService:      rms,
+		websocketManagerService: wsms,
+		sessionConfig:           sessionConfig,
+		passwordLogin:           passwordLogin,
+	}
+}
+
+func (h *APIHandler) NotFound(w http.ResponseWriter, r *http.Request) {
+	helpers.SendAPIError(w, http.StatusNotFound, helpers.APICodeNotFound, "No such endpoint")
+}
+
+func (h *APIHandler) MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	helpers.SendAPIError(w, http.StatusMethodNotAllowed, helpers.APICodeMethodNotAllowed, "Method not allowed")
+}
+
+func apiSession(r *http.Request) *models.Session {
+	return r.Context().Value(constants.SessionContextKey).(*models.Session)
+}
+
+// Parse the {id} url param, answering 400 when it isn't a uuid
+func apiRoomID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	ID, err := uuid.Parse(chi.URLParam(r, "id"))
+
+	if err != nil {
+		helpers.SendAPIError(w, http.StatusBadRequest, helpers.APICodeBadRequest, "Invalid room id")
+		return uuid.Nil, false
+	}
+
+	return ID, true
+}
+
+// Parse page and per_page, the window fetches one extra row to tell
+// whether there is a next page
+func apiPage(w http.ResponseWriter, r *http.Request) (dtos.PageDto, *helpers.PageMeta, bool) {
+	meta := &helpers.PageMeta{Page: 1, PerPage: defaultPerPage}
+
+	if value := r.URL.Query().Get("page"); value != "" {
+		page, err := strconv.Atoi(value)
+		if err != nil || page < 1 {
+			helpers.SendAPIError(w, http.StatusBadRequest, helpers.APICodeBadRequest, "page must be a positive integer")
+			return dtos.PageDto{}, nil, false
+		}
+		meta.Page = page
+	}
+
+	if value := r.URL.Query().Get("per_page"); value != "" {
+		perPage, err := strconv.Atoi(value)
+		if err != nil || perPage < 1 || perPage > maxPerPage {
+			helpers.SendAPIError(w, http.StatusBadRequest, helpers.APICodeBadRequest, "per_page must be between 1 and 100")
+			return dtos.PageDto{}, nil, false
+		}
+		meta.PerPage = perPage
+	}
+
+	page := dtos.PageDto{
+		Limit:  meta.PerPage + 1,
+		Offset: (meta.Page - 1) * meta.PerPage,
+	}
+
+	return page, meta, true
+}
+
+// Trim the extra row fetched by apiPage and record whether it was there
+func trimPage[T any](items []T, meta *helpers.PageMeta) []T {
+	if len(items) > meta.PerPage {
+		meta.HasMore = true
+		return items[:meta.PerPage]
+	}
+
+	return items
+}
+
+// Answer a service error with its status, unknown errors are logged as internal
+func (h *APIHandler) sendServiceError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, services.ErrRoomNotFound):
+		helpers.SendAPIError(w, http.StatusNotFound, helpers.APICodeNotFound, "Room not found")
+	case errors.Is(err, services.ErrNotRoomHost):
+		helpers.SendAPIError(w, http.StatusForbidden, helpers.APICodeForbidden, err.Error())
+	case errors.Is(err, services.ErrRoomFull):
+		helpers.SendAPIError(w, http.StatusConflict, helpers.APICodeConflict, err.Error())
+	case errors.Is(err, services.ErrAPITokenNotFound):
+		helpers.SendAPIError(w, http.StatusNotFound, helpers.APICodeNotFound, "API token not found")
+	case errors.Is(err, services.ErrTwoFactorCodeInvalid):
+		helpers.SendAPIValidationError(w, map[string][]string{"code": {err.Error()}})
+	case errors.Is(err, services.ErrTwoFactorSessionInvalid):
+		helpers.SendAPIError(w, http.StatusUnauthorized, helpers.APICodeUnauthorized, err.Error())
+	case errors.Is(err, services.ErrTwoFactorEnabled),
+		errors.Is(err, services.ErrTwoFactorNotEnabled),
+		errors.Is(err, services.ErrTwoFactorEnrollmentExpired):
+		helpers.SendAPIError(w, http.StatusConflict, helpers.APICodeConflict, err.Error())
+	case errors.Is(err, services.ErrRoomPasswordRequired):
+		helpers.SendAPIValidationError(w, map[string][]string{"password": {err.Error()}})
+	case errors.Is(err, services.ErrRoomSourceRequired):
+		helpers.SendAPIValidationError(w, map[string][]string{"src": {err.Error()}})
+	case errors.Is(err, services.ErrLibraryItemNotFound), errors.Is(err, services.ErrLibraryItemNotReady):
+		helpers.SendAPIValidationError(w, map[string][]string{"library_item_id": {err.Error()}})
+	default:
+		logging.FromContext(r.Context()).Error("api request failed", "err", err)
+		helpers.SendAPIError(w, http.StatusInternalServerError, helpers.APICodeInternal, "Something went wrong")
+	}
+}
+
+// Password sign in and sign up are off when only single sign-on is allowed
+func (h *APIHandler) requirePasswordLogin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.passwordLogin {
+			helpers.SendAPIError(w, http.StatusForbidden, helpers.APICodeForbidden, "Password sign in is disabled, sign in through single sign-on")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Routes mounts the API, auth guards every endpoint but signing in and up
+// and personal API tokens are held to their scopes. Two-factor settings
+// take a password session only.
+// Keep it in step with openapi.Operations, pkg/apiclient/contract_test.go compares the two.
+func (h *APIHandler) Routes(validate *validator.Validate, auth func(http.Handler) http.Handler) chi.Router {
+	r := chi.NewRouter()
+
+	r.NotFound(h.NotFound)
+	r.MethodNotAllowed(h.MethodNotAllowed)
+
+	r.With(h.requirePasswordLogin, interceptors.ValidateAPIBody[dtos.SignInDto](validate)).Post("/sessions", h.CreateSession)
+	r.With(h.requirePasswordLogin, interceptors.ValidateAPIBody[dtos.SignUpDto](validate)).Post("/users", h.CreateUser)
+	r.With(interceptors.ValidateAPIBody[dtos.VerifyTwoFactorDto](validate)).Post("/sessions/two-factor", h.VerifyTwoFactor)
+
+	r.Group(func(r chi.Router) {
+		r.Use(auth)
+
+		r.Delete("/sessions/current", h.DeleteSession)
+
+		r.Get("/users/me", h.GetMe)
+		r.Get("/users/{username}", h.GetUser)
+
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.APIRequirePasswordSession)
+
+			r.Post("/users/me/two-factor", h.EnableTwoFactor)
+			r.With(interceptors.ValidateAPIBody[dtos.TwoFactorCodeDto](validate)).Post("/users/me/two-factor/confirm", h.ConfirmTwoFactor)
+			r.With(interceptors.ValidateAPIBody[dtos.TwoFactorCodeDto](validate)).Post("/users/me/two-factor/disable", h.DisableTwoFactor)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.APIRequireScope(models.ScopeRoomsRead))
+
+			r.Get("/rooms", h.ListRooms)
+			r.Get("/rooms/{id}", h.GetRoom)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.APIRequireScope(models.ScopeRoomsWrite))
+
+			r.With(interceptors.ValidateAPIBody[dtos.CreateRoomDto](validate)).Post("/rooms", h.CreateRoom)
+			r.With(interceptors.ValidateAPIBody[dtos.UpdateRoomDto](validate)).Patch("/rooms/{id}", h.UpdateRoom)
+			r.Delete("/rooms/{id}", h.DeleteRoom)
+		})
+
+		r.With(middlewares.APIRequireScope(models.ScopeChatRead)).Get("/rooms/{id}/messages", h.ListRoomMessages)
+		r.With(
+			middlewares.APIRequireScope(models.ScopeChatWrite),
+			interceptors.ValidateAPIBody[dtos.CreateRoomMessageRequestDto](validate),
+		).Post("/rooms/{id}/messages", h.CreateRoomMessage)
+	})
+
+	return r
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/dliluashvili/cowatchit/internal/dtos"
+	"github.com/dliluashvili/cowatchit/internal/helpers"
+	"github.com/dliluashvili/cowatchit/internal/logging"
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/dliluashvili/cowatchit/internal/protocol"
+	"github.com/dliluashvili/cowatchit/internal/shared/constants"
+)
+
+// GET /api/v1/rooms/{id}/messages?page=&per_page=, oldest first
+func (h *APIHandler) ListRoomMessages(w http.ResponseWriter, r *http.Request) {
+	room, ok := h.accessibleRoom(w, r)
+	if !ok {
+		return
+	}
+
+	page, meta, ok := apiPage(w, r)
+	if !ok {
+		return
+	}
+
+	roomMessages, err := h.roomMessageService.GetRoomMessages(r.Context(), room.ID, page)
+
+	if err != nil {
+		h.sendServiceError(w, r, err)
+		return
+	}
+
+	roomMessages = trimPage(roomMessages, meta)
+
+	response := make([]*dtos.RoomMessageResponse, 0, len(roomMessages))
+	for _, roomMessage := range roomMessages {
+		response = append(response, dtos.NewRoomMessageResponse(roomMessage))
+	}
+
+	helpers.SendAPIPage(w, response, meta)
+}
+
+// POST /api/v1/rooms/{id}/messages posts to the room's chat, connected
+// sockets receive it like any other chat message
+func (h *APIHandler) CreateRoomMessage(w http.ResponseWriter, r *http.Request) {
+	room, ok := h.accessibleRoom(w, r)
+	if !ok {
+		return
+	}
+
+	validated := r.Context().Value(constants.ValidatedContextKey).(*dtos.CreateRoomMessageRequestDto)
+	user := apiSession(r).User
+
+	roomMessage, err := h.roomMessageService.Create(r.Context(), &dtos.CreateRoomMessageDto{
+		Content:        validated.Content,
+		RoomID:         room.ID,
+		SenderID:       user.ID,
+		SenderUsername: user.Username,
+		IsHost:         room.HostID == user.ID,
+	})
+
+	if err != nil {
+		h.sendServiceError(w, r, err)
+		return
+	}
+
+	if messageMsg, err := protocol.NewEvent(protocol.EventChatMessageReceived, toChatMessage(roomMessage)); err == nil {
+		if err := h.websocketManagerService.BroadcastToRoom(r.Context(), room.ID, messageMsg, ""); err != nil {
+			logging.FromContext(r.Context()).Warn("broadcast failed", "event", protocol.EventChatMessageReceived, "err", err)
+		}
+	}
+
+	helpers.SendAPIData(w, http.StatusCreated, dtos.NewRoomMessageResponse(roomMessage))
+}
+
+// Load the {id} room, private rooms' chats are limited to the host,
+// admins and users currently in the room
+func (h *APIHandler) accessibleRoom(w http.ResponseWriter, r *http.Request) (*models.Room, bool) {
+	ID, ok := apiRoomID(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	room, err := h.roomService.FindOne(r.Context(), ID)
+
+	if err != nil {
+		h.sendServiceError(w, r, err)
+		return nil, false
+	}
+
+	user := apiSession(r).User
+
+	if room.Private && !user.IsAdmin && room.HostID != user.ID &&
+		!slices.Contains(h.websocketManagerService.GetUsersInRoom(room.ID), user.ID) {
+		helpers.SendAPIError(w, http.StatusForbidden, helpers.APICodeForbidden, "Join the room to use its chat")
+		return nil, false
+	}
+
+	return room, true
+}
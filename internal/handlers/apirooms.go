@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/dliluashvili/cowatchit/internal/dtos"
+	"github.com/dliluashvili/cowatchit/internal/helpers"
+	"github.com/dliluashvili/cowatchit/internal/shared/constants"
+)
+
+// GET /api/v1/rooms?filter=all|public|private&keyword=&my=true&page=&per_page=
+func (h *APIHandler) ListRooms(w http.ResponseWriter, r *http.Request) {
+	page, meta, ok := apiPage(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	filter := query.Get("filter")
+	keyword := query.Get("keyword")
+	viewer := apiSession(r).User
+
+	switch filter {
+	case "", "all", "public", "private":
+	default:
+		helpers.SendAPIError(w, http.StatusBadRequest, helpers.APICodeBadRequest, "filter must be all, public or private")
+		return
+	}
+
+	rooms, err := h.roomService.Find(r.Context(), &dtos.FindRoomDto{
+		Filter:     &filter,
+		Keyword:    &keyword,
+		My:         helpers.StringToBool(query.Get("my")),
+		AuthUserID: &viewer.ID,
+		Page:       page,
+	})
+
+	if err != nil {
+		h.sendServiceError(w, r, err)
+		return
+	}
+
+	rooms = trimPage(rooms, meta)
+
+	response := make([]*dtos.RoomResponse, 0, len(rooms))
+	for i := range rooms {
+		response = append(response, dtos.NewRoomResponse(&rooms[i], viewer))
+	}
+
+	helpers.SendAPIPage(w, response, meta)
+}
+
+// POST /api/v1/rooms
+func (h *APIHandler) CreateRoom(w http.ResponseWriter, r *http.Request) {
+	validated := r.Context().Value(constants.ValidatedContextKey).(*dtos.CreateRoomDto)
+
+	room, err := h.roomService.Create(r.Context(), &dtos.CreateRoomServiceDto{
+		HostID:        apiSession(r).User.ID,
+		CreateRoomDto: validated,
+	})
+
+	if err != nil {
+		h.sendServiceError(w, r, err)
+		return
+	}
+
+	helpers.SendAPIData(w, http.StatusCreated, dtos.NewRoomResponse(room, apiSession(r).User))
+}
+
+// GET /api/v1/rooms/{id}
+func (h *APIHandler) GetRoom(w http.ResponseWriter, r *http.Request) {
+	ID, ok := apiRoomID(w, r)
+	if !ok {
+		return
+	}
+
+	room, err := h.roomService.FindOne(r.Context(), ID)
+
+	if err != nil {
+		h.sendServiceError(w, r, err)
+		return
+	}
+
+	helpers.SendAPIData(w, http.StatusOK, dtos.NewRoomResponse(room, apiSession(r).User))
+}
+
+// PATCH /api/v1/rooms/{id}, host or admin only
+func (h *APIHandler) UpdateRoom(w http.ResponseWriter, r *http.Request) {
+	ID, ok := apiRoomID(w, r)
+	if !ok {
+		return
+	}
+
+	validated := r.Context().Value(constants.ValidatedContextKey).(*dtos.UpdateRoomDto)
+
+	room, err := h.roomService.Update(r.Context(), apiSession(r).User, ID, validated)
+
+	if err != nil {
+		h.sendServiceError(w, r, err)
+		return
+	}
+
+	helpers.SendAPIData(w, http.StatusOK, dtos.NewRoomResponse(room, apiSession(r).User))
+}
+
+// DELETE /api/v1/rooms/{id}, host or admin only
+func (h *APIHandler) DeleteRoom(w http.ResponseWriter, r *http.Request) {
+	ID, ok := apiRoomID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.roomService.Delete(r.Context(), apiSession(r).User, ID); err != nil {
+		h.sendServiceError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/dliluashvili/cowatchit/internal/dtos"
+	"github.com/dliluashvili/cowatchit/internal/helpers"
+	"github.com/dliluashvili/cowatchit/internal/logging"
+	"github.com/dliluashvili/cowatchit/internal/metrics"
+	"github.com/dliluashvili/cowatchit/internal/shared/constants"
+)
+
+// POST /api/v1/sessions signs in, the session is set as a cookie and
+// returned as a bearer token
+func (h *APIHandler) CreateSession(w http.ResponseWriter, r *http.Request) {
+	validated := r.Context().Value(constants.ValidatedContextKey).(*dtos.SignInDto)
+
+	sessionModel, code, err := h.authService.SignIn(r.Context(), validated)
+
+	if err != nil {
+		logging.FromContext(r.Context()).Info("sign in failed", "status", code, "err", err)
+		metrics.SignIns.WithLabelValues("failure").Inc()
+		helpers.SendAPIError(w, http.StatusUnauthorized, helpers.APICodeUnauthorized, "Invalid credentials")
+		return
+	}
+
+	metrics.SignIns.WithLabelValues("success").Inc()
+
+	http.SetCookie(w, helpers.SessionCookie(h.sessionConfig, sessionModel.SessionID))
+
+	helpers.SendAPIData(w, http.StatusCreated, dtos.NewSessionResponse(sessionModel))
+}
+
+// DELETE /api/v1/sessions/current signs out, a personal API token is
+// revoked instead
+func (h *APIHandler) DeleteSession(w http.ResponseWriter, r *http.Request) {
+	session := apiSession(r)
+
+	if session.IsAPIToken() {
+		if err := h.apiTokenService.Revoke(r.Context(), session.User.ID, *session.APITokenID); err != nil {
+			h.sendServiceError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if err := h.sessionService.Delete(r.Context(), session); err != nil {
+		h.sendServiceError(w, r, err)
+		return
+	}
+
+	http.SetCookie(w, helpers.ExpiredSessionCookie(h.sessionConfig))
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"net/http"
+
+	"github.com/dliluashvili/cowatchit/internal/dtos"
+	"github.com/dliluashvili/cowatchit/internal/helpers"
+	"github.com/dliluashvili/cowatchit/internal/logging"
+	"github.com/dliluashvili/cowatchit/internal/shared/constants"
+)
+
+// POST /api/v1/sessions/two-factor trades the pending token from
+// CreateSession and a code for a session
+func (h *APIHandler) VerifyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	validated := r.Context().Value(constants.ValidatedContextKey).(*dtos.VerifyTwoFactorDto)
+
+	sessionModel, err := h.twoFactorService.Verify(r.Context(), validated.Token, validated.Code)
+
+	if err != nil {
+		logging.FromContext(r.Context()).Info("two-factor verification failed", "err", err)
+		h.sendServiceError(w, r, err)
+		return
+	}
+
+	http.SetCookie(w, helpers.SessionCookie(h.sessionConfig, sessionModel.SessionID))
+
+	helpers.SendAPIData(w, http.StatusCreated, dtos.NewSessionResponse(sessionModel))
+}
+
+// POST /api/v1/users/me/two-factor starts turning two-factor on
+func (h *APIHandler) EnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	enrollment, err := h.twoFactorService.Enroll(r.Context(), apiSession(r).User.ID)
+
+	if err != nil {
+		h.sendServiceError(w, r, err)
+		return
+	}
+
+	helpers.SendAPIData(w, http.StatusCreated, &dtos.TwoFactorEnrollmentResponse{
+		Secret:          enrollment.Secret,
+		ProvisioningURI: enrollment.ProvisioningURI,
+		QRCode:          base64.StdEncoding.EncodeToString(enrollment.QRCode),
+	})
+}
+
+// POST /api/v1/users/me/two-factor/confirm turns two-factor on with a
+// first code from the app
+func (h *APIHandler) ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	validated := r.Context().Value(constants.ValidatedContextKey).(*dtos.TwoFactorCodeDto)
+
+	codes, err := h.twoFactorService.ConfirmEnrollment(r.Context(), apiSession(r).User.ID, validated.Code)
+
+	if err != nil {
+		h.sendServiceError(w, r, err)
+		return
+	}
+
+	helpers.SendAPIData(w, http.StatusOK, &dtos.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// POST /api/v1/users/me/two-factor/disable
+func (h *APIHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	validated := r.Context().Value(constants.ValidatedContextKey).(*dtos.TwoFactorCodeDto)
+
+	if err := h.twoFactorService.Disable(r.Context(), apiSession(r).User.ID, validated.Code); err != nil {
+		h.sendServiceError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
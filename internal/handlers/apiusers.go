@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/dliluashvili/cowatchit/internal/dtos"
+	"github.com/dliluashvili/cowatchit/internal/helpers"
+	"github.com/dliluashvili/cowatchit/internal/logging"
+	"github.com/dliluashvili/cowatchit/internal/shared/constants"
+	"github.com/go-chi/chi"
+)
+
+// POST /api/v1/users signs up and starts a session
+func (h *APIHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	validated := r.Context().Value(constants.ValidatedContextKey).(*dtos.SignUpDto)
+
+	if *validated.Password != *validated.PasswordConfirmation {
+		helpers.SendAPIValidationError(w, map[string][]string{
+			"password_confirmation": {"password_confirmation must match password"},
+		})
+		return
+	}
+
+	sessionModel, code, err := h.authService.SignUp(r.Context(), validated)
+
+	if err != nil {
+		if code == http.StatusConflict {
+			helpers.SendAPIError(w, http.StatusConflict, helpers.APICodeConflict, "User already exists")
+			return
+		}
+
+		logging.FromContext(r.Context()).Error("sign up failed", "status", code, "err", err)
+		helpers.SendAPIError(w, http.StatusInternalServerError, helpers.APICodeInternal, "Unable to sign up")
+		return
+	}
+
+	http.SetCookie(w, helpers.SessionCookie(h.sessionConfig, sessionModel.SessionID))
+
+	helpers.SendAPIData(w, http.StatusCreated, dtos.NewSessionResponse(sessionModel))
+}
+
+// GET /api/v1/users/me
+func (h *APIHandler) GetMe(w http.ResponseWriter, r *http.Request) {
+	user, err := h.userService.Me(r.Context(), apiSession(r).User.ID)
+
+	if err != nil {
+		h.sendServiceError(w, r, err)
+		return
+	}
+
+	if user == nil {
+		helpers.SendAPIError(w, http.StatusNotFound, helpers.APICodeNotFound, "User not found")
+		return
+	}
+
+	helpers.SendAPIData(w, http.StatusOK, dtos.NewMeResponse(user))
+}
+
+// GET /api/v1/users/{username}
+func (h *APIHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	user, err := h.userService.FindByUsername(r.Context(), chi.URLParam(r, "username"))
+
+	if err != nil {
+		h.sendServiceError(w, r, err)
+		return
+	}
+
+	if user == nil {
+		helpers.SendAPIError(w, http.StatusNotFound, helpers.APICodeNotFound, "User not found")
+		return
+	}
+
+	helpers.SendAPIData(w, http.StatusOK, dtos.NewUserResponse(user))
+}
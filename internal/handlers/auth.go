@@ -1,22 +1,26 @@
 package handlers
 
 import (
-	"fmt"
 	"net/http"
 
+	"github.com/dliluashvili/cowatchit/internal/config"
 	"github.com/dliluashvili/cowatchit/internal/dtos"
 	"github.com/dliluashvili/cowatchit/internal/helpers"
+	"github.com/dliluashvili/cowatchit/internal/logging"
+	"github.com/dliluashvili/cowatchit/internal/metrics"
 	"github.com/dliluashvili/cowatchit/internal/services"
 	"github.com/dliluashvili/cowatchit/internal/shared/constants"
 )
 
 type AuthHandler struct {
-	authService *services.AuthService
+	authService   *services.AuthService
+	sessionConfig config.SessionConfig
 }
 
-func NewAuthHandler(s *services.AuthService) *AuthHandler {
+func NewAuthHandler(s *services.AuthService, sessionConfig config.SessionConfig) *AuthHandler {
 	return &AuthHandler{
-		authService: s,
+		authService:   s,
+		sessionConfig: sessionConfig,
 	}
 }
 
@@ -26,6 +30,8 @@ func (h *AuthHandler) SignIn(w http.ResponseWriter, r *http.Request) {
 	sessionModel, code, err := h.authService.SignIn(r.Context(), validated)
 
 	if err != nil {
+		logging.FromContext(r.Context()).Info("sign in failed", "status", code, "err", err)
+		metrics.SignIns.WithLabelValues("failure").Inc()
 		helpers.SendJson(w, &helpers.Response{
 			Data: map[string]bool{
 				"success": false,
@@ -37,20 +43,17 @@ func (h *AuthHandler) SignIn(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	metrics.SignIns.WithLabelValues("success").Inc()
+
 	sessionID := sessionModel.SessionID
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "sessionId",
-		Value:    sessionID,
-		Path:     "/",
-		MaxAge:   int(constants.SessionDuration.Seconds()),
-		HttpOnly: true,
-		SameSite: http.SameSiteLaxMode,
-	})
+	http.SetCookie(w, helpers.SessionCookie(h.sessionConfig, sessionID))
 
+	// The page asks for the code next
 	helpers.SendJson(w, &helpers.Response{
 		Data: map[string]bool{
-			"success": true,
+			"success":    true,
+			"two_factor": sessionModel.PendingTwoFactor,
 		},
 		Message: "User has been created",
 		Status:  code,
@@ -63,7 +66,7 @@ func (h *AuthHandler) SignUp(w http.ResponseWriter, r *http.Request) {
 	sessionModel, code, err := h.authService.SignUp(r.Context(), validated)
 
 	if err != nil {
-		fmt.Println("error while signing up", err)
+		logging.FromContext(r.Context()).Error("sign up failed", "err", err)
 		helpers.SendJson(w, &helpers.Response{
 			Data: map[string]bool{
 				"success": false,
@@ -77,14 +80,7 @@ func (h *AuthHandler) SignUp(w http.ResponseWriter, r *http.Request) {
 
 	sessionID := sessionModel.SessionID
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "sessionId",
-		Value:    sessionID,
-		Path:     "/",
-		MaxAge:   int(constants.SessionDuration.Seconds()),
-		HttpOnly: true,
-		SameSite: http.SameSiteLaxMode,
-	})
+	http.SetCookie(w, helpers.SessionCookie(h.sessionConfig, sessionID))
 
 	helpers.SendJson(w, &helpers.Response{
 		Data: map[string]bool{
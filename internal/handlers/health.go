@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/dliluashvili/cowatchit/internal/helpers"
+	"github.com/dliluashvili/cowatchit/internal/logging"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const readinessTimeout = 2 * time.Second
+
+type HealthHandler struct {
+	db          *gorm.DB
+	redisClient *redis.Client
+	draining    atomic.Bool
+}
+
+func NewHealthHandler(db *gorm.DB, redisClient *redis.Client) *HealthHandler {
+	return &HealthHandler{
+		db:          db,
+		redisClient: redisClient,
+	}
+}
+
+// SetDraining fails readiness so the load balancer stops sending traffic
+func (h *HealthHandler) SetDraining() {
+	h.draining.Store(true)
+}
+
+// Healthz answers as long as the process can serve requests
+func (h *HealthHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	helpers.SendJson(w, &helpers.Response{
+		Data:    map[string]string{"status": "ok"},
+		Message: "ok",
+		Status:  http.StatusOK,
+	})
+}
+
+// Readyz checks Postgres and Redis, and fails while shutting down
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	checks := map[string]string{
+		"postgres": "ok",
+		"redis":    "ok",
+	}
+
+	ready := true
+
+	if err := h.pingPostgres(ctx); err != nil {
+		logging.FromContext(ctx).Warn("readiness check failed", "check", "postgres", "err", err)
+		checks["postgres"] = err.Error()
+		ready = false
+	}
+
+	if err := h.redisClient.Ping(ctx).Err(); err != nil {
+		logging.FromContext(ctx).Warn("readiness check failed", "check", "redis", "err", err)
+		checks["redis"] = err.Error()
+		ready = false
+	}
+
+	if h.draining.Load() {
+		checks["server"] = "shutting down"
+		ready = false
+	}
+
+	status := http.StatusOK
+	message := "ready"
+
+	if !ready {
+		status = http.StatusServiceUnavailable
+		message = "not ready"
+	}
+
+	helpers.SendJson(w, &helpers.Response{
+		Data:    checks,
+		Message: message,
+		Status:  status,
+	})
+}
+
+func (h *HealthHandler) pingPostgres(ctx context.Context) error {
+	sqlDB, err := h.db.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.PingContext(ctx)
+}
@@ -3,11 +3,32 @@ package handlers
 import (
 	"net/http"
 
+	"github.com/dliluashvili/cowatchit/internal/config"
+	"github.com/dliluashvili/cowatchit/internal/params"
 	"github.com/dliluashvili/cowatchit/internal/templates"
 )
 
-func HandleLanding(w http.ResponseWriter, r *http.Request) {
-	component := templates.LandingPage()
+type LandingHandler struct {
+	oidcConfig config.OIDCConfig
+}
+
+func NewLandingHandler(oidcConfig config.OIDCConfig) *LandingHandler {
+	return &LandingHandler{
+		oidcConfig: oidcConfig,
+	}
+}
+
+func (h *LandingHandler) HandleLanding(w http.ResponseWriter, r *http.Request) {
+	authParams := &params.AuthParams{
+		PasswordLogin: h.oidcConfig.PasswordLogin,
+		Error:         ssoErrorMessages[r.URL.Query().Get(ssoErrorParam)],
+	}
+
+	if h.oidcConfig.Enabled() {
+		authParams.SSOName = h.oidcConfig.ProviderName
+	}
+
+	component := templates.LandingPage(authParams)
 
 	component.Render(r.Context(), w)
 }
@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dliluashvili/cowatchit/internal/dtos"
+	"github.com/dliluashvili/cowatchit/internal/helpers"
+	"github.com/dliluashvili/cowatchit/internal/logging"
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/dliluashvili/cowatchit/internal/params"
+	"github.com/dliluashvili/cowatchit/internal/services"
+	"github.com/dliluashvili/cowatchit/internal/shared/constants"
+	"github.com/dliluashvili/cowatchit/internal/templates"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+// Content type of upload chunks, as in tus
+const chunkContentType = "application/offset+octet-stream"
+
+type LibraryHandler struct {
+	libraryService *services.LibraryService
+}
+
+func NewLibraryHandler(ls *services.LibraryService) *LibraryHandler {
+	return &LibraryHandler{
+		libraryService: ls,
+	}
+}
+
+func (h *LibraryHandler) HandleLibraryPage(w http.ResponseWriter, r *http.Request) {
+	libraryParams, ok := h.libraryParams(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Header.Get("HX-Request") == "true" {
+		templates.Library(libraryParams).Render(r.Context(), w)
+		return
+	}
+
+	templates.LibraryPage(libraryParams).Render(r.Context(), w)
+}
+
+// Items renders the list of videos, the page polls it while uploads or
+// transcodes are running
+func (h *LibraryHandler) Items(w http.ResponseWriter, r *http.Request) {
+	libraryParams, ok := h.libraryParams(w, r)
+	if !ok {
+		return
+	}
+
+	templates.LibraryItems(libraryParams).Render(r.Context(), w)
+}
+
+// Create starts an upload, chunks then go to Upload
+func (h *LibraryHandler) Create(w http.ResponseWriter, r *http.Request) {
+	validated := r.Context().Value(constants.ValidatedContextKey).(*dtos.CreateLibraryItemDto)
+
+	session := r.Context().Value(constants.SessionContextKey).(*models.Session)
+
+	item, err := h.libraryService.Create(r.Context(), session.User.ID, validated)
+
+	if errors.Is(err, services.ErrLibraryFileTooLarge) || errors.Is(err, services.ErrLibraryQuotaExceeded) {
+		helpers.SendJson(w, &helpers.Response{
+			Data:    map[string][]string{"file": {err.Error()}},
+			Message: "invalid video",
+			Status:  http.StatusUnprocessableEntity,
+		})
+		return
+	}
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to create library item", "err", err)
+		helpers.SendJson(w, &helpers.Response{
+			Message: "Unable to start upload",
+			Status:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("library upload started", "library_item_id", item.ID, "size", item.Size)
+
+	helpers.SendJson(w, &helpers.Response{
+		Data:    item,
+		Message: "all good",
+		Status:  http.StatusCreated,
+	})
+}
+
+// UploadStatus tells a client resuming an upload where to carry on
+func (h *LibraryHandler) UploadStatus(w http.ResponseWriter, r *http.Request) {
+	item, ok := h.find(w, r)
+	if !ok {
+		return
+	}
+
+	helpers.SendJson(w, &helpers.Response{
+		Data:    item,
+		Message: "all good",
+		Status:  http.StatusOK,
+	})
+}
+
+// Upload appends the body at the Upload-Offset header. The reply carries
+// the item as it is now, also on conflicts so the client can catch up.
+func (h *LibraryHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	session := r.Context().Value(constants.SessionContextKey).(*models.Session)
+
+	ID, err := uuid.Parse(chi.URLParam(r, "id"))
+
+	if err != nil {
+		helpers.SendJson(w, &helpers.Response{
+			Message: "bad request",
+			Status:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	if r.Header.Get("Content-Type") != chunkContentType {
+		helpers.SendJson(w, &helpers.Response{
+			Message: "chunks must be sent as " + chunkContentType,
+			Status:  http.StatusUnsupportedMediaType,
+		})
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+
+	if err != nil || offset < 0 {
+		helpers.SendJson(w, &helpers.Response{
+			Message: "Upload-Offset header must be a byte offset",
+			Status:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	item, err := h.libraryService.Append(r.Context(), session.User.ID, ID, offset, r.Body)
+
+	switch {
+	case err == nil:
+		helpers.SendJson(w, &helpers.Response{
+			Data:    item,
+			Message: "all good",
+			Status:  http.StatusOK,
+		})
+	case errors.Is(err, services.ErrLibraryItemNotFound):
+		helpers.SendJson(w, &helpers.Response{
+			Message: err.Error(),
+			Status:  http.StatusNotFound,
+		})
+	case errors.Is(err, services.ErrLibraryOffsetMismatch), errors.Is(err, services.ErrLibraryUploadComplete):
+		item, _ = h.libraryService.Find(r.Context(), session.User.ID, ID)
+		helpers.SendJson(w, &helpers.Response{
+			Data:    item,
+			Message: err.Error(),
+			Status:  http.StatusConflict,
+		})
+	case errors.Is(err, services.ErrLibraryFileTooLarge):
+		helpers.SendJson(w, &helpers.Response{
+			Message: "chunk runs past the declared size",
+			Status:  http.StatusRequestEntityTooLarge,
+		})
+	default:
+		logging.FromContext(r.Context()).Error("failed to append chunk", "library_item_id", ID, "err", err)
+		helpers.SendJson(w, &helpers.Response{
+			Data:    item,
+			Message: "Unable to save chunk",
+			Status:  http.StatusInternalServerError,
+		})
+	}
+}
+
+func (h *LibraryHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	session := r.Context().Value(constants.SessionContextKey).(*models.Session)
+
+	ID, err := uuid.Parse(chi.URLParam(r, "id"))
+
+	if err != nil {
+		helpers.SendJson(w, &helpers.Response{
+			Message: "bad request",
+			Status:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	err = h.libraryService.Delete(r.Context(), session.User.ID, ID)
+
+	var message string
+
+	switch {
+	case errors.Is(err, services.ErrLibraryItemNotFound):
+		helpers.SendJson(w, &helpers.Response{
+			Message: err.Error(),
+			Status:  http.StatusNotFound,
+		})
+		return
+	case errors.Is(err, services.ErrLibraryItemInUse):
+		message = err.Error()
+	case err != nil:
+		logging.FromContext(r.Context()).Error("failed to delete library item", "library_item_id", ID, "err", err)
+		helpers.SendJson(w, &helpers.Response{
+			Message: "Unable to delete video",
+			Status:  http.StatusInternalServerError,
+		})
+		return
+	default:
+		logging.FromContext(r.Context()).Info("library item deleted", "library_item_id", ID)
+	}
+
+	libraryParams, ok := h.libraryParams(w, r)
+	if !ok {
+		return
+	}
+
+	libraryParams.Error = message
+
+	templates.LibraryItems(libraryParams).Render(r.Context(), w)
+}
+
+// Transcode queues a video whose transcoding failed again
+func (h *LibraryHandler) Transcode(w http.ResponseWriter, r *http.Request) {
+	session := r.Context().Value(constants.SessionContextKey).(*models.Session)
+
+	ID, err := uuid.Parse(chi.URLParam(r, "id"))
+
+	if err != nil {
+		helpers.SendJson(w, &helpers.Response{
+			Message: "bad request",
+			Status:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	err = h.libraryService.Transcode(r.Context(), session.User.ID, ID)
+
+	var message string
+
+	switch {
+	case errors.Is(err, services.ErrLibraryItemNotFound):
+		helpers.SendJson(w, &helpers.Response{
+			Message: err.Error(),
+			Status:  http.StatusNotFound,
+		})
+		return
+	case errors.Is(err, services.ErrLibraryItemNotReady):
+		message = err.Error()
+	case err != nil:
+		logging.FromContext(r.Context()).Error("failed to queue transcoding", "library_item_id", ID, "err", err)
+		helpers.SendJson(w, &helpers.Response{
+			Message: "Unable to queue transcoding",
+			Status:  http.StatusInternalServerError,
+		})
+		return
+	default:
+		logging.FromContext(r.Context()).Info("transcoding queued", "library_item_id", ID)
+	}
+
+	libraryParams, ok := h.libraryParams(w, r)
+	if !ok {
+		return
+	}
+
+	libraryParams.Error = message
+
+	templates.LibraryItems(libraryParams).Render(r.Context(), w)
+}
+
+func (h *LibraryHandler) find(w http.ResponseWriter, r *http.Request) (*models.LibraryItem, bool) {
+	session := r.Context().Value(constants.SessionContextKey).(*models.Session)
+
+	ID, err := uuid.Parse(chi.URLParam(r, "id"))
+
+	if err != nil {
+		helpers.SendJson(w, &helpers.Response{
+			Message: "bad request",
+			Status:  http.StatusBadRequest,
+		})
+		return nil, false
+	}
+
+	item, err := h.libraryService.Find(r.Context(), session.User.ID, ID)
+
+	if errors.Is(err, services.ErrLibraryItemNotFound) {
+		helpers.SendJson(w, &helpers.Response{
+			Message: err.Error(),
+			Status:  http.StatusNotFound,
+		})
+		return nil, false
+	}
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to find library item", "library_item_id", ID, "err", err)
+		helpers.SendJson(w, &helpers.Response{
+			Message: "Unable to load video",
+			Status:  http.StatusInternalServerError,
+		})
+		return nil, false
+	}
+
+	return item, true
+}
+
+func (h *LibraryHandler) libraryParams(w http.ResponseWriter, r *http.Request) (*params.LibraryParams, bool) {
+	session := r.Context().Value(constants.SessionContextKey).(*models.Session)
+
+	items, err := h.libraryService.List(r.Context(), session.User.ID)
+
+	var usage *services.LibraryUsage
+	if err == nil {
+		usage, err = h.libraryService.Usage(r.Context(), session.User.ID)
+	}
+
+	var jobs map[uuid.UUID]*models.MediaJob
+	if err == nil {
+		jobs, err = h.libraryService.Jobs(r.Context(), items)
+	}
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to load library", "err", err)
+		helpers.SendJson(w, &helpers.Response{
+			Message: "Unable to load library",
+			Status:  http.StatusInternalServerError,
+		})
+		return nil, false
+	}
+
+	return &params.LibraryParams{
+		Items: items,
+		Jobs:  jobs,
+		Used:  usage.Used,
+		Quota: usage.Quota,
+	}, true
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/dliluashvili/cowatchit/internal/logging"
+	"github.com/dliluashvili/cowatchit/internal/services"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+type MediaHandler struct {
+	roomService             *services.RoomService
+	websocketManagerService *services.WebSocketManagerService
+}
+
+func NewMediaHandler(rs *services.RoomService, wsm *services.WebSocketManagerService) *MediaHandler {
+	return &MediaHandler{
+		roomService:             rs,
+		websocketManagerService: wsm,
+	}
+}
+
+// Serve streams a file of the library video a room plays. The link has to
+// be signed for a participant who is still in the room, so it stops working
+// for anyone it was passed on to once they leave or it expires.
+func (h *MediaHandler) Serve(w http.ResponseWriter, r *http.Request) {
+	roomID, err := uuid.Parse(chi.URLParam(r, "roomID"))
+
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+
+	userID, err := h.roomService.MediaViewer(roomID, query)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	// Sockets live on one instance, media requests have to reach the same
+	if !h.websocketManagerService.IsUserInRoom(roomID, userID) {
+		http.Error(w, "not in the room", http.StatusForbidden)
+		return
+	}
+
+	file, err := h.roomService.OpenMedia(r.Context(), roomID, userID, chi.URLParam(r, "name"), query)
+
+	switch {
+	case errors.Is(err, services.ErrRoomNotFound), errors.Is(err, services.ErrLibraryItemNotFound), errors.Is(err, services.ErrLibraryItemNotReady):
+		http.NotFound(w, r)
+		return
+	case err != nil:
+		logging.FromContext(r.Context()).Error("failed to serve media", "room_id", roomID, "err", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	defer file.Content.Close()
+
+	// Links are per viewer, shared caches must not hand them to others
+	w.Header().Set("Content-Type", file.ContentType)
+	w.Header().Set("Cache-Control", "private, no-store")
+
+	if seeker, ok := file.Content.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, "", time.Time{}, seeker)
+		return
+	}
+
+	io.Copy(w, file.Content)
+}
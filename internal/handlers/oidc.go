@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dliluashvili/cowatchit/internal/config"
+	"github.com/dliluashvili/cowatchit/internal/dtos"
+	"github.com/dliluashvili/cowatchit/internal/helpers"
+	"github.com/dliluashvili/cowatchit/internal/logging"
+	"github.com/dliluashvili/cowatchit/internal/metrics"
+	"github.com/dliluashvili/cowatchit/internal/params"
+	"github.com/dliluashvili/cowatchit/internal/services"
+	"github.com/dliluashvili/cowatchit/internal/shared/formatter"
+	"github.com/dliluashvili/cowatchit/internal/templates"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	oidcStateCookie  = "oidc_state"
+	oidcSignupCookie = "oidc_signup"
+	// Query param of the landing page naming why single sign-on failed
+	ssoErrorParam = "sso_error"
+)
+
+// Reasons shown on the landing page, keyed by the sso_error param
+var ssoErrorMessages = map[string]string{
+	"expired":       services.ErrOIDCLoginExpired.Error(),
+	"signup":        services.ErrOIDCSignupExpired.Error(),
+	"email_missing": services.ErrOIDCEmailMissing.Error(),
+	"domain":        services.ErrOIDCDomainNotAllowed.Error(),
+	"email_taken":   services.ErrOIDCEmailTaken.Error(),
+	"denied":        "Sign in was cancelled at the identity provider",
+	"failed":        "Sign in failed, try again",
+}
+
+// OIDCHandler runs single sign-on: the redirect to the provider, its
+// callback and the username step of first time users
+type OIDCHandler struct {
+	oidcService   *services.OIDCService
+	validate      *validator.Validate
+	sessionConfig config.SessionConfig
+}
+
+func NewOIDCHandler(oidcService *services.OIDCService, validate *validator.Validate, sessionConfig config.SessionConfig) *OIDCHandler {
+	return &OIDCHandler{
+		oidcService:   oidcService,
+		validate:      validate,
+		sessionConfig: sessionConfig,
+	}
+}
+
+// GET /auth/oidc/login sends the browser to the provider
+func (h *OIDCHandler) Login(w http.ResponseWriter, r *http.Request) {
+	url, state, err := h.oidcService.AuthCodeURL(r.Context())
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to start oidc sign in", "err", err)
+		h.fail(w, r, "failed")
+		return
+	}
+
+	http.SetCookie(w, helpers.FlowCookie(h.sessionConfig, oidcStateCookie, state, services.OIDCLoginTTL))
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+// GET /auth/oidc/callback is where the provider sends the browser back
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
+	query := r.URL.Query()
+
+	http.SetCookie(w, helpers.FlowCookie(h.sessionConfig, oidcStateCookie, "", -1))
+
+	if providerErr := query.Get("error"); providerErr != "" {
+		logger.Info("oidc provider refused sign in", "error", providerErr, "description", query.Get("error_description"))
+		h.fail(w, r, "denied")
+		return
+	}
+
+	// The state must come back to the browser that asked for it
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || cookie.Value == "" || cookie.Value != query.Get("state") {
+		logger.Info("oidc state mismatch")
+		h.fail(w, r, "expired")
+		return
+	}
+
+	result, err := h.oidcService.Callback(r.Context(), cookie.Value, query.Get("code"))
+
+	if err != nil {
+		metrics.SignIns.WithLabelValues("failure").Inc()
+		h.failWith(w, r, err)
+		return
+	}
+
+	if result.Session == nil {
+		http.SetCookie(w, helpers.FlowCookie(h.sessionConfig, oidcSignupCookie, result.SignupToken, services.OIDCSignupTTL))
+		http.Redirect(w, r, "/auth/oidc/username", http.StatusSeeOther)
+		return
+	}
+
+	metrics.SignIns.WithLabelValues("success").Inc()
+
+	http.SetCookie(w, helpers.SessionCookie(h.sessionConfig, result.Session.SessionID))
+
+	if result.Session.PendingTwoFactor {
+		http.Redirect(w, r, "/auth/two-factor", http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, "/rooms", http.StatusSeeOther)
+}
+
+// GET /auth/oidc/username asks a first time user for a username
+func (h *OIDCHandler) HandlePickUsernamePage(w http.ResponseWriter, r *http.Request) {
+	pending, ok := h.pendingSignup(w, r)
+	if !ok {
+		return
+	}
+
+	templates.PickUsernamePage(&params.PickUsernameParams{
+		Email:    pending.Email,
+		Username: pending.SuggestedUsername,
+	}).Render(r.Context(), w)
+}
+
+// POST /auth/oidc/username creates the account, validation errors are
+// rendered into the form since htmx doesn't swap error responses
+func (h *OIDCHandler) PickUsername(w http.ResponseWriter, r *http.Request) {
+	pending, ok := h.pendingSignup(w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	dto := &dtos.PickUsernameDto{Username: r.PostForm.Get("username")}
+
+	if err := h.validate.StructCtx(r.Context(), dto); err != nil {
+		var ve validator.ValidationErrors
+		if !errors.As(err, &ve) {
+			http.Error(w, "Validation failed", http.StatusBadRequest)
+			return
+		}
+
+		templates.PickUsernameForm(&params.PickUsernameParams{
+			Email:    pending.Email,
+			Username: dto.Username,
+			Errors:   formatter.ErrorFormatter(ve),
+		}).Render(r.Context(), w)
+		return
+	}
+
+	cookie, _ := r.Cookie(oidcSignupCookie)
+
+	session, err := h.oidcService.CompleteSignup(r.Context(), cookie.Value, dto.Username)
+
+	if err != nil {
+		metrics.SignIns.WithLabelValues("failure").Inc()
+		h.failWith(w, r, err)
+		return
+	}
+
+	metrics.SignIns.WithLabelValues("success").Inc()
+	logging.FromContext(r.Context()).Info("account created through sso", "user_id", session.User.ID)
+
+	http.SetCookie(w, helpers.FlowCookie(h.sessionConfig, oidcSignupCookie, "", -1))
+	http.SetCookie(w, helpers.SessionCookie(h.sessionConfig, session.SessionID))
+
+	w.Header().Set("HX-Redirect", "/rooms")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *OIDCHandler) pendingSignup(w http.ResponseWriter, r *http.Request) (*services.PendingSignup, bool) {
+	cookie, err := r.Cookie(oidcSignupCookie)
+	if err != nil || cookie.Value == "" {
+		h.fail(w, r, "signup")
+		return nil, false
+	}
+
+	pending, err := h.oidcService.PendingSignup(r.Context(), cookie.Value)
+	if err != nil {
+		h.failWith(w, r, err)
+		return nil, false
+	}
+
+	return pending, true
+}
+
+// Back to the landing page with the reason for err, unknown errors are logged
+func (h *OIDCHandler) failWith(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, services.ErrOIDCLoginExpired):
+		h.fail(w, r, "expired")
+	case errors.Is(err, services.ErrOIDCSignupExpired):
+		h.fail(w, r, "signup")
+	case errors.Is(err, services.ErrOIDCEmailMissing):
+		h.fail(w, r, "email_missing")
+	case errors.Is(err, services.ErrOIDCDomainNotAllowed):
+		h.fail(w, r, "domain")
+	case errors.Is(err, services.ErrOIDCEmailTaken):
+		h.fail(w, r, "email_taken")
+	default:
+		logging.FromContext(r.Context()).Error("oidc sign in failed", "err", err)
+		h.fail(w, r, "failed")
+	}
+}
+
+func (h *OIDCHandler) fail(w http.ResponseWriter, r *http.Request, reason string) {
+	url := "/?" + ssoErrorParam + "=" + reason
+
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", url)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	http.Redirect(w, r, url, http.StatusSeeOther)
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/dliluashvili/cowatchit/internal/logging"
+	"github.com/dliluashvili/cowatchit/internal/openapi"
+)
+
+// The document only changes with the binary
+var openAPISpec = sync.OnceValues(func() ([]byte, error) {
+	return json.Marshal(openapi.Spec())
+})
+
+// OpenAPISpec serves the OpenAPI document of /api/v1
+func OpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	spec, err := openAPISpec()
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to build openapi spec", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(spec)
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dliluashvili/cowatchit/internal/dtos"
+	"github.com/dliluashvili/cowatchit/internal/helpers"
+	"github.com/dliluashvili/cowatchit/internal/logging"
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/dliluashvili/cowatchit/internal/params"
+	"github.com/dliluashvili/cowatchit/internal/services"
+	"github.com/dliluashvili/cowatchit/internal/shared/constants"
+	"github.com/dliluashvili/cowatchit/internal/shared/formatter"
+	"github.com/dliluashvili/cowatchit/internal/templates"
+	"github.com/go-chi/chi"
+	"github.com/go-playground/validator/v10"
+)
+
+type ProfileHandler struct {
+	validate       *validator.Validate
+	profileService *services.ProfileService
+	userService    *services.UserService
+	sessionService *services.SessionService
+	imageService   *services.ImageService
+}
+
+func NewProfileHandler(validate *validator.Validate, ps *services.ProfileService, us *services.UserService, ss *services.SessionService, is *services.ImageService) *ProfileHandler {
+	return &ProfileHandler{
+		validate:       validate,
+		profileService: ps,
+		userService:    us,
+		sessionService: ss,
+		imageService:   is,
+	}
+}
+
+func (h *ProfileHandler) HandleEditPage(w http.ResponseWriter, r *http.Request) {
+	session := r.Context().Value(constants.SessionContextKey).(*models.Session)
+
+	user, err := h.userService.Me(r.Context(), session.User.ID)
+
+	if err != nil || user == nil {
+		logging.FromContext(r.Context()).Error("failed to fetch authed user", "err", err)
+		helpers.SendJson(w, &helpers.Response{
+			Message: "Unable to load profile",
+			Status:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	editParams, ok := h.editParams(w, r, user)
+	if !ok {
+		return
+	}
+
+	if r.Header.Get("HX-Request") == "true" {
+		templates.EditProfile(editParams).Render(r.Context(), w)
+		return
+	}
+
+	templates.EditProfilePage(editParams).Render(r.Context(), w)
+}
+
+// Update handles the htmx form, errors are rendered into the form since
+// htmx doesn't swap error responses
+func (h *ProfileHandler) Update(w http.ResponseWriter, r *http.Request) {
+	session := r.Context().Value(constants.SessionContextKey).(*models.Session)
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	dto := &dtos.UpdateProfileDto{
+		Username:    r.PostForm.Get("username"),
+		DisplayName: r.PostForm.Get("display_name"),
+		Bio:         r.PostForm.Get("bio"),
+		Gender:      r.PostForm.Get("gender"),
+		CountryID:   r.PostForm.Get("country_id"),
+	}
+
+	formErrors := map[string][]string{}
+
+	if err := h.validate.StructCtx(r.Context(), dto); err != nil {
+		var ve validator.ValidationErrors
+		if !errors.As(err, &ve) {
+			http.Error(w, "Validation failed", http.StatusBadRequest)
+			return
+		}
+
+		formErrors = formatter.ErrorFormatter(ve)
+	}
+
+	var user *models.User
+
+	if len(formErrors) == 0 {
+		updated, err := h.profileService.Update(r.Context(), session.User.ID, dto)
+
+		switch {
+		case errors.Is(err, services.ErrUsernameTaken):
+			formErrors["username"] = []string{err.Error()}
+		case errors.Is(err, services.ErrCountryNotFound):
+			formErrors["country_id"] = []string{err.Error()}
+		case err != nil:
+			logging.FromContext(r.Context()).Error("failed to update profile", "err", err)
+			helpers.SendJson(w, &helpers.Response{
+				Message: "Unable to update profile",
+				Status:  http.StatusInternalServerError,
+			})
+			return
+		default:
+			user = updated
+		}
+	}
+
+	if user != nil {
+		// Chat and room pages read the username from the session
+		if err := h.sessionService.RefreshUser(r.Context(), session.SessionID, user); err != nil {
+			logging.FromContext(r.Context()).Warn("failed to refresh session user", "err", err)
+		}
+
+		logging.FromContext(r.Context()).Info("profile updated")
+	} else {
+		// Show what was submitted next to its errors
+		user = &models.User{
+			Username:    dto.Username,
+			DisplayName: dto.DisplayName,
+			Bio:         dto.Bio,
+			Avatar:      session.User.Avatar,
+			Gender:      dto.Gender,
+		}
+	}
+
+	editParams, ok := h.editParams(w, r, user)
+	if !ok {
+		return
+	}
+
+	if len(formErrors) > 0 {
+		editParams.CountryID = dto.CountryID
+	}
+
+	editParams.Saved = len(formErrors) == 0
+	editParams.Errors = formErrors
+
+	templates.ProfileForm(editParams).Render(r.Context(), w)
+}
+
+// UploadAvatar replaces the avatar with the upload and re-renders the form
+func (h *ProfileHandler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
+	session := r.Context().Value(constants.SessionContextKey).(*models.Session)
+
+	file, err := formImage(w, r, "avatar_file", h.imageService.MaxUploadBytes())
+
+	var user *models.User
+
+	if err == nil {
+		defer file.Close()
+		user, err = h.profileService.SetAvatar(r.Context(), session.User.ID, file)
+	}
+
+	formErrors := map[string][]string{}
+
+	if message, ok := imageErrorMessage(err); ok {
+		formErrors["avatar_file"] = []string{message}
+	} else if err != nil {
+		logging.FromContext(r.Context()).Error("failed to upload avatar", "err", err)
+		helpers.SendJson(w, &helpers.Response{
+			Message: "Unable to upload avatar",
+			Status:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	if user == nil {
+		user, err = h.userService.Me(r.Context(), session.User.ID)
+
+		if err != nil || user == nil {
+			logging.FromContext(r.Context()).Error("failed to fetch authed user", "err", err)
+			helpers.SendJson(w, &helpers.Response{
+				Message: "Unable to load profile",
+				Status:  http.StatusInternalServerError,
+			})
+			return
+		}
+	}
+
+	editParams, ok := h.editParams(w, r, user)
+	if !ok {
+		return
+	}
+
+	editParams.Saved = len(formErrors) == 0
+	editParams.Errors = formErrors
+
+	templates.ProfileForm(editParams).Render(r.Context(), w)
+}
+
+func (h *ProfileHandler) HandlePublicPage(w http.ResponseWriter, r *http.Request) {
+	session := r.Context().Value(constants.SessionContextKey).(*models.Session)
+
+	profile, err := h.profileService.Public(r.Context(), chi.URLParam(r, "username"), session.User.ID)
+
+	if errors.Is(err, services.ErrUserNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to load profile", "err", err)
+		helpers.SendJson(w, &helpers.Response{
+			Message: "Unable to load profile",
+			Status:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	profileParams := &params.ProfileParams{
+		User:        profile.User,
+		Country:     profile.Country,
+		HostedRooms: profile.HostedRooms,
+		SharedRooms: profile.SharedRooms,
+		Own:         profile.User.ID == session.User.ID,
+	}
+
+	if r.Header.Get("HX-Request") == "true" {
+		templates.PublicProfile(profileParams).Render(r.Context(), w)
+		return
+	}
+
+	templates.PublicProfilePage(profileParams).Render(r.Context(), w)
+}
+
+func (h *ProfileHandler) editParams(w http.ResponseWriter, r *http.Request, user *models.User) (*params.EditProfileParams, bool) {
+	countries, err := h.profileService.Countries(r.Context())
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to list countries", "err", err)
+		helpers.SendJson(w, &helpers.Response{
+			Message: "Unable to list countries",
+			Status:  http.StatusInternalServerError,
+		})
+		return nil, false
+	}
+
+	editParams := &params.EditProfileParams{
+		User:      user,
+		Countries: countries,
+	}
+
+	if user.CountryID != nil {
+		editParams.CountryID = user.CountryID.String()
+	}
+
+	return editParams, true
+}
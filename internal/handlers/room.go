@@ -1,11 +1,13 @@
 package handlers
 
 import (
-	"fmt"
+	"encoding/base64"
+	"errors"
 	"net/http"
 
 	"github.com/dliluashvili/cowatchit/internal/dtos"
 	"github.com/dliluashvili/cowatchit/internal/helpers"
+	"github.com/dliluashvili/cowatchit/internal/logging"
 	"github.com/dliluashvili/cowatchit/internal/models"
 	"github.com/dliluashvili/cowatchit/internal/params"
 	"github.com/dliluashvili/cowatchit/internal/services"
@@ -16,12 +18,16 @@ import (
 )
 
 type Roomhandler struct {
-	roomService *services.RoomService
+	roomService    *services.RoomService
+	imageService   *services.ImageService
+	libraryService *services.LibraryService
 }
 
-func NewRoomHandler(rs *services.RoomService) *Roomhandler {
+func NewRoomHandler(rs *services.RoomService, is *services.ImageService, ls *services.LibraryService) *Roomhandler {
 	return &Roomhandler{
-		roomService: rs,
+		roomService:    rs,
+		imageService:   is,
+		libraryService: ls,
 	}
 }
 
@@ -43,7 +49,7 @@ func (rh *Roomhandler) HandleRoomsPage(w http.ResponseWriter, r *http.Request) {
 		AuthUserID: &userID,
 	}
 
-	rooms, err := rh.roomService.Find(findRoomDto)
+	rooms, err := rh.roomService.Find(r.Context(), findRoomDto)
 
 	if err != nil {
 
@@ -68,10 +74,26 @@ func (rh *Roomhandler) HandleRoomsPage(w http.ResponseWriter, r *http.Request) {
 }
 
 func (rh *Roomhandler) HandleCreateRoomPage(w http.ResponseWriter, r *http.Request) {
+	session := r.Context().Value(constants.SessionContextKey).(*models.Session)
+
+	items, err := rh.libraryService.List(r.Context(), session.User.ID)
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to list library", "err", err)
+	}
+
+	var videos []models.LibraryItem
+
+	for _, item := range items {
+		if item.Ready() {
+			videos = append(videos, item)
+		}
+	}
+
 	if r.Header.Get("HX-Request") == "true" {
-		templates.CreateRoom().Render(r.Context(), w)
+		templates.CreateRoom(videos).Render(r.Context(), w)
 	} else {
-		templates.CreateRoomPage().Render(r.Context(), w)
+		templates.CreateRoomPage(videos).Render(r.Context(), w)
 	}
 }
 
@@ -87,10 +109,28 @@ func (rh *Roomhandler) Create(w http.ResponseWriter, r *http.Request) {
 		CreateRoomDto: validated,
 	}
 
-	_, err := rh.roomService.Create(r.Context(), createRoomServiceDto)
+	room, err := rh.roomService.Create(r.Context(), createRoomServiceDto)
+
+	if errors.Is(err, services.ErrRoomSourceRequired) {
+		helpers.SendJson(w, &helpers.Response{
+			Data:    map[string][]string{"src": {err.Error()}},
+			Message: "invalid source",
+			Status:  http.StatusUnprocessableEntity,
+		})
+		return
+	}
+
+	if errors.Is(err, services.ErrLibraryItemNotFound) || errors.Is(err, services.ErrLibraryItemNotReady) {
+		helpers.SendJson(w, &helpers.Response{
+			Data:    map[string][]string{"library_item_id": {err.Error()}},
+			Message: "invalid video",
+			Status:  http.StatusUnprocessableEntity,
+		})
+		return
+	}
 
 	if err != nil {
-		fmt.Println("roomHandler@Create", err)
+		logging.FromContext(r.Context()).Error("failed to create room", "err", err)
 		helpers.SendJson(w, &helpers.Response{
 			Data: map[string]bool{
 				"success": false,
@@ -102,9 +142,114 @@ func (rh *Roomhandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The poster is uploaded to the new room's id afterwards
+	helpers.SendJson(w, &helpers.Response{
+		Data: map[string]any{
+			"success": true,
+			"id":      room.ID,
+		},
+		Message: "all good",
+		Status:  http.StatusOK,
+	})
+}
+
+// PreviewPoster returns the upload as it would be stored, as a fragment for
+// htmx and as the WebP itself otherwise
+func (rh *Roomhandler) PreviewPoster(w http.ResponseWriter, r *http.Request) {
+	htmx := r.Header.Get("HX-Request") == "true"
+
+	var data []byte
+
+	file, err := formImage(w, r, "poster", rh.imageService.MaxUploadBytes())
+
+	if err == nil {
+		defer file.Close()
+		data, err = rh.imageService.Process(file, services.PosterImage)
+	}
+
+	if err != nil {
+		message, ok := imageErrorMessage(err)
+
+		if !ok {
+			logging.FromContext(r.Context()).Error("failed to preview poster", "err", err)
+			message = "Unable to process the image"
+		}
+
+		if htmx {
+			templates.PosterPreview("", message).Render(r.Context(), w)
+			return
+		}
+
+		http.Error(w, message, http.StatusUnprocessableEntity)
+		return
+	}
+
+	if htmx {
+		templates.PosterPreview("data:image/webp;base64,"+base64.StdEncoding.EncodeToString(data), "").Render(r.Context(), w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/webp")
+	w.Write(data)
+}
+
+func (rh *Roomhandler) UploadPoster(w http.ResponseWriter, r *http.Request) {
+	session := r.Context().Value(constants.SessionContextKey).(*models.Session)
+
+	ID, err := uuid.Parse(chi.URLParam(r, "id"))
+
+	if err != nil {
+		helpers.SendJson(w, &helpers.Response{
+			Message: "bad request",
+			Status:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	file, err := formImage(w, r, "poster", rh.imageService.MaxUploadBytes())
+
+	var room *models.Room
+
+	if err == nil {
+		defer file.Close()
+		room, err = rh.roomService.SetPoster(r.Context(), session.User, ID, file)
+	}
+
+	if message, ok := imageErrorMessage(err); ok {
+		helpers.SendJson(w, &helpers.Response{
+			Data:    map[string][]string{"poster": {message}},
+			Message: "invalid poster",
+			Status:  http.StatusUnprocessableEntity,
+		})
+		return
+	}
+
+	switch {
+	case errors.Is(err, services.ErrRoomNotFound):
+		helpers.SendJson(w, &helpers.Response{
+			Message: err.Error(),
+			Status:  http.StatusNotFound,
+		})
+		return
+	case errors.Is(err, services.ErrNotRoomHost):
+		helpers.SendJson(w, &helpers.Response{
+			Message: err.Error(),
+			Status:  http.StatusForbidden,
+		})
+		return
+	case err != nil:
+		logging.FromContext(r.Context()).Error("failed to upload poster", "room_id", ID, "err", err)
+		helpers.SendJson(w, &helpers.Response{
+			Message: "Unable to upload poster",
+			Status:  http.StatusInternalServerError,
+		})
+		return
+	}
+
 	helpers.SendJson(w, &helpers.Response{
-		Data: map[string]bool{
+		Data: map[string]any{
 			"success": true,
+			"poster":  room.Poster,
 		},
 		Message: "all good",
 		Status:  http.StatusOK,
@@ -117,7 +262,7 @@ func (rh *Roomhandler) HandleRoomJoinPage(w http.ResponseWriter, r *http.Request
 	ID, err := uuid.Parse(idStr)
 
 	if err != nil {
-		fmt.Println("err", err)
+		logging.FromContext(r.Context()).Info("room lookup failed", "room_id", idStr, "err", err)
 		helpers.SendJson(w, &helpers.Response{
 			Data:    nil,
 			Message: "bad request",
@@ -127,10 +272,10 @@ func (rh *Roomhandler) HandleRoomJoinPage(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	room, err := rh.roomService.FindOne(ID)
+	room, err := rh.roomService.FindOne(r.Context(), ID)
 
 	if err != nil {
-		fmt.Println("err", err)
+		logging.FromContext(r.Context()).Info("room lookup failed", "room_id", idStr, "err", err)
 
 		helpers.SendJson(w, &helpers.Response{
 			Data:    nil,
@@ -155,7 +300,7 @@ func (rh *Roomhandler) HandleRoomPage(w http.ResponseWriter, r *http.Request) {
 	ID, err := uuid.Parse(idStr)
 
 	if err != nil {
-		fmt.Println("err", err)
+		logging.FromContext(r.Context()).Info("room lookup failed", "room_id", idStr, "err", err)
 		helpers.SendJson(w, &helpers.Response{
 			Data:    nil,
 			Message: "bad request",
@@ -164,10 +309,10 @@ func (rh *Roomhandler) HandleRoomPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	exists, err := rh.roomService.Exists(ID)
+	exists, err := rh.roomService.Exists(r.Context(), ID)
 
 	if err != nil || !exists {
-		fmt.Println("err", err)
+		logging.FromContext(r.Context()).Info("room lookup failed", "room_id", idStr, "err", err)
 		helpers.SendJson(w, &helpers.Response{
 			Data:    nil,
 			Message: "bad request",
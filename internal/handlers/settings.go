@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dliluashvili/cowatchit/internal/dtos"
+	"github.com/dliluashvili/cowatchit/internal/helpers"
+	"github.com/dliluashvili/cowatchit/internal/logging"
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/dliluashvili/cowatchit/internal/params"
+	"github.com/dliluashvili/cowatchit/internal/services"
+	"github.com/dliluashvili/cowatchit/internal/shared/constants"
+	"github.com/dliluashvili/cowatchit/internal/shared/formatter"
+	"github.com/dliluashvili/cowatchit/internal/templates"
+	"github.com/go-chi/chi"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+type SettingsHandler struct {
+	validate         *validator.Validate
+	apiTokenService  *services.APITokenService
+	twoFactorService *services.TwoFactorService
+}
+
+func NewSettingsHandler(validate *validator.Validate, ats *services.APITokenService, tfs *services.TwoFactorService) *SettingsHandler {
+	return &SettingsHandler{
+		validate:         validate,
+		apiTokenService:  ats,
+		twoFactorService: tfs,
+	}
+}
+
+func (h *SettingsHandler) HandleSettingsPage(w http.ResponseWriter, r *http.Request) {
+	tokensParams, ok := h.tokensParams(w, r)
+	if !ok {
+		return
+	}
+
+	twoFactorParams, ok := h.twoFactorParams(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Header.Get("HX-Request") == "true" {
+		templates.Settings(tokensParams, twoFactorParams).Render(r.Context(), w)
+		return
+	}
+
+	templates.SettingsPage(tokensParams, twoFactorParams).Render(r.Context(), w)
+}
+
+// CreateAPIToken handles the htmx form, validation errors are rendered
+// into the form since htmx doesn't swap error responses
+func (h *SettingsHandler) CreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	session := r.Context().Value(constants.SessionContextKey).(*models.Session)
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	dto := &dtos.CreateAPITokenDto{
+		Name:   r.PostForm.Get("name"),
+		Scopes: r.PostForm["scopes"],
+	}
+
+	formErrors := map[string][]string{}
+
+	if value := r.PostForm.Get("expires_in_days"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil {
+			formErrors["expires_in_days"] = []string{"expires_in_days is invalid"}
+		}
+		dto.ExpiresInDays = days
+	}
+
+	if err := h.validate.StructCtx(r.Context(), dto); err != nil {
+		var ve validator.ValidationErrors
+		if !errors.As(err, &ve) {
+			http.Error(w, "Validation failed", http.StatusBadRequest)
+			return
+		}
+
+		for field, messages := range formatter.ErrorFormatter(ve) {
+			formErrors[field] = append(formErrors[field], messages...)
+		}
+	}
+
+	newToken := ""
+
+	if len(formErrors) == 0 {
+		token, secret, err := h.apiTokenService.Create(r.Context(), session.User.ID, dto)
+
+		if err != nil {
+			logging.FromContext(r.Context()).Error("failed to create api token", "err", err)
+			helpers.SendJson(w, &helpers.Response{
+				Message: "Unable to create API token",
+				Status:  http.StatusInternalServerError,
+			})
+			return
+		}
+
+		logging.FromContext(r.Context()).Info("api token created", "api_token_id", token.ID, "scopes", token.Scopes)
+		newToken = secret
+	}
+
+	tokensParams, ok := h.tokensParams(w, r)
+	if !ok {
+		return
+	}
+
+	tokensParams.NewToken = newToken
+	tokensParams.Errors = formErrors
+
+	templates.APITokens(tokensParams).Render(r.Context(), w)
+}
+
+func (h *SettingsHandler) RevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	session := r.Context().Value(constants.SessionContextKey).(*models.Session)
+
+	ID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.SendJson(w, &helpers.Response{
+			Message: "bad request",
+			Status:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	if err := h.apiTokenService.Revoke(r.Context(), session.User.ID, ID); err != nil {
+		if errors.Is(err, services.ErrAPITokenNotFound) {
+			helpers.SendJson(w, &helpers.Response{
+				Message: "API token not found",
+				Status:  http.StatusNotFound,
+			})
+			return
+		}
+
+		logging.FromContext(r.Context()).Error("failed to revoke api token", "api_token_id", ID, "err", err)
+		helpers.SendJson(w, &helpers.Response{
+			Message: "Unable to revoke API token",
+			Status:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("api token revoked", "api_token_id", ID)
+
+	tokensParams, ok := h.tokensParams(w, r)
+	if !ok {
+		return
+	}
+
+	templates.APITokens(tokensParams).Render(r.Context(), w)
+}
+
+func (h *SettingsHandler) tokensParams(w http.ResponseWriter, r *http.Request) (*params.APITokensParams, bool) {
+	session := r.Context().Value(constants.SessionContextKey).(*models.Session)
+
+	tokens, err := h.apiTokenService.List(r.Context(), session.User.ID)
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to list api tokens", "err", err)
+		helpers.SendJson(w, &helpers.Response{
+			Message: "Unable to list API tokens",
+			Status:  http.StatusInternalServerError,
+		})
+		return nil, false
+	}
+
+	return &params.APITokensParams{Tokens: tokens}, true
+}
+
+// EnrollTwoFactor shows the QR code of a new secret
+func (h *SettingsHandler) EnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	session := r.Context().Value(constants.SessionContextKey).(*models.Session)
+
+	enrollment, err := h.twoFactorService.Enroll(r.Context(), session.User.ID)
+
+	if err != nil && !errors.Is(err, services.ErrTwoFactorEnabled) {
+		logging.FromContext(r.Context()).Error("failed to start two-factor enrollment", "err", err)
+		helpers.SendJson(w, &helpers.Response{
+			Message: "Unable to set up two-factor authentication",
+			Status:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	twoFactorParams, ok := h.twoFactorParams(w, r)
+	if !ok {
+		return
+	}
+
+	setEnrollment(twoFactorParams, enrollment)
+
+	templates.TwoFactor(twoFactorParams).Render(r.Context(), w)
+}
+
+// ConfirmTwoFactor turns two-factor on with a first code and shows the
+// recovery codes, after a wrong code the QR code stays up
+func (h *SettingsHandler) ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	session := r.Context().Value(constants.SessionContextKey).(*models.Session)
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	codes, err := h.twoFactorService.ConfirmEnrollment(r.Context(), session.User.ID, r.PostForm.Get("code"))
+
+	message, ok := h.twoFactorError(w, r, err)
+	if !ok {
+		return
+	}
+
+	if message == "" {
+		logging.FromContext(r.Context()).Info("two-factor enabled")
+	}
+
+	twoFactorParams, ok := h.twoFactorParams(w, r)
+	if !ok {
+		return
+	}
+
+	twoFactorParams.RecoveryCodes = codes
+	twoFactorParams.Error = message
+
+	if !twoFactorParams.Enabled {
+		enrollment, err := h.twoFactorService.PendingEnrollment(r.Context(), session.User.ID)
+		if err != nil && !errors.Is(err, services.ErrTwoFactorEnrollmentExpired) {
+			logging.FromContext(r.Context()).Error("failed to load two-factor enrollment", "err", err)
+		}
+		setEnrollment(twoFactorParams, enrollment)
+	}
+
+	templates.TwoFactor(twoFactorParams).Render(r.Context(), w)
+}
+
+func (h *SettingsHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	session := r.Context().Value(constants.SessionContextKey).(*models.Session)
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	err := h.twoFactorService.Disable(r.Context(), session.User.ID, r.PostForm.Get("code"))
+
+	message, ok := h.twoFactorError(w, r, err)
+	if !ok {
+		return
+	}
+
+	if message == "" {
+		logging.FromContext(r.Context()).Info("two-factor disabled")
+	}
+
+	twoFactorParams, ok := h.twoFactorParams(w, r)
+	if !ok {
+		return
+	}
+
+	twoFactorParams.Error = message
+
+	templates.TwoFactor(twoFactorParams).Render(r.Context(), w)
+}
+
+// The message to render for a two-factor error the user can act on,
+// anything else is answered as internal
+func (h *SettingsHandler) twoFactorError(w http.ResponseWriter, r *http.Request, err error) (string, bool) {
+	switch {
+	case err == nil:
+		return "", true
+	case errors.Is(err, services.ErrTwoFactorCodeInvalid),
+		errors.Is(err, services.ErrTwoFactorEnrollmentExpired),
+		errors.Is(err, services.ErrTwoFactorEnabled),
+		errors.Is(err, services.ErrTwoFactorNotEnabled):
+		return err.Error(), true
+	}
+
+	logging.FromContext(r.Context()).Error("two-factor settings failed", "err", err)
+	helpers.SendJson(w, &helpers.Response{
+		Message: "Unable to change two-factor authentication",
+		Status:  http.StatusInternalServerError,
+	})
+
+	return "", false
+}
+
+func setEnrollment(twoFactorParams *params.TwoFactorParams, enrollment *services.TwoFactorEnrollment) {
+	if enrollment == nil {
+		return
+	}
+
+	twoFactorParams.Secret = enrollment.Secret
+	twoFactorParams.QRCode = "data:image/png;base64," + base64.StdEncoding.EncodeToString(enrollment.QRCode)
+}
+
+func (h *SettingsHandler) twoFactorParams(w http.ResponseWriter, r *http.Request) (*params.TwoFactorParams, bool) {
+	session := r.Context().Value(constants.SessionContextKey).(*models.Session)
+
+	enabled, left, err := h.twoFactorService.Status(r.Context(), session.User.ID)
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to load two-factor status", "err", err)
+		helpers.SendJson(w, &helpers.Response{
+			Message: "Unable to load two-factor status",
+			Status:  http.StatusInternalServerError,
+		})
+		return nil, false
+	}
+
+	return &params.TwoFactorParams{Enabled: enabled, RecoveryCodesLeft: left}, true
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dliluashvili/cowatchit/internal/config"
+	"github.com/dliluashvili/cowatchit/internal/helpers"
+	"github.com/dliluashvili/cowatchit/internal/logging"
+	"github.com/dliluashvili/cowatchit/internal/params"
+	"github.com/dliluashvili/cowatchit/internal/services"
+	"github.com/dliluashvili/cowatchit/internal/templates"
+)
+
+// TwoFactorHandler asks for the second factor after a password or single
+// sign-on, the session cookie holds the pending session until then
+type TwoFactorHandler struct {
+	sessionService   *services.SessionService
+	twoFactorService *services.TwoFactorService
+	sessionConfig    config.SessionConfig
+}
+
+func NewTwoFactorHandler(ss *services.SessionService, tfs *services.TwoFactorService, sessionConfig config.SessionConfig) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		sessionService:   ss,
+		twoFactorService: tfs,
+		sessionConfig:    sessionConfig,
+	}
+}
+
+// GET /auth/two-factor
+func (h *TwoFactorHandler) HandleVerifyPage(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(h.sessionConfig.SessionCookieName())
+	if err != nil {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	if _, err := h.sessionService.GetPending(r.Context(), cookie.Value); err != nil {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	templates.VerifyTwoFactorPage(&params.VerifyTwoFactorParams{}).Render(r.Context(), w)
+}
+
+// POST /auth/two-factor swaps the pending session for a full one, a wrong
+// code is rendered into the form since htmx doesn't swap error responses
+func (h *TwoFactorHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	sessionID := ""
+	if cookie, err := r.Cookie(h.sessionConfig.SessionCookieName()); err == nil {
+		sessionID = cookie.Value
+	}
+
+	session, err := h.twoFactorService.Verify(r.Context(), sessionID, r.PostForm.Get("code"))
+
+	switch {
+	case errors.Is(err, services.ErrTwoFactorCodeInvalid):
+		templates.VerifyTwoFactorForm(&params.VerifyTwoFactorParams{Error: err.Error()}).Render(r.Context(), w)
+		return
+	case errors.Is(err, services.ErrTwoFactorSessionInvalid):
+		http.SetCookie(w, helpers.ExpiredSessionCookie(h.sessionConfig))
+		w.Header().Set("HX-Redirect", "/")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case err != nil:
+		logging.FromContext(r.Context()).Error("two-factor verification failed", "err", err)
+		templates.VerifyTwoFactorForm(&params.VerifyTwoFactorParams{Error: "Something went wrong, try again"}).Render(r.Context(), w)
+		return
+	}
+
+	http.SetCookie(w, helpers.SessionCookie(h.sessionConfig, session.SessionID))
+
+	w.Header().Set("HX-Redirect", "/rooms")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"errors"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/dliluashvili/cowatchit/internal/services"
+)
+
+// Room for the other fields and the multipart framing around the file
+const multipartOverhead = 1 << 20
+
+// formImage opens the image uploaded as field, refusing bodies past maxBytes
+// before they're read to the end
+func formImage(w http.ResponseWriter, r *http.Request, field string, maxBytes int64) (multipart.File, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+multipartOverhead)
+
+	file, _, err := r.FormFile(field)
+
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return nil, services.ErrImageTooLarge
+	case err != nil:
+		return nil, errImageMissing
+	}
+
+	return file, nil
+}
+
+var errImageMissing = errors.New("choose an image to upload")
+
+// imageErrorMessage is what the uploader is shown, false for failures that
+// aren't theirs
+func imageErrorMessage(err error) (string, bool) {
+	switch {
+	case errors.Is(err, errImageMissing),
+		errors.Is(err, services.ErrImageTooLarge),
+		errors.Is(err, services.ErrImageType),
+		errors.Is(err, services.ErrImageInvalid):
+		return err.Error(), true
+	}
+
+	return "", false
+}
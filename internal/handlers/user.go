@@ -1,10 +1,10 @@
 package handlers
 
 import (
-	"fmt"
 	"net/http"
 
 	"github.com/dliluashvili/cowatchit/internal/helpers"
+	"github.com/dliluashvili/cowatchit/internal/logging"
 	"github.com/dliluashvili/cowatchit/internal/models"
 	"github.com/dliluashvili/cowatchit/internal/services"
 	"github.com/dliluashvili/cowatchit/internal/shared/constants"
@@ -23,10 +23,10 @@ func NewUserHandler(us *services.UserService) *UserHandler {
 func (h *UserHandler) Me(w http.ResponseWriter, r *http.Request) {
 	session := r.Context().Value(constants.SessionContextKey).(*models.Session)
 
-	user, err := h.userService.Me(session.User.ID)
+	user, err := h.userService.Me(r.Context(), session.User.ID)
 
 	if err != nil {
-		fmt.Println("Error /me", err)
+		logging.FromContext(r.Context()).Error("failed to fetch authed user", "err", err)
 		helpers.SendJson(w, &helpers.Response{
 			Data:    nil,
 			Message: "Unable to fetch authed user",
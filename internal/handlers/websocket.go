@@ -2,653 +2,430 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"log"
+	"errors"
 	"net/http"
 
 	"github.com/coder/websocket"
-	"github.com/dliluashvili/cowatchit/internal/dtos"
 	"github.com/dliluashvili/cowatchit/internal/helpers"
+	"github.com/dliluashvili/cowatchit/internal/logging"
+	"github.com/dliluashvili/cowatchit/internal/metrics"
+	"github.com/dliluashvili/cowatchit/internal/middlewares"
 	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/dliluashvili/cowatchit/internal/protocol"
 	"github.com/dliluashvili/cowatchit/internal/services"
+	"github.com/dliluashvili/cowatchit/internal/tracing"
 	"github.com/dliluashvili/cowatchit/internal/types"
+	"github.com/dliluashvili/cowatchit/internal/wsrouter"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type WebSocketHandler struct {
 	validate                *validator.Validate
 	websocketManagerService *services.WebSocketManagerService
 	sessionService          *services.SessionService
+	apiTokenService         *services.APITokenService
 	roomService             *services.RoomService
 	roomMessageService      *services.RoomMessageService
+	allowedOrigins          []string
+	router                  *wsrouter.Router
 }
 
 func NewWebSocketHandler(
 	validate *validator.Validate,
 	websocketManagerService *services.WebSocketManagerService,
 	sessionService *services.SessionService,
+	apiTokenService *services.APITokenService,
 	roomService *services.RoomService,
 	roomMessageService *services.RoomMessageService,
+	allowedOrigins []string,
 ) *WebSocketHandler {
-	return &WebSocketHandler{
+	h := &WebSocketHandler{
 		validate:                validate,
 		websocketManagerService: websocketManagerService,
 		sessionService:          sessionService,
+		apiTokenService:         apiTokenService,
 		roomService:             roomService,
 		roomMessageService:      roomMessageService,
+		allowedOrigins:          allowedOrigins,
 	}
+
+	h.router = wsrouter.New(func(req *wsrouter.Request, msg *protocol.Message) error {
+		return h.send(req.Context, req.Socket, msg)
+	})
+
+	h.router.Use(
+		wsrouter.Tracing(),
+		wsrouter.Recoverer(),
+		wsrouter.Logger(),
+		wsrouter.Metrics(),
+		wsrouter.RateLimit(),
+		wsrouter.Validate(validate),
+		wsrouter.Authorize(websocketManagerService),
+	)
+
+	h.registerRoutes()
+
+	return h
+}
+
+// Every event the socket accepts, handlers live next to their feature
+func (h *WebSocketHandler) registerRoutes() {
+	wsrouter.On(h.router, protocol.EventUserJoinRequest, wsrouter.Options{
+		Access: wsrouter.AccessAny,
+		Scope:  models.ScopeRoomsRead,
+		Rate:   1,
+		Burst:  3,
+	}, h.handleRoomJoin)
+
+	wsrouter.On(h.router, protocol.EventResume, wsrouter.Options{
+		Access: wsrouter.AccessAny,
+		Scope:  models.ScopeRoomsRead,
+		Rate:   1,
+		Burst:  3,
+	}, h.handleResume)
+
+	wsrouter.On(h.router, protocol.EventUserLeaveRequest, wsrouter.Options{
+		Access: wsrouter.AccessMember,
+		Rate:   1,
+		Burst:  3,
+	}, h.handleRoomLeave)
+
+	wsrouter.On(h.router, protocol.EventChatMessageSend, wsrouter.Options{
+		Access: wsrouter.AccessMember,
+		Scope:  models.ScopeChatWrite,
+		Rate:   2,
+		Burst:  5,
+	}, h.handleRoomMessage)
+
+	wsrouter.On(h.router, protocol.EventRoomMessagesRequest, wsrouter.Options{
+		Access: wsrouter.AccessMember,
+		Scope:  models.ScopeChatRead,
+		Rate:   1,
+		Burst:  3,
+	}, h.handleRoomMessages)
+
+	wsrouter.On(h.router, protocol.EventHostStateSend, wsrouter.Options{
+		Access: wsrouter.AccessHost,
+		Scope:  models.ScopeRoomsWrite,
+		Rate:   10,
+		Burst:  20,
+	}, h.handleHostStateChange)
+
+	wsrouter.On(h.router, protocol.EventMediaLinkRequest, wsrouter.Options{
+		Access: wsrouter.AccessMember,
+		Scope:  models.ScopeRoomsRead,
+		Rate:   1,
+		Burst:  3,
+	}, h.handleMediaLink)
 }
 
 func (h *WebSocketHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	// Clients retry and land on another instance
+	if h.websocketManagerService.IsDraining() {
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, "server is restarting", http.StatusServiceUnavailable)
+		return
+	}
+
+	// The page's own host is always allowed, cookies would otherwise let any site join as the user
 	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
-		OriginPatterns: []string{"*"},
+		OriginPatterns: h.allowedOrigins,
 	})
 
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+
 	if err != nil {
-		log.Println("WebSocket accept error:", err)
+		logger.Warn("websocket accept failed", "err", err)
 		return
 	}
 
 	defer conn.Close(websocket.StatusInternalError, "internal error")
 
-	ctx := r.Context()
+	// Covers the handshake only, events get their own traces linked to this one
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(r.Header))
+	ctx, span := tracing.Tracer.Start(ctx, "ws connect", trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+
+	protocolVersion, err := protocol.Negotiate(r.URL.Query().Get(protocol.VersionQueryParam))
+	if err != nil {
+		logger.Warn("protocol negotiation failed", "err", err)
+		conn.Close(websocket.StatusPolicyViolation, "unsupported protocol version")
+		return
+	}
 
 	// Authenticate session
 	sessionModel, err := h.authenticateSession(r)
 	if err != nil {
-		log.Println("Authentication error:", err)
+		logger.Warn("websocket authentication failed", "err", err)
 		conn.Close(websocket.StatusPolicyViolation, "authentication failed")
 		return
 	}
 
-	log.Println("User authenticated:", sessionModel.User.Username)
+	logger = logger.With("user_id", sessionModel.User.ID, "username", sessionModel.User.Username)
+	ctx = logging.WithLogger(ctx, logger)
 
 	// Create WebSocket context
 	socketID := helpers.GenerateSocketID()
 
 	wsCtx := &types.WebSocketContext{
-		ID:     socketID,
-		User:   sessionModel.User,
-		RoomID: uuid.Nil, // Will be set when user joins a room
-		Conn:   conn,
+		ID:              socketID,
+		User:            sessionModel.User,
+		RoomID:          uuid.Nil, // Will be set when user joins a room
+		Conn:            conn,
+		ProtocolVersion: protocolVersion,
+		Logger:          logger,
 	}
 
-	// Send socketId to client immediately (no need to wait for IDENTIFY request)
-	identifyData := struct {
-		SocketID     string    `json:"socket_id"`
-		AuthID       uuid.UUID `json:"auth_id"`
-		AuthUsername string    `json:"auth_username"`
-	}{
-		SocketID:     socketID,
-		AuthID:       sessionModel.User.ID,
-		AuthUsername: sessionModel.User.Username,
-	}
+	wsCtx.Log().Info("websocket connected", "protocol_version", protocolVersion)
+
+	span.SetAttributes(
+		attribute.String("ws.socket_id", socketID),
+		attribute.String("enduser.id", sessionModel.User.ID.String()),
+		attribute.Int("ws.protocol_version", protocolVersion),
+	)
+
+	// All writes to this connection go through its writer.
+	// wsCtx.ID changes if the connection resumes an earlier socket.
+	writer := h.websocketManagerService.Attach(socketID, conn)
+
+	// Why the message loop ended, nil if it never started
+	var readErr error
+	defer func() {
+		h.disconnect(ctx, sessionModel, wsCtx, readErr)
+	}()
 
-	rawData, _ := json.Marshal(identifyData)
+	// The request context outlives a hijacked connection, so tie the socket's
+	// context to its writer: once the connection fails or is reaped, queries
+	// started by its events are cancelled
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-writer.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
 
-	identifyMsg := types.WSMessage{
-		Type:  types.TypeEvent,
-		Event: types.EventIdentify,
-		Data:  rawData,
+	resumeToken, err := h.websocketManagerService.IssueResumeToken(socketID)
+	if err != nil {
+		wsCtx.Log().Error("failed to issue resume token", "err", err)
+		return
 	}
 
-	data, _ := json.Marshal(identifyMsg)
+	// Send socketId to client immediately (no need to wait for IDENTIFY request)
+	identifyMsg, err := protocol.NewEvent(protocol.EventIdentify, protocol.Identify{
+		SocketID:          socketID,
+		AuthID:            sessionModel.User.ID,
+		AuthUsername:      sessionModel.User.Username,
+		ResumeToken:       resumeToken,
+		ProtocolVersion:   protocolVersion,
+		SupportedVersions: protocol.SupportedVersions,
+	})
+
+	if err != nil {
+		wsCtx.Log().Error("failed to build identify", "err", err)
+		return
+	}
 
-	if err := conn.Write(ctx, websocket.MessageText, data); err != nil {
-		log.Println("Failed to send socket ID:", err)
+	if err := h.send(ctx, wsCtx, identifyMsg); err != nil {
+		wsCtx.Log().Warn("failed to send identify", "err", err)
 		return
 	}
 
-	log.Printf("Socket ID sent to user %s: %s", sessionModel.User.Username, socketID)
+	span.End()
+
+	// Ping the peer while the message loop reads, so half-open sockets get reaped
+	go h.websocketManagerService.Keepalive(ctx, socketID, conn)
 
 	// Message handling loop
-	h.handleMessageLoop(ctx, conn, sessionModel, wsCtx)
+	readErr = h.handleMessageLoop(ctx, conn, sessionModel, wsCtx)
 }
 
+// Browsers send the session cookie, bots a personal API token as a bearer
 func (h *WebSocketHandler) authenticateSession(r *http.Request) (*models.Session, error) {
-	cookie, err := r.Cookie("sessionId")
-	if err != nil {
-		return nil, fmt.Errorf("missing session cookie: %w", err)
-	}
-
-	return h.sessionService.GetUserBySession(r.Context(), cookie.Value)
+	return middlewares.Authenticate(r, h.sessionService, h.apiTokenService)
 }
 
-func (h *WebSocketHandler) handleMessageLoop(
+// Release the connection once it ends. A client that closed the socket
+// itself has left, any other drop holds its participant slot for a RESUME.
+func (h *WebSocketHandler) disconnect(
 	ctx context.Context,
-	conn *websocket.Conn,
 	sessionModel *models.Session,
 	wsCtx *types.WebSocketContext,
+	readErr error,
 ) {
-	defer func() {
-		h.websocketManagerService.Unregister(wsCtx.ID)
+	// Stop writing first, once suspended the socket may be resumed by another connection
+	h.websocketManagerService.Detach(wsCtx.ID)
 
-		participants, err := h.websocketManagerService.GetRoomParticipants(wsCtx.RoomID)
-
-		if err != nil {
-			log.Printf("error particpant: %v", err)
-			return
-		}
-
-		userLeftData := struct {
-			IsHost              bool   `json:"is_host"`
-			UserID              string `json:"user_id"`
-			Username            string `json:"username"`
-			SocketID            string `json:"socket_id"`
-			CountedParticipants int    `json:"counted_participants"`
-		}{
-			IsHost:              wsCtx.IsHost,
-			UserID:              sessionModel.User.ID.String(),
-			Username:            sessionModel.User.Username,
-			SocketID:            wsCtx.ID,
-			CountedParticipants: len(*participants),
-		}
-
-		broadcastRawData, _ := json.Marshal(userLeftData)
-
-		broadcastMsg := types.WSMessage{
-			Type:  types.TypeEvent,
-			Event: types.EventUserLeft,
-			Data:  broadcastRawData,
-		}
+	switch websocket.CloseStatus(readErr) {
+	case websocket.StatusNormalClosure, websocket.StatusGoingAway:
+	default:
+		suspended := h.websocketManagerService.Suspend(wsCtx.ID, func() {
+			h.leaveRoom(context.Background(), sessionModel, wsCtx)
+		})
 
-		messageDataJSON, _ := json.Marshal(broadcastMsg)
-		if err := h.websocketManagerService.BroadcastToRoom(ctx, wsCtx.RoomID, messageDataJSON, wsCtx.ID); err != nil {
-			log.Printf("Broadcast message error: %v", err)
+		if suspended {
 			return
 		}
+	}
 
-	}()
+	// The socket context may already be cancelled
+	h.leaveRoom(context.WithoutCancel(ctx), sessionModel, wsCtx)
+}
 
+// Read and dispatch messages until the connection ends, returning why
+func (h *WebSocketHandler) handleMessageLoop(
+	ctx context.Context,
+	conn *websocket.Conn,
+	sessionModel *models.Session,
+	wsCtx *types.WebSocketContext,
+) error {
 	for {
 		select {
 		case <-ctx.Done():
-			return
+			return ctx.Err()
 		default:
 			msgType, data, err := conn.Read(ctx)
 			if err != nil {
-				log.Printf("WebSocket read error: %v", err)
-				return
+				wsCtx.Log().Info("websocket disconnected", "reason", err)
+				return err
 			}
 
+			h.websocketManagerService.Touch(wsCtx.ID)
+
 			// Only handle text messages
 			if msgType != websocket.MessageText {
 				continue
 			}
 
-			var msg types.WSMessage
-			if err := json.Unmarshal(data, &msg); err != nil {
-				h.sendWSError(ctx, conn, "invalid message format")
+			msg, err := protocol.Parse(data)
+			if err != nil {
+				h.sendWSError(ctx, wsCtx, nil, protocol.CodeInvalidMessage, "invalid message format")
 				continue
 			}
 
 			// Process room actions
-			if err := h.handleRoomAction(ctx, conn, sessionModel, wsCtx, msg); err != nil {
-				log.Printf("Room action error: %v", err)
-				h.sendWSError(ctx, conn, "failed to process room action")
-			}
+			h.router.Dispatch(&wsrouter.Request{
+				Context: ctx,
+				Session: sessionModel,
+				Socket:  wsCtx,
+				Message: msg,
+			})
 		}
 	}
 }
 
-func (h *WebSocketHandler) handleRoomAction(
+// Unregister the socket and tell the room the user left
+func (h *WebSocketHandler) leaveRoom(
 	ctx context.Context,
-	conn *websocket.Conn,
 	sessionModel *models.Session,
 	wsCtx *types.WebSocketContext,
-	msg types.WSMessage,
-) error {
-	switch msg.Event {
-	case types.EventUserJoinRequest:
-		return h.handleRoomJoin(ctx, conn, sessionModel, wsCtx, msg.Data)
-	case types.EventUserLeft:
-		return h.handleRoomLeave(ctx, conn, sessionModel, wsCtx, msg.Data)
-	case types.EventChatMessageSend:
-		return h.handleRoomMessage(ctx, conn, sessionModel, wsCtx, msg.Data)
-	case types.EventRoomMessagesRequest:
-		return h.handleRoomMessages(ctx, conn, sessionModel, wsCtx, msg.Data)
-	case types.EventHostStateSend:
-		return h.handleHostStateChange(ctx, conn, sessionModel, wsCtx, msg.Data)
-	default:
-		return fmt.Errorf("unknown room action: %s", msg.Event)
-	}
-}
-
-func (h *WebSocketHandler) handleRoomJoin(
-	ctx context.Context,
-	conn *websocket.Conn,
-	sessionModel *models.Session,
-	wsCtx *types.WebSocketContext,
-	payload json.RawMessage,
-) error {
-	log.Println("User is joining room")
-
-	type JoinPayload struct {
-		RoomID string `json:"room_id" validate:"required,uuid"`
-	}
-
-	var joinPayload JoinPayload
-	if err := json.Unmarshal(payload, &joinPayload); err != nil {
-		return h.sendWSError(ctx, conn, "invalid join payload")
-	}
-
-	if err := h.validate.Struct(joinPayload); err != nil {
-		return h.sendWSError(ctx, conn, "validation failed")
-	}
-
-	// Parse room ID
-	roomID, err := uuid.Parse(joinPayload.RoomID)
-
-	if err != nil {
-		return h.sendWSError(ctx, conn, "invalid room ID")
-	}
-
-	// Get room details
-	room, err := h.roomService.FindOne(roomID)
-
-	if err != nil {
-		return h.sendWSError(ctx, conn, "room not found")
-	}
-
-	// Update WebSocket context with room
-
-	isHost := room.HostID == sessionModel.User.ID
-
-	wsCtx.IsHost = isHost
-	wsCtx.RoomID = roomID
-
-	// Register connection in WebSocket manager
-
-	if err := h.websocketManagerService.Register(wsCtx, room); err != nil {
-		return h.sendWSError(ctx, conn, err.Error())
-	}
+) {
+	h.websocketManagerService.Unregister(wsCtx.ID)
 
-	participants, err := h.websocketManagerService.GetRoomParticipants(roomID)
+	participants, err := h.websocketManagerService.GetRoomParticipants(wsCtx.RoomID)
 
 	if err != nil {
-		return h.sendWSError(ctx, conn, "Bad error")
-	}
-
-	roomMetadata := h.websocketManagerService.GetRoomMetadata(roomID)
-
-	if roomMetadata == nil {
-		fmt.Println("room doesnt exist ;)")
-		return h.sendWSError(ctx, conn, "Bad error")
-	}
-
-	// Send join confirmation to user
-	joinData := struct {
-		Title              string            `json:"title"`
-		Host               string            `json:"host"`
-		IsHost             bool              `json:"is_host"`
-		Src                string            `json:"src"`
-		State              string            `json:"state"`
-		CurrentTimeSeconds float64           `json:"current_time_seconds"`
-		Participants       *types.UserIDInfo `json:"participants"`
-	}{
-		Title:              room.Title,
-		Host:               room.HostUsername,
-		IsHost:             isHost,
-		Src:                room.Src,
-		State:              roomMetadata.State,
-		CurrentTimeSeconds: roomMetadata.CurrentTimeSeconds,
-		Participants:       participants,
-	}
-
-	rawData, _ := json.Marshal(joinData)
-
-	joinMsg := types.WSMessage{
-		Type:  types.TypeEvent,
-		Event: types.EventUserJoinAnswer,
-		Data:  rawData,
+		wsCtx.Log().Warn("failed to load participants", "err", err)
+		return
 	}
-	confirmationData, _ := json.Marshal(joinMsg)
 
-	if err := conn.Write(ctx, websocket.MessageText, confirmationData); err != nil {
-		h.websocketManagerService.Unregister(wsCtx.ID)
-		return err
+	// User is still in the room through another socket
+	if _, exists := (*participants)[sessionModel.User.ID]; exists {
+		return
 	}
 
-	log.Printf("User %s joined room %s", sessionModel.User.Username, roomID)
-
-	userJointData := struct {
-		IsHost              bool   `json:"is_host"`
-		UserID              string `json:"user_id"`
-		Username            string `json:"username"`
-		CountedParticipants int    `json:"counted_participants"`
-	}{
+	broadcastMsg, err := protocol.NewEvent(protocol.EventUserLeft, protocol.UserLeft{
 		IsHost:              wsCtx.IsHost,
-		UserID:              sessionModel.User.ID.String(),
+		UserID:              sessionModel.User.ID,
 		Username:            sessionModel.User.Username,
+		SocketID:            wsCtx.ID,
 		CountedParticipants: len(*participants),
-	}
-
-	broadcastRawData, _ := json.Marshal(userJointData)
-
-	broadcastMsg := types.WSMessage{
-		Type:  types.TypeEvent,
-		Event: types.EventUserJoint,
-		Data:  broadcastRawData,
-	}
-
-	messageDataJSON, _ := json.Marshal(broadcastMsg)
-	if err := h.websocketManagerService.BroadcastToRoom(ctx, wsCtx.RoomID, messageDataJSON, wsCtx.ID); err != nil {
-		log.Printf("Broadcast message error: %v", err)
-		return h.sendWSError(ctx, conn, "failed to send message")
-	}
-
-	return nil
-}
-
-func (h *WebSocketHandler) handleRoomMessage(
-	ctx context.Context,
-	conn *websocket.Conn,
-	sessionModel *models.Session,
-	wsCtx *types.WebSocketContext,
-	payload json.RawMessage,
-) error {
-	type MessagePayload struct {
-		RoomId   string `json:"room_id" validate:"required,uuid"`
-		SocketID string `json:"socket_id" `
-		Content  string `json:"content" validate:"required,max=250"`
-	}
-
-	var msgPayload MessagePayload
-
-	if err := json.Unmarshal(payload, &msgPayload); err != nil {
-		return h.sendWSError(ctx, conn, "invalid message payload")
-	}
-
-	if err := h.validate.Struct(msgPayload); err != nil {
-		return h.sendWSError(ctx, conn, "validation failed")
-	}
-
-	roomID, err := uuid.Parse(msgPayload.RoomId)
-
-	if err != nil {
-		fmt.Println("err", err)
-		return h.sendWSError(ctx, conn, "validation failed")
-	}
-
-	createMessageRoomDto := &dtos.CreateRoomMessageDto{
-		Content:        msgPayload.Content,
-		RoomID:         roomID,
-		SenderID:       sessionModel.User.ID,
-		IsHost:         wsCtx.IsHost,
-		SenderUsername: sessionModel.User.Username,
-	}
-
-	isUserAllowed := h.websocketManagerService.IsUserAllowed(roomID, createMessageRoomDto.SenderID, wsCtx.ID)
-
-	if !isUserAllowed {
-		fmt.Println("not allowed room socket")
-
-		return h.sendWSError(ctx, conn, "bad request")
-	}
-
-	roomMessageService, err := h.roomMessageService.Create(createMessageRoomDto)
+	})
 
 	if err != nil {
-		fmt.Println("err", err)
-		return h.sendWSError(ctx, conn, "very bad error :X")
-	}
-
-	messageData := struct {
-		SenderID       uuid.UUID `json:"sender_id"`
-		SenderUsername string    `json:"sender_username"`
-		Content        string    `json:"content"`
-		IsHost         bool      `json:"is_host"`
-		CreatedAt      string    `json:"created_at"`
-	}{
-		SenderID:       roomMessageService.SenderID,
-		SenderUsername: roomMessageService.SenderUsername,
-		Content:        roomMessageService.Content,
-		IsHost:         roomMessageService.IsHost,
-		CreatedAt:      roomMessageService.CreatedAt.String(),
-	}
-
-	rawData, _ := json.Marshal(messageData)
-
-	messageMsg := types.WSMessage{
-		Type:  types.TypeEvent,
-		Event: types.EventChatMessageReceived,
-		Data:  rawData,
+		wsCtx.Log().Error("failed to build user left", "err", err)
+		return
 	}
-	messageDataJSON, _ := json.Marshal(messageMsg)
 
-	if err := h.websocketManagerService.BroadcastToRoom(ctx, wsCtx.RoomID, messageDataJSON, wsCtx.ID); err != nil {
-		log.Printf("Broadcast message error: %v", err)
-		return h.sendWSError(ctx, conn, "failed to send message")
+	if err := h.websocketManagerService.BroadcastToRoom(ctx, wsCtx.RoomID, broadcastMsg, wsCtx.ID); err != nil {
+		wsCtx.Log().Warn("broadcast failed", "event", protocol.EventUserLeft, "err", err)
 	}
-
-	return nil
 }
 
-func (h *WebSocketHandler) handleHostStateChange(
-	ctx context.Context,
-	conn *websocket.Conn,
-	sessionModel *models.Session,
-	wsCtx *types.WebSocketContext,
-	payload json.RawMessage,
-) error {
-
-	if !wsCtx.IsHost {
-		return h.sendWSError(ctx, conn, "invalid request broooo")
-	}
-
-	type MessagePayload struct {
-		State              string  `json:"state"`
-		CurrentTimeSeconds float64 `json:"current_time_seconds"`
-		RoomId             string  `json:"room_id" validate:"required,uuid"`
-		SocketID           string  `json:"socket_id" `
-	}
-
-	var msgPayload MessagePayload
-
-	if err := json.Unmarshal(payload, &msgPayload); err != nil {
-		return h.sendWSError(ctx, conn, "invalid message payload")
-	}
-
-	if err := h.validate.Struct(msgPayload); err != nil {
-		return h.sendWSError(ctx, conn, "validation failed")
-	}
-
-	roomID, err := uuid.Parse(msgPayload.RoomId)
-
+func (h *WebSocketHandler) send(ctx context.Context, wsCtx *types.WebSocketContext, msg *protocol.Message) error {
+	data, err := protocol.Encode(msg)
 	if err != nil {
-		fmt.Println("err", err)
-		return h.sendWSError(ctx, conn, "validation failed")
-	}
-
-	userID := sessionModel.User.ID
-
-	isUserAllowed := h.websocketManagerService.IsUserAllowed(roomID, userID, wsCtx.ID)
-
-	if !isUserAllowed {
-		fmt.Println("not allowed room socket")
-
-		return h.sendWSError(ctx, conn, "bad request")
+		return err
 	}
 
-	messageData := struct {
-		State              string  `json:"state"`
-		CurrentTimeSeconds float64 `json:"current_time_seconds"`
-	}{
-		State:              msgPayload.State,
-		CurrentTimeSeconds: msgPayload.CurrentTimeSeconds,
+	if err := h.websocketManagerService.SendToSocket(ctx, wsCtx.ID, data); err != nil {
+		return err
 	}
 
-	rawData, _ := json.Marshal(messageData)
-
-	messageMsg := types.WSMessage{
-		Type:  types.TypeEvent,
-		Event: types.EventHostStateReceived,
-		Data:  rawData,
+	event := msg.Event
+	if msg.Type == protocol.TypeError {
+		event = protocol.TypeError
 	}
 
-	broadcastData, _ := json.Marshal(messageMsg)
-
-	if err := h.websocketManagerService.BroadcastToRoom(ctx, wsCtx.RoomID, broadcastData, wsCtx.ID); err != nil {
-		log.Printf("Broadcast leave error: %v", err)
-	}
+	metrics.WSEventsOut.WithLabelValues(event).Inc()
 
 	return nil
 }
 
-func (h *WebSocketHandler) handleRoomMessages(
+func (h *WebSocketHandler) sendWSError(
 	ctx context.Context,
-	conn *websocket.Conn,
-	sessionModel *models.Session,
 	wsCtx *types.WebSocketContext,
-	payload json.RawMessage,
+	req *protocol.Message,
+	code protocol.ErrorCode,
+	errMsg string,
 ) error {
-	type MessagePayload struct {
-		RoomId   string `json:"room_id" validate:"required,uuid"`
-		SocketID string `json:"socket_id" `
-	}
-
-	var msgPayload MessagePayload
-
-	if err := json.Unmarshal(payload, &msgPayload); err != nil {
-		return h.sendWSError(ctx, conn, "invalid message payload")
-	}
-
-	if err := h.validate.Struct(msgPayload); err != nil {
-		return h.sendWSError(ctx, conn, "validation failed")
-	}
-
-	roomID, err := uuid.Parse(msgPayload.RoomId)
-
-	if err != nil {
-		fmt.Println("err", err)
-		return h.sendWSError(ctx, conn, "validation failed")
-	}
-
-	userID := sessionModel.User.ID
-
-	isUserAllowed := h.websocketManagerService.IsUserAllowed(roomID, userID, wsCtx.ID)
-
-	if !isUserAllowed {
-		fmt.Println("not allowed room socket")
-
-		return h.sendWSError(ctx, conn, "bad request")
-	}
-
-	roomMessages, err := h.roomMessageService.GetRoomMessages(roomID)
-
-	if err != nil {
-		fmt.Println("err", err)
-		return h.sendWSError(ctx, conn, "very bad error :X")
-	}
-
-	messageData := struct {
-		Messages []*models.RoomMessage `json:"messages"`
-		AuthID   uuid.UUID             `json:"auth_id"`
-	}{
-		Messages: roomMessages,
-		AuthID:   userID,
-	}
-
-	rawData, _ := json.Marshal(messageData)
-
-	messageMsg := types.WSMessage{
-		Type:  types.TypeEvent,
-		Event: types.EventRoomMessagesAnswer,
-		Data:  rawData,
-	}
-	messageDataJSON, _ := json.Marshal(messageMsg)
-
-	if err := conn.Write(ctx, websocket.MessageText, messageDataJSON); err != nil {
-		log.Println("Failed to send socket ID:", err)
-		return nil
-	}
-
-	return nil
+	return h.send(ctx, wsCtx, protocol.NewError(code, errMsg).ReplyTo(req))
 }
 
-func (h *WebSocketHandler) handleRoomLeave(
-	ctx context.Context,
-	conn *websocket.Conn,
-	sessionModel *models.Session,
-	wsCtx *types.WebSocketContext,
-	payload json.RawMessage,
-) error {
-	fmt.Println("user left")
-
-	if wsCtx.RoomID == uuid.Nil {
-		return h.sendWSError(ctx, conn, "not in a room")
-	}
-
-	roomID := wsCtx.RoomID
-
-	// Send leave confirmation to user
-	leaveData := struct {
-		RoomID   string `json:"room_id"`
-		SocketID string `json:"socket_id"`
-		Message  string `json:"message"`
-	}{
-		RoomID:   roomID.String(),
-		SocketID: wsCtx.ID,
-		Message:  "Successfully left the room",
-	}
-
-	rawData, _ := json.Marshal(leaveData)
-	leaveMsg := types.WSMessage{
-		Type:  types.TypeEvent,
-		Event: types.EventUserLeft,
-		Data:  rawData,
-	}
-	confirmationData, _ := json.Marshal(leaveMsg)
-	if err := conn.Write(ctx, websocket.MessageText, confirmationData); err != nil {
-		return err
+// Map manager registration errors to protocol errors
+func registerError(err error) *protocol.Error {
+	switch {
+	case errors.Is(err, services.ErrTooManySockets):
+		return protocol.Errorf(protocol.CodeTooManySockets, "%s", err)
+	case errors.Is(err, services.ErrRoomAtCapacity):
+		return protocol.Errorf(protocol.CodeRoomFull, "%s", err)
+	case errors.Is(err, services.ErrRoomNotActive):
+		return protocol.Errorf(protocol.CodeRoomNotFound, "%s", err)
+	default:
+		return protocol.Errorf(protocol.CodeInternal, "failed to join room")
 	}
+}
 
-	log.Printf("User %s left room %s", sessionModel.User.Username, roomID)
-
-	// Broadcast user left to room
-	userLeftData := struct {
-		UserID   string `json:"user_id"`
-		Username string `json:"username"`
-		SocketID string `json:"socket_id"`
-	}{
-		UserID:   sessionModel.User.ID.String(),
-		Username: sessionModel.User.Username,
-		SocketID: wsCtx.ID,
-	}
+func toParticipants(participants *types.UserIDInfo) map[uuid.UUID]protocol.Participant {
+	result := make(map[uuid.UUID]protocol.Participant, len(*participants))
 
-	broadcastRawData, _ := json.Marshal(userLeftData)
-	broadcastMsg := types.WSMessage{
-		Type:  types.TypeEvent,
-		Event: types.EventUserLeft,
-		Data:  broadcastRawData,
-	}
-	broadcastData, _ := json.Marshal(broadcastMsg)
-	if err := h.websocketManagerService.BroadcastToRoom(ctx, roomID, broadcastData, wsCtx.ID); err != nil {
-		log.Printf("Broadcast leave error: %v", err)
+	for userID, userInfo := range *participants {
+		result[userID] = protocol.Participant{
+			Username: userInfo.Username,
+			IsHost:   userInfo.IsHost,
+		}
 	}
 
-	// Unregister from WebSocket manager
-	h.websocketManagerService.Unregister(wsCtx.ID)
-
-	return nil
+	return result
 }
 
-func (h *WebSocketHandler) sendWSError(ctx context.Context, conn *websocket.Conn, errMsg string) error {
-	errorData := struct {
-		Message string `json:"message"`
-	}{
-		Message: errMsg,
+func toChatMessage(roomMessage *models.RoomMessage) protocol.ChatMessage {
+	return protocol.ChatMessage{
+		ID:             roomMessage.ID,
+		RoomID:         roomMessage.RoomID,
+		SenderID:       roomMessage.SenderID,
+		SenderUsername: roomMessage.SenderUsername,
+		Content:        roomMessage.Content,
+		IsHost:         roomMessage.IsHost,
+		CreatedAt:      roomMessage.CreatedAt,
 	}
-
-	rawData, _ := json.Marshal(errorData)
-	errorMsg := types.WSMessage{
-		Type:  types.TypeError,
-		Event: types.TypeError,
-		Data:  rawData,
-	}
-
-	data, _ := json.Marshal(errorMsg)
-	return conn.Write(ctx, websocket.MessageText, data)
 }
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"github.com/dliluashvili/cowatchit/internal/dtos"
+	"github.com/dliluashvili/cowatchit/internal/protocol"
+	"github.com/dliluashvili/cowatchit/internal/wsrouter"
+)
+
+func (h *WebSocketHandler) handleRoomMessage(req *wsrouter.Request, payload *protocol.ChatMessageSend) error {
+	sessionModel := req.Session
+	wsCtx := req.Socket
+
+	createMessageRoomDto := &dtos.CreateRoomMessageDto{
+		Content:        payload.Content,
+		RoomID:         wsCtx.RoomID,
+		SenderID:       sessionModel.User.ID,
+		IsHost:         wsCtx.IsHost,
+		SenderUsername: sessionModel.User.Username,
+	}
+
+	roomMessage, err := h.roomMessageService.Create(req.Context, createMessageRoomDto)
+
+	if err != nil {
+		wsCtx.Log().Error("failed to save room message", "err", err)
+		return protocol.Errorf(protocol.CodeInternal, "failed to save message")
+	}
+
+	messageMsg, err := protocol.NewEvent(protocol.EventChatMessageReceived, toChatMessage(roomMessage))
+
+	if err != nil {
+		return err
+	}
+
+	if err := h.websocketManagerService.BroadcastToRoom(req.Context, wsCtx.RoomID, messageMsg, wsCtx.ID); err != nil {
+		wsCtx.Log().Warn("broadcast failed", "event", protocol.EventChatMessageReceived, "err", err)
+	}
+
+	return nil
+}
+
+func (h *WebSocketHandler) handleRoomMessages(req *wsrouter.Request, _ *protocol.RoomMessagesRequest) error {
+	wsCtx := req.Socket
+
+	roomMessages, err := h.roomMessageService.GetRoomMessages(req.Context, wsCtx.RoomID, dtos.PageDto{})
+
+	if err != nil {
+		wsCtx.Log().Error("failed to load room messages", "err", err)
+		return protocol.Errorf(protocol.CodeInternal, "failed to load messages")
+	}
+
+	messages := make([]protocol.ChatMessage, 0, len(roomMessages))
+	for _, roomMessage := range roomMessages {
+		messages = append(messages, toChatMessage(roomMessage))
+	}
+
+	messageMsg, err := protocol.NewEvent(protocol.EventRoomMessagesAnswer, protocol.RoomMessagesAnswer{
+		Messages: messages,
+		AuthID:   req.Session.User.ID,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if err := h.send(req.Context, wsCtx, messageMsg.ReplyTo(req.Message)); err != nil {
+		wsCtx.Log().Warn("failed to send room messages", "err", err)
+	}
+
+	return nil
+}
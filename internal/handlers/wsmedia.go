@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/dliluashvili/cowatchit/internal/logging"
+	"github.com/dliluashvili/cowatchit/internal/metrics"
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/dliluashvili/cowatchit/internal/protocol"
+	"github.com/dliluashvili/cowatchit/internal/wsrouter"
+)
+
+// handleMediaLink hands the participant a fresh link to the room's video,
+// clients ask before theirs expires
+func (h *WebSocketHandler) handleMediaLink(req *wsrouter.Request, _ *protocol.MediaLinkRequest) error {
+	ctx := req.Context
+	wsCtx := req.Socket
+
+	room, err := h.roomService.FindOne(ctx, wsCtx.RoomID)
+
+	if err != nil {
+		return protocol.Errorf(protocol.CodeRoomNotFound, "room not found")
+	}
+
+	media, err := h.roomService.Media(ctx, room, req.Session.User.ID)
+
+	if err != nil {
+		wsCtx.Log().Error("failed to get room media url", "err", err)
+		return protocol.Errorf(protocol.CodeInternal, "room media is unavailable")
+	}
+
+	linkMsg, err := protocol.NewEvent(protocol.EventMediaLinkAnswer, protocol.MediaLinkAnswer{
+		Src:                 media.Src,
+		SrcExpiresInSeconds: int64(media.ExpiresIn.Seconds()),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return h.send(ctx, wsCtx, linkMsg.ReplyTo(req.Message))
+}
+
+// HandleMediaReady moves the active rooms playing item to its new source.
+// Only sockets on this instance are told, the others pick it up with their
+// next link.
+func (h *WebSocketHandler) HandleMediaReady(ctx context.Context, item *models.LibraryItem) {
+	log := logging.FromContext(ctx)
+
+	rooms, err := h.roomService.FindByLibraryItem(ctx, item.ID)
+
+	if err != nil {
+		log.Error("failed to find rooms playing library item", "err", err)
+		return
+	}
+
+	for _, room := range rooms {
+		sockets := h.websocketManagerService.GetRoomSockets(room.ID)
+
+		if len(sockets) == 0 {
+			continue
+		}
+
+		// Each participant's link is signed for them
+		for socketID, userID := range sockets {
+			media, err := h.roomService.Media(ctx, &room, userID)
+
+			if err != nil {
+				log.Error("failed to get room media", "room_id", room.ID, "err", err)
+				break
+			}
+
+			h.websocketManagerService.SetRoomDuration(room.ID, media.DurationSeconds)
+
+			message, err := protocol.NewEvent(protocol.EventRoomMediaChanged, protocol.RoomMediaChanged{
+				Src:                 media.Src,
+				SrcExpiresInSeconds: int64(media.ExpiresIn.Seconds()),
+				DurationSeconds:     media.DurationSeconds,
+			})
+
+			if err != nil {
+				log.Error("failed to build media event", "err", err)
+				return
+			}
+
+			data, err := protocol.Encode(message)
+
+			if err != nil {
+				log.Error("failed to encode media event", "err", err)
+				return
+			}
+
+			// Suspended sockets get the new source with their next link
+			if err := h.websocketManagerService.SendToSocket(ctx, socketID, data); err != nil {
+				log.Debug("send failed", "event", protocol.EventRoomMediaChanged, "socket_id", socketID, "err", err)
+				continue
+			}
+
+			metrics.WSEventsOut.WithLabelValues(protocol.EventRoomMediaChanged).Inc()
+		}
+	}
+}
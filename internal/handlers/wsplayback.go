@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"github.com/dliluashvili/cowatchit/internal/protocol"
+	"github.com/dliluashvili/cowatchit/internal/wsrouter"
+)
+
+func (h *WebSocketHandler) handleHostStateChange(req *wsrouter.Request, payload *protocol.HostStateSend) error {
+	wsCtx := req.Socket
+
+	// Kept for joiners, a position past the end of the video is moved to it
+	currentTime := h.websocketManagerService.SetPlayback(wsCtx.RoomID, payload.State, payload.CurrentTimeSeconds)
+
+	messageMsg, err := protocol.NewEvent(protocol.EventHostStateReceived, protocol.HostStateReceived{
+		State:              payload.State,
+		CurrentTimeSeconds: currentTime,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if err := h.websocketManagerService.BroadcastStateToRoom(req.Context, wsCtx.RoomID, messageMsg, wsCtx.ID); err != nil {
+		wsCtx.Log().Warn("broadcast failed", "event", protocol.EventHostStateReceived, "err", err)
+	}
+
+	return nil
+}
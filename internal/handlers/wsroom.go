@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"github.com/dliluashvili/cowatchit/internal/metrics"
+	"github.com/dliluashvili/cowatchit/internal/protocol"
+	"github.com/dliluashvili/cowatchit/internal/services"
+	"github.com/dliluashvili/cowatchit/internal/wsrouter"
+	"github.com/google/uuid"
+)
+
+func (h *WebSocketHandler) handleRoomJoin(req *wsrouter.Request, payload *protocol.JoinRequest) error {
+	ctx := req.Context
+	sessionModel := req.Session
+	wsCtx := req.Socket
+
+	// Parse room ID
+	roomID, err := uuid.Parse(payload.RoomID)
+
+	if err != nil {
+		return protocol.Errorf(protocol.CodeValidationFailed, "invalid room ID")
+	}
+
+	// Get room details
+	room, err := h.roomService.FindOne(ctx, roomID)
+
+	if err != nil {
+		return protocol.Errorf(protocol.CodeRoomNotFound, "room not found")
+	}
+
+	// Other tabs of someone already let in don't ask again
+	if !h.roomService.CanEnter(room, sessionModel.User, payload.Password) &&
+		!h.websocketManagerService.IsUserInRoom(roomID, sessionModel.User.ID) {
+		return protocol.Errorf(protocol.CodeRoomLocked, "this private room needs its password")
+	}
+
+	media, err := h.roomService.Media(ctx, room, sessionModel.User.ID)
+
+	if err != nil {
+		wsCtx.Log().Error("failed to get room media url", "err", err)
+		return protocol.Errorf(protocol.CodeInternal, "room media is unavailable")
+	}
+
+	// Update WebSocket context with room
+
+	isHost := room.HostID == sessionModel.User.ID
+
+	wsCtx.IsHost = isHost
+	wsCtx.RoomID = roomID
+
+	// Register connection in WebSocket manager
+
+	if err := h.websocketManagerService.Register(wsCtx, room); err != nil {
+		return registerError(err)
+	}
+
+	participants, err := h.websocketManagerService.GetRoomParticipants(roomID)
+
+	if err != nil {
+		return protocol.Errorf(protocol.CodeRoomNotFound, "room is not active")
+	}
+
+	h.websocketManagerService.SetRoomDuration(roomID, media.DurationSeconds)
+
+	state, currentTime, active := h.websocketManagerService.GetPlayback(roomID)
+
+	if !active {
+		return protocol.Errorf(protocol.CodeRoomNotFound, "room is not active")
+	}
+
+	// Send join confirmation to user
+	joinMsg, err := protocol.NewEvent(protocol.EventUserJoinAnswer, protocol.JoinAnswer{
+		Title:               room.Title,
+		Host:                room.HostUsername,
+		IsHost:              isHost,
+		Src:                 media.Src,
+		SrcExpiresInSeconds: int64(media.ExpiresIn.Seconds()),
+		State:               state,
+		CurrentTimeSeconds:  currentTime,
+		DurationSeconds:     media.DurationSeconds,
+		Participants:        toParticipants(participants),
+	})
+
+	if err != nil {
+		h.websocketManagerService.Unregister(wsCtx.ID)
+		return err
+	}
+
+	if err := h.send(ctx, wsCtx, joinMsg.ReplyTo(req.Message)); err != nil {
+		h.websocketManagerService.Unregister(wsCtx.ID)
+		return err
+	}
+
+	wsCtx.Log().Info("user joined room")
+
+	if err := h.roomService.RecordWatch(ctx, roomID, sessionModel.User.ID); err != nil {
+		wsCtx.Log().Warn("failed to record watch history", "err", err)
+	}
+
+	broadcastMsg, err := protocol.NewEvent(protocol.EventUserJoint, protocol.UserJoint{
+		IsHost:              wsCtx.IsHost,
+		UserID:              sessionModel.User.ID,
+		Username:            sessionModel.User.Username,
+		CountedParticipants: len(*participants),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if err := h.websocketManagerService.BroadcastToRoom(ctx, wsCtx.RoomID, broadcastMsg, wsCtx.ID); err != nil {
+		wsCtx.Log().Warn("broadcast failed", "event", protocol.EventUserJoint, "err", err)
+	}
+
+	return nil
+}
+
+func (h *WebSocketHandler) handleResume(req *wsrouter.Request, payload *protocol.ResumeRequest) error {
+	sessionModel := req.Session
+	wsCtx := req.Socket
+
+	if wsCtx.RoomID != uuid.Nil {
+		return protocol.Errorf(protocol.CodeAlreadyInRoom, "already in a room")
+	}
+
+	// The answer is queued ahead of the replayed events
+	resumed, err := h.websocketManagerService.Resume(
+		payload.ResumeToken,
+		wsCtx.ID,
+		sessionModel.User.ID,
+		payload.LastSeq,
+		func(resumed *services.ResumedSocket) ([]byte, error) {
+			resumeMsg, err := protocol.NewEvent(protocol.EventResumeAnswer, protocol.ResumeAnswer{
+				SocketID:       resumed.SocketID,
+				RoomID:         resumed.RoomID,
+				ResumeToken:    resumed.ResumeToken,
+				ReplayComplete: resumed.ReplayComplete,
+			})
+
+			if err != nil {
+				return nil, err
+			}
+
+			return protocol.Encode(resumeMsg.ReplyTo(req.Message))
+		},
+	)
+
+	if resumed == nil {
+		return protocol.Errorf(protocol.CodeResumeRejected, "resume failed")
+	}
+
+	wsCtx.ID = resumed.SocketID
+	wsCtx.RoomID = resumed.RoomID
+	wsCtx.IsHost = resumed.IsHost
+
+	if err != nil {
+		return err
+	}
+
+	metrics.WSEventsOut.WithLabelValues(protocol.EventResumeAnswer).Inc()
+
+	wsCtx.Log().Info("socket resumed", "replay_complete", resumed.ReplayComplete)
+
+	return nil
+}
+
+func (h *WebSocketHandler) handleRoomLeave(req *wsrouter.Request, _ *protocol.LeaveRequest) error {
+	ctx := req.Context
+	sessionModel := req.Session
+	wsCtx := req.Socket
+
+	roomID := wsCtx.RoomID
+
+	// Send leave confirmation to user
+	leaveMsg, err := protocol.NewEvent(protocol.EventUserLeaveAnswer, protocol.LeaveAnswer{
+		RoomID:   roomID,
+		SocketID: wsCtx.ID,
+		Message:  "Successfully left the room",
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if err := h.send(ctx, wsCtx, leaveMsg.ReplyTo(req.Message)); err != nil {
+		return err
+	}
+
+	wsCtx.Log().Info("user left room")
+
+	// Unregister from WebSocket manager and broadcast user left to room
+	h.leaveRoom(ctx, sessionModel, wsCtx)
+
+	wsCtx.RoomID = uuid.Nil
+	wsCtx.IsHost = false
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package helpers
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Error codes of the /api/v1 error envelope
+const (
+	APICodeBadRequest       = "bad_request"
+	APICodeUnauthorized     = "unauthorized"
+	APICodeForbidden        = "forbidden"
+	APICodeNotFound         = "not_found"
+	APICodeMethodNotAllowed = "method_not_allowed"
+	APICodeConflict         = "conflict"
+	APICodeValidationFailed = "validation_failed"
+	APICodeRateLimited      = "rate_limited"
+	APICodeInternal         = "internal"
+)
+
+// Envelope of every /api/v1 response, either Data (with Meta for lists) or Error is set
+type APIResponse struct {
+	Data  any       `json:"data,omitempty"`
+	Meta  *PageMeta `json:"meta,omitempty"`
+	Error *APIError `json:"error,omitempty"`
+}
+
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Per field validation messages
+	Fields map[string][]string `json:"fields,omitempty"`
+}
+
+type PageMeta struct {
+	Page    int  `json:"page"`
+	PerPage int  `json:"per_page"`
+	HasMore bool `json:"has_more"`
+}
+
+func SendAPIData(w http.ResponseWriter, status int, data any) {
+	sendAPI(w, status, &APIResponse{Data: data})
+}
+
+func SendAPIPage(w http.ResponseWriter, data any, meta *PageMeta) {
+	sendAPI(w, http.StatusOK, &APIResponse{Data: data, Meta: meta})
+}
+
+func SendAPIError(w http.ResponseWriter, status int, code, message string) {
+	sendAPI(w, status, &APIResponse{Error: &APIError{Code: code, Message: message}})
+}
+
+func SendAPIValidationError(w http.ResponseWriter, fields map[string][]string) {
+	sendAPI(w, http.StatusUnprocessableEntity, &APIResponse{Error: &APIError{
+		Code:    APICodeValidationFailed,
+		Message: "Validation failed",
+		Fields:  fields,
+	}})
+}
+
+func sendAPI(w http.ResponseWriter, status int, response *APIResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
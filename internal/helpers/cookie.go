@@ -0,0 +1,63 @@
+package helpers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/dliluashvili/cowatchit/internal/config"
+)
+
+const CSRFCookieName = "csrf_token"
+
+// Session cookie with the configured name and security attributes
+func SessionCookie(cfg config.SessionConfig, sessionID string) *http.Cookie {
+	return &http.Cookie{
+		Name:     cfg.SessionCookieName(),
+		Value:    sessionID,
+		Path:     "/",
+		Domain:   cfg.CookieDomain,
+		MaxAge:   int(cfg.Duration.Seconds()),
+		HttpOnly: true,
+		Secure:   cfg.CookieSecure,
+		SameSite: cfg.SameSite(),
+	}
+}
+
+// ExpiredSessionCookie tells the browser to drop the session cookie
+func ExpiredSessionCookie(cfg config.SessionConfig) *http.Cookie {
+	cookie := SessionCookie(cfg, "")
+	cookie.MaxAge = -1
+
+	return cookie
+}
+
+// CSRFCookie carries the token state-changing page requests must echo.
+// Lax rather than strict, so arriving from another site keeps the token
+// the open tabs already hold.
+func CSRFCookie(cfg config.SessionConfig, token string) *http.Cookie {
+	return &http.Cookie{
+		Name:     cfg.Cookie(CSRFCookieName),
+		Value:    token,
+		Path:     "/",
+		Domain:   cfg.CookieDomain,
+		MaxAge:   int(cfg.Duration.Seconds()),
+		HttpOnly: true,
+		Secure:   cfg.CookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// Short lived cookie binding a single sign-on flow to the browser that
+// started it. Always lax, strict would not survive the provider's redirect.
+func FlowCookie(cfg config.SessionConfig, name, value string, maxAge time.Duration) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/auth/oidc",
+		Domain:   cfg.CookieDomain,
+		MaxAge:   int(maxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   cfg.CookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
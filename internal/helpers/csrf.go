@@ -0,0 +1,20 @@
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/dliluashvili/cowatchit/internal/shared/constants"
+)
+
+// CSRFToken is the token the CSRF middleware put in ctx, for pages to render
+func CSRFToken(ctx context.Context) string {
+	token, _ := ctx.Value(constants.CSRFTokenContextKey).(string)
+	return token
+}
+
+// CSRFHeaders is an hx-headers value sending the token with every htmx request
+func CSRFHeaders(ctx context.Context) string {
+	headers, _ := json.Marshal(map[string]string{"X-CSRF-Token": CSRFToken(ctx)})
+	return string(headers)
+}
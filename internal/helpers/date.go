@@ -1,14 +1,15 @@
 package helpers
 
 import (
-	"log"
+	"fmt"
+	"log/slog"
 	"time"
 )
 
 func StringToDate(value string) time.Time {
 	parsedDate, err := time.Parse("2006-01-02", value)
 	if err != nil {
-		log.Println("Invalid date_of_birth format:", err)
+		slog.Warn("invalid date_of_birth format", "err", err)
 	}
 	return parsedDate
 }
@@ -23,3 +24,24 @@ func CalculateAge(dob time.Time) int {
 
 	return age
 }
+
+// FormatOptionalDate renders t as a date, or fallback when it is unset
+func FormatOptionalDate(t *time.Time, fallback string) string {
+	if t == nil {
+		return fallback
+	}
+
+	return t.Format("Jan 2, 2006")
+}
+
+// FormatSeconds renders a media length like 1:02:03, or 4:05 under an hour
+func FormatSeconds(seconds float64) string {
+	total := int(seconds)
+	hours, minutes, secs := total/3600, total/60%60, total%60
+
+	if hours > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", hours, minutes, secs)
+	}
+
+	return fmt.Sprintf("%d:%02d", minutes, secs)
+}
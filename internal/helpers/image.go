@@ -7,12 +7,18 @@ import (
 	_ "image/jpeg"
 	_ "image/png"
 	"io"
+	"net/http"
+	"slices"
 
+	"golang.org/x/image/draw"
 	_ "golang.org/x/image/webp"
 
 	"github.com/chai2010/webp"
 )
 
+// Image types uploads may be in, as sniffed from their first bytes
+var ImageTypes = []string{"image/jpeg", "image/png", "image/webp"}
+
 func CheckSize(r io.Reader, maxKB int) (bool, []byte, error) {
 	var buf bytes.Buffer
 	size, err := io.Copy(&buf, r)
@@ -22,12 +28,47 @@ func CheckSize(r io.Reader, maxKB int) (bool, []byte, error) {
 	return size <= int64(maxKB*1024), buf.Bytes(), nil
 }
 
+// DetectImageType sniffs the type of data, false when it isn't one of ImageTypes
+func DetectImageType(data []byte) (string, bool) {
+	contentType := http.DetectContentType(data)
+
+	return contentType, slices.Contains(ImageTypes, contentType)
+}
+
+// ResizeCover scales img to fill width x height and crops what overflows,
+// keeping the center
+func ResizeCover(img image.Image, width, height int) image.Image {
+	bounds := img.Bounds()
+	crop := bounds
+
+	// Trim the sides or the top and bottom to the target aspect ratio
+	if bounds.Dx()*height > bounds.Dy()*width {
+		w := bounds.Dy() * width / height
+		crop.Min.X += (bounds.Dx() - w) / 2
+		crop.Max.X = crop.Min.X + w
+	} else {
+		h := bounds.Dx() * height / width
+		crop.Min.Y += (bounds.Dy() - h) / 2
+		crop.Max.Y = crop.Min.Y + h
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, crop, draw.Src, nil)
+
+	return dst
+}
+
 func CompressToWebP(r io.Reader, maxKB int) ([]byte, error) {
 	img, _, err := image.Decode(r)
 	if err != nil {
 		return nil, err
 	}
 
+	return EncodeWebP(img, maxKB)
+}
+
+// EncodeWebP lowers the quality until the image fits in maxKB
+func EncodeWebP(img image.Image, maxKB int) ([]byte, error) {
 	var (
 		buf     bytes.Buffer
 		quality = 90
@@ -3,7 +3,7 @@ package helpers
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"log/slog"
 	"net/http"
 
 	"github.com/coder/websocket"
@@ -33,7 +33,7 @@ func SendWSResponse(ctx context.Context, conn *websocket.Conn, response *WSRespo
 	data, err := json.Marshal(response)
 
 	if err != nil {
-		fmt.Println("err", err)
+		slog.Error("failed to encode ws response", "err", err)
 		return err
 	}
 
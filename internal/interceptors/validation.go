@@ -21,7 +21,7 @@ func ValidateBody[T any](validate *validator.Validate) func(http.Handler) http.H
 				return
 			}
 
-			if err := validate.Struct(dto); err != nil {
+			if err := validate.StructCtx(r.Context(), dto); err != nil {
 				if ve, ok := err.(validator.ValidationErrors); ok {
 					formatted := formatter.ErrorFormatter(ve)
 
@@ -43,3 +43,30 @@ func ValidateBody[T any](validate *validator.Validate) func(http.Handler) http.H
 		})
 	}
 }
+
+// ValidateAPIBody is ValidateBody answering with the /api/v1 error envelope
+func ValidateAPIBody[T any](validate *validator.Validate) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var dto T
+
+			if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+				helpers.SendAPIError(w, http.StatusBadRequest, helpers.APICodeBadRequest, "Invalid request body")
+				return
+			}
+
+			if err := validate.StructCtx(r.Context(), dto); err != nil {
+				if ve, ok := err.(validator.ValidationErrors); ok {
+					helpers.SendAPIValidationError(w, formatter.ErrorFormatter(ve))
+					return
+				}
+
+				helpers.SendAPIError(w, http.StatusBadRequest, helpers.APICodeBadRequest, "Validation failed")
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), constants.ValidatedContextKey, &dto)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package jsonschema
+
+import (
+	"maps"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Generator builds JSON Schemas from Go types. Structs are collected in
+// Defs and referenced as RefPrefix + type name.
+type Generator struct {
+	Defs      map[string]any
+	refPrefix string
+	types     map[reflect.Type]map[string]any
+}
+
+func New(refPrefix string) *Generator {
+	g := &Generator{
+		Defs:      map[string]any{},
+		refPrefix: refPrefix,
+		types:     map[reflect.Type]map[string]any{},
+	}
+
+	g.Map(reflect.TypeOf(uuid.UUID{}), map[string]any{"type": "string", "format": "uuid"})
+	g.Map(reflect.TypeOf(time.Time{}), map[string]any{"type": "string", "format": "date-time"})
+
+	return g
+}
+
+// Map makes t use a fixed schema instead of one derived from its kind
+func (g *Generator) Map(t reflect.Type, schema map[string]any) {
+	g.types[t] = schema
+}
+
+// Ref points at a definition
+func (g *Generator) Ref(name string) map[string]any {
+	return map[string]any{"$ref": g.refPrefix + name}
+}
+
+// SchemaFor describes t. Output schemas are for what the server sends, where
+// every field that isn't omitempty is present; input schemas only require
+// fields validated as required and reject unknown properties.
+func (g *Generator) SchemaFor(t reflect.Type, output bool) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	// Callers add validation keywords, hand out a copy
+	if schema, exists := g.types[t]; exists {
+		return maps.Clone(schema)
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.SchemaFor(t.Elem(), output)}
+	case reflect.Map:
+		schema := map[string]any{
+			"type":                 "object",
+			"additionalProperties": g.SchemaFor(t.Elem(), output),
+		}
+		if key, exists := g.types[t.Key()]; exists && key["format"] != nil {
+			schema["propertyNames"] = map[string]any{"format": key["format"]}
+		}
+		return schema
+	case reflect.Struct:
+		g.defineStruct(t, output)
+		return g.Ref(t.Name())
+	}
+
+	return map[string]any{}
+}
+
+func (g *Generator) defineStruct(t reflect.Type, output bool) {
+	if _, exists := g.Defs[t.Name()]; exists {
+		return
+	}
+
+	// Reserve the name first so recursive types terminate
+	g.Defs[t.Name()] = nil
+
+	properties := map[string]any{}
+	required := []string{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, omitEmpty := JSONName(field)
+		if name == "-" {
+			continue
+		}
+
+		schema := g.SchemaFor(field.Type, output)
+		isRequired := ApplyValidateTag(schema, field.Tag.Get("validate"))
+
+		// The server always sends fields that are not omitempty
+		if isRequired || (output && !omitEmpty) {
+			required = append(required, name)
+		}
+
+		properties[name] = schema
+	}
+
+	g.Defs[t.Name()] = map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": !output,
+	}
+}
+
+// JSONName is the field's name on the wire and whether it may be left out
+func JSONName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "" {
+		return field.Name, false
+	}
+
+	name, options, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+
+	return name, strings.Contains(options, "omitempty") || strings.Contains(options, "omitzero")
+}
+
+// ApplyValidateTag translates the validator tags we use into schema keywords,
+// reports whether the field is required
+func ApplyValidateTag(schema map[string]any, tag string) bool {
+	required := false
+
+	if tag == "" {
+		return required
+	}
+
+	isString := schema["type"] == "string"
+
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+
+		switch name {
+		case "required":
+			required = true
+		case "uuid":
+			schema["format"] = "uuid"
+		case "url":
+			schema["format"] = "uri"
+		case "email":
+			schema["format"] = "email"
+		case "oneof":
+			schema["enum"] = strings.Fields(param)
+		case "min", "max", "gte", "lte":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+
+			keyword := map[string]string{"min": "minimum", "gte": "minimum", "max": "maximum", "lte": "maximum"}[name]
+			if isString && (name == "min" || name == "max") {
+				keyword = map[string]string{"min": "minLength", "max": "maxLength"}[name]
+			}
+
+			schema[keyword] = n
+		}
+	}
+
+	return required
+}
@@ -0,0 +1,60 @@
+// Package logging builds the application's slog logger and carries it through contexts
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+type Options struct {
+	Level  slog.Level
+	Format string
+	Output io.Writer
+}
+
+func New(opts Options) *slog.Logger {
+	if opts.Output == nil {
+		opts.Output = os.Stdout
+	}
+
+	handlerOpts := &slog.HandlerOptions{
+		Level:       opts.Level,
+		ReplaceAttr: Redact,
+	}
+
+	if opts.Format == FormatJSON {
+		return slog.New(slog.NewJSONHandler(opts.Output, handlerOpts))
+	}
+
+	return slog.New(slog.NewTextHandler(opts.Output, handlerOpts))
+}
+
+type loggerKey struct{}
+
+// WithLogger stores logger in ctx, handlers further down log through it
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger stored in ctx or the default one
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+			return logger
+		}
+	}
+
+	return slog.Default()
+}
+
+// With adds attributes to the logger carried by ctx
+func With(ctx context.Context, args ...any) context.Context {
+	return WithLogger(ctx, FromContext(ctx).With(args...))
+}
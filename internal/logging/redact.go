@@ -0,0 +1,41 @@
+package logging
+
+import (
+	"log/slog"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// Keys whose values never reach the logs, compared without case, dashes or underscores
+var sensitiveKeys = []string{
+	"password",
+	"sessionid",
+	"session",
+	"cookie",
+	"authorization",
+	"token",
+	"resumetoken",
+	"secret",
+}
+
+// Redact is a slog ReplaceAttr that masks secrets by attribute name
+func Redact(_ []string, a slog.Attr) slog.Attr {
+	if IsSensitive(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+
+	return a
+}
+
+func IsSensitive(key string) bool {
+	normalized := strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(normalized, sensitive) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,103 @@
+// Package metrics holds the Prometheus collectors served on /metrics
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "cowatchit"
+
+// Registry holds every collector of the app, kept apart from the global one
+var Registry = prometheus.NewRegistry()
+
+var (
+	WSEventsIn = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "ws",
+		Name:      "events_in_total",
+		Help:      "Websocket events received, by event and result code.",
+	}, []string{"event", "code"})
+
+	WSEventsOut = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "ws",
+		Name:      "events_out_total",
+		Help:      "Websocket events queued to sockets, by event.",
+	}, []string{"event"})
+
+	BroadcastDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "ws",
+		Name:      "broadcast_duration_seconds",
+		Help:      "Time spent fanning an event out to a room.",
+		Buckets:   []float64{.0001, .0005, .001, .005, .01, .05, .1, .5},
+	}, []string{"event"})
+
+	BroadcastErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "ws",
+		Name:      "broadcast_errors_total",
+		Help:      "Sockets a broadcast could not be queued on, by event.",
+	}, []string{"event"})
+
+	SignIns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "auth",
+		Name:      "sign_ins_total",
+		Help:      "Sign in attempts, by result.",
+	}, []string{"result"})
+
+	RateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_total",
+		Help:      "Requests rejected by a rate limiter, by scope (http or ws) and route.",
+	}, []string{"scope", "route"})
+
+	DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Database call latency, by operation and table.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "table", "status"})
+
+	RedisCommandDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "redis",
+		Name:      "command_duration_seconds",
+		Help:      "Redis command latency, by command.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"command", "status"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		WSEventsIn,
+		WSEventsOut,
+		BroadcastDuration,
+		BroadcastErrors,
+		SignIns,
+		RateLimited,
+		DBQueryDuration,
+		RedisCommandDuration,
+	)
+}
+
+// Handler serves the registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+func status(err error) string {
+	if err != nil {
+		return "error"
+	}
+
+	return "ok"
+}
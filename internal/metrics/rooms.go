@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RoomStats is the part of the websocket manager the room gauges read from
+type RoomStats interface {
+	GetActiveRoomIDs() []uuid.UUID
+	GetRoomUserCount(roomID uuid.UUID) int
+	GetRoomSocketCount(roomID uuid.UUID) int
+	GetConnectedSocketCount() int
+	GetSuspendedSocketCount() int
+	GetReapedSocketCount() int64
+}
+
+var (
+	roomsActiveDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "ws", "rooms_active"),
+		"Rooms with at least one registered socket.",
+		nil, nil,
+	)
+	socketsConnectedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "ws", "sockets_connected"),
+		"Open websocket connections.",
+		nil, nil,
+	)
+	socketsSuspendedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "ws", "sockets_suspended"),
+		"Dropped sockets waiting to be resumed.",
+		nil, nil,
+	)
+	socketsReapedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "ws", "sockets_reaped_total"),
+		"Sockets closed because the peer stopped answering pings.",
+		nil, nil,
+	)
+	// Rooms aren't labels, their ids would leak private rooms and grow without bound
+	roomUsersDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "ws", "room_users"),
+		"Users in rooms, summed over all rooms.",
+		nil, nil,
+	)
+	roomSocketsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "ws", "room_sockets"),
+		"Sockets registered in rooms, summed over all rooms.",
+		nil, nil,
+	)
+	roomUsersMaxDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "ws", "room_users_max"),
+		"Users in the fullest room.",
+		nil, nil,
+	)
+)
+
+// roomCollector reads the gauges from the manager at scrape time
+type roomCollector struct {
+	stats RoomStats
+}
+
+// RegisterRooms exposes the websocket manager's room and socket counts
+func RegisterRooms(stats RoomStats) {
+	Registry.MustRegister(&roomCollector{stats: stats})
+}
+
+func (c *roomCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- roomsActiveDesc
+	ch <- socketsConnectedDesc
+	ch <- socketsSuspendedDesc
+	ch <- socketsReapedDesc
+	ch <- roomUsersDesc
+	ch <- roomSocketsDesc
+	ch <- roomUsersMaxDesc
+}
+
+func (c *roomCollector) Collect(ch chan<- prometheus.Metric) {
+	roomIDs := c.stats.GetActiveRoomIDs()
+
+	ch <- prometheus.MustNewConstMetric(roomsActiveDesc, prometheus.GaugeValue, float64(len(roomIDs)))
+	ch <- prometheus.MustNewConstMetric(socketsConnectedDesc, prometheus.GaugeValue, float64(c.stats.GetConnectedSocketCount()))
+	ch <- prometheus.MustNewConstMetric(socketsSuspendedDesc, prometheus.GaugeValue, float64(c.stats.GetSuspendedSocketCount()))
+	ch <- prometheus.MustNewConstMetric(socketsReapedDesc, prometheus.CounterValue, float64(c.stats.GetReapedSocketCount()))
+
+	var users, sockets, maxUsers int
+	for _, roomID := range roomIDs {
+		roomUsers := c.stats.GetRoomUserCount(roomID)
+		users += roomUsers
+		sockets += c.stats.GetRoomSocketCount(roomID)
+		maxUsers = max(maxUsers, roomUsers)
+	}
+
+	ch <- prometheus.MustNewConstMetric(roomUsersDesc, prometheus.GaugeValue, float64(users))
+	ch <- prometheus.MustNewConstMetric(roomSocketsDesc, prometheus.GaugeValue, float64(sockets))
+	ch <- prometheus.MustNewConstMetric(roomUsersMaxDesc, prometheus.GaugeValue, float64(maxUsers))
+}
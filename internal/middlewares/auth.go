@@ -2,39 +2,141 @@ package middlewares
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/dliluashvili/cowatchit/internal/helpers"
+	"github.com/dliluashvili/cowatchit/internal/logging"
+	"github.com/dliluashvili/cowatchit/internal/models"
 	"github.com/dliluashvili/cowatchit/internal/services"
 
 	"github.com/dliluashvili/cowatchit/internal/shared/constants"
 )
 
-func AuthSession(sessionService *services.SessionService) func(http.Handler) http.Handler {
+func AuthSession(sessionService *services.SessionService, apiTokenService *services.APITokenService) func(http.Handler) http.Handler {
+	return authSession(sessionService, apiTokenService, handleUnauthorized)
+}
+
+// APIAuthSession is AuthSession answering with the /api/v1 error envelope
+func APIAuthSession(sessionService *services.SessionService, apiTokenService *services.APITokenService) func(http.Handler) http.Handler {
+	return authSession(sessionService, apiTokenService, func(w http.ResponseWriter, r *http.Request) {
+		helpers.SendAPIError(w, http.StatusUnauthorized, helpers.APICodeUnauthorized, "Invalid or expired session")
+	})
+}
+
+func authSession(sessionService *services.SessionService, apiTokenService *services.APITokenService, unauthorized http.HandlerFunc) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			sessionID := extractSessionID(r)
-			if sessionID == "" {
-				handleUnauthorized(w, r)
-				return
-			}
-
-			session, err := sessionService.GetUserBySession(r.Context(), sessionID)
+			session, err := Authenticate(r, sessionService, apiTokenService)
 
 			if err != nil || session == nil {
-				handleUnauthorized(w, r)
+				unauthorized(w, r)
 				return
 			}
 
 			// Inject session into context
 			ctx := context.WithValue(r.Context(), constants.SessionContextKey, session)
+			ctx = logging.With(ctx, "user_id", session.User.ID)
+			if session.IsAPIToken() {
+				ctx = logging.With(ctx, "api_token_id", *session.APITokenID)
+			}
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
-func extractSessionID(r *http.Request) string {
+// Authenticate resolves the request's session id or personal API token
+// into a session
+func Authenticate(r *http.Request, sessionService *services.SessionService, apiTokenService *services.APITokenService) (*models.Session, error) {
+	sessionID := extractSessionID(r, sessionService.CookieName())
+	if sessionID == "" {
+		return nil, errors.New("missing credentials")
+	}
+
+	if services.IsAPIToken(sessionID) {
+		return apiTokenService.Authenticate(r.Context(), sessionID)
+	}
+
+	session, err := sessionService.GetUserBySession(r.Context(), sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the two-factor endpoints take a pending session
+	if session.PendingTwoFactor {
+		return nil, errors.New("second factor not verified")
+	}
+
+	return session, nil
+}
+
+// RequireScope lets through sessions holding scope, API tokens carry
+// only the scopes they were created with
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return requireScope(scope, func(w http.ResponseWriter, r *http.Request) {
+		helpers.SendJson(w, &helpers.Response{
+			Status:  http.StatusForbidden,
+			Message: "API token lacks the " + scope + " scope",
+		})
+	})
+}
+
+// APIRequireScope is RequireScope answering with the /api/v1 error envelope
+func APIRequireScope(scope string) func(http.Handler) http.Handler {
+	return requireScope(scope, func(w http.ResponseWriter, r *http.Request) {
+		helpers.SendAPIError(w, http.StatusForbidden, helpers.APICodeForbidden, "API token lacks the "+scope+" scope")
+	})
+}
+
+func requireScope(scope string, forbidden http.HandlerFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			session := r.Context().Value(constants.SessionContextKey).(*models.Session)
+
+			if !session.HasScope(scope) {
+				forbidden(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequirePasswordSession keeps API tokens out, for pages such as the one
+// managing the tokens themselves
+func RequirePasswordSession(next http.Handler) http.Handler {
+	return requirePasswordSession(next, func(w http.ResponseWriter, r *http.Request) {
+		helpers.SendJson(w, &helpers.Response{
+			Status:  http.StatusForbidden,
+			Message: "API tokens can't be used here",
+		})
+	})
+}
+
+// APIRequirePasswordSession is RequirePasswordSession answering with the
+// /api/v1 error envelope
+func APIRequirePasswordSession(next http.Handler) http.Handler {
+	return requirePasswordSession(next, func(w http.ResponseWriter, r *http.Request) {
+		helpers.SendAPIError(w, http.StatusForbidden, helpers.APICodeForbidden, "API tokens can't be used here")
+	})
+}
+
+func requirePasswordSession(next http.Handler, forbidden http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(constants.SessionContextKey).(*models.Session)
+
+		if session.IsAPIToken() {
+			forbidden(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func extractSessionID(r *http.Request, cookieName string) string {
 	auth := r.Header.Get("Authorization")
 	if strings.HasPrefix(auth, "Bearer ") {
 		return strings.TrimPrefix(auth, "Bearer ")
@@ -44,7 +146,7 @@ func extractSessionID(r *http.Request) string {
 		return sessionID
 	}
 
-	cookie, err := r.Cookie("sessionId")
+	cookie, err := r.Cookie(cookieName)
 	if err == nil {
 		return cookie.Value
 	}
@@ -0,0 +1,61 @@
+package middlewares
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dliluashvili/cowatchit/internal/helpers"
+	"github.com/dliluashvili/cowatchit/internal/metrics"
+	"github.com/dliluashvili/cowatchit/internal/openapi"
+	"golang.org/x/time/rate"
+)
+
+// RateLimit allows each client IP r requests per second with the given burst
+func RateLimit(r rate.Limit, burst int) func(http.Handler) http.Handler {
+	var (
+		visitors = make(map[string]*rate.Limiter)
+		mu       sync.Mutex
+	)
+
+	getVisitor := func(ip string) *rate.Limiter {
+		mu.Lock()
+		defer mu.Unlock()
+
+		limiter, exists := visitors[ip]
+		if !exists {
+			limiter = rate.NewLimiter(r, burst)
+			visitors[ip] = limiter
+		}
+
+		return limiter
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			ip := req.RemoteAddr // Ideally, parse real IP behind proxies
+			limiter := getVisitor(ip)
+
+			reservation := limiter.Reserve()
+			if delay := reservation.Delay(); delay > 0 {
+				// Refused requests don't use up a token
+				reservation.Cancel()
+				metrics.RateLimited.WithLabelValues("http", "all").Inc()
+
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+
+				if strings.HasPrefix(req.URL.Path, openapi.BasePath) {
+					helpers.SendAPIError(w, http.StatusTooManyRequests, helpers.APICodeRateLimited, "Too many requests")
+					return
+				}
+
+				http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, req)
+		})
+	}
+}
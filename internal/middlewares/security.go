@@ -0,0 +1,176 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/dliluashvili/cowatchit/internal/config"
+	"github.com/dliluashvili/cowatchit/internal/helpers"
+	"github.com/dliluashvili/cowatchit/internal/logging"
+	"github.com/dliluashvili/cowatchit/internal/openapi"
+	"github.com/dliluashvili/cowatchit/internal/shared/constants"
+)
+
+const (
+	// Header htmx and fetch calls send the CSRF token in
+	CSRFHeader = "X-CSRF-Token"
+	// Form field plain forms send it in
+	CSRFField = "csrf_token"
+)
+
+// SecurityHeaders sets the content security policy and the headers that
+// keep pages out of frames and sniffing. HSTS is only sent over HTTPS,
+// browsers ignore it otherwise.
+func SecurityHeaders(cfg config.SecurityConfig) func(http.Handler) http.Handler {
+	hsts := ""
+	if cfg.HSTSMaxAge > 0 {
+		hsts = fmt.Sprintf("max-age=%d; includeSubDomains", int(cfg.HSTSMaxAge.Seconds()))
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := w.Header()
+
+			if cfg.ContentSecurityPolicy != "" {
+				header.Set("Content-Security-Policy", cfg.ContentSecurityPolicy)
+			}
+			header.Set("X-Frame-Options", "DENY")
+			header.Set("X-Content-Type-Options", "nosniff")
+			header.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+
+			if hsts != "" && (r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https") {
+				header.Set("Strict-Transport-Security", hsts)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// SameOrigin turns away state-changing requests a browser sent from
+// another site. Clients that send no Origin, like scripts, pass.
+func SameOrigin(allowedOrigins []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isSafeMethod(r.Method) || OriginAllowed(r, allowedOrigins) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			logging.FromContext(r.Context()).Warn("cross-origin request refused", "origin", r.Header.Get("Origin"))
+
+			if strings.HasPrefix(r.URL.Path, openapi.BasePath) {
+				helpers.SendAPIError(w, http.StatusForbidden, helpers.APICodeForbidden, "Cross-origin request refused")
+				return
+			}
+
+			helpers.SendJson(w, &helpers.Response{
+				Status:  http.StatusForbidden,
+				Message: "Cross-origin request refused",
+			})
+		})
+	}
+}
+
+// OriginAllowed tells whether the request's Origin is the server itself or
+// matches one of patterns, the way the websocket upgrade checks it.
+// A request without Origin is allowed.
+func OriginAllowed(r *http.Request, patterns []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return r.Header.Get("Sec-Fetch-Site") != "cross-site"
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	for _, pattern := range patterns {
+		target := u.Host
+		if strings.Contains(pattern, "://") {
+			target = u.Scheme + "://" + u.Host
+		}
+
+		if matched, _ := path.Match(strings.ToLower(pattern), strings.ToLower(target)); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CSRF hands every visitor a token in a cookie and the request context, for
+// pages to render, and makes state-changing requests echo it in the
+// X-CSRF-Token header or the csrf_token field. Requests carrying an
+// Authorization header are exempt, browsers don't attach one on their own.
+func CSRF(cfg config.SessionConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := ""
+			if cookie, err := r.Cookie(cfg.Cookie(helpers.CSRFCookieName)); err == nil {
+				token = cookie.Value
+			}
+
+			if !isSafeMethod(r.Method) && r.Header.Get("Authorization") == "" {
+				sent := r.Header.Get(CSRFHeader)
+				if sent == "" && isURLEncodedForm(r) {
+					sent = r.PostFormValue(CSRFField)
+				}
+
+				if token == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+					logging.FromContext(r.Context()).Warn("csrf token mismatch")
+					helpers.SendJson(w, &helpers.Response{
+						Status:  http.StatusForbidden,
+						Message: "Invalid or missing CSRF token, reload the page",
+					})
+					return
+				}
+			}
+
+			if token == "" {
+				token = newCSRFToken()
+				http.SetCookie(w, helpers.CSRFCookie(cfg, token))
+			}
+
+			ctx := context.WithValue(r.Context(), constants.CSRFTokenContextKey, token)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Only plain form posts may carry the token in the body. Parsing any other
+// body here would read uploads into memory before the handler sees them.
+func isURLEncodedForm(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	return err == nil && mediaType == "application/x-www-form-urlencoded"
+}
+
+func newCSRFToken() string {
+	bytes := make([]byte, 32)
+	rand.Read(bytes)
+
+	return hex.EncodeToString(bytes)
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,198 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dliluashvili/cowatchit/internal/config"
+	"github.com/dliluashvili/cowatchit/internal/helpers"
+	"github.com/dliluashvili/cowatchit/internal/openapi"
+)
+
+const testCSRFToken = "token"
+
+// Answers 200 with the token the middleware put in the context
+var echoCSRF = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(helpers.CSRFToken(r.Context())))
+})
+
+func multipartBody(t *testing.T, fields map[string]string) (string, *bytes.Buffer) {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	for name, value := range fields {
+		if err := form.WriteField(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	form.Close()
+
+	return form.FormDataContentType(), body
+}
+
+func TestCSRF(t *testing.T) {
+	cfg := config.Default().Session
+	formBody := url.Values{CSRFField: {testCSRFToken}}.Encode()
+	jsonBody, _ := json.Marshal(map[string]string{CSRFField: testCSRFToken})
+	multipartType, multipartForm := multipartBody(t, map[string]string{CSRFField: testCSRFToken})
+
+	tests := []struct {
+		name        string
+		method      string
+		cookie      bool
+		header      map[string]string
+		contentType string
+		body        string
+		want        int
+	}{
+		{name: "safe method", method: http.MethodGet, want: http.StatusOK},
+		{name: "missing token", method: http.MethodPost, cookie: true, want: http.StatusForbidden},
+		{name: "wrong token", method: http.MethodPost, cookie: true, header: map[string]string{CSRFHeader: "guess"}, want: http.StatusForbidden},
+		{name: "header token", method: http.MethodDelete, cookie: true, header: map[string]string{CSRFHeader: testCSRFToken}, want: http.StatusOK},
+		{name: "no cookie", method: http.MethodPost, header: map[string]string{CSRFHeader: testCSRFToken}, want: http.StatusForbidden},
+		{
+			name:        "url-encoded form field",
+			method:      http.MethodPost,
+			cookie:      true,
+			contentType: "application/x-www-form-urlencoded; charset=utf-8",
+			body:        formBody,
+			want:        http.StatusOK,
+		},
+		{
+			name:        "multipart form field",
+			method:      http.MethodPost,
+			cookie:      true,
+			contentType: multipartType,
+			body:        multipartForm.String(),
+			want:        http.StatusForbidden,
+		},
+		{
+			name:        "json body field",
+			method:      http.MethodPost,
+			cookie:      true,
+			contentType: "application/json",
+			body:        string(jsonBody),
+			want:        http.StatusForbidden,
+		},
+		{name: "authorization header", method: http.MethodPost, header: map[string]string{"Authorization": "Bearer key"}, want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/rooms", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			for name, value := range tt.header {
+				r.Header.Set(name, value)
+			}
+			if tt.cookie {
+				r.AddCookie(&http.Cookie{Name: cfg.Cookie(helpers.CSRFCookieName), Value: testCSRFToken})
+			}
+
+			w := httptest.NewRecorder()
+			CSRF(cfg)(echoCSRF).ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSRFIssuesToken(t *testing.T) {
+	cfg := config.Default().Session
+
+	w := httptest.NewRecorder()
+	CSRF(cfg)(echoCSRF).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != cfg.Cookie(helpers.CSRFCookieName) || cookies[0].Value == "" {
+		t.Fatalf("cookies = %v, want a fresh CSRF cookie", cookies)
+	}
+
+	if w.Body.String() != cookies[0].Value {
+		t.Errorf("context token %q, cookie %q", w.Body.String(), cookies[0].Value)
+	}
+}
+
+// The middleware must leave multipart bodies for the handler to read
+func TestCSRFLeavesMultipartBody(t *testing.T) {
+	cfg := config.Default().Session
+	contentType, body := multipartBody(t, map[string]string{"title": "Movie night"})
+
+	r := httptest.NewRequest(http.MethodPost, "/rooms", body)
+	r.Header.Set("Content-Type", contentType)
+	r.Header.Set(CSRFHeader, testCSRFToken)
+	r.AddCookie(&http.Cookie{Name: cfg.Cookie(helpers.CSRFCookieName), Value: testCSRFToken})
+
+	title := ""
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		title = r.FormValue("title")
+	})
+
+	w := httptest.NewRecorder()
+	CSRF(cfg)(handler).ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK || title != "Movie night" {
+		t.Errorf("status %d and title %q, want 200 and the posted title", w.Code, title)
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	allowed := []string{"*.example.com", "https://app.test"}
+
+	tests := []struct {
+		name   string
+		method string
+		header map[string]string
+		want   int
+	}{
+		{name: "cross-origin read", method: http.MethodGet, header: map[string]string{"Origin": "https://evil.test"}, want: http.StatusOK},
+		{name: "same origin", method: http.MethodPost, header: map[string]string{"Origin": "http://example.com"}, want: http.StatusOK},
+		{name: "allowed host", method: http.MethodPost, header: map[string]string{"Origin": "https://www.example.com"}, want: http.StatusOK},
+		{name: "allowed origin", method: http.MethodPost, header: map[string]string{"Origin": "https://app.test"}, want: http.StatusOK},
+		{name: "allowed host on another scheme", method: http.MethodPost, header: map[string]string{"Origin": "http://app.test"}, want: http.StatusForbidden},
+		{name: "cross-origin", method: http.MethodPost, header: map[string]string{"Origin": "https://evil.test"}, want: http.StatusForbidden},
+		{name: "opaque origin", method: http.MethodPost, header: map[string]string{"Origin": "null"}, want: http.StatusForbidden},
+		{name: "no origin or referer", method: http.MethodPost, want: http.StatusOK},
+		{name: "no origin from another site", method: http.MethodPost, header: map[string]string{"Sec-Fetch-Site": "cross-site"}, want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "http://example.com/rooms", nil)
+			for name, value := range tt.header {
+				r.Header.Set(name, value)
+			}
+
+			w := httptest.NewRecorder()
+			SameOrigin(allowed)(echoCSRF).ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+// API clients get the error envelope, not the page response
+func TestSameOriginAPIError(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, openapi.BasePath+"/rooms", nil)
+	r.Header.Set("Origin", "https://evil.test")
+
+	w := httptest.NewRecorder()
+	SameOrigin(nil)(echoCSRF).ServeHTTP(w, r)
+
+	var response helpers.APIResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.Error == nil || response.Error.Code != helpers.APICodeForbidden {
+		t.Errorf("body = %s, want a %s error", w.Body.String(), helpers.APICodeForbidden)
+	}
+}
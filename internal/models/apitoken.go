@@ -0,0 +1,48 @@
+package models
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// What a personal API token may do, sessions signed in with a password
+// may do everything
+const (
+	ScopeRoomsRead  = "rooms:read"
+	ScopeRoomsWrite = "rooms:write"
+	ScopeChatRead   = "chat:read"
+	ScopeChatWrite  = "chat:write"
+)
+
+var Scopes = []string{
+	ScopeRoomsRead,
+	ScopeRoomsWrite,
+	ScopeChatRead,
+	ScopeChatWrite,
+}
+
+// Personal access token, only the SHA-256 of the secret is stored
+type APIToken struct {
+	ID     uuid.UUID      `json:"id"`
+	UserID uuid.UUID      `json:"user_id"`
+	Name   string         `json:"name"`
+	Prefix string         `json:"prefix"`
+	Hash   string         `json:"-"`
+	Scopes pq.StringArray `json:"scopes" gorm:"type:text[]"`
+	// Updated at most once a minute
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (t *APIToken) Expired() bool {
+	return t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt)
+}
+
+func (t *APIToken) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, scope)
+}
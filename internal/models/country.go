@@ -0,0 +1,30 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type Country struct {
+	ID     uuid.UUID `json:"id"`
+	Title  string    `json:"title"`
+	EmojiU string    `json:"emoji_u"`
+	Native string    `json:"native"`
+}
+
+// Flag turns EmojiU, like "U+1F1EC U+1F1EA", into the emoji
+func (c *Country) Flag() string {
+	var flag strings.Builder
+
+	for _, code := range strings.Fields(c.EmojiU) {
+		r, err := strconv.ParseInt(strings.TrimPrefix(code, "U+"), 16, 32)
+		if err != nil {
+			return ""
+		}
+		flag.WriteRune(rune(r))
+	}
+
+	return flag.String()
+}
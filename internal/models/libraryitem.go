@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	LibraryItemUploading = "uploading"
+	LibraryItemReady     = "ready"
+)
+
+// A video a user uploaded to their library. It is written to a partial
+// file chunk by chunk and moved to storage once all Size bytes are in.
+type LibraryItem struct {
+	ID          uuid.UUID `json:"id"`
+	UserID      uuid.UUID `json:"user_id"`
+	Title       string    `json:"title"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	// Bytes received so far, where the next chunk starts
+	Received int64 `json:"received"`
+	// Storage key, set once the upload is complete
+	Key    string `json:"-"`
+	Status string `json:"status"`
+	// Found by probing the file, zero until then
+	DurationSeconds float64 `json:"duration_seconds"`
+	Width           int     `json:"width"`
+	Height          int     `json:"height"`
+	VideoCodec      string  `json:"video_codec"`
+	AudioCodec      string  `json:"audio_codec"`
+	// Storage key of the HLS master playlist, set once transcoded
+	ManifestKey string    `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (i *LibraryItem) Ready() bool {
+	return i.Status == LibraryItemReady
+}
+
+// Progress is the share of the file uploaded, in percent
+func (i *LibraryItem) Progress() int {
+	if i.Size == 0 {
+		return 100
+	}
+
+	return int(i.Received * 100 / i.Size)
+}
+
+// Streamable tells whether the item was transcoded to HLS
+func (i *LibraryItem) Streamable() bool {
+	return i.ManifestKey != ""
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	MediaJobQueued  = "queued"
+	MediaJobRunning = "running"
+	MediaJobReady   = "ready"
+	MediaJobFailed  = "failed"
+)
+
+// Steps of a running job
+const (
+	MediaStageProbing     = "probing"
+	MediaStageTranscoding = "transcoding"
+	MediaStageStoring     = "storing"
+)
+
+// A job probing a library video and transcoding it to HLS. Workers claim
+// queued jobs, and running ones whose lease ran out because their worker
+// went away.
+type MediaJob struct {
+	ID            uuid.UUID `json:"id"`
+	LibraryItemID uuid.UUID `json:"library_item_id"`
+	Status        string    `json:"status"`
+	Stage         string    `json:"stage"`
+	// Share of the transcode done, in percent
+	Progress int `json:"progress"`
+	Attempts int `json:"attempts"`
+	// Why the last attempt failed
+	Error      string     `json:"error"`
+	LeaseUntil *time.Time `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+func (j *MediaJob) Active() bool {
+	return j.Status == MediaJobQueued || j.Status == MediaJobRunning
+}
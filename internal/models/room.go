@@ -14,10 +14,12 @@ type Room struct {
 	Capacity     int       `json:"capacity"`
 	Description  string    `json:"description"`
 	Src          string    `json:"src"`
-	Poster       string    `json:"poster"`
-	Private      bool      `json:"private"`
-	Password     string    `json:"password"`
-	Hidden       bool      `json:"hidden"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	// Set when the room plays a video from the host's library instead of Src
+	LibraryItemID *uuid.UUID `json:"library_item_id"`
+	Poster        string     `json:"poster"`
+	Private       bool       `json:"private"`
+	Password      string     `json:"password"`
+	Hidden        bool       `json:"hidden"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
 }
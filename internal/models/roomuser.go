@@ -6,7 +6,8 @@ import (
 	"github.com/google/uuid"
 )
 
-type RoomRepository struct {
+// A user who joined a room, kept as watch history. UpdatedAt is the last join.
+type RoomUser struct {
 	ID        uuid.UUID `json:"id"`
 	UserID    uuid.UUID `json:"user_id"`
 	RoomID    uuid.UUID `json:"room_id"`
@@ -1,7 +1,10 @@
 package models
 
 import (
+	"slices"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 type Session struct {
@@ -9,4 +12,20 @@ type Session struct {
 	SessionID string    `json:"session_id"`
 	SocketID  *string   `json:"socket_id"`
 	ExpiresAt time.Time `json:"expires_at"`
+	// Set when the request authenticated with a personal API token
+	APITokenID *uuid.UUID `json:"api_token_id,omitempty"`
+	Scopes     []string   `json:"scopes,omitempty"`
+	// Set until the second factor is verified, the session is good for
+	// nothing else
+	PendingTwoFactor bool `json:"pending_two_factor,omitempty"`
+}
+
+// Whether the session came from a personal API token
+func (s *Session) IsAPIToken() bool {
+	return s.APITokenID != nil
+}
+
+// Password sessions have every scope, token sessions the ones granted
+func (s *Session) HasScope(scope string) bool {
+	return !s.IsAPIToken() || slices.Contains(s.Scopes, scope)
 }
@@ -7,14 +7,37 @@ import (
 )
 
 type User struct {
-	ID          uuid.UUID  `json:"id"`
-	Username    string     `json:"username"`
-	Email       string     `json:"email"`
-	DateOfBirth time.Time  `json:"date_of_birth"`
-	Gender      string     `json:"gender"`
-	Age         uint8      `json:"age"`
-	Password    string     `json:"-"`
-	DeletedAt   *time.Time `json:"deleted_at"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
+	ID          uuid.UUID `json:"id"`
+	Username    string    `json:"username"`
+	Email       string    `json:"email"`
+	DateOfBirth time.Time `json:"date_of_birth"`
+	Gender      string    `json:"gender"`
+	Age         uint8     `json:"age"`
+	Password    string    `json:"-"`
+	IsAdmin     bool      `json:"is_admin"`
+	TOTPSecret  string    `json:"-"`
+	// Profile, all optional
+	DisplayName string     `json:"display_name"`
+	Bio         string     `json:"bio"`
+	Avatar      string     `json:"avatar"`
+	CountryID   *uuid.UUID `json:"country_id"`
+	// Set once two-factor authentication is confirmed
+	TOTPEnabledAt *time.Time `json:"totp_enabled_at,omitempty"`
+	DeletedAt     *time.Time `json:"deleted_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// The display name, or the username when none is set
+func (u *User) Name() string {
+	if u.DisplayName != "" {
+		return u.DisplayName
+	}
+
+	return u.Username
+}
+
+// Whether signing in needs a second factor
+func (u *User) TwoFactorEnabled() bool {
+	return u.TOTPEnabledAt != nil
 }
@@ -0,0 +1,276 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/dliluashvili/cowatchit/internal/helpers"
+	"github.com/dliluashvili/cowatchit/internal/jsonschema"
+)
+
+// GenerateClient writes the typed part of pkg/apiclient: a struct per
+// schema and a method per operation. The hand written client.go provides
+// Client and do.
+func GenerateClient(pkg string) ([]byte, error) {
+	c := &clientGenerator{
+		types:   map[string]reflect.Type{},
+		imports: map[string]bool{"context": true, "net/http": true},
+	}
+
+	c.collect(reflect.TypeOf(helpers.PageMeta{}))
+	for _, op := range Operations {
+		if op.Request != nil {
+			c.collect(reflect.TypeOf(op.Request))
+		}
+		if op.Response != nil {
+			c.collect(reflect.TypeOf(op.Response))
+		}
+	}
+
+	names := make([]string, 0, len(c.types))
+	for name := range c.types {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		c.writeStruct(c.types[name])
+	}
+
+	for _, op := range Operations {
+		c.writeOperation(op)
+	}
+
+	// Standard library first, like goimports
+	var std, external []string
+	for path := range c.imports {
+		if strings.Contains(strings.Split(path, "/")[0], ".") {
+			external = append(external, fmt.Sprintf("%q", path))
+		} else {
+			std = append(std, fmt.Sprintf("%q", path))
+		}
+	}
+	slices.Sort(std)
+	slices.Sort(external)
+
+	imports := std
+	if len(external) > 0 {
+		imports = append(append(imports, ""), external...)
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by cmd/apigen from internal/openapi. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "package %s\n\n", pkg)
+	fmt.Fprintf(&out, "import (\n%s\n)\n\n", strings.Join(imports, "\n"))
+	out.Write(c.buf.Bytes())
+
+	return format.Source(out.Bytes())
+}
+
+type clientGenerator struct {
+	buf     bytes.Buffer
+	types   map[string]reflect.Type
+	imports map[string]bool
+}
+
+func (c *clientGenerator) collect(t reflect.Type) {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct || t.PkgPath() == "time" || t.PkgPath() == "github.com/google/uuid" {
+		return
+	}
+
+	if _, exists := c.types[t.Name()]; exists {
+		return
+	}
+
+	c.types[t.Name()] = t
+
+	for i := 0; i < t.NumField(); i++ {
+		c.collect(t.Field(i).Type)
+	}
+}
+
+func (c *clientGenerator) typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return "*" + c.typeName(t.Elem())
+	case reflect.Slice:
+		return "[]" + c.typeName(t.Elem())
+	case reflect.Map:
+		return "map[" + c.typeName(t.Key()) + "]" + c.typeName(t.Elem())
+	case reflect.Struct, reflect.Array:
+		switch t.PkgPath() {
+		case "time", "github.com/google/uuid":
+			c.imports[t.PkgPath()] = true
+			return t.String()
+		}
+		return t.Name()
+	}
+
+	return t.Kind().String()
+}
+
+func (c *clientGenerator) writeStruct(t reflect.Type) {
+	b := &c.buf
+
+	fmt.Fprintf(b, "type %s struct {\n", t.Name())
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		if name, _ := jsonschema.JSONName(field); name == "-" {
+			continue
+		}
+
+		fmt.Fprintf(b, "%s %s `json:%q`\n", field.Name, c.typeName(field.Type), field.Tag.Get("json"))
+	}
+
+	fmt.Fprintf(b, "}\n\n")
+}
+
+func (c *clientGenerator) writeOperation(op Operation) {
+	b := &c.buf
+
+	var query []Param
+	args := []string{"ctx context.Context"}
+	path := []string{}
+
+	literal := ""
+	for _, segment := range strings.Split(strings.TrimPrefix(op.Path, "/"), "/") {
+		name, isParam := strings.CutPrefix(segment, "{")
+		if !isParam {
+			literal += "/" + segment
+			continue
+		}
+
+		name = strings.TrimSuffix(name, "}")
+		c.imports["fmt"] = true
+		c.imports["net/url"] = true
+		path = append(path, fmt.Sprintf("%q", literal+"/"), fmt.Sprintf("url.PathEscape(fmt.Sprint(%s))", goName(name, false)))
+		literal = ""
+	}
+	if literal != "" {
+		path = append(path, fmt.Sprintf("%q", literal))
+	}
+
+	for _, param := range op.Params {
+		if param.In == "path" {
+			args = append(args, goName(param.Name, false)+" "+c.typeName(reflect.TypeOf(param.Type)))
+		} else {
+			query = append(query, param)
+		}
+	}
+
+	if len(query) > 0 {
+		c.writeParams(op.ID+"Params", query)
+		args = append(args, "params *"+op.ID+"Params")
+	}
+
+	if op.Request != nil {
+		args = append(args, "body *"+reflect.TypeOf(op.Request).Name())
+	}
+
+	queryArg := "nil"
+	if len(query) > 0 {
+		queryArg = "params.values()"
+	}
+
+	bodyArg := "nil"
+	if op.Request != nil {
+		bodyArg = "body"
+	}
+
+	call := fmt.Sprintf("c.do(ctx, http.Method%s, %s, %s, %s", methodConst(op.Method), strings.Join(path, "+"), queryArg, bodyArg)
+
+	fmt.Fprintf(b, "// %s sends %s %s\n//\n// %s\n", op.ID, op.Method, op.Path, op.Summary)
+
+	switch {
+	case op.Response == nil:
+		fmt.Fprintf(b, "func (c *Client) %s(%s) error {\n", op.ID, strings.Join(args, ", "))
+		fmt.Fprintf(b, "_, err := %s, nil)\nreturn err\n}\n\n", call)
+	case op.List:
+		name := reflect.TypeOf(op.Response).Name()
+		fmt.Fprintf(b, "func (c *Client) %s(%s) ([]%s, *PageMeta, error) {\n", op.ID, strings.Join(args, ", "), name)
+		fmt.Fprintf(b, "var data []%s\nmeta, err := %s, &data)\nif err != nil {\nreturn nil, nil, err\n}\nreturn data, meta, nil\n}\n\n", name, call)
+	default:
+		name := reflect.TypeOf(op.Response).Name()
+		fmt.Fprintf(b, "func (c *Client) %s(%s) (*%s, error) {\n", op.ID, strings.Join(args, ", "), name)
+		fmt.Fprintf(b, "var data %s\nif _, err := %s, &data); err != nil {\nreturn nil, err\n}\nreturn &data, nil\n}\n\n", name, call)
+	}
+}
+
+// Query parameters become a struct, zero fields are left out of the url
+func (c *clientGenerator) writeParams(name string, params []Param) {
+	b := &c.buf
+
+	fmt.Fprintf(b, "type %s struct {\n", name)
+	for _, param := range params {
+		if param.Description != "" {
+			fmt.Fprintf(b, "// %s\n", param.Description)
+		}
+		fmt.Fprintf(b, "%s %s\n", goName(param.Name, true), paramType(param))
+	}
+	fmt.Fprintf(b, "}\n\n")
+
+	c.imports["net/url"] = true
+
+	fmt.Fprintf(b, "func (p *%s) values() url.Values {\nvalues := url.Values{}\nif p == nil {\nreturn values\n}\n", name)
+	for _, param := range params {
+		field := "p." + goName(param.Name, true)
+
+		switch reflect.TypeOf(param.Type).Kind() {
+		case reflect.Bool:
+			c.imports["strconv"] = true
+			fmt.Fprintf(b, "if %s != nil {\nvalues.Set(%q, strconv.FormatBool(*%s))\n}\n", field, param.Name, field)
+		case reflect.Int:
+			c.imports["strconv"] = true
+			fmt.Fprintf(b, "if %s != 0 {\nvalues.Set(%q, strconv.Itoa(%s))\n}\n", field, param.Name, field)
+		default:
+			fmt.Fprintf(b, "if %s != \"\" {\nvalues.Set(%q, %s)\n}\n", field, param.Name, field)
+		}
+	}
+	fmt.Fprintf(b, "return values\n}\n\n")
+}
+
+func paramType(param Param) string {
+	switch reflect.TypeOf(param.Type).Kind() {
+	case reflect.Bool:
+		return "*bool"
+	case reflect.Int:
+		return "int"
+	}
+
+	return "string"
+}
+
+// snake_case to CamelCase, or camelCase when exported is false
+func goName(name string, exported bool) string {
+	parts := strings.Split(name, "_")
+
+	for i, part := range parts {
+		if i == 0 && !exported {
+			continue
+		}
+		if part == "id" {
+			parts[i] = "ID"
+			continue
+		}
+		parts[i] = strings.ToUpper(part[:1]) + part[1:]
+	}
+
+	return strings.Join(parts, "")
+}
+
+func methodConst(method string) string {
+	return strings.ToUpper(method[:1]) + strings.ToLower(method[1:])
+}
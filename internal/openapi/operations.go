@@ -0,0 +1,241 @@
+// Package openapi describes the /api/v1 JSON API as an OpenAPI document
+// and generates its Go client
+package openapi
+
+//go:generate go run ../../cmd/apigen -out ../../pkg/apiclient/client_gen.go
+
+import (
+	"net/http"
+
+	"github.com/dliluashvili/cowatchit/internal/dtos"
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/google/uuid"
+)
+
+const (
+	BasePath = "/api/v1"
+	SpecPath = "/api/openapi.json"
+	Version  = "1.0.0"
+)
+
+type Param struct {
+	Name string
+	// "path" or "query"
+	In          string
+	Description string
+	// Zero value of the parameter's type
+	Type any
+	// Allowed values
+	Enum []string
+}
+
+// Operation is one endpoint of the API. The router in handlers.APIHandler
+// is checked against this table by pkg/apiclient/contract_test.go.
+type Operation struct {
+	// Also the name of the APIHandler method and of the client method
+	ID      string
+	Method  string
+	Path    string
+	Summary string
+	Tag     string
+	// Served without a session
+	Public bool
+	// Scope a personal API token needs, empty when any token may call it
+	Scope string
+	// Refused to personal API tokens
+	PasswordSession bool
+	Params          []Param
+	// Zero value of the JSON body, nil when there is none
+	Request any
+	// Zero value of the returned data, nil for 204 No Content
+	Response any
+	// Response is the element of a paginated list
+	List   bool
+	Status int
+}
+
+var (
+	roomIDParam = Param{Name: "id", In: "path", Description: "Room id", Type: uuid.UUID{}}
+	pageParams  = []Param{
+		{Name: "page", In: "query", Description: "Page number, from 1", Type: 0},
+		{Name: "per_page", In: "query", Description: "Items per page, at most 100 (default 20)", Type: 0},
+	}
+)
+
+var Operations = []Operation{
+	{
+		ID:       "CreateSession",
+		Method:   http.MethodPost,
+		Path:     BasePath + "/sessions",
+		Summary:  "Sign in, the token is also set as the session cookie. With two-factor on it only works for VerifyTwoFactor.",
+		Tag:      "sessions",
+		Public:   true,
+		Request:  dtos.SignInDto{},
+		Response: dtos.SessionResponse{},
+		Status:   http.StatusCreated,
+	},
+	{
+		ID:       "VerifyTwoFactor",
+		Method:   http.MethodPost,
+		Path:     BasePath + "/sessions/two-factor",
+		Summary:  "Finish signing in with a two-factor or recovery code",
+		Tag:      "sessions",
+		Public:   true,
+		Request:  dtos.VerifyTwoFactorDto{},
+		Response: dtos.SessionResponse{},
+		Status:   http.StatusCreated,
+	},
+	{
+		ID:      "DeleteSession",
+		Method:  http.MethodDelete,
+		Path:    BasePath + "/sessions/current",
+		Summary: "Sign out, called with a personal API token it revokes the token",
+		Tag:     "sessions",
+		Status:  http.StatusNoContent,
+	},
+	{
+		ID:       "CreateUser",
+		Method:   http.MethodPost,
+		Path:     BasePath + "/users",
+		Summary:  "Sign up and start a session",
+		Tag:      "users",
+		Public:   true,
+		Request:  dtos.SignUpDto{},
+		Response: dtos.SessionResponse{},
+		Status:   http.StatusCreated,
+	},
+	{
+		ID:       "GetMe",
+		Method:   http.MethodGet,
+		Path:     BasePath + "/users/me",
+		Summary:  "The signed in user",
+		Tag:      "users",
+		Response: dtos.MeResponse{},
+		Status:   http.StatusOK,
+	},
+	{
+		ID:              "EnableTwoFactor",
+		Method:          http.MethodPost,
+		Path:            BasePath + "/users/me/two-factor",
+		Summary:         "Start turning two-factor on, returns the secret for an authenticator app",
+		Tag:             "users",
+		PasswordSession: true,
+		Response:        dtos.TwoFactorEnrollmentResponse{},
+		Status:          http.StatusCreated,
+	},
+	{
+		ID:              "ConfirmTwoFactor",
+		Method:          http.MethodPost,
+		Path:            BasePath + "/users/me/two-factor/confirm",
+		Summary:         "Turn two-factor on with a first code, returns the recovery codes",
+		Tag:             "users",
+		PasswordSession: true,
+		Request:         dtos.TwoFactorCodeDto{},
+		Response:        dtos.RecoveryCodesResponse{},
+		Status:          http.StatusOK,
+	},
+	{
+		ID:              "DisableTwoFactor",
+		Method:          http.MethodPost,
+		Path:            BasePath + "/users/me/two-factor/disable",
+		Summary:         "Turn two-factor off with a current or recovery code",
+		Tag:             "users",
+		PasswordSession: true,
+		Request:         dtos.TwoFactorCodeDto{},
+		Status:          http.StatusNoContent,
+	},
+	{
+		ID:       "GetUser",
+		Method:   http.MethodGet,
+		Path:     BasePath + "/users/{username}",
+		Summary:  "A user's public profile",
+		Tag:      "users",
+		Params:   []Param{{Name: "username", In: "path", Type: ""}},
+		Response: dtos.UserResponse{},
+		Status:   http.StatusOK,
+	},
+	{
+		ID:      "ListRooms",
+		Method:  http.MethodGet,
+		Path:    BasePath + "/rooms",
+		Summary: "List rooms, newest first",
+		Tag:     "rooms",
+		Scope:   models.ScopeRoomsRead,
+		Params: append([]Param{
+			{Name: "filter", In: "query", Description: "all, public or private", Type: "", Enum: []string{"all", "public", "private"}},
+			{Name: "keyword", In: "query", Description: "Part of the title", Type: ""},
+			{Name: "my", In: "query", Description: "Only rooms hosted by the signed in user", Type: false},
+		}, pageParams...),
+		Response: dtos.RoomResponse{},
+		List:     true,
+		Status:   http.StatusOK,
+	},
+	{
+		ID:       "CreateRoom",
+		Method:   http.MethodPost,
+		Path:     BasePath + "/rooms",
+		Summary:  "Create a room hosted by the signed in user",
+		Tag:      "rooms",
+		Scope:    models.ScopeRoomsWrite,
+		Request:  dtos.CreateRoomDto{},
+		Response: dtos.RoomResponse{},
+		Status:   http.StatusCreated,
+	},
+	{
+		ID:       "GetRoom",
+		Method:   http.MethodGet,
+		Path:     BasePath + "/rooms/{id}",
+		Summary:  "Get a room",
+		Tag:      "rooms",
+		Scope:    models.ScopeRoomsRead,
+		Params:   []Param{roomIDParam},
+		Response: dtos.RoomResponse{},
+		Status:   http.StatusOK,
+	},
+	{
+		ID:       "UpdateRoom",
+		Method:   http.MethodPatch,
+		Path:     BasePath + "/rooms/{id}",
+		Summary:  "Change the given fields of a room, host or admin only",
+		Tag:      "rooms",
+		Scope:    models.ScopeRoomsWrite,
+		Params:   []Param{roomIDParam},
+		Request:  dtos.UpdateRoomDto{},
+		Response: dtos.RoomResponse{},
+		Status:   http.StatusOK,
+	},
+	{
+		ID:      "DeleteRoom",
+		Method:  http.MethodDelete,
+		Path:    BasePath + "/rooms/{id}",
+		Summary: "Delete a room and its messages, host or admin only",
+		Tag:     "rooms",
+		Scope:   models.ScopeRoomsWrite,
+		Params:  []Param{roomIDParam},
+		Status:  http.StatusNoContent,
+	},
+	{
+		ID:       "ListRoomMessages",
+		Method:   http.MethodGet,
+		Path:     BasePath + "/rooms/{id}/messages",
+		Summary:  "A room's chat, oldest first",
+		Tag:      "messages",
+		Scope:    models.ScopeChatRead,
+		Params:   append([]Param{roomIDParam}, pageParams...),
+		Response: dtos.RoomMessageResponse{},
+		List:     true,
+		Status:   http.StatusOK,
+	},
+	{
+		ID:       "CreateRoomMessage",
+		Method:   http.MethodPost,
+		Path:     BasePath + "/rooms/{id}/messages",
+		Summary:  "Post to a room's chat, connected sockets receive it",
+		Tag:      "messages",
+		Scope:    models.ScopeChatWrite,
+		Params:   []Param{roomIDParam},
+		Request:  dtos.CreateRoomMessageRequestDto{},
+		Response: dtos.RoomMessageResponse{},
+		Status:   http.StatusCreated,
+	},
+}
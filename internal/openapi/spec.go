@@ -0,0 +1,206 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/dliluashvili/cowatchit/internal/config"
+	"github.com/dliluashvili/cowatchit/internal/helpers"
+	"github.com/dliluashvili/cowatchit/internal/jsonschema"
+)
+
+const refPrefix = "#/components/schemas/"
+
+// Spec builds the OpenAPI 3.1 document of Operations, schemas come from
+// the dtos and their validator tags
+func Spec() map[string]any {
+	g := jsonschema.New(refPrefix)
+
+	pageMeta := g.SchemaFor(reflect.TypeOf(helpers.PageMeta{}), true)
+	apiError := g.SchemaFor(reflect.TypeOf(helpers.APIError{}), true)
+
+	g.Defs["ErrorResponse"] = map[string]any{
+		"type":       "object",
+		"required":   []string{"error"},
+		"properties": map[string]any{"error": apiError},
+	}
+
+	paths := map[string]any{}
+
+	for _, op := range Operations {
+		item, exists := paths[op.Path].(map[string]any)
+		if !exists {
+			item = map[string]any{}
+			paths[op.Path] = item
+		}
+
+		item[strings.ToLower(op.Method)] = operation(g, op, pageMeta)
+	}
+
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":   "CoWatchIt API",
+			"version": Version,
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": g.Defs,
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "The token returned by CreateSession, or a personal API token created on the settings page",
+				},
+				"cookie": map[string]any{
+					"type": "apiKey",
+					"in":   "cookie",
+					"name": config.Default().Session.CookieName,
+				},
+			},
+			"responses": map[string]any{
+				"Error": map[string]any{
+					"description": "The request failed",
+					"content":     jsonContent(g.Ref("ErrorResponse")),
+				},
+			},
+		},
+		"security": []any{
+			map[string]any{"bearer": []string{}},
+			map[string]any{"cookie": []string{}},
+		},
+	}
+}
+
+func operation(g *jsonschema.Generator, op Operation, pageMeta map[string]any) map[string]any {
+	errorRef := map[string]any{"$ref": "#/components/responses/Error"}
+
+	responses := map[string]any{
+		"default": errorRef,
+	}
+
+	for _, status := range errorStatuses(op) {
+		responses[strconv.Itoa(status)] = errorRef
+	}
+
+	success := map[string]any{"description": http.StatusText(op.Status)}
+
+	if op.Response != nil {
+		data := g.SchemaFor(reflect.TypeOf(op.Response), true)
+		properties := map[string]any{"data": data}
+		required := []string{"data"}
+
+		if op.List {
+			properties["data"] = map[string]any{"type": "array", "items": data}
+			properties["meta"] = pageMeta
+			required = append(required, "meta")
+		}
+
+		success["content"] = jsonContent(map[string]any{
+			"type":       "object",
+			"required":   required,
+			"properties": properties,
+		})
+	}
+
+	responses[strconv.Itoa(op.Status)] = success
+
+	result := map[string]any{
+		"operationId": op.ID,
+		"summary":     op.Summary,
+		"tags":        []string{op.Tag},
+		"responses":   responses,
+	}
+
+	if op.Public {
+		result["security"] = []any{}
+	}
+
+	// OpenAPI 3.1 lets non-OAuth schemes list the roles they need
+	if op.Scope != "" {
+		result["security"] = []any{
+			map[string]any{"bearer": []string{op.Scope}},
+			map[string]any{"cookie": []string{}},
+		}
+		result["description"] = "Personal API tokens need the " + op.Scope + " scope."
+	}
+
+	if op.PasswordSession {
+		result["description"] = "Personal API tokens can't call this."
+	}
+
+	if len(op.Params) > 0 {
+		params := make([]any, 0, len(op.Params))
+
+		for _, param := range op.Params {
+			schema := g.SchemaFor(reflect.TypeOf(param.Type), false)
+			if len(param.Enum) > 0 {
+				schema["enum"] = param.Enum
+			}
+
+			spec := map[string]any{
+				"name":     param.Name,
+				"in":       param.In,
+				"required": param.In == "path",
+				"schema":   schema,
+			}
+			if param.Description != "" {
+				spec["description"] = param.Description
+			}
+
+			params = append(params, spec)
+		}
+
+		result["parameters"] = params
+	}
+
+	if op.Request != nil {
+		result["requestBody"] = map[string]any{
+			"required": true,
+			"content":  jsonContent(g.SchemaFor(reflect.TypeOf(op.Request), false)),
+		}
+	}
+
+	return result
+}
+
+// The failures an operation can answer with besides 500
+func errorStatuses(op Operation) []int {
+	statuses := []int{}
+
+	if op.Request != nil || len(op.Params) > 0 {
+		statuses = append(statuses, http.StatusBadRequest)
+	}
+
+	if !op.Public {
+		statuses = append(statuses, http.StatusUnauthorized)
+	}
+
+	if op.Scope != "" || op.PasswordSession {
+		statuses = append(statuses, http.StatusForbidden)
+	}
+
+	for _, param := range op.Params {
+		if param.In == "path" {
+			statuses = append(statuses, http.StatusNotFound)
+			break
+		}
+	}
+
+	if op.Request != nil {
+		statuses = append(statuses, http.StatusUnprocessableEntity)
+	}
+
+	// Every route is behind the per-IP rate limit
+	statuses = append(statuses, http.StatusTooManyRequests)
+
+	return statuses
+}
+
+func jsonContent(schema map[string]any) map[string]any {
+	return map[string]any{
+		"application/json": map[string]any{"schema": schema},
+	}
+}
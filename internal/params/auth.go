@@ -0,0 +1,20 @@
+package params
+
+type AuthParams struct {
+	// Label of the single sign-on button, empty when it is off
+	SSOName       string
+	PasswordLogin bool
+	// Why the last single sign-on attempt failed
+	Error string
+}
+
+type PickUsernameParams struct {
+	Email    string
+	Username string
+	// Per field validation messages
+	Errors map[string][]string
+}
+
+type VerifyTwoFactorParams struct {
+	Error string
+}
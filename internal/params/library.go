@@ -0,0 +1,37 @@
+package params
+
+import (
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/google/uuid"
+)
+
+type LibraryParams struct {
+	Items []models.LibraryItem
+	// Transcoding jobs by item id
+	Jobs map[uuid.UUID]*models.MediaJob
+	// Bytes taken and allowed
+	Used  int64
+	Quota int64
+	Error string
+}
+
+// Job is the item's transcoding job, nil when it has none
+func (p *LibraryParams) Job(itemID uuid.UUID) *models.MediaJob {
+	return p.Jobs[itemID]
+}
+
+// Busy tells whether an upload or job is still going, the list refreshes
+// itself until none is
+func (p *LibraryParams) Busy() bool {
+	for _, item := range p.Items {
+		if !item.Ready() {
+			return true
+		}
+
+		if job := p.Job(item.ID); job != nil && job.Active() {
+			return true
+		}
+	}
+
+	return false
+}
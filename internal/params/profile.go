@@ -0,0 +1,22 @@
+package params
+
+import "github.com/dliluashvili/cowatchit/internal/models"
+
+type EditProfileParams struct {
+	// The saved profile, or the submitted one when it didn't validate
+	User      *models.User
+	CountryID string
+	Countries []models.Country
+	Saved     bool
+	// Per field validation messages
+	Errors map[string][]string
+}
+
+type ProfileParams struct {
+	User        *models.User
+	Country     *models.Country
+	HostedRooms []models.Room
+	SharedRooms []models.Room
+	// Whether the viewer is looking at their own profile
+	Own bool
+}
@@ -0,0 +1,23 @@
+package params
+
+import "github.com/dliluashvili/cowatchit/internal/models"
+
+type APITokensParams struct {
+	Tokens []*models.APIToken
+	// Secret of the token just created, shown once
+	NewToken string
+	// Per field validation messages of the create form
+	Errors map[string][]string
+}
+
+type TwoFactorParams struct {
+	Enabled           bool
+	RecoveryCodesLeft int64
+	// Set while turning two-factor on, until a code confirms it
+	Secret string
+	// data: URL of the QR code PNG
+	QRCode string
+	// Shown once, right after two-factor is turned on
+	RecoveryCodes []string
+	Error         string
+}
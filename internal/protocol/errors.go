@@ -0,0 +1,58 @@
+package protocol
+
+import "fmt"
+
+// ErrorCode is a machine readable reason carried by every ERROR message
+type ErrorCode string
+
+const (
+	CodeInvalidMessage   ErrorCode = "INVALID_MESSAGE"
+	CodeInvalidPayload   ErrorCode = "INVALID_PAYLOAD"
+	CodeValidationFailed ErrorCode = "VALIDATION_FAILED"
+	CodeUnknownEvent     ErrorCode = "UNKNOWN_EVENT"
+	CodeNotInRoom        ErrorCode = "NOT_IN_ROOM"
+	CodeAlreadyInRoom    ErrorCode = "ALREADY_IN_ROOM"
+	CodeRoomNotFound     ErrorCode = "ROOM_NOT_FOUND"
+	CodeRoomFull         ErrorCode = "ROOM_FULL"
+	CodeRoomLocked       ErrorCode = "ROOM_LOCKED"
+	CodeTooManySockets   ErrorCode = "TOO_MANY_SOCKETS"
+	CodeForbidden        ErrorCode = "FORBIDDEN"
+	CodeResumeRejected   ErrorCode = "RESUME_REJECTED"
+	CodeRateLimited      ErrorCode = "RATE_LIMITED"
+	CodeInternal         ErrorCode = "INTERNAL"
+)
+
+// Payload of ERROR, also returned by event handlers as an error
+// to answer the client with a specific code
+type Error struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+}
+
+func Errorf(code ErrorCode, format string, args ...any) *Error {
+	return &Error{
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+var ErrorCodes = []ErrorCode{
+	CodeInvalidMessage,
+	CodeInvalidPayload,
+	CodeValidationFailed,
+	CodeUnknownEvent,
+	CodeNotInRoom,
+	CodeAlreadyInRoom,
+	CodeRoomNotFound,
+	CodeRoomFull,
+	CodeRoomLocked,
+	CodeTooManySockets,
+	CodeForbidden,
+	CodeResumeRejected,
+	CodeRateLimited,
+	CodeInternal,
+}
@@ -0,0 +1,157 @@
+// Package protocol describes the messages exchanged over the room websocket
+package protocol
+
+//go:generate go run ../../cmd/wsschema -out ../../static/src/protocol.schema.json
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+const (
+	Version1 = 1
+
+	CurrentVersion = Version1
+)
+
+var SupportedVersions = []int{Version1}
+
+// Query parameter the client uses to ask for a protocol version on connect
+const VersionQueryParam = "protocol_version"
+
+var ErrUnsupportedVersion = errors.New("unsupported protocol version")
+
+const (
+	TypeEvent = "EVENT"
+	TypeError = "ERROR"
+)
+
+const (
+	StateStop    = "STOP"
+	StatePaused  = "PAUSED"
+	StatePlaying = "PLAYING"
+	StateEnd     = "END"
+)
+
+// Client -> server
+const (
+	EventUserJoinRequest     = "USER_JOIN_REQUEST"
+	EventUserLeaveRequest    = "USER_LEAVE_REQUEST"
+	EventChatMessageSend     = "CHAT_MESSAGE_SEND"
+	EventRoomMessagesRequest = "ROOM_MESSAGES_REQUEST"
+	EventHostStateSend       = "HOST_STATE_SEND"
+	EventUserStateSend       = "USER_STATE_SEND"
+	EventMediaLinkRequest    = "MEDIA_LINK_REQUEST"
+	EventResume              = "RESUME"
+)
+
+// Server -> client
+const (
+	EventIdentify            = "IDENTIFY"
+	EventUserJoinAnswer      = "USER_JOIN_ANSWER"
+	EventUserLeaveAnswer     = "USER_LEAVE_ANSWER"
+	EventUserJoint           = "USER_JOINT"
+	EventUserLeft            = "USER_LEFT"
+	EventChatMessageReceived = "CHAT_MESSAGE_RECEIVED"
+	EventRoomMessagesAnswer  = "ROOM_MESSAGES_ANSWER"
+	EventHostStateReceived   = "HOST_STATE_RECEIVED"
+	EventUserStateReceived   = "USER_STATE_RECEIVED"
+	EventVideoPaused         = "VIDEO_PAUSED"
+	EventVideoPlaying        = "VIDEO_PLAYING"
+	EventRoomMediaChanged    = "ROOM_MEDIA_CHANGED"
+	EventMediaLinkAnswer     = "MEDIA_LINK_ANSWER"
+	EventResumeAnswer        = "RESUME_ANSWER"
+	EventServerRestarting    = "SERVER_RESTARTING"
+)
+
+const MaxRequestIDLength = 64
+
+// Message is the envelope of every websocket frame
+type Message struct {
+	Type  string `json:"type"`
+	Event string `json:"event,omitempty"`
+	// Set by the client on requests and echoed on the reply
+	RequestID string `json:"request_id,omitempty"`
+	// Set on room broadcasts
+	Seq  uint64          `json:"seq,omitempty"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+func NewEvent(event string, data any) (*Message, error) {
+	rawData, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s: %w", event, err)
+	}
+
+	return &Message{
+		Type:  TypeEvent,
+		Event: event,
+		Data:  rawData,
+	}, nil
+}
+
+func NewError(code ErrorCode, message string) *Message {
+	rawData, _ := json.Marshal(Error{
+		Code:    code,
+		Message: message,
+	})
+
+	return &Message{
+		Type:  TypeError,
+		Event: TypeError,
+		Data:  rawData,
+	}
+}
+
+// Correlate the message with the request it answers
+func (m *Message) ReplyTo(req *Message) *Message {
+	if req != nil {
+		m.RequestID = req.RequestID
+	}
+
+	return m
+}
+
+// Decode the payload into v
+func (m *Message) Decode(v any) error {
+	if len(m.Data) == 0 {
+		return json.Unmarshal([]byte("{}"), v)
+	}
+
+	return json.Unmarshal(m.Data, v)
+}
+
+func Encode(m *Message) ([]byte, error) {
+	return json.Marshal(m)
+}
+
+func Parse(data []byte) (*Message, error) {
+	var msg Message
+
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+
+	if len(msg.RequestID) > MaxRequestIDLength {
+		return nil, fmt.Errorf("request_id longer than %d characters", MaxRequestIDLength)
+	}
+
+	return &msg, nil
+}
+
+// Negotiate picks the version to speak, an empty request means the current one
+func Negotiate(requested string) (int, error) {
+	if requested == "" {
+		return CurrentVersion, nil
+	}
+
+	version, err := strconv.Atoi(requested)
+	if err != nil || !slices.Contains(SupportedVersions, version) {
+		return 0, fmt.Errorf("%w: %s", ErrUnsupportedVersion, requested)
+	}
+
+	return version, nil
+}
@@ -0,0 +1,183 @@
+package protocol
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RoomScoped payloads name the room they act on, it has to be the socket's room
+type RoomScoped interface {
+	TargetRoomID() string
+}
+
+// Client -> server payloads
+
+type JoinRequest struct {
+	RoomID string `json:"room_id" validate:"required,uuid"`
+	// Needed for private rooms the user doesn't host
+	Password string `json:"password,omitempty" validate:"omitempty,max=20"`
+}
+
+type LeaveRequest struct{}
+
+type ChatMessageSend struct {
+	RoomID  string `json:"room_id" validate:"required,uuid"`
+	Content string `json:"content" validate:"required,max=250"`
+}
+
+func (p ChatMessageSend) TargetRoomID() string { return p.RoomID }
+
+type RoomMessagesRequest struct {
+	RoomID string `json:"room_id" validate:"required,uuid"`
+}
+
+func (p RoomMessagesRequest) TargetRoomID() string { return p.RoomID }
+
+type HostStateSend struct {
+	RoomID             string  `json:"room_id" validate:"required,uuid"`
+	State              string  `json:"state" validate:"required,oneof=STOP PAUSED PLAYING END"`
+	CurrentTimeSeconds float64 `json:"current_time_seconds" validate:"gte=0"`
+}
+
+func (p HostStateSend) TargetRoomID() string { return p.RoomID }
+
+// Asks for a fresh link to the room's video before the current one expires
+type MediaLinkRequest struct {
+	RoomID string `json:"room_id" validate:"required,uuid"`
+}
+
+func (p MediaLinkRequest) TargetRoomID() string { return p.RoomID }
+
+type ResumeRequest struct {
+	ResumeToken string `json:"resume_token" validate:"required"`
+	LastSeq     uint64 `json:"last_seq"`
+}
+
+// Server -> client payloads
+
+type Identify struct {
+	SocketID          string    `json:"socket_id"`
+	AuthID            uuid.UUID `json:"auth_id"`
+	AuthUsername      string    `json:"auth_username"`
+	ResumeToken       string    `json:"resume_token"`
+	ProtocolVersion   int       `json:"protocol_version"`
+	SupportedVersions []int     `json:"supported_versions"`
+}
+
+type Participant struct {
+	Username string `json:"username"`
+	IsHost   bool   `json:"is_host"`
+}
+
+type JoinAnswer struct {
+	Title  string `json:"title"`
+	Host   string `json:"host"`
+	IsHost bool   `json:"is_host"`
+	Src    string `json:"src"`
+	// Zero when src doesn't expire
+	SrcExpiresInSeconds int64   `json:"src_expires_in_seconds"`
+	State               string  `json:"state"`
+	CurrentTimeSeconds  float64 `json:"current_time_seconds"`
+	// Zero when unknown
+	DurationSeconds float64                   `json:"duration_seconds"`
+	Participants    map[uuid.UUID]Participant `json:"participants"`
+}
+
+type LeaveAnswer struct {
+	RoomID   uuid.UUID `json:"room_id"`
+	SocketID string    `json:"socket_id"`
+	Message  string    `json:"message"`
+}
+
+type UserJoint struct {
+	IsHost              bool      `json:"is_host"`
+	UserID              uuid.UUID `json:"user_id"`
+	Username            string    `json:"username"`
+	CountedParticipants int       `json:"counted_participants"`
+}
+
+type UserLeft struct {
+	IsHost              bool      `json:"is_host"`
+	UserID              uuid.UUID `json:"user_id"`
+	Username            string    `json:"username"`
+	SocketID            string    `json:"socket_id"`
+	CountedParticipants int       `json:"counted_participants"`
+}
+
+type ChatMessage struct {
+	ID             uuid.UUID `json:"id"`
+	RoomID         uuid.UUID `json:"room_id"`
+	SenderID       uuid.UUID `json:"sender_id"`
+	SenderUsername string    `json:"sender_username"`
+	Content        string    `json:"content"`
+	IsHost         bool      `json:"is_host"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type RoomMessagesAnswer struct {
+	Messages []ChatMessage `json:"messages"`
+	AuthID   uuid.UUID     `json:"auth_id"`
+}
+
+type HostStateReceived struct {
+	State              string  `json:"state"`
+	CurrentTimeSeconds float64 `json:"current_time_seconds"`
+}
+
+// Sent when the room's video moves to a new source, such as once a library
+// video is transcoded
+type RoomMediaChanged struct {
+	Src                 string  `json:"src"`
+	SrcExpiresInSeconds int64   `json:"src_expires_in_seconds"`
+	DurationSeconds     float64 `json:"duration_seconds"`
+}
+
+// A participant's new link to the room's video. It points to another file
+// than the last one when the video changed in the meantime.
+type MediaLinkAnswer struct {
+	Src                 string `json:"src"`
+	SrcExpiresInSeconds int64  `json:"src_expires_in_seconds"`
+}
+
+type ResumeAnswer struct {
+	SocketID       string    `json:"socket_id"`
+	RoomID         uuid.UUID `json:"room_id"`
+	ResumeToken    string    `json:"resume_token"`
+	ReplayComplete bool      `json:"replay_complete"`
+}
+
+// Sent to every socket before the server shuts down
+type ServerRestarting struct {
+	// Wait at least this long before reconnecting
+	ReconnectAfterMs int64  `json:"reconnect_after_ms"`
+	Message          string `json:"message"`
+}
+
+// Requests maps every client event to its payload
+var Requests = map[string]any{
+	EventUserJoinRequest:     JoinRequest{},
+	EventUserLeaveRequest:    LeaveRequest{},
+	EventChatMessageSend:     ChatMessageSend{},
+	EventRoomMessagesRequest: RoomMessagesRequest{},
+	EventHostStateSend:       HostStateSend{},
+	EventMediaLinkRequest:    MediaLinkRequest{},
+	EventResume:              ResumeRequest{},
+}
+
+// Responses maps every server event to its payload
+var Responses = map[string]any{
+	EventIdentify:            Identify{},
+	EventUserJoinAnswer:      JoinAnswer{},
+	EventUserLeaveAnswer:     LeaveAnswer{},
+	EventUserJoint:           UserJoint{},
+	EventUserLeft:            UserLeft{},
+	EventChatMessageReceived: ChatMessage{},
+	EventRoomMessagesAnswer:  RoomMessagesAnswer{},
+	EventHostStateReceived:   HostStateReceived{},
+	EventRoomMediaChanged:    RoomMediaChanged{},
+	EventMediaLinkAnswer:     MediaLinkAnswer{},
+	EventResumeAnswer:        ResumeAnswer{},
+	EventServerRestarting:    ServerRestarting{},
+	TypeError:                Error{},
+}
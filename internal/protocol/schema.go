@@ -0,0 +1,63 @@
+package protocol
+
+import (
+	"reflect"
+	"slices"
+
+	"github.com/dliluashvili/cowatchit/internal/jsonschema"
+)
+
+const SchemaDraft = jsonschema.Draft
+
+// Schema describes the envelope, every event payload and the error codes
+// as a JSON Schema document, the TypeScript client generates its types from it
+func Schema() map[string]any {
+	g := jsonschema.New("#/$defs/")
+
+	g.Defs["ErrorCode"] = map[string]any{
+		"type": "string",
+		"enum": ErrorCodes,
+	}
+	g.Map(reflect.TypeOf(ErrorCode("")), g.Ref("ErrorCode"))
+	g.Map(reflect.TypeOf(Message{}.Data), map[string]any{})
+
+	requests := map[string]any{}
+	for event, payload := range Requests {
+		requests[event] = g.SchemaFor(reflect.TypeOf(payload), false)
+	}
+
+	responses := map[string]any{}
+	for event, payload := range Responses {
+		responses[event] = g.SchemaFor(reflect.TypeOf(payload), true)
+	}
+
+	events := make([]string, 0, len(requests)+len(responses))
+	for event := range requests {
+		events = append(events, event)
+	}
+	for event := range responses {
+		if !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+	slices.Sort(events)
+
+	return map[string]any{
+		"$schema":     SchemaDraft,
+		"title":       "CoWatchIt websocket protocol",
+		"x-version":   CurrentVersion,
+		"x-supported": SupportedVersions,
+		"x-requests":  requests,
+		"x-responses": responses,
+		"$defs":       g.Defs,
+		"type":        "object",
+		"required":    []string{"type"},
+		"properties": map[string]any{
+			"type":       map[string]any{"type": "string", "enum": []string{TypeEvent, TypeError}},
+			"event":      map[string]any{"type": "string", "enum": events},
+			"request_id": map[string]any{"type": "string", "maxLength": MaxRequestIDLength},
+			"seq":        map[string]any{"type": "integer", "minimum": 0},
+			"data":       map[string]any{"type": "object"},
+		},
+	}
+}
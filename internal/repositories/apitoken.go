@@ -0,0 +1,108 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dliluashvili/cowatchit/internal/dtos"
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+type APITokenRepository struct {
+	db      *gorm.DB
+	timeout time.Duration
+}
+
+func NewAPITokenRepository(db *gorm.DB, timeout time.Duration) *APITokenRepository {
+	return &APITokenRepository{
+		db:      db,
+		timeout: timeout,
+	}
+}
+
+func (r *APITokenRepository) Create(ctx context.Context, dto *dtos.CreateAPITokenRepoDto) (*models.APIToken, error) {
+	token := &models.APIToken{
+		ID:        uuid.New(),
+		UserID:    dto.UserID,
+		Name:      dto.Name,
+		Prefix:    dto.Prefix,
+		Hash:      dto.Hash,
+		Scopes:    pq.StringArray(dto.Scopes),
+		ExpiresAt: dto.ExpiresAt,
+	}
+
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	if err := db.Create(token).Error; err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// Revoked tokens are found too, callers decide what to do with them
+func (r *APITokenRepository) FindByHash(ctx context.Context, hash string) (*models.APIToken, error) {
+	var token models.APIToken
+
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	err := db.Where("hash = ?", hash).First(&token).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// The user's tokens that are not revoked, newest first
+func (r *APITokenRepository) FindByUser(ctx context.Context, userID uuid.UUID) ([]*models.APIToken, error) {
+	var tokens []*models.APIToken
+
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	result := db.Where("user_id = ? AND revoked_at IS NULL", userID).Order("created_at DESC, id").Find(&tokens)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return tokens, nil
+}
+
+func (r *APITokenRepository) Revoke(ctx context.Context, userID, ID uuid.UUID) error {
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	result := db.Model(&models.APIToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", ID, userID).
+		Update("revoked_at", time.Now())
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *APITokenRepository) UpdateLastUsed(ctx context.Context, ID uuid.UUID, at time.Time) error {
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	return db.Model(&models.APIToken{}).Where("id = ?", ID).Update("last_used_at", at).Error
+}
@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type CountryRepository struct {
+	db      *gorm.DB
+	timeout time.Duration
+}
+
+func NewCountryRepository(db *gorm.DB, timeout time.Duration) *CountryRepository {
+	return &CountryRepository{
+		db:      db,
+		timeout: timeout,
+	}
+}
+
+func (r *CountryRepository) FindAll(ctx context.Context) ([]models.Country, error) {
+	var countries []models.Country
+
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	if err := db.Order("title").Find(&countries).Error; err != nil {
+		return nil, err
+	}
+
+	return countries, nil
+}
+
+func (r *CountryRepository) FindOne(ctx context.Context, ID uuid.UUID) (*models.Country, error) {
+	var country models.Country
+
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	err := db.Where("id = ?", ID).First(&country).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &country, nil
+}
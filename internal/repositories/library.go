@@ -0,0 +1,217 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dliluashvili/cowatchit/internal/dtos"
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LibraryRepository struct {
+	db      *gorm.DB
+	timeout time.Duration
+}
+
+func NewLibraryRepository(db *gorm.DB, timeout time.Duration) *LibraryRepository {
+	return &LibraryRepository{
+		db:      db,
+		timeout: timeout,
+	}
+}
+
+// Create adds the item unless the user's items would then take more than
+// dto.Quota, ErrQuotaExceeded. The user's row is locked while summing, so
+// uploads started at the same time can't both fit in the same space.
+func (r *LibraryRepository) Create(ctx context.Context, dto *dtos.CreateLibraryItemRepoDto) (*models.LibraryItem, error) {
+	item := &models.LibraryItem{
+		ID:          uuid.New(),
+		UserID:      dto.UserID,
+		Title:       dto.Title,
+		Filename:    dto.Filename,
+		ContentType: dto.ContentType,
+		Size:        dto.Size,
+		Status:      models.LibraryItemUploading,
+	}
+
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").
+			Where("id = ?", dto.UserID).
+			First(&user).Error
+
+		if err != nil {
+			return err
+		}
+
+		var used int64
+
+		err = tx.Model(&models.LibraryItem{}).
+			Where("user_id = ?", dto.UserID).
+			Select("COALESCE(SUM(size), 0)").
+			Scan(&used).Error
+
+		if err != nil {
+			return err
+		}
+
+		if used+dto.Size > dto.Quota {
+			return ErrQuotaExceeded
+		}
+
+		return tx.Create(item).Error
+	})
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// Uploads resume right after a chunk is written, so this must not read a replica
+func (r *LibraryRepository) FindOne(ctx context.Context, ID uuid.UUID) (*models.LibraryItem, error) {
+	var item models.LibraryItem
+
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	err := db.Where("id = ?", ID).First(&item).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &item, nil
+}
+
+// The user's items, newest first
+func (r *LibraryRepository) FindByUser(ctx context.Context, userID uuid.UUID) ([]models.LibraryItem, error) {
+	var items []models.LibraryItem
+
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	result := db.Where("user_id = ?", userID).Order("created_at DESC, id").Find(&items)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return items, nil
+}
+
+// UsedBytes counts the full size of every item, finished or not
+func (r *LibraryRepository) UsedBytes(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var used int64
+
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	err := db.Model(&models.LibraryItem{}).
+		Where("user_id = ?", userID).
+		Select("COALESCE(SUM(size), 0)").
+		Scan(&used).Error
+
+	return used, err
+}
+
+// UpdateReceived moves the offset from one value to another, ErrNotFound
+// when it was no longer from, another chunk got there first
+func (r *LibraryRepository) UpdateReceived(ctx context.Context, ID uuid.UUID, from, to int64) error {
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	result := db.Model(&models.LibraryItem{}).
+		Where("id = ? AND received = ? AND status = ?", ID, from, models.LibraryItemUploading).
+		Update("received", to)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *LibraryRepository) MarkReady(ctx context.Context, ID uuid.UUID, key string) error {
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	return db.Model(&models.LibraryItem{}).Where("id = ?", ID).Updates(map[string]any{
+		"key":    key,
+		"status": models.LibraryItemReady,
+	}).Error
+}
+
+// InUse tells whether any room plays the item
+func (r *LibraryRepository) InUse(ctx context.Context, ID uuid.UUID) (bool, error) {
+	var count int64
+
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	result := db.Where("library_item_id = ?", ID).Model(&models.Room{}).Count(&count)
+
+	return count > 0, result.Error
+}
+
+func (r *LibraryRepository) Delete(ctx context.Context, ID uuid.UUID) error {
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	return db.Delete(&models.LibraryItem{}, "id = ?", ID).Error
+}
+
+// SetProbe saves what probing found out about the item's file
+func (r *LibraryRepository) SetProbe(ctx context.Context, ID uuid.UUID, dto *dtos.LibraryItemProbeDto) error {
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	return db.Model(&models.LibraryItem{}).Where("id = ?", ID).Updates(map[string]any{
+		"duration_seconds": dto.DurationSeconds,
+		"width":            dto.Width,
+		"height":           dto.Height,
+		"video_codec":      dto.VideoCodec,
+		"audio_codec":      dto.AudioCodec,
+	}).Error
+}
+
+// SetManifest points the item at its HLS master playlist, ErrNotFound when
+// the item was deleted meanwhile
+func (r *LibraryRepository) SetManifest(ctx context.Context, ID uuid.UUID, key string) error {
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	result := db.Model(&models.LibraryItem{}).Where("id = ?", ID).Update("manifest_key", key)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type MediaJobRepository struct {
+	db      *gorm.DB
+	timeout time.Duration
+}
+
+func NewMediaJobRepository(db *gorm.DB, timeout time.Duration) *MediaJobRepository {
+	return &MediaJobRepository{
+		db:      db,
+		timeout: timeout,
+	}
+}
+
+// Enqueue queues a job for the item, starting over one that already exists
+func (r *MediaJobRepository) Enqueue(ctx context.Context, itemID uuid.UUID) error {
+	job := &models.MediaJob{
+		ID:            uuid.New(),
+		LibraryItemID: itemID,
+		Status:        models.MediaJobQueued,
+	}
+
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "library_item_id"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"status":      models.MediaJobQueued,
+			"stage":       "",
+			"progress":    0,
+			"attempts":    0,
+			"error":       "",
+			"lease_until": nil,
+			"updated_at":  time.Now(),
+		}),
+	}).Create(job).Error
+}
+
+// The jobs of the given items, items without one are left out
+func (r *MediaJobRepository) FindByItems(ctx context.Context, itemIDs []uuid.UUID) ([]models.MediaJob, error) {
+	var jobs []models.MediaJob
+
+	if len(itemIDs) == 0 {
+		return jobs, nil
+	}
+
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	result := db.Where("library_item_id IN ?", itemIDs).Find(&jobs)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return jobs, nil
+}
+
+// Claim takes the oldest queued job, or a running one whose worker let its
+// lease run out, and leases it for lease. ErrNotFound when there is none.
+// Concurrent claims skip each other's rows instead of waiting on them.
+func (r *MediaJobRepository) Claim(ctx context.Context, lease time.Duration) (*models.MediaJob, error) {
+	var job models.MediaJob
+
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? OR (status = ? AND lease_until < ?)", models.MediaJobQueued, models.MediaJobRunning, now).
+			Order("created_at, id").
+			First(&job).Error
+
+		if err != nil {
+			return err
+		}
+
+		leaseUntil := now.Add(lease)
+
+		job.Status = models.MediaJobRunning
+		job.Attempts++
+		job.LeaseUntil = &leaseUntil
+
+		return tx.Model(&job).Updates(map[string]any{
+			"status":      job.Status,
+			"attempts":    job.Attempts,
+			"lease_until": leaseUntil,
+		}).Error
+	})
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// Progress records how far a running job got and extends its lease,
+// ErrNotFound when the job is gone or no longer running
+func (r *MediaJobRepository) Progress(ctx context.Context, ID uuid.UUID, stage string, progress int, lease time.Duration) error {
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	result := db.Model(&models.MediaJob{}).
+		Where("id = ? AND status = ?", ID, models.MediaJobRunning).
+		Updates(map[string]any{
+			"stage":       stage,
+			"progress":    progress,
+			"lease_until": time.Now().Add(lease),
+		})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *MediaJobRepository) Finish(ctx context.Context, ID uuid.UUID) error {
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	return db.Model(&models.MediaJob{}).Where("id = ?", ID).Updates(map[string]any{
+		"status":      models.MediaJobReady,
+		"stage":       "",
+		"progress":    100,
+		"error":       "",
+		"lease_until": nil,
+	}).Error
+}
+
+// Fail records why the job failed, it is queued again when retry is set
+func (r *MediaJobRepository) Fail(ctx context.Context, ID uuid.UUID, reason string, retry bool) error {
+	status := models.MediaJobFailed
+	if retry {
+		status = models.MediaJobQueued
+	}
+
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	return db.Model(&models.MediaJob{}).Where("id = ?", ID).Updates(map[string]any{
+		"status":      status,
+		"stage":       "",
+		"progress":    0,
+		"error":       reason,
+		"lease_until": nil,
+	}).Error
+}
+
+// Release hands a job back to the queue without counting the attempt, for
+// a worker that stops before finishing it
+func (r *MediaJobRepository) Release(ctx context.Context, ID uuid.UUID) error {
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	return db.Model(&models.MediaJob{}).
+		Where("id = ? AND status = ?", ID, models.MediaJobRunning).
+		Updates(map[string]any{
+			"status":      models.MediaJobQueued,
+			"stage":       "",
+			"progress":    0,
+			"attempts":    gorm.Expr("GREATEST(attempts - 1, 0)"),
+			"lease_until": nil,
+		}).Error
+}
@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dliluashvili/cowatchit/db"
+	"github.com/dliluashvili/cowatchit/internal/dtos"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// Returned when the record to read or change doesn't exist
+var ErrNotFound = errors.New("record not found")
+
+// Returned when a write would take a user over their quota
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// withContext binds a call to ctx, so it stops when the caller goes away,
+// and bounds it by timeout when that is positive. Inside Transaction the
+// call runs on the transaction.
+func withContext(ctx context.Context, db *gorm.DB, timeout time.Duration) (*gorm.DB, context.CancelFunc) {
+	cancel := context.CancelFunc(func() {})
+
+	if tx, ok := ctx.Value(txContextKey{}).(*gorm.DB); ok {
+		db = tx
+	}
+
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+
+	return db.WithContext(ctx), cancel
+}
+
+// replica sends a read to a read replica, for listings that can show data
+// a moment old (replicas may lag behind). Everything else reads the primary.
+func replica(query *gorm.DB) *gorm.DB {
+	return query.Clauses(dbresolver.Use(db.ReplicaResolver), dbresolver.Read)
+}
+
+// paginate applies the page window, a zero limit selects everything
+func paginate(db *gorm.DB, page dtos.PageDto) *gorm.DB {
+	if page.Limit > 0 {
+		db = db.Limit(page.Limit)
+	}
+
+	if page.Offset > 0 {
+		db = db.Offset(page.Offset)
+	}
+
+	return db
+}
@@ -0,0 +1,82 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type RecoveryCodeRepository struct {
+	db      *gorm.DB
+	timeout time.Duration
+}
+
+func NewRecoveryCodeRepository(db *gorm.DB, timeout time.Duration) *RecoveryCodeRepository {
+	return &RecoveryCodeRepository{
+		db:      db,
+		timeout: timeout,
+	}
+}
+
+// Replace swaps the user's codes for new ones, run it in a transaction
+// so the old codes survive a failed insert
+func (r *RecoveryCodeRepository) Replace(ctx context.Context, userID uuid.UUID, hashes []string) error {
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	if err := db.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+
+	codes := make([]*models.RecoveryCode, 0, len(hashes))
+	for _, hash := range hashes {
+		codes = append(codes, &models.RecoveryCode{
+			ID:     uuid.New(),
+			UserID: userID,
+			Hash:   hash,
+		})
+	}
+
+	return db.Create(codes).Error
+}
+
+// Use marks an unused code as used, ErrNotFound when there is none
+func (r *RecoveryCodeRepository) Use(ctx context.Context, userID uuid.UUID, hash string) error {
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	result := db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", time.Now())
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *RecoveryCodeRepository) CountUnused(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var count int64
+
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	err := db.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count).Error
+
+	return count, err
+}
+
+func (r *RecoveryCodeRepository) DeleteByUser(ctx context.Context, userID uuid.UUID) error {
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	return db.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+}
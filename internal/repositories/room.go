@@ -1,6 +1,10 @@
 package repositories
 
 import (
+	"context"
+	"errors"
+	"time"
+
 	"github.com/dliluashvili/cowatchit/internal/dtos"
 	"github.com/dliluashvili/cowatchit/internal/models"
 	"github.com/google/uuid"
@@ -8,16 +12,18 @@ import (
 )
 
 type RoomRepository struct {
-	db *gorm.DB
+	db      *gorm.DB
+	timeout time.Duration
 }
 
-func NewRoomRepository(db *gorm.DB) *RoomRepository {
+func NewRoomRepository(db *gorm.DB, timeout time.Duration) *RoomRepository {
 	return &RoomRepository{
-		db: db,
+		db:      db,
+		timeout: timeout,
 	}
 }
 
-func (rp *RoomRepository) Create(dto *dtos.CreateRoomRepoDto) (*models.Room, error) {
+func (rp *RoomRepository) Create(ctx context.Context, dto *dtos.CreateRoomRepoDto) (*models.Room, error) {
 
 	hidden := false
 
@@ -26,19 +32,23 @@ func (rp *RoomRepository) Create(dto *dtos.CreateRoomRepoDto) (*models.Room, err
 	}
 
 	room := &models.Room{
-		ID:           uuid.New(),
-		HostID:       dto.HostID,
-		HostUsername: dto.HostUsername,
-		Title:        dto.Title,
-		Description:  dto.Description,
-		Src:          dto.Src,
-		Capacity:     dto.Capacity,
-		Private:      dto.Private,
-		Password:     dto.Password,
-		Hidden:       hidden,
+		ID:            uuid.New(),
+		HostID:        dto.HostID,
+		HostUsername:  dto.HostUsername,
+		Title:         dto.Title,
+		Description:   dto.Description,
+		Src:           dto.Src,
+		LibraryItemID: dto.LibraryItemID,
+		Capacity:      dto.Capacity,
+		Private:       dto.Private,
+		Password:      dto.Password,
+		Hidden:        hidden,
 	}
 
-	result := rp.db.Create(room)
+	db, cancel := withContext(ctx, rp.db, rp.timeout)
+	defer cancel()
+
+	result := db.Create(room)
 
 	if result.Error != nil {
 		return nil, result.Error
@@ -47,10 +57,11 @@ func (rp *RoomRepository) Create(dto *dtos.CreateRoomRepoDto) (*models.Room, err
 	return room, nil
 }
 
-func (rp *RoomRepository) Find(dto *dtos.FindRoomDto) ([]models.Room, error) {
+func (rp *RoomRepository) Find(ctx context.Context, dto *dtos.FindRoomDto) ([]models.Room, error) {
 	var rooms []models.Room
 
-	query := rp.db
+	query, cancel := withContext(ctx, rp.db, rp.timeout)
+	defer cancel()
 
 	if dto.Filter != nil && *dto.Filter != "" {
 		switch *dto.Filter {
@@ -61,15 +72,19 @@ func (rp *RoomRepository) Find(dto *dtos.FindRoomDto) ([]models.Room, error) {
 		}
 	}
 
-	if dto.My != nil {
-		query.Where("host_id = ?", dto.AuthUserID)
+	if dto.My != nil && *dto.My {
+		query = query.Where("host_id = ?", dto.AuthUserID)
+	}
+
+	if dto.HostID != nil {
+		query = query.Where("host_id = ?", dto.HostID)
 	}
 
 	if dto.Keyword != nil && *dto.Keyword != "" {
-		query.Where("title ILIKE ?", "%"+*dto.Keyword+"%")
+		query = query.Where("title ILIKE ?", "%"+*dto.Keyword+"%")
 	}
 
-	result := query.Find(&rooms)
+	result := paginate(replica(query).Order("created_at DESC, id"), dto.Page).Find(&rooms)
 
 	if result.Error != nil {
 		return nil, result.Error
@@ -78,19 +93,114 @@ func (rp *RoomRepository) Find(dto *dtos.FindRoomDto) ([]models.Room, error) {
 	return rooms, nil
 }
 
-func (rp *RoomRepository) FindOne(ID uuid.UUID) (*models.Room, error) {
+// FindShared lists the rooms both users have joined, the most recently
+// joined by userID first
+func (rp *RoomRepository) FindShared(ctx context.Context, userID, otherID uuid.UUID, page dtos.PageDto) ([]models.Room, error) {
+	var rooms []models.Room
+
+	db, cancel := withContext(ctx, rp.db, rp.timeout)
+	defer cancel()
+
+	result := paginate(
+		db.Joins("JOIN room_users mine ON mine.room_id = rooms.id AND mine.user_id = ?", userID).
+			Where("EXISTS (SELECT 1 FROM room_users theirs WHERE theirs.room_id = rooms.id AND theirs.user_id = ?)", otherID).
+			Order("mine.updated_at DESC, rooms.id"),
+		page,
+	).Find(&rooms)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return rooms, nil
+}
+
+// The rooms playing a library item
+func (rp *RoomRepository) FindByLibraryItem(ctx context.Context, itemID uuid.UUID) ([]models.Room, error) {
+	var rooms []models.Room
+
+	db, cancel := withContext(ctx, rp.db, rp.timeout)
+	defer cancel()
+
+	result := db.Where("library_item_id = ?", itemID).Find(&rooms)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return rooms, nil
+}
+
+func (rp *RoomRepository) FindOne(ctx context.Context, ID uuid.UUID) (*models.Room, error) {
 	var room models.Room
 
-	result := rp.db.Where("id = ?", ID).First(&room)
+	db, cancel := withContext(ctx, rp.db, rp.timeout)
+	defer cancel()
+
+	result := db.Where("id = ?", ID).First(&room)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
 
 	return &room, result.Error
 }
 
-func (rp *RoomRepository) Exists(ID uuid.UUID) (bool, error) {
+func (rp *RoomRepository) Exists(ctx context.Context, ID uuid.UUID) (bool, error) {
 	var count int64
-	result := rp.db.Where("id = ?", ID).Model(&models.Room{}).Count(&count)
+
+	db, cancel := withContext(ctx, rp.db, rp.timeout)
+	defer cancel()
+
+	result := db.Where("id = ?", ID).Model(&models.Room{}).Count(&count)
 
 	return count > 0, result.Error
 }
 
+func (rp *RoomRepository) Update(ctx context.Context, ID uuid.UUID, updates map[string]any) error {
+	if len(updates) == 0 {
+		return nil
+	}
+
+	db, cancel := withContext(ctx, rp.db, rp.timeout)
+	defer cancel()
+
+	result := db.Model(&models.Room{}).Where("id = ?", ID).Updates(updates)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (rp *RoomRepository) Delete(ctx context.Context, ID uuid.UUID) error {
+	db, cancel := withContext(ctx, rp.db, rp.timeout)
+	defer cancel()
+
+	result := db.Where("id = ?", ID).Delete(&models.Room{})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
 func (rp *RoomRepository) Join() {}
+
+// UpdateHostUsername rewrites the username copied into the host's rooms
+func (rp *RoomRepository) UpdateHostUsername(ctx context.Context, hostID uuid.UUID, username string) error {
+	db, cancel := withContext(ctx, rp.db, rp.timeout)
+	defer cancel()
+
+	return db.Model(&models.Room{}).Where("host_id = ?", hostID).Update("host_username", username).Error
+}
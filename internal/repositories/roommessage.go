@@ -1,6 +1,9 @@
 package repositories
 
 import (
+	"context"
+	"time"
+
 	"github.com/dliluashvili/cowatchit/internal/dtos"
 	"github.com/dliluashvili/cowatchit/internal/models"
 	"github.com/google/uuid"
@@ -8,16 +11,18 @@ import (
 )
 
 type RoomMessageRepository struct {
-	db *gorm.DB
+	db      *gorm.DB
+	timeout time.Duration
 }
 
-func NewRoomMessageRepository(db *gorm.DB) *RoomMessageRepository {
+func NewRoomMessageRepository(db *gorm.DB, timeout time.Duration) *RoomMessageRepository {
 	return &RoomMessageRepository{
-		db: db,
+		db:      db,
+		timeout: timeout,
 	}
 }
 
-func (rmp *RoomMessageRepository) Create(createRoomMessageDto *dtos.CreateRoomMessageDto) (*models.RoomMessage, error) {
+func (rmp *RoomMessageRepository) Create(ctx context.Context, createRoomMessageDto *dtos.CreateRoomMessageDto) (*models.RoomMessage, error) {
 	roomMessage := &models.RoomMessage{
 		ID:             uuid.New(),
 		Content:        createRoomMessageDto.Content,
@@ -27,7 +32,10 @@ func (rmp *RoomMessageRepository) Create(createRoomMessageDto *dtos.CreateRoomMe
 		IsHost:         createRoomMessageDto.IsHost,
 	}
 
-	result := rmp.db.Create(roomMessage)
+	db, cancel := withContext(ctx, rmp.db, rmp.timeout)
+	defer cancel()
+
+	result := db.Create(roomMessage)
 
 	if result.Error != nil {
 		return nil, result.Error
@@ -36,10 +44,13 @@ func (rmp *RoomMessageRepository) Create(createRoomMessageDto *dtos.CreateRoomMe
 	return roomMessage, nil
 }
 
-func (rmp *RoomMessageRepository) FindByRoom(roomID uuid.UUID) ([]*models.RoomMessage, error) {
+func (rmp *RoomMessageRepository) FindByRoom(ctx context.Context, roomID uuid.UUID, page dtos.PageDto) ([]*models.RoomMessage, error) {
 	var roomMessages []*models.RoomMessage
 
-	result := rmp.db.Where("room_id = ?", roomID).Find(&roomMessages)
+	db, cancel := withContext(ctx, rmp.db, rmp.timeout)
+	defer cancel()
+
+	result := paginate(replica(db).Where("room_id = ?", roomID).Order("created_at, id"), page).Find(&roomMessages)
 
 	if result.Error != nil {
 		return nil, result.Error
@@ -47,3 +58,18 @@ func (rmp *RoomMessageRepository) FindByRoom(roomID uuid.UUID) ([]*models.RoomMe
 
 	return roomMessages, nil
 }
+
+func (rmp *RoomMessageRepository) DeleteByRoom(ctx context.Context, roomID uuid.UUID) error {
+	db, cancel := withContext(ctx, rmp.db, rmp.timeout)
+	defer cancel()
+
+	return db.Where("room_id = ?", roomID).Delete(&models.RoomMessage{}).Error
+}
+
+// UpdateSenderUsername rewrites the username copied into the sender's messages
+func (rmp *RoomMessageRepository) UpdateSenderUsername(ctx context.Context, senderID uuid.UUID, username string) error {
+	db, cancel := withContext(ctx, rmp.db, rmp.timeout)
+	defer cancel()
+
+	return db.Model(&models.RoomMessage{}).Where("sender_id = ?", senderID).Update("sender_username", username).Error
+}
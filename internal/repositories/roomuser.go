@@ -0,0 +1,41 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RoomUserRepository struct {
+	db      *gorm.DB
+	timeout time.Duration
+}
+
+func NewRoomUserRepository(db *gorm.DB, timeout time.Duration) *RoomUserRepository {
+	return &RoomUserRepository{
+		db:      db,
+		timeout: timeout,
+	}
+}
+
+// Add records that the user joined the room, joining again only moves
+// its updated_at
+func (r *RoomUserRepository) Add(ctx context.Context, roomID, userID uuid.UUID) error {
+	roomUser := &models.RoomUser{
+		ID:     uuid.New(),
+		RoomID: roomID,
+		UserID: userID,
+	}
+
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "room_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"updated_at"}),
+	}).Create(roomUser).Error
+}
@@ -1,6 +1,7 @@
 package repositories
 
 import (
+	"context"
 	"errors"
 	"time"
 
@@ -12,16 +13,18 @@ import (
 )
 
 type UserRepository struct {
-	db *gorm.DB
+	db      *gorm.DB
+	timeout time.Duration
 }
 
-func NewUserRepository(db *gorm.DB) *UserRepository {
+func NewUserRepository(db *gorm.DB, timeout time.Duration) *UserRepository {
 	return &UserRepository{
-		db: db,
+		db:      db,
+		timeout: timeout,
 	}
 }
 
-func (r *UserRepository) Update(userID uuid.UUID, updates map[string]any) error {
+func (r *UserRepository) Update(ctx context.Context, userID uuid.UUID, updates map[string]any) error {
 	if len(updates) == 0 {
 		return nil
 	}
@@ -38,10 +41,13 @@ func (r *UserRepository) Update(userID uuid.UUID, updates map[string]any) error
 		safeUpdates[k] = v
 	}
 
-	return r.db.Model(&models.User{}).Where("id = ?", userID).Updates(safeUpdates).Error
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	return db.Model(&models.User{}).Where("id = ?", userID).Updates(safeUpdates).Error
 }
 
-func (r *UserRepository) Create(dto *dtos.CreateUserDto) (*models.User, error) {
+func (r *UserRepository) Create(ctx context.Context, dto *dtos.CreateUserDto) (*models.User, error) {
 	user := models.User{
 		ID:          uuid.New(),
 		Username:    *dto.Username,
@@ -53,7 +59,10 @@ func (r *UserRepository) Create(dto *dtos.CreateUserDto) (*models.User, error) {
 		DeletedAt:   nil,
 	}
 
-	result := r.db.Create(&user)
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	result := db.Create(&user)
 
 	if result.Error != nil {
 		return nil, result.Error
@@ -62,8 +71,9 @@ func (r *UserRepository) Create(dto *dtos.CreateUserDto) (*models.User, error) {
 	return &user, nil
 }
 
-func (r *UserRepository) FindByField(params map[string]any) (*models.User, error) {
-	query := r.db
+func (r *UserRepository) FindByField(ctx context.Context, params map[string]any) (*models.User, error) {
+	query, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
 
 	for k, v := range params {
 		if k == "" {
@@ -86,8 +96,11 @@ func (r *UserRepository) FindByField(params map[string]any) (*models.User, error
 	return &u, nil
 }
 
-func (r *UserRepository) Delete(ID uuid.UUID) (*bool, error) {
-	result := r.db.Model(&models.User{}).
+func (r *UserRepository) Delete(ctx context.Context, ID uuid.UUID) (*bool, error) {
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	result := db.Model(&models.User{}).
 		Where("id = ?", ID).
 		Updates(map[string]any{
 			"deletedAt": time.Now().UTC(),
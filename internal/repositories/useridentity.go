@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dliluashvili/cowatchit/internal/dtos"
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type UserIdentityRepository struct {
+	db      *gorm.DB
+	timeout time.Duration
+}
+
+func NewUserIdentityRepository(db *gorm.DB, timeout time.Duration) *UserIdentityRepository {
+	return &UserIdentityRepository{
+		db:      db,
+		timeout: timeout,
+	}
+}
+
+func (r *UserIdentityRepository) Create(ctx context.Context, dto *dtos.CreateUserIdentityDto) (*models.UserIdentity, error) {
+	identity := &models.UserIdentity{
+		ID:      uuid.New(),
+		UserID:  dto.UserID,
+		Issuer:  dto.Issuer,
+		Subject: dto.Subject,
+		Email:   dto.Email,
+	}
+
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	if err := db.Create(identity).Error; err != nil {
+		return nil, err
+	}
+
+	return identity, nil
+}
+
+func (r *UserIdentityRepository) FindBySubject(ctx context.Context, issuer, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+
+	db, cancel := withContext(ctx, r.db, r.timeout)
+	defer cancel()
+
+	err := db.Where("issuer = ? AND subject = ?", issuer, subject).First(&identity).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &identity, nil
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dliluashvili/cowatchit/internal/dtos"
+	"github.com/dliluashvili/cowatchit/internal/helpers"
+	"github.com/dliluashvili/cowatchit/internal/logging"
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/dliluashvili/cowatchit/internal/repositories"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrAPITokenInvalid  = errors.New("invalid, expired or revoked api token")
+	ErrAPITokenNotFound = errors.New("api token not found")
+)
+
+const (
+	// Tells personal tokens apart from session ids in Authorization headers
+	APITokenPrefix = "cwi_"
+	// Characters after APITokenPrefix kept to recognize a token in listings
+	apiTokenPrefixLength = 8
+	// Uses closer together than this don't update last_used_at
+	apiTokenTouchInterval = time.Minute
+)
+
+type APITokenService struct {
+	repository  APITokenRepository
+	userService *UserService
+}
+
+func NewAPITokenService(r APITokenRepository, us *UserService) *APITokenService {
+	return &APITokenService{
+		repository:  r,
+		userService: us,
+	}
+}
+
+// Whether a bearer value is a personal token rather than a session id
+func IsAPIToken(value string) bool {
+	return strings.HasPrefix(value, APITokenPrefix)
+}
+
+// Create issues a token for the user. The returned secret is the only copy,
+// only its hash is stored.
+func (s *APITokenService) Create(ctx context.Context, userID uuid.UUID, dto *dtos.CreateAPITokenDto) (*models.APIToken, string, error) {
+	random, err := helpers.GenerateSessionID()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate api token: %w", err)
+	}
+
+	secret := APITokenPrefix + random
+
+	scopes := slices.Clone(dto.Scopes)
+	slices.Sort(scopes)
+
+	var expiresAt *time.Time
+	if dto.ExpiresInDays > 0 {
+		at := time.Now().AddDate(0, 0, dto.ExpiresInDays)
+		expiresAt = &at
+	}
+
+	token, err := s.repository.Create(ctx, &dtos.CreateAPITokenRepoDto{
+		UserID:    userID,
+		Name:      strings.TrimSpace(dto.Name),
+		Prefix:    secret[:len(APITokenPrefix)+apiTokenPrefixLength],
+		Hash:      hashAPIToken(secret),
+		Scopes:    slices.Compact(scopes),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to save api token: %w", err)
+	}
+
+	return token, secret, nil
+}
+
+// The user's tokens that are not revoked, newest first
+func (s *APITokenService) List(ctx context.Context, userID uuid.UUID) ([]*models.APIToken, error) {
+	return s.repository.FindByUser(ctx, userID)
+}
+
+func (s *APITokenService) Revoke(ctx context.Context, userID, ID uuid.UUID) error {
+	err := s.repository.Revoke(ctx, userID, ID)
+
+	if errors.Is(err, repositories.ErrNotFound) {
+		return ErrAPITokenNotFound
+	}
+
+	return err
+}
+
+// Authenticate resolves a token secret into a session carrying the token's
+// scopes, and records when the token was used
+func (s *APITokenService) Authenticate(ctx context.Context, secret string) (*models.Session, error) {
+	token, err := s.repository.FindByHash(ctx, hashAPIToken(secret))
+
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, ErrAPITokenInvalid
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch api token: %w", err)
+	}
+
+	if token.RevokedAt != nil || token.Expired() {
+		return nil, ErrAPITokenInvalid
+	}
+
+	user, err := s.userService.Me(ctx, token.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch api token user: %w", err)
+	}
+
+	if user == nil {
+		return nil, ErrAPITokenInvalid
+	}
+
+	if now := time.Now(); token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > apiTokenTouchInterval {
+		// A failed update must not fail the request
+		if err := s.repository.UpdateLastUsed(ctx, token.ID, now); err != nil {
+			logging.FromContext(ctx).Warn("failed to record api token use", "api_token_id", token.ID, "err", err)
+		}
+	}
+
+	session := &models.Session{
+		User:       user,
+		APITokenID: &token.ID,
+		Scopes:     token.Scopes,
+	}
+
+	if token.ExpiresAt != nil {
+		session.ExpiresAt = *token.ExpiresAt
+	}
+
+	return session, nil
+}
+
+// Tokens carry 256 random bits, a plain digest is enough to store them
+func hashAPIToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
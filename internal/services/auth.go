@@ -27,7 +27,7 @@ func (s *AuthService) SignIn(ctx context.Context, dto *dtos.SignInDto) (*models.
 		return nil, 500, errors.New("password is required")
 	}
 
-	user, err := s.checkUser(*dto.Username)
+	user, err := s.checkUser(ctx, *dto.Username)
 	if err != nil {
 		return nil, 500, errors.New("invalid credentials")
 	}
@@ -45,7 +45,7 @@ func (s *AuthService) SignIn(ctx context.Context, dto *dtos.SignInDto) (*models.
 
 	if helpers.NeedsRehash(user.Password, helpers.DefaultCost) {
 		newHash, _ := helpers.HashPassword(password)
-		err := s.userService.UpdatePasswordHash(user.ID, newHash)
+		err := s.userService.UpdatePasswordHash(ctx, user.ID, newHash)
 
 		if err != nil {
 			return nil, 500, errors.New("failed on password rehash")
@@ -62,7 +62,7 @@ func (s *AuthService) SignIn(ctx context.Context, dto *dtos.SignInDto) (*models.
 }
 
 func (s *AuthService) SignUp(ctx context.Context, dto *dtos.SignUpDto) (*models.Session, int, error) {
-	found, err := s.checkUser(*dto.Username)
+	found, err := s.checkUser(ctx, *dto.Username)
 
 	if err != nil {
 		return nil, 500, err
@@ -82,7 +82,7 @@ func (s *AuthService) SignUp(ctx context.Context, dto *dtos.SignUpDto) (*models.
 		return nil, 500, err
 	}
 
-	newUser, err := s.userService.Create(&dtos.CreateUserDto{
+	newUser, err := s.userService.Create(ctx, &dtos.CreateUserDto{
 		Username:    dto.Username,
 		Gender:      dto.Gender,
 		Password:    &hashed,
@@ -110,11 +110,14 @@ func (s *AuthService) generateSessionModel(ctx context.Context, user *models.Use
 		Username: user.Username,
 		Age:      user.Age,
 		Gender:   user.Gender,
+		IsAdmin:  user.IsAdmin,
+		// Asks CreateAndSave for a pending session
+		TOTPEnabledAt: user.TOTPEnabledAt,
 	})
 
 	return session, err
 }
 
-func (s *AuthService) checkUser(username string) (*models.User, error) {
-	return s.userService.FindByUsername(username)
+func (s *AuthService) checkUser(ctx context.Context, username string) (*models.User, error) {
+	return s.userService.FindByUsername(ctx, username)
 }
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/dliluashvili/cowatchit/internal/helpers"
+	"github.com/dliluashvili/cowatchit/internal/logging"
+	"github.com/dliluashvili/cowatchit/internal/storage"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrImageTooLarge = errors.New("image is too large")
+	ErrImageType     = errors.New("image must be a JPEG, PNG or WebP")
+	ErrImageInvalid  = errors.New("image could not be read")
+)
+
+// Images bigger than this are refused before decoding, a small file can
+// still claim a huge canvas
+const maxImagePixels = 50_000_000
+
+// ImageKind is what an upload is turned into
+type ImageKind struct {
+	// Key prefix in the store
+	Prefix string
+	Width  int
+	Height int
+	// Largest WebP kept, quality drops until it fits
+	MaxKB int
+}
+
+var (
+	AvatarImage = ImageKind{Prefix: "avatars", Width: 256, Height: 256, MaxKB: 64}
+	PosterImage = ImageKind{Prefix: "posters", Width: 400, Height: 600, MaxKB: 160}
+)
+
+type ImageService struct {
+	store       storage.Store
+	maxUploadKB int
+}
+
+func NewImageService(store storage.Store, maxUploadKB int) *ImageService {
+	return &ImageService{
+		store:       store,
+		maxUploadKB: maxUploadKB,
+	}
+}
+
+// MaxUploadBytes is the largest upload Process accepts
+func (s *ImageService) MaxUploadBytes() int64 {
+	return int64(s.maxUploadKB) * 1024
+}
+
+// Process checks the upload is an image of a sane size, then crops it to
+// kind's dimensions and compresses it to WebP
+func (s *ImageService) Process(r io.Reader, kind ImageKind) ([]byte, error) {
+	ok, data, err := helpers.CheckSize(io.LimitReader(r, s.MaxUploadBytes()+1), s.maxUploadKB)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+
+	if !ok {
+		return nil, ErrImageTooLarge
+	}
+
+	if _, ok := helpers.DetectImageType(data); !ok {
+		return nil, ErrImageType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+
+	if err != nil {
+		return nil, ErrImageInvalid
+	}
+
+	if config.Width*config.Height > maxImagePixels {
+		return nil, ErrImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+
+	if err != nil {
+		return nil, ErrImageInvalid
+	}
+
+	encoded, err := helpers.EncodeWebP(helpers.ResizeCover(img, kind.Width, kind.Height), kind.MaxKB)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+
+	return encoded, nil
+}
+
+// Save processes the upload and stores it under a new key for owner,
+// returning its URL
+func (s *ImageService) Save(ctx context.Context, kind ImageKind, owner uuid.UUID, r io.Reader) (string, error) {
+	data, err := s.Process(r, kind)
+
+	if err != nil {
+		return "", err
+	}
+
+	// A new key per upload, stale copies in caches never shadow it
+	key := fmt.Sprintf("%s/%s/%d.webp", kind.Prefix, owner, time.Now().UnixNano())
+
+	if err := s.store.Put(ctx, key, bytes.NewReader(data), int64(len(data)), "image/webp"); err != nil {
+		return "", fmt.Errorf("failed to store image: %w", err)
+	}
+
+	return s.store.URL(key), nil
+}
+
+// Remove deletes an image Save stored for owner, URLs pointing anywhere
+// else, including another owner's images, are left alone.
+// Failures are only logged, an orphaned file is harmless.
+func (s *ImageService) Remove(ctx context.Context, kind ImageKind, owner uuid.UUID, url string) {
+	key, ok := storage.KeyOf(s.store, url)
+
+	if !ok || !strings.HasPrefix(key, kind.Prefix+"/"+owner.String()+"/") {
+		return
+	}
+
+	if err := s.store.Delete(ctx, key); err != nil {
+		logging.FromContext(ctx).Warn("failed to delete image", "key", key, "err", err)
+	}
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dliluashvili/cowatchit/internal/storage"
+	"github.com/google/uuid"
+)
+
+func TestImageRemoveOnlyOwnImages(t *testing.T) {
+	store := storage.NewMemory("/uploads", []byte("key"))
+	images := NewImageService(store, 1024)
+	ctx := context.Background()
+
+	owner, other := uuid.New(), uuid.New()
+	keys := map[string]string{
+		"own":           "avatars/" + owner.String() + "/1.webp",
+		"other user":    "avatars/" + other.String() + "/1.webp",
+		"poster":        "posters/" + owner.String() + "/1.webp",
+		"library media": storage.PrivatePrefix + "library/" + owner.String() + "/video.mp4",
+	}
+
+	for _, key := range keys {
+		if err := store.Put(ctx, key, strings.NewReader("data"), 4, "image/webp"); err != nil {
+			t.Fatalf("Put %s: %v", key, err)
+		}
+	}
+
+	for name, key := range keys {
+		images.Remove(ctx, AvatarImage, owner, store.URL(key))
+
+		_, err := store.Get(ctx, key)
+		if deleted := errors.Is(err, storage.ErrNotFound); deleted != (name == "own") {
+			t.Errorf("%s: deleted = %v", name, deleted)
+		}
+	}
+
+	// Not a store URL at all
+	images.Remove(ctx, AvatarImage, owner, "https://example.com/avatars/"+owner.String()+"/1.webp")
+}
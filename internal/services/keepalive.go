@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+type KeepaliveOptions struct {
+	// How often the server pings each socket
+	PingInterval time.Duration
+	// How long a ping may wait for its pong
+	PongTimeout time.Duration
+	// How long a socket may go without a pong or a message
+	IdleTimeout time.Duration
+}
+
+var DefaultKeepaliveOptions = KeepaliveOptions{
+	PingInterval: 20 * time.Second,
+	PongTimeout:  10 * time.Second,
+	IdleTimeout:  60 * time.Second,
+}
+
+// Keepalive pings the socket until ctx is done and reaps it once the peer
+// stops answering. Reaping closes the connection, which ends the read loop
+// and lets the caller clean up through Unregister.
+// Pings are only answered while the connection is being read.
+func (sm *WebSocketManagerService) Keepalive(ctx context.Context, socketID string, conn *websocket.Conn) {
+	writer, err := sm.GetSocketWriter(socketID)
+	if err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(sm.keepaliveOptions.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-writer.Done():
+			return
+		case <-ticker.C:
+		}
+
+		pingCtx, cancel := context.WithTimeout(ctx, sm.keepaliveOptions.PongTimeout)
+		err := conn.Ping(pingCtx)
+		cancel()
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err == nil {
+			writer.Touch()
+		}
+
+		if idle := time.Since(writer.LastSeen()); idle > sm.keepaliveOptions.IdleTimeout {
+			sm.reap(socketID, writer, conn, idle)
+			return
+		}
+	}
+}
+
+// Touch marks the socket as alive, call it for every received message
+func (sm *WebSocketManagerService) Touch(socketID string) {
+	if writer, err := sm.GetSocketWriter(socketID); err == nil {
+		writer.Touch()
+	}
+}
+
+// Number of sockets closed because the peer stopped responding
+func (sm *WebSocketManagerService) GetReapedSocketCount() int64 {
+	return sm.reapedSockets.Load()
+}
+
+func (sm *WebSocketManagerService) reap(socketID string, writer *SocketWriter, conn *websocket.Conn, idle time.Duration) {
+	sm.reapedSockets.Add(1)
+
+	slog.Warn("reaping unresponsive socket", "socket_id", socketID, "idle", idle.Round(time.Second))
+
+	// Stopping the writer also cancels the socket's context
+	writer.Close()
+	conn.CloseNow()
+}
@@ -0,0 +1,498 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/dliluashvili/cowatchit/internal/config"
+	"github.com/dliluashvili/cowatchit/internal/dtos"
+	"github.com/dliluashvili/cowatchit/internal/logging"
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/dliluashvili/cowatchit/internal/repositories"
+	"github.com/dliluashvili/cowatchit/internal/storage"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrLibraryItemNotFound   = errors.New("video not found")
+	ErrLibraryQuotaExceeded  = errors.New("not enough space left in your library")
+	ErrLibraryFileTooLarge   = errors.New("video is too large")
+	ErrLibraryOffsetMismatch = errors.New("upload offset doesn't match the bytes received")
+	ErrLibraryUploadComplete = errors.New("upload is already complete")
+	ErrLibraryItemInUse      = errors.New("video is played by a room, delete the room first")
+	ErrLibraryItemNotReady   = errors.New("video hasn't finished uploading")
+)
+
+// Extensions kept on storage keys, anything else is dropped
+var libraryExtension = regexp.MustCompile(`^\.[a-z0-9]{1,8}$`)
+
+// Name the original upload is served under, next to the HLS files
+const librarySourceName = "source"
+
+// Names of the files a transcode writes, playlists reference no others
+var (
+	playlistName = regexp.MustCompile(`^(master|stream_[0-9]+)\.m3u8$`)
+	segmentName  = regexp.MustCompile(`^stream_[0-9]+_[0-9]+\.ts$`)
+	playlistURI  = regexp.MustCompile(`URI="([^"]*)"`)
+)
+
+// MediaFile is one file of a library video as it is served
+type MediaFile struct {
+	ContentType string
+	// Implements io.Seeker, so ranges can be served
+	Content io.ReadCloser
+}
+
+// Rewritten playlists are served from memory
+type mediaBuffer struct {
+	*bytes.Reader
+}
+
+func (mediaBuffer) Close() error {
+	return nil
+}
+
+// LibraryUsage is how much of their quota a user has taken, in bytes
+type LibraryUsage struct {
+	Used  int64
+	Quota int64
+}
+
+// LibraryService keeps the videos users upload. Chunks are appended to a
+// partial file at the offset the client says it's at, so an interrupted
+// upload resumes where it stopped; the finished file is moved to storage.
+type LibraryService struct {
+	repository    LibraryRepository
+	jobRepository MediaJobRepository
+	store         storage.Store
+	cfg           config.LibraryConfig
+	// Chunks of one item are written one at a time, striped by id
+	locks [64]sync.Mutex
+}
+
+func NewLibraryService(r LibraryRepository, jr MediaJobRepository, store storage.Store, cfg config.LibraryConfig) *LibraryService {
+	return &LibraryService{
+		repository:    r,
+		jobRepository: jr,
+		store:         store,
+		cfg:           cfg,
+	}
+}
+
+func (s *LibraryService) List(ctx context.Context, userID uuid.UUID) ([]models.LibraryItem, error) {
+	return s.repository.FindByUser(ctx, userID)
+}
+
+// Jobs returns the transcoding job of each item that has one, by item id
+func (s *LibraryService) Jobs(ctx context.Context, items []models.LibraryItem) (map[uuid.UUID]*models.MediaJob, error) {
+	itemIDs := make([]uuid.UUID, len(items))
+	for i, item := range items {
+		itemIDs[i] = item.ID
+	}
+
+	jobs, err := s.jobRepository.FindByItems(ctx, itemIDs)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find media jobs: %w", err)
+	}
+
+	byItem := make(map[uuid.UUID]*models.MediaJob, len(jobs))
+	for i := range jobs {
+		byItem[jobs[i].LibraryItemID] = &jobs[i]
+	}
+
+	return byItem, nil
+}
+
+func (s *LibraryService) Usage(ctx context.Context, userID uuid.UUID) (*LibraryUsage, error) {
+	used, err := s.repository.UsedBytes(ctx, userID)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum library size: %w", err)
+	}
+
+	return &LibraryUsage{Used: used, Quota: s.cfg.QuotaMB << 20}, nil
+}
+
+// Find returns one of the user's items
+func (s *LibraryService) Find(ctx context.Context, userID, ID uuid.UUID) (*models.LibraryItem, error) {
+	item, err := s.repository.FindOne(ctx, ID)
+
+	if errors.Is(err, repositories.ErrNotFound) || (err == nil && item.UserID != userID) {
+		return nil, ErrLibraryItemNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find library item: %w", err)
+	}
+
+	return item, nil
+}
+
+// Create starts an upload, the whole size counts against the quota from now
+func (s *LibraryService) Create(ctx context.Context, userID uuid.UUID, dto *dtos.CreateLibraryItemDto) (*models.LibraryItem, error) {
+	if dto.Size > s.cfg.MaxFileMB<<20 {
+		return nil, ErrLibraryFileTooLarge
+	}
+
+	if err := os.MkdirAll(s.cfg.PartialDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create partial upload directory: %w", err)
+	}
+
+	item, err := s.repository.Create(ctx, &dtos.CreateLibraryItemRepoDto{
+		UserID:               userID,
+		Quota:                s.cfg.QuotaMB << 20,
+		CreateLibraryItemDto: dto,
+	})
+
+	if errors.Is(err, repositories.ErrQuotaExceeded) {
+		return nil, ErrLibraryQuotaExceeded
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to save library item: %w", err)
+	}
+
+	return item, nil
+}
+
+// Append writes a chunk starting at offset, which must be the number of
+// bytes received so far. What arrived before a broken connection is kept.
+// The last chunk moves the file to storage; if that fails, an empty chunk
+// at the final offset tries again.
+func (s *LibraryService) Append(ctx context.Context, userID, ID uuid.UUID, offset int64, r io.Reader) (*models.LibraryItem, error) {
+	lock := &s.locks[ID[len(ID)-1]%byte(len(s.locks))]
+	lock.Lock()
+	defer lock.Unlock()
+
+	item, err := s.Find(ctx, userID, ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if item.Ready() {
+		return nil, ErrLibraryUploadComplete
+	}
+
+	if offset != item.Received {
+		return nil, ErrLibraryOffsetMismatch
+	}
+
+	written, writeErr := s.writeChunk(ID, offset, item.Size-offset, r)
+
+	if written > 0 {
+		if err := s.repository.UpdateReceived(ctx, ID, offset, offset+written); err != nil {
+			return nil, fmt.Errorf("failed to record upload offset: %w", err)
+		}
+
+		item.Received += written
+	}
+
+	if writeErr != nil {
+		return item, writeErr
+	}
+
+	if item.Received == item.Size {
+		if err := s.finish(ctx, item); err != nil {
+			return item, err
+		}
+	}
+
+	return item, nil
+}
+
+// writeChunk appends at most remaining bytes of r at offset, bytes past
+// offset left by a chunk that failed midway are overwritten
+func (s *LibraryService) writeChunk(ID uuid.UUID, offset, remaining int64, r io.Reader) (int64, error) {
+	file, err := os.OpenFile(s.partialPath(ID), os.O_WRONLY|os.O_CREATE, 0o600)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to open partial upload: %w", err)
+	}
+	defer file.Close()
+
+	if err := file.Truncate(offset); err != nil {
+		return 0, fmt.Errorf("failed to truncate partial upload: %w", err)
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to seek partial upload: %w", err)
+	}
+
+	written, err := io.Copy(file, io.LimitReader(r, remaining+1))
+
+	// Nothing of a chunk running past the declared size is kept
+	if written > remaining {
+		file.Truncate(offset)
+		return 0, ErrLibraryFileTooLarge
+	}
+
+	if syncErr := file.Sync(); err == nil {
+		err = syncErr
+	}
+
+	if err != nil {
+		return written, fmt.Errorf("failed to write chunk: %w", err)
+	}
+
+	return written, nil
+}
+
+func (s *LibraryService) finish(ctx context.Context, item *models.LibraryItem) error {
+	file, err := os.Open(s.partialPath(item.ID))
+
+	if err != nil {
+		return fmt.Errorf("failed to open partial upload: %w", err)
+	}
+	defer file.Close()
+
+	key := fmt.Sprintf("%slibrary/%s/%s%s", storage.PrivatePrefix, item.UserID, item.ID, libraryExt(item.Filename))
+
+	if err := s.store.Put(ctx, key, file, item.Size, item.ContentType); err != nil {
+		return fmt.Errorf("failed to store video: %w", err)
+	}
+
+	if err := s.repository.MarkReady(ctx, item.ID, key); err != nil {
+		return fmt.Errorf("failed to mark video ready: %w", err)
+	}
+
+	item.Key = key
+	item.Status = models.LibraryItemReady
+
+	if err := os.Remove(file.Name()); err != nil {
+		logging.FromContext(ctx).Warn("failed to remove partial upload", "library_item_id", item.ID, "err", err)
+	}
+
+	// The original plays meanwhile, and for good if this fails
+	if err := s.jobRepository.Enqueue(ctx, item.ID); err != nil {
+		logging.FromContext(ctx).Error("failed to queue transcoding", "library_item_id", item.ID, "err", err)
+	}
+
+	return nil
+}
+
+// Transcode queues the item to be transcoded again, after a failed job
+func (s *LibraryService) Transcode(ctx context.Context, userID, ID uuid.UUID) error {
+	if _, err := s.Playable(ctx, userID, ID); err != nil {
+		return err
+	}
+
+	if err := s.jobRepository.Enqueue(ctx, ID); err != nil {
+		return fmt.Errorf("failed to queue transcoding: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes the item and its file, unless a room plays it
+func (s *LibraryService) Delete(ctx context.Context, userID, ID uuid.UUID) error {
+	item, err := s.Find(ctx, userID, ID)
+
+	if err != nil {
+		return err
+	}
+
+	inUse, err := s.repository.InUse(ctx, ID)
+
+	if err != nil {
+		return fmt.Errorf("failed to check library item use: %w", err)
+	}
+
+	if inUse {
+		return ErrLibraryItemInUse
+	}
+
+	if err := s.repository.Delete(ctx, ID); err != nil {
+		return fmt.Errorf("failed to delete library item: %w", err)
+	}
+
+	if item.Key != "" {
+		if err := s.store.Delete(ctx, item.Key); err != nil {
+			logging.FromContext(ctx).Warn("failed to delete video", "key", item.Key, "err", err)
+		}
+	}
+
+	// A job still running finds the item gone and cleans up after itself
+	if err := s.store.DeletePrefix(ctx, hlsPrefix(item)); err != nil {
+		logging.FromContext(ctx).Warn("failed to delete hls files", "library_item_id", ID, "err", err)
+	}
+
+	if err := os.Remove(s.partialPath(ID)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logging.FromContext(ctx).Warn("failed to remove partial upload", "library_item_id", ID, "err", err)
+	}
+
+	return nil
+}
+
+// Playable returns the user's item if a room can play it
+func (s *LibraryService) Playable(ctx context.Context, userID, ID uuid.UUID) (*models.LibraryItem, error) {
+	item, err := s.Find(ctx, userID, ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !item.Ready() {
+		return nil, ErrLibraryItemNotReady
+	}
+
+	return item, nil
+}
+
+// Playing returns the item a room plays, which has to be fully uploaded
+func (s *LibraryService) Playing(ctx context.Context, ID uuid.UUID) (*models.LibraryItem, error) {
+	item, err := s.repository.FindOne(ctx, ID)
+
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, ErrLibraryItemNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find library item: %w", err)
+	}
+
+	if !item.Ready() {
+		return nil, ErrLibraryItemNotReady
+	}
+
+	return item, nil
+}
+
+// Entry is the file a player starts from: the HLS master playlist once the
+// item is transcoded, the original until then
+func (s *LibraryService) Entry(item *models.LibraryItem) string {
+	if item.Streamable() {
+		return path.Base(item.ManifestKey)
+	}
+
+	return librarySourceName
+}
+
+// Open reads one of the files the item is played from, name being its
+// entry or one the playlists point to. Playlists come back with every uri
+// replaced by link(uri), their files are private and only the caller knows
+// where it serves them.
+func (s *LibraryService) Open(ctx context.Context, item *models.LibraryItem, name string, link func(name string) string) (*MediaFile, error) {
+	switch {
+	case name == librarySourceName:
+		return s.open(ctx, item.Key, item.ContentType)
+	case !item.Streamable():
+		return nil, ErrLibraryItemNotFound
+	case segmentName.MatchString(name):
+		return s.open(ctx, hlsPrefix(item)+name, "video/mp2t")
+	case !playlistName.MatchString(name):
+		return nil, ErrLibraryItemNotFound
+	}
+
+	file, err := s.open(ctx, hlsPrefix(item)+name, "application/vnd.apple.mpegurl")
+
+	if err != nil {
+		return nil, err
+	}
+
+	playlist, err := io.ReadAll(file.Content)
+	file.Content.Close()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read playlist: %w", err)
+	}
+
+	playlist, err = rewritePlaylist(playlist, func(uri string) (string, error) {
+		if !playlistName.MatchString(uri) && !segmentName.MatchString(uri) {
+			return "", fmt.Errorf("unexpected uri %q in playlist %s", uri, name)
+		}
+
+		return link(uri), nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	file.Content = mediaBuffer{bytes.NewReader(playlist)}
+
+	return file, nil
+}
+
+func (s *LibraryService) open(ctx context.Context, key, contentType string) (*MediaFile, error) {
+	reader, err := s.store.Get(ctx, key)
+
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrLibraryItemNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to open media file: %w", err)
+	}
+
+	return &MediaFile{ContentType: contentType, Content: reader}, nil
+}
+
+func (s *LibraryService) partialPath(ID uuid.UUID) string {
+	return filepath.Join(s.cfg.PartialDir, ID.String())
+}
+
+func libraryExt(filename string) string {
+	ext := strings.ToLower(path.Ext(filename))
+
+	if !libraryExtension.MatchString(ext) {
+		return ""
+	}
+
+	return ext
+}
+
+// Where the item's HLS files are kept
+func hlsPrefix(item *models.LibraryItem) string {
+	return fmt.Sprintf("%slibrary/%s/%s/hls/", storage.PrivatePrefix, item.UserID, item.ID)
+}
+
+// rewritePlaylist replaces every uri of an m3u8 playlist, the lines naming
+// a playlist or segment and the URI attributes of tags
+func rewritePlaylist(playlist []byte, rewrite func(uri string) (string, error)) ([]byte, error) {
+	var out bytes.Buffer
+	var err error
+
+	for _, line := range strings.Split(string(playlist), "\n") {
+		line = strings.TrimRight(line, "\r")
+
+		switch {
+		case strings.HasPrefix(line, "#"):
+			line = playlistURI.ReplaceAllStringFunc(line, func(attr string) string {
+				uri, rewriteErr := rewrite(playlistURI.FindStringSubmatch(attr)[1])
+				if rewriteErr != nil && err == nil {
+					err = rewriteErr
+				}
+
+				return `URI="` + uri + `"`
+			})
+		case strings.TrimSpace(line) != "":
+			uri, rewriteErr := rewrite(strings.TrimSpace(line))
+			if rewriteErr != nil && err == nil {
+				err = rewriteErr
+			}
+
+			line = uri
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+
+	return bytes.TrimSuffix(out.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,401 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dliluashvili/cowatchit/internal/config"
+	"github.com/dliluashvili/cowatchit/internal/dtos"
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/dliluashvili/cowatchit/internal/repositories"
+	"github.com/dliluashvili/cowatchit/internal/storage"
+	"github.com/google/uuid"
+)
+
+// Hands out copies, the way rows are read back from the database
+type memoryLibraryItems struct {
+	items map[uuid.UUID]models.LibraryItem
+}
+
+func (m *memoryLibraryItems) Create(ctx context.Context, dto *dtos.CreateLibraryItemRepoDto) (*models.LibraryItem, error) {
+	used, _ := m.UsedBytes(ctx, dto.UserID)
+	if used+dto.Size > dto.Quota {
+		return nil, repositories.ErrQuotaExceeded
+	}
+
+	item := models.LibraryItem{
+		ID:          uuid.New(),
+		UserID:      dto.UserID,
+		Title:       dto.Title,
+		Filename:    dto.Filename,
+		ContentType: dto.ContentType,
+		Size:        dto.Size,
+		Status:      models.LibraryItemUploading,
+	}
+	m.items[item.ID] = item
+
+	return &item, nil
+}
+
+func (m *memoryLibraryItems) FindOne(_ context.Context, ID uuid.UUID) (*models.LibraryItem, error) {
+	item, exists := m.items[ID]
+	if !exists {
+		return nil, repositories.ErrNotFound
+	}
+
+	return &item, nil
+}
+
+func (m *memoryLibraryItems) FindByUser(_ context.Context, userID uuid.UUID) ([]models.LibraryItem, error) {
+	var items []models.LibraryItem
+	for _, item := range m.items {
+		if item.UserID == userID {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func (m *memoryLibraryItems) UsedBytes(_ context.Context, userID uuid.UUID) (int64, error) {
+	var used int64
+	for _, item := range m.items {
+		if item.UserID == userID {
+			used += item.Size
+		}
+	}
+	return used, nil
+}
+
+func (m *memoryLibraryItems) UpdateReceived(_ context.Context, ID uuid.UUID, from, to int64) error {
+	item, exists := m.items[ID]
+	if !exists || item.Received != from || item.Status != models.LibraryItemUploading {
+		return repositories.ErrNotFound
+	}
+
+	item.Received = to
+	m.items[ID] = item
+	return nil
+}
+
+func (m *memoryLibraryItems) MarkReady(_ context.Context, ID uuid.UUID, key string) error {
+	item := m.items[ID]
+	item.Key = key
+	item.Status = models.LibraryItemReady
+	m.items[ID] = item
+	return nil
+}
+
+func (m *memoryLibraryItems) InUse(context.Context, uuid.UUID) (bool, error) {
+	return false, nil
+}
+
+func (m *memoryLibraryItems) Delete(_ context.Context, ID uuid.UUID) error {
+	delete(m.items, ID)
+	return nil
+}
+
+func (m *memoryLibraryItems) SetProbe(context.Context, uuid.UUID, *dtos.LibraryItemProbeDto) error {
+	return nil
+}
+
+func (m *memoryLibraryItems) SetManifest(_ context.Context, ID uuid.UUID, key string) error {
+	item := m.items[ID]
+	item.ManifestKey = key
+	m.items[ID] = item
+	return nil
+}
+
+type memoryMediaJobs struct {
+	queued []uuid.UUID
+}
+
+func (m *memoryMediaJobs) Enqueue(_ context.Context, itemID uuid.UUID) error {
+	m.queued = append(m.queued, itemID)
+	return nil
+}
+
+func (m *memoryMediaJobs) FindByItems(context.Context, []uuid.UUID) ([]models.MediaJob, error) {
+	return nil, nil
+}
+
+func (m *memoryMediaJobs) Claim(context.Context, time.Duration) (*models.MediaJob, error) {
+	return nil, nil
+}
+
+func (m *memoryMediaJobs) Progress(context.Context, uuid.UUID, string, int, time.Duration) error {
+	return nil
+}
+
+func (m *memoryMediaJobs) Finish(context.Context, uuid.UUID) error {
+	return nil
+}
+
+func (m *memoryMediaJobs) Fail(context.Context, uuid.UUID, string, bool) error {
+	return nil
+}
+
+func (m *memoryMediaJobs) Release(context.Context, uuid.UUID) error {
+	return nil
+}
+
+type libraryFixture struct {
+	service *LibraryService
+	items   *memoryLibraryItems
+	jobs    *memoryMediaJobs
+	store   *storage.Memory
+	user    uuid.UUID
+}
+
+func newLibraryFixture(t *testing.T) *libraryFixture {
+	f := &libraryFixture{
+		items: &memoryLibraryItems{items: map[uuid.UUID]models.LibraryItem{}},
+		jobs:  &memoryMediaJobs{},
+		store: storage.NewMemory("/uploads", []byte("key")),
+		user:  uuid.New(),
+	}
+
+	f.service = NewLibraryService(f.items, f.jobs, f.store, config.LibraryConfig{
+		PartialDir: t.TempDir(),
+		QuotaMB:    1,
+		MaxFileMB:  1,
+	})
+
+	return f
+}
+
+func (f *libraryFixture) create(t *testing.T, size int64) *models.LibraryItem {
+	t.Helper()
+
+	item, err := f.service.Create(context.Background(), f.user, &dtos.CreateLibraryItemDto{
+		Title:       "Movie night",
+		Filename:    "Movie.MP4",
+		ContentType: "video/mp4",
+		Size:        size,
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	return item
+}
+
+func (f *libraryFixture) read(t *testing.T, key string) string {
+	t.Helper()
+
+	reader, err := f.store.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Get %s: %v", key, err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+func TestLibraryUploadInChunks(t *testing.T) {
+	f := newLibraryFixture(t)
+	ctx := context.Background()
+	item := f.create(t, 10)
+
+	for _, chunk := range []struct {
+		offset int64
+		data   string
+	}{{0, "0123"}, {4, "4567"}, {8, "89"}} {
+		got, err := f.service.Append(ctx, f.user, item.ID, chunk.offset, strings.NewReader(chunk.data))
+		if err != nil {
+			t.Fatalf("Append at %d: %v", chunk.offset, err)
+		}
+		if want := chunk.offset + int64(len(chunk.data)); got.Received != want {
+			t.Errorf("received %d after the chunk at %d, want %d", got.Received, chunk.offset, want)
+		}
+	}
+
+	item, err := f.service.Find(ctx, f.user, item.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := storage.PrivatePrefix + "library/" + f.user.String() + "/" + item.ID.String() + ".mp4"; !item.Ready() || item.Key != want {
+		t.Fatalf("item = %+v, want ready under %s", item, want)
+	}
+
+	if got := f.read(t, item.Key); got != "0123456789" {
+		t.Errorf("stored %q, want the chunks in order", got)
+	}
+
+	if _, err := os.Stat(f.service.partialPath(item.ID)); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("partial upload left behind: %v", err)
+	}
+
+	if len(f.jobs.queued) != 1 || f.jobs.queued[0] != item.ID {
+		t.Errorf("queued %v, want a transcode of %s", f.jobs.queued, item.ID)
+	}
+}
+
+func TestLibraryAppendRejectsMisplacedChunks(t *testing.T) {
+	f := newLibraryFixture(t)
+	ctx := context.Background()
+	item := f.create(t, 8)
+
+	if _, err := f.service.Append(ctx, f.user, item.ID, 0, strings.NewReader("0123")); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		user   uuid.UUID
+		offset int64
+		data   string
+		want   error
+	}{
+		{"duplicate chunk", f.user, 0, "0123", ErrLibraryOffsetMismatch},
+		{"chunk ahead", f.user, 6, "67", ErrLibraryOffsetMismatch},
+		{"past the declared size", f.user, 4, "456789", ErrLibraryFileTooLarge},
+		{"another user's item", uuid.New(), 4, "4567", ErrLibraryItemNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := f.service.Append(ctx, tt.user, item.ID, tt.offset, strings.NewReader(tt.data)); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+
+			if got := f.items.items[item.ID].Received; got != 4 {
+				t.Errorf("received %d, want 4", got)
+			}
+		})
+	}
+
+	if _, err := f.service.Append(ctx, f.user, item.ID, 4, strings.NewReader("4567")); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	if got := f.read(t, f.items.items[item.ID].Key); got != "01234567" {
+		t.Errorf("stored %q, want 01234567", got)
+	}
+
+	if _, err := f.service.Append(ctx, f.user, item.ID, 8, strings.NewReader("8")); !errors.Is(err, ErrLibraryUploadComplete) {
+		t.Errorf("chunk after the last: err = %v, want %v", err, ErrLibraryUploadComplete)
+	}
+}
+
+func TestLibraryQuota(t *testing.T) {
+	f := newLibraryFixture(t)
+	ctx := context.Background()
+	quota := int64(1 << 20)
+
+	dto := func(size int64) *dtos.CreateLibraryItemDto {
+		return &dtos.CreateLibraryItemDto{Title: "Movie", Filename: "movie.mp4", ContentType: "video/mp4", Size: size}
+	}
+
+	if _, err := f.service.Create(ctx, f.user, dto(quota+1)); !errors.Is(err, ErrLibraryFileTooLarge) {
+		t.Errorf("file over the size limit: err = %v, want %v", err, ErrLibraryFileTooLarge)
+	}
+
+	// Unfinished uploads count in full
+	f.create(t, quota*3/4)
+
+	if _, err := f.service.Create(ctx, f.user, dto(quota/2)); !errors.Is(err, ErrLibraryQuotaExceeded) {
+		t.Errorf("file over the quota: err = %v, want %v", err, ErrLibraryQuotaExceeded)
+	}
+
+	f.create(t, quota/4)
+
+	usage, err := f.service.Usage(ctx, f.user)
+	if err != nil || usage.Used != quota || usage.Quota != quota {
+		t.Errorf("Usage = %+v, %v, want the whole quota used", usage, err)
+	}
+
+	// Other users have their own quota
+	if _, err := f.service.Create(ctx, uuid.New(), dto(quota)); err != nil {
+		t.Errorf("another user's upload: %v", err)
+	}
+}
+
+func TestLibraryOpenRewritesPlaylists(t *testing.T) {
+	f := newLibraryFixture(t)
+	ctx := context.Background()
+
+	item := &models.LibraryItem{ID: uuid.New(), UserID: f.user, ContentType: "video/mp4", Status: models.LibraryItemReady}
+	item.Key = storage.PrivatePrefix + "library/" + f.user.String() + "/" + item.ID.String() + ".mp4"
+	item.ManifestKey = hlsPrefix(item) + "master.m3u8"
+
+	files := map[string]string{
+		item.Key: "original",
+		item.ManifestKey: "#EXTM3U\r\n" +
+			"#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"audio\",URI=\"stream_1.m3u8\"\r\n" +
+			"#EXT-X-STREAM-INF:BANDWIDTH=800000,AUDIO=\"audio\"\r\n" +
+			"stream_0.m3u8\r\n",
+		hlsPrefix(item) + "stream_0.m3u8": "#EXTM3U\n#EXTINF:4.0,\nstream_0_0.ts\n\n#EXT-X-ENDLIST",
+		hlsPrefix(item) + "stream_1.m3u8": "#EXTM3U\n#EXTINF:4.0,\n../../other/stream_0_0.ts",
+		hlsPrefix(item) + "stream_0_0.ts": "segment",
+	}
+	for key, data := range files {
+		if err := f.store.Put(ctx, key, strings.NewReader(data), int64(len(data)), ""); err != nil {
+			t.Fatalf("Put %s: %v", key, err)
+		}
+	}
+
+	link := func(name string) string {
+		return "/media/" + name + "?token=t"
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		want        string
+		err         error
+	}{
+		{"source", "video/mp4", "original", nil},
+		{
+			"master.m3u8",
+			"application/vnd.apple.mpegurl",
+			"#EXTM3U\n" +
+				"#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"audio\",URI=\"/media/stream_1.m3u8?token=t\"\n" +
+				"#EXT-X-STREAM-INF:BANDWIDTH=800000,AUDIO=\"audio\"\n" +
+				"/media/stream_0.m3u8?token=t\n",
+			nil,
+		},
+		{"stream_0.m3u8", "application/vnd.apple.mpegurl", "#EXTM3U\n#EXTINF:4.0,\n/media/stream_0_0.ts?token=t\n\n#EXT-X-ENDLIST", nil},
+		{"stream_0_0.ts", "video/mp2t", "segment", nil},
+		{"stream_0_1.ts", "", "", ErrLibraryItemNotFound},
+		{"../secret.ts", "", "", ErrLibraryItemNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := f.service.Open(ctx, item, tt.name, link)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			defer file.Content.Close()
+
+			data, err := io.ReadAll(file.Content)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if file.ContentType != tt.contentType || string(data) != tt.want {
+				t.Errorf("got %s %q, want %s %q", file.ContentType, data, tt.contentType, tt.want)
+			}
+		})
+	}
+
+	// A playlist pointing outside the item's files is not served
+	if _, err := f.service.Open(ctx, item, "stream_1.m3u8", link); err == nil {
+		t.Error("served a playlist with a foreign uri")
+	}
+}
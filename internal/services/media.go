@@ -0,0 +1,642 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dliluashvili/cowatchit/internal/config"
+	"github.com/dliluashvili/cowatchit/internal/dtos"
+	"github.com/dliluashvili/cowatchit/internal/logging"
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/dliluashvili/cowatchit/internal/repositories"
+	"github.com/dliluashvili/cowatchit/internal/storage"
+	"github.com/google/uuid"
+)
+
+var errMediaNoVideo = errors.New("file has no video stream")
+
+const (
+	// How long a claimed job stays with its worker without a heartbeat
+	mediaJobLease = 2 * time.Minute
+	// How often a running job writes its progress
+	mediaProgressInterval = 5 * time.Second
+	// HLS segment length in seconds, every rendition has a keyframe there
+	hlsSegmentSeconds = 6
+	// How much of ffmpeg's output a failed job keeps
+	mediaErrorTail = 2000
+)
+
+// A rung of the HLS ladder, renditions taller than the source are skipped
+type rendition struct {
+	Height      int
+	VideoKbps   int
+	AudioKbps   int
+	MaxrateKbps int
+}
+
+var mediaLadder = []rendition{
+	{Height: 1080, VideoKbps: 5000, AudioKbps: 128, MaxrateKbps: 5350},
+	{Height: 720, VideoKbps: 2800, AudioKbps: 128, MaxrateKbps: 3000},
+	{Height: 480, VideoKbps: 1400, AudioKbps: 96, MaxrateKbps: 1500},
+	{Height: 360, VideoKbps: 800, AudioKbps: 96, MaxrateKbps: 856},
+}
+
+// MediaService runs the transcoding queue. Workers claim jobs from Postgres,
+// probe the video with ffprobe, transcode it to an HLS ladder with ffmpeg
+// and store the result next to the original. Any number of instances can
+// run workers, each job is leased to one at a time.
+type MediaService struct {
+	jobRepository     MediaJobRepository
+	libraryRepository LibraryRepository
+	store             storage.Store
+	cfg               config.MediaConfig
+
+	mu      sync.Mutex
+	onReady []func(ctx context.Context, item *models.LibraryItem)
+}
+
+func NewMediaService(jr MediaJobRepository, lr LibraryRepository, store storage.Store, cfg config.MediaConfig) *MediaService {
+	return &MediaService{
+		jobRepository:     jr,
+		libraryRepository: lr,
+		store:             store,
+		cfg:               cfg,
+	}
+}
+
+// OnReady registers fn to be called with each item once it is transcoded
+func (s *MediaService) OnReady(fn func(ctx context.Context, item *models.LibraryItem)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.onReady = append(s.onReady, fn)
+}
+
+// Run works the queue with the configured number of workers until ctx is
+// done, a job cut short is handed back to the queue
+func (s *MediaService) Run(ctx context.Context) {
+	if s.cfg.Workers == 0 {
+		return
+	}
+
+	for _, tool := range []string{s.cfg.FFmpegPath, s.cfg.FFprobePath} {
+		if _, err := exec.LookPath(tool); err != nil {
+			logging.FromContext(ctx).Error("media workers not started, transcoding tool missing", "tool", tool, "err", err)
+			return
+		}
+	}
+
+	var wg sync.WaitGroup
+
+	for i := range s.cfg.Workers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			s.work(logging.With(ctx, "media_worker", i))
+		}()
+	}
+
+	wg.Wait()
+}
+
+func (s *MediaService) work(ctx context.Context) {
+	for {
+		job, err := s.jobRepository.Claim(ctx, mediaJobLease)
+
+		if err == nil {
+			s.run(ctx, job)
+			continue
+		}
+
+		if !errors.Is(err, repositories.ErrNotFound) && ctx.Err() == nil {
+			logging.FromContext(ctx).Error("failed to claim media job", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.cfg.PollInterval):
+		}
+	}
+}
+
+// run processes a claimed job and records how it ended
+func (s *MediaService) run(ctx context.Context, job *models.MediaJob) {
+	ctx = logging.With(ctx, "media_job_id", job.ID, "library_item_id", job.LibraryItemID, "attempt", job.Attempts)
+	log := logging.FromContext(ctx)
+
+	// Past the attempts, the job's workers kept dying before failing it
+	if job.Attempts > s.cfg.MaxAttempts {
+		if err := s.jobRepository.Fail(ctx, job.ID, "gave up after "+strconv.Itoa(s.cfg.MaxAttempts)+" attempts", false); err != nil {
+			log.Error("failed to give up media job", "err", err)
+		}
+		return
+	}
+
+	log.Info("media job started")
+	started := time.Now()
+
+	item, err := s.process(ctx, job)
+
+	// Shutting down is not the job's fault
+	if ctx.Err() != nil {
+		releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+		defer cancel()
+
+		if err := s.jobRepository.Release(releaseCtx, job.ID); err != nil {
+			log.Warn("failed to release media job", "err", err)
+		}
+		return
+	}
+
+	if errors.Is(err, repositories.ErrNotFound) {
+		log.Info("media job dropped, the video was deleted or another worker took the job over")
+		return
+	}
+
+	if err != nil {
+		retry := !errors.Is(err, errMediaNoVideo) && job.Attempts < s.cfg.MaxAttempts
+		log.Warn("media job failed", "err", err, "retry", retry)
+
+		if err := s.jobRepository.Fail(ctx, job.ID, truncateTail(err.Error(), mediaErrorTail), retry); err != nil {
+			log.Error("failed to record media job failure", "err", err)
+		}
+		return
+	}
+
+	if err := s.jobRepository.Finish(ctx, job.ID); err != nil {
+		log.Error("failed to finish media job", "err", err)
+		return
+	}
+
+	log.Info("media job finished", "duration", time.Since(started))
+
+	s.mu.Lock()
+	onReady := s.onReady
+	s.mu.Unlock()
+
+	for _, fn := range onReady {
+		fn(ctx, item)
+	}
+}
+
+// process probes and transcodes the job's item, ErrNotFound when the item
+// or job went away meanwhile
+func (s *MediaService) process(ctx context.Context, job *models.MediaJob) (*models.LibraryItem, error) {
+	item, err := s.libraryRepository.FindOne(ctx, job.LibraryItemID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	progress := newJobProgress(s.jobRepository, job.ID)
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	// Keeps the lease while the slow steps run, and stops the job once it
+	// is lost; other errors are retried on the next tick
+	go func() {
+		ticker := time.NewTicker(mediaJobLease / 4)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if err := progress.flush(ctx); errors.Is(err, repositories.ErrNotFound) {
+				cancel(err)
+				return
+			}
+		}
+	}()
+
+	dir, err := os.MkdirTemp(s.cfg.WorkDir, "media-job-")
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create work dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := progress.report(ctx, models.MediaStageProbing, 0); err != nil {
+		return nil, err
+	}
+
+	input := filepath.Join(dir, "source")
+
+	if err := s.download(ctx, item.Key, input); err != nil {
+		return nil, lostJob(ctx, err)
+	}
+
+	probe, hasAudio, err := s.probe(ctx, input)
+
+	if err != nil {
+		return nil, lostJob(ctx, err)
+	}
+
+	if err := s.libraryRepository.SetProbe(ctx, item.ID, probe); err != nil {
+		return nil, fmt.Errorf("failed to save probe: %w", err)
+	}
+
+	item.DurationSeconds = probe.DurationSeconds
+	item.Width = probe.Width
+	item.Height = probe.Height
+	item.VideoCodec = probe.VideoCodec
+	item.AudioCodec = probe.AudioCodec
+
+	if err := progress.report(ctx, models.MediaStageTranscoding, 0); err != nil {
+		return nil, err
+	}
+
+	out := filepath.Join(dir, "hls")
+
+	if err := os.Mkdir(out, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create output dir: %w", err)
+	}
+
+	if err := s.transcode(ctx, input, out, probe, hasAudio, progress); err != nil {
+		return nil, lostJob(ctx, err)
+	}
+
+	if err := progress.report(ctx, models.MediaStageStoring, 100); err != nil {
+		return nil, err
+	}
+
+	prefix := hlsPrefix(item)
+
+	if err := s.upload(ctx, out, prefix); err != nil {
+		return nil, lostJob(ctx, err)
+	}
+
+	manifestKey := prefix + "master.m3u8"
+
+	if err := s.libraryRepository.SetManifest(ctx, item.ID, manifestKey); err != nil {
+		cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+		defer cancel()
+
+		if err := s.store.DeletePrefix(cleanupCtx, prefix); err != nil {
+			logging.FromContext(ctx).Warn("failed to delete hls files", "err", err)
+		}
+
+		return nil, err
+	}
+
+	item.ManifestKey = manifestKey
+
+	return item, nil
+}
+
+// lostJob is ErrNotFound when the job was lost while it ran, err otherwise
+func lostJob(ctx context.Context, err error) error {
+	if errors.Is(context.Cause(ctx), repositories.ErrNotFound) {
+		return repositories.ErrNotFound
+	}
+
+	return err
+}
+
+func (s *MediaService) download(ctx context.Context, key, path string) error {
+	reader, err := s.store.Get(ctx, key)
+
+	if err != nil {
+		return fmt.Errorf("failed to open video: %w", err)
+	}
+	defer reader.Close()
+
+	file, err := os.Create(path)
+
+	if err != nil {
+		return fmt.Errorf("failed to create work file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, reader); err != nil {
+		return fmt.Errorf("failed to download video: %w", err)
+	}
+
+	return nil
+}
+
+type ffprobeOutput struct {
+	Streams []struct {
+		CodecType string `json:"codec_type"`
+		CodecName string `json:"codec_name"`
+		Width     int    `json:"width"`
+		Height    int    `json:"height"`
+	} `json:"streams"`
+	Format struct {
+		Duration string `json:"duration"`
+	} `json:"format"`
+}
+
+// probe reads the duration, codecs and size of the first video and audio
+// streams of path
+func (s *MediaService) probe(ctx context.Context, path string) (*dtos.LibraryItemProbeDto, bool, error) {
+	cmd := exec.CommandContext(ctx, s.cfg.FFprobePath,
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		path,
+	)
+
+	stderr := &tailBuffer{max: mediaErrorTail}
+	cmd.Stderr = stderr
+
+	output, err := cmd.Output()
+
+	if err != nil {
+		return nil, false, fmt.Errorf("ffprobe failed: %w: %s", err, stderr)
+	}
+
+	var parsed ffprobeOutput
+
+	if err := json.Unmarshal(output, &parsed); err != nil {
+		return nil, false, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	probe := &dtos.LibraryItemProbeDto{}
+	probe.DurationSeconds, _ = strconv.ParseFloat(parsed.Format.Duration, 64)
+
+	hasVideo, hasAudio := false, false
+
+	for _, stream := range parsed.Streams {
+		switch {
+		case stream.CodecType == "video" && !hasVideo:
+			hasVideo = true
+			probe.VideoCodec = stream.CodecName
+			probe.Width = stream.Width
+			probe.Height = stream.Height
+		case stream.CodecType == "audio" && !hasAudio:
+			hasAudio = true
+			probe.AudioCodec = stream.CodecName
+		}
+	}
+
+	if !hasVideo {
+		return nil, false, errMediaNoVideo
+	}
+
+	return probe, hasAudio, nil
+}
+
+// transcode writes master.m3u8, a stream_<n>.m3u8 playlist per rendition and
+// their segments to dir
+func (s *MediaService) transcode(ctx context.Context, input, dir string, probe *dtos.LibraryItemProbeDto, hasAudio bool, progress *jobProgress) error {
+	ladder := renditions(probe.Height)
+
+	args := []string{
+		"-hide_banner", "-nostdin", "-y",
+		"-i", input,
+		"-progress", "pipe:1", "-nostats",
+	}
+
+	split := fmt.Sprintf("[0:v]split=%d", len(ladder))
+	scales := make([]string, len(ladder))
+	streamMap := make([]string, len(ladder))
+
+	for i, r := range ladder {
+		split += fmt.Sprintf("[s%d]", i)
+		scales[i] = fmt.Sprintf("[s%d]scale=-2:%d,format=yuv420p[v%d]", i, r.Height, i)
+		streamMap[i] = fmt.Sprintf("v:%d", i)
+	}
+
+	args = append(args, "-filter_complex", split+";"+strings.Join(scales, ";"))
+
+	for i, r := range ladder {
+		args = append(args,
+			"-map", fmt.Sprintf("[v%d]", i),
+			fmt.Sprintf("-b:v:%d", i), fmt.Sprintf("%dk", r.VideoKbps),
+			fmt.Sprintf("-maxrate:v:%d", i), fmt.Sprintf("%dk", r.MaxrateKbps),
+			fmt.Sprintf("-bufsize:v:%d", i), fmt.Sprintf("%dk", r.VideoKbps*3/2),
+		)
+
+		if hasAudio {
+			args = append(args,
+				"-map", "0:a:0",
+				fmt.Sprintf("-b:a:%d", i), fmt.Sprintf("%dk", r.AudioKbps),
+			)
+			streamMap[i] += fmt.Sprintf(",a:%d", i)
+		}
+	}
+
+	args = append(args,
+		"-c:v", "libx264", "-preset", "veryfast", "-profile:v", "main",
+		"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", hlsSegmentSeconds),
+		"-sc_threshold", "0",
+	)
+
+	if hasAudio {
+		args = append(args, "-c:a", "aac", "-ac", "2")
+	}
+
+	args = append(args,
+		"-f", "hls",
+		"-hls_time", strconv.Itoa(hlsSegmentSeconds),
+		"-hls_playlist_type", "vod",
+		"-hls_flags", "independent_segments",
+		"-hls_segment_filename", filepath.Join(dir, "stream_%v_%05d.ts"),
+		"-master_pl_name", "master.m3u8",
+		"-var_stream_map", strings.Join(streamMap, " "),
+		filepath.Join(dir, "stream_%v.m3u8"),
+	)
+
+	cmd := exec.CommandContext(ctx, s.cfg.FFmpegPath, args...)
+
+	stderr := &tailBuffer{max: mediaErrorTail}
+	cmd.Stderr = stderr
+
+	stdout, err := cmd.StdoutPipe()
+
+	if err != nil {
+		return fmt.Errorf("failed to pipe ffmpeg output: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	// -progress writes key=value lines, out_time_us is how far it got
+	scanner := bufio.NewScanner(stdout)
+
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(scanner.Text(), "=")
+
+		if key != "out_time_us" || probe.DurationSeconds <= 0 {
+			continue
+		}
+
+		us, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		percent := int(float64(us) / 1e6 / probe.DurationSeconds * 100)
+
+		// A lost job is stopped through ctx, other errors are retried
+		progress.report(ctx, models.MediaStageTranscoding, min(max(percent, 0), 99))
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("ffmpeg failed: %w: %s", err, stderr)
+	}
+
+	return nil
+}
+
+// upload puts every file of dir under prefix, after clearing what an
+// earlier attempt left there
+func (s *MediaService) upload(ctx context.Context, dir, prefix string) error {
+	if err := s.store.DeletePrefix(ctx, prefix); err != nil {
+		return fmt.Errorf("failed to clear hls files: %w", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+
+	if err != nil {
+		return fmt.Errorf("failed to list hls files: %w", err)
+	}
+
+	for _, entry := range entries {
+		contentType := "video/mp2t"
+		if strings.HasSuffix(entry.Name(), ".m3u8") {
+			contentType = "application/vnd.apple.mpegurl"
+		}
+
+		if err := s.putFile(ctx, filepath.Join(dir, entry.Name()), prefix+entry.Name(), contentType); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *MediaService) putFile(ctx context.Context, path, key, contentType string) error {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+
+	if err := s.store.Put(ctx, key, file, info.Size(), contentType); err != nil {
+		return fmt.Errorf("failed to store %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// renditions picks the rungs of the ladder no taller than the source, or a
+// single one at the source's height when it is shorter than all of them
+func renditions(height int) []rendition {
+	var picked []rendition
+
+	for _, r := range mediaLadder {
+		if r.Height <= height {
+			picked = append(picked, r)
+		}
+	}
+
+	if len(picked) == 0 {
+		lowest := mediaLadder[len(mediaLadder)-1]
+		if height > 0 {
+			lowest.Height = height &^ 1
+		}
+		picked = append(picked, lowest)
+	}
+
+	return picked
+}
+
+// jobProgress is where a running job got to, written to Postgres at most
+// every mediaProgressInterval unless the stage changes
+type jobProgress struct {
+	repository MediaJobRepository
+	jobID      uuid.UUID
+
+	mu       sync.Mutex
+	stage    string
+	percent  int
+	lastSave time.Time
+}
+
+func newJobProgress(r MediaJobRepository, jobID uuid.UUID) *jobProgress {
+	return &jobProgress{
+		repository: r,
+		jobID:      jobID,
+	}
+}
+
+// report sets the progress, ErrNotFound when the job was lost
+func (p *jobProgress) report(ctx context.Context, stage string, percent int) error {
+	p.mu.Lock()
+	changed := stage != p.stage
+	p.stage, p.percent = stage, percent
+	due := changed || time.Since(p.lastSave) >= mediaProgressInterval
+	p.mu.Unlock()
+
+	if !due {
+		return nil
+	}
+
+	return p.flush(ctx)
+}
+
+// flush writes the progress and renews the lease
+func (p *jobProgress) flush(ctx context.Context) error {
+	p.mu.Lock()
+	stage, percent := p.stage, p.percent
+	p.lastSave = time.Now()
+	p.mu.Unlock()
+
+	return p.repository.Progress(ctx, p.jobID, stage, percent, mediaJobLease)
+}
+
+// tailBuffer keeps the last max bytes written to it
+type tailBuffer struct {
+	max int
+	buf []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+
+	if len(b.buf) > b.max {
+		b.buf = b.buf[len(b.buf)-b.max:]
+	}
+
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	return strings.TrimSpace(string(b.buf))
+}
+
+func truncateTail(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	return strings.ToValidUTF8(s[len(s)-n:], "")
+}
@@ -0,0 +1,401 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/dliluashvili/cowatchit/internal/config"
+	"github.com/dliluashvili/cowatchit/internal/dtos"
+	"github.com/dliluashvili/cowatchit/internal/helpers"
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/dliluashvili/cowatchit/internal/repositories"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrOIDCLoginExpired     = errors.New("sign in took too long or was started elsewhere")
+	ErrOIDCSignupExpired    = errors.New("sign up took too long, sign in again")
+	ErrOIDCEmailMissing     = errors.New("the identity provider didn't share an email address")
+	ErrOIDCDomainNotAllowed = errors.New("this email domain may not sign in")
+	ErrOIDCEmailTaken       = errors.New("an account with this email already exists, sign in with its password")
+)
+
+const (
+	// How long the provider may take to send the user back
+	OIDCLoginTTL = 10 * time.Minute
+	// How long a first time user has to pick a username
+	OIDCSignupTTL = 15 * time.Minute
+)
+
+var usernameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+
+// Kept in Redis between redirecting to the provider and its callback
+type oidcLogin struct {
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+// PendingSignup is a verified identity without an account yet
+type PendingSignup struct {
+	Issuer            string `json:"issuer"`
+	Subject           string `json:"subject"`
+	Email             string `json:"email"`
+	SuggestedUsername string `json:"suggested_username"`
+}
+
+// Outcome of the provider's callback, either a session or a signup
+// waiting for a username
+type OIDCResult struct {
+	Session     *models.Session
+	SignupToken string
+}
+
+type oidcClaims struct {
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username"`
+}
+
+// OIDCService signs users in through an OpenID Connect provider, linking
+// its identities to users and creating accounts on first sign in
+type OIDCService struct {
+	config      config.OIDCConfig
+	redisClient *redis.Client
+	identities  UserIdentityRepository
+	transactor  Transactor
+	userService *UserService
+	authService *AuthService
+
+	// Discovered on first use, so the server starts while the provider is down
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+func NewOIDCService(
+	cfg config.OIDCConfig,
+	rc *redis.Client,
+	identities UserIdentityRepository,
+	tx Transactor,
+	us *UserService,
+	as *AuthService,
+) *OIDCService {
+	return &OIDCService{
+		config:      cfg,
+		redisClient: rc,
+		identities:  identities,
+		transactor:  tx,
+		userService: us,
+		authService: as,
+	}
+}
+
+func (s *OIDCService) discover(ctx context.Context) (*oidc.Provider, *oauth2.Config, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.provider == nil {
+		provider, err := oidc.NewProvider(ctx, s.config.Issuer)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to discover oidc provider: %w", err)
+		}
+		s.provider = provider
+	}
+
+	oauthConfig := &oauth2.Config{
+		ClientID:     s.config.ClientID,
+		ClientSecret: s.config.ClientSecret,
+		RedirectURL:  s.config.RedirectURL,
+		Endpoint:     s.provider.Endpoint(),
+		Scopes:       s.config.Scopes,
+	}
+
+	return s.provider, oauthConfig, nil
+}
+
+// AuthCodeURL starts a sign in, the returned state must come back with
+// the callback from the same browser
+func (s *OIDCService) AuthCodeURL(ctx context.Context) (string, string, error) {
+	_, oauthConfig, err := s.discover(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := helpers.GenerateSessionID()
+	if err != nil {
+		return "", "", err
+	}
+
+	nonce, err := helpers.GenerateSessionID()
+	if err != nil {
+		return "", "", err
+	}
+
+	login := oidcLogin{
+		Nonce:    nonce,
+		Verifier: oauth2.GenerateVerifier(),
+	}
+
+	data, err := json.Marshal(login)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := s.redisClient.Set(ctx, oidcLoginKey(state), data, OIDCLoginTTL).Err(); err != nil {
+		return "", "", fmt.Errorf("failed to save oidc login: %w", err)
+	}
+
+	url := oauthConfig.AuthCodeURL(state, oidc.Nonce(login.Nonce), oauth2.S256ChallengeOption(login.Verifier))
+
+	return url, state, nil
+}
+
+// Callback redeems the provider's code. Known identities get a session,
+// an unknown one with a verified email matching an account is linked to
+// it, any other becomes a pending signup.
+func (s *OIDCService) Callback(ctx context.Context, state, code string) (*OIDCResult, error) {
+	provider, oauthConfig, err := s.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := s.redisClient.GetDel(ctx, oidcLoginKey(state)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrOIDCLoginExpired
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch oidc login: %w", err)
+	}
+
+	var login oidcLogin
+	if err := json.Unmarshal(data, &login); err != nil {
+		return nil, fmt.Errorf("failed to parse oidc login: %w", err)
+	}
+
+	token, err := oauthConfig.Exchange(ctx, code, oauth2.VerifierOption(login.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange oidc code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("oidc token response has no id_token")
+	}
+
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: s.config.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify id token: %w", err)
+	}
+
+	if idToken.Nonce != login.Nonce {
+		return nil, errors.New("id token nonce mismatch")
+	}
+
+	var claims oidcClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to parse id token claims: %w", err)
+	}
+
+	if claims.Email == "" {
+		return nil, ErrOIDCEmailMissing
+	}
+
+	if !s.domainAllowed(claims.Email, claims.EmailVerified) {
+		return nil, ErrOIDCDomainNotAllowed
+	}
+
+	user, err := s.findUser(ctx, idToken, &claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if user != nil {
+		session, err := s.authService.generateSessionModel(ctx, user)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create session: %w", err)
+		}
+
+		return &OIDCResult{Session: session}, nil
+	}
+
+	signupToken, err := s.savePendingSignup(ctx, &PendingSignup{
+		Issuer:            idToken.Issuer,
+		Subject:           idToken.Subject,
+		Email:             claims.Email,
+		SuggestedUsername: suggestUsername(claims.PreferredUsername, claims.Email),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &OIDCResult{SignupToken: signupToken}, nil
+}
+
+// The user an identity belongs to, nil when it needs an account
+func (s *OIDCService) findUser(ctx context.Context, idToken *oidc.IDToken, claims *oidcClaims) (*models.User, error) {
+	identity, err := s.identities.FindBySubject(ctx, idToken.Issuer, idToken.Subject)
+
+	if err == nil {
+		user, err := s.userService.Me(ctx, identity.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch identity user: %w", err)
+		}
+		if user == nil {
+			return nil, fmt.Errorf("user %s of identity %s is gone", identity.UserID, identity.ID)
+		}
+		return user, nil
+	}
+
+	if !errors.Is(err, repositories.ErrNotFound) {
+		return nil, fmt.Errorf("failed to fetch identity: %w", err)
+	}
+
+	user, err := s.userService.FindByEmail(ctx, claims.Email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user by email: %w", err)
+	}
+
+	if user == nil {
+		return nil, nil
+	}
+
+	// Only the provider vouching for the address proves it is the same person
+	if !claims.EmailVerified {
+		return nil, ErrOIDCEmailTaken
+	}
+
+	_, err = s.identities.Create(ctx, &dtos.CreateUserIdentityDto{
+		UserID:  user.ID,
+		Issuer:  idToken.Issuer,
+		Subject: idToken.Subject,
+		Email:   claims.Email,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	return user, nil
+}
+
+func (s *OIDCService) PendingSignup(ctx context.Context, token string) (*PendingSignup, error) {
+	data, err := s.redisClient.Get(ctx, oidcSignupKey(token)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrOIDCSignupExpired
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pending signup: %w", err)
+	}
+
+	var pending PendingSignup
+	if err := json.Unmarshal(data, &pending); err != nil {
+		return nil, fmt.Errorf("failed to parse pending signup: %w", err)
+	}
+
+	return &pending, nil
+}
+
+// CompleteSignup creates the account of a pending signup with the chosen,
+// already validated, username and signs it in
+func (s *OIDCService) CompleteSignup(ctx context.Context, token, username string) (*models.Session, error) {
+	pending, err := s.PendingSignup(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	var user *models.User
+
+	err = s.transactor.Transaction(ctx, func(ctx context.Context) error {
+		user, err = s.userService.CreateExternal(ctx, username, pending.Email)
+		if err != nil {
+			return fmt.Errorf("failed to create user: %w", err)
+		}
+
+		_, err = s.identities.Create(ctx, &dtos.CreateUserIdentityDto{
+			UserID:  user.ID,
+			Issuer:  pending.Issuer,
+			Subject: pending.Subject,
+			Email:   pending.Email,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create identity: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.redisClient.Del(ctx, oidcSignupKey(token)).Err(); err != nil {
+		return nil, fmt.Errorf("failed to delete pending signup: %w", err)
+	}
+
+	return s.authService.generateSessionModel(ctx, user)
+}
+
+func (s *OIDCService) savePendingSignup(ctx context.Context, pending *PendingSignup) (string, error) {
+	token, err := helpers.GenerateSessionID()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.redisClient.Set(ctx, oidcSignupKey(token), data, OIDCSignupTTL).Err(); err != nil {
+		return "", fmt.Errorf("failed to save pending signup: %w", err)
+	}
+
+	return token, nil
+}
+
+// With an allowlist the domain must also be vouched for by the provider
+func (s *OIDCService) domainAllowed(email string, verified bool) bool {
+	if len(s.config.AllowedDomains) == 0 {
+		return true
+	}
+
+	_, domain, found := strings.Cut(email, "@")
+
+	return found && verified && slices.ContainsFunc(s.config.AllowedDomains, func(allowed string) bool {
+		return strings.EqualFold(allowed, domain)
+	})
+}
+
+func oidcLoginKey(state string) string {
+	return fmt.Sprintf("oidc_login:%s", state)
+}
+
+func oidcSignupKey(token string) string {
+	return fmt.Sprintf("oidc_signup:%s", token)
+}
+
+// A starting point that passes the username validator, users may change it
+func suggestUsername(preferred, email string) string {
+	name := preferred
+	if name == "" {
+		name, _, _ = strings.Cut(email, "@")
+	}
+
+	name = strings.Trim(usernameInvalidChars.ReplaceAllString(name, "_"), "_")
+
+	if len(name) > 15 {
+		name = name[:15]
+	}
+
+	for len(name) < 4 {
+		name += "_"
+	}
+
+	return name
+}
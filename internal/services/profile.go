@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/dliluashvili/cowatchit/internal/dtos"
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/dliluashvili/cowatchit/internal/repositories"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUserNotFound    = errors.New("user not found")
+	ErrUsernameTaken   = errors.New("username is already taken")
+	ErrCountryNotFound = errors.New("country not found")
+)
+
+// How many rooms each list of a public profile shows
+const ProfileRoomsLimit = 12
+
+// Profile is what /u/{username} shows
+type Profile struct {
+	User    *models.User
+	Country *models.Country
+	// Rooms the user hosts
+	HostedRooms []models.Room
+	// Rooms the user and the viewer have both joined, the user's own
+	// history when they look at their profile
+	SharedRooms []models.Room
+}
+
+type ProfileService struct {
+	countryRepository     CountryRepository
+	roomRepository        RoomRepository
+	roomMessageRepository RoomMessageRepository
+	transactor            Transactor
+	userService           *UserService
+	imageService          *ImageService
+}
+
+func NewProfileService(
+	cr CountryRepository,
+	rp RoomRepository,
+	rmp RoomMessageRepository,
+	tx Transactor,
+	us *UserService,
+	is *ImageService,
+) *ProfileService {
+	return &ProfileService{
+		countryRepository:     cr,
+		roomRepository:        rp,
+		roomMessageRepository: rmp,
+		transactor:            tx,
+		userService:           us,
+		imageService:          is,
+	}
+}
+
+func (s *ProfileService) Countries(ctx context.Context) ([]models.Country, error) {
+	return s.countryRepository.FindAll(ctx)
+}
+
+// Update saves the profile and returns the user as changed. A new username
+// is copied into the user's rooms and messages in the same transaction.
+func (s *ProfileService) Update(ctx context.Context, userID uuid.UUID, dto *dtos.UpdateProfileDto) (*models.User, error) {
+	user, err := s.userService.Me(ctx, userID)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	var countryID *uuid.UUID
+
+	if dto.CountryID != "" {
+		ID, err := uuid.Parse(dto.CountryID)
+		if err != nil {
+			return nil, ErrCountryNotFound
+		}
+
+		if _, err := s.countryRepository.FindOne(ctx, ID); err != nil {
+			if errors.Is(err, repositories.ErrNotFound) {
+				return nil, ErrCountryNotFound
+			}
+			return nil, fmt.Errorf("failed to find country: %w", err)
+		}
+
+		countryID = &ID
+	}
+
+	renamed := dto.Username != user.Username
+
+	if renamed {
+		existing, err := s.userService.FindByUsername(ctx, dto.Username)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to check username: %w", err)
+		}
+
+		if existing != nil && existing.ID != user.ID {
+			return nil, ErrUsernameTaken
+		}
+	}
+
+	user.Username = dto.Username
+	user.DisplayName = dto.DisplayName
+	user.Bio = dto.Bio
+	user.Gender = dto.Gender
+	user.CountryID = countryID
+
+	err = s.transactor.Transaction(ctx, func(ctx context.Context) error {
+		if err := s.userService.UpdateProfile(ctx, user); err != nil {
+			return fmt.Errorf("failed to update user: %w", err)
+		}
+
+		if !renamed {
+			return nil
+		}
+
+		// Rooms and messages keep a copy of the username
+		if err := s.roomRepository.UpdateHostUsername(ctx, user.ID, user.Username); err != nil {
+			return fmt.Errorf("failed to rename room host: %w", err)
+		}
+
+		if err := s.roomMessageRepository.UpdateSenderUsername(ctx, user.ID, user.Username); err != nil {
+			return fmt.Errorf("failed to rename message sender: %w", err)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// SetAvatar stores the uploaded image as the user's avatar
+func (s *ProfileService) SetAvatar(ctx context.Context, userID uuid.UUID, r io.Reader) (*models.User, error) {
+	user, err := s.userService.Me(ctx, userID)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	avatar, err := s.imageService.Save(ctx, AvatarImage, user.ID, r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.userService.SetAvatar(ctx, user.ID, avatar); err != nil {
+		s.imageService.Remove(ctx, AvatarImage, user.ID, avatar)
+		return nil, fmt.Errorf("failed to update avatar: %w", err)
+	}
+
+	s.imageService.Remove(ctx, AvatarImage, user.ID, user.Avatar)
+	user.Avatar = avatar
+
+	return user, nil
+}
+
+// Public loads the profile of username as viewerID sees it
+func (s *ProfileService) Public(ctx context.Context, username string, viewerID uuid.UUID) (*Profile, error) {
+	user, err := s.userService.FindByUsername(ctx, username)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	profile := &Profile{User: user}
+
+	if user.CountryID != nil {
+		country, err := s.countryRepository.FindOne(ctx, *user.CountryID)
+
+		if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+			return nil, fmt.Errorf("failed to find country: %w", err)
+		}
+
+		profile.Country = country
+	}
+
+	page := dtos.PageDto{Limit: ProfileRoomsLimit}
+
+	profile.HostedRooms, err = s.roomRepository.Find(ctx, &dtos.FindRoomDto{
+		HostID: &user.ID,
+		Page:   page,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find hosted rooms: %w", err)
+	}
+
+	profile.SharedRooms, err = s.roomRepository.FindShared(ctx, viewerID, user.ID, page)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find shared rooms: %w", err)
+	}
+
+	return profile, nil
+}
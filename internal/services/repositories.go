@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/dliluashvili/cowatchit/internal/dtos"
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/dliluashvili/cowatchit/internal/repositories"
+	"github.com/google/uuid"
+)
+
+// Storage the services depend on, implemented by the repositories package
+
+type UserRepository interface {
+	Create(ctx context.Context, dto *dtos.CreateUserDto) (*models.User, error)
+	FindByField(ctx context.Context, params map[string]any) (*models.User, error)
+	Update(ctx context.Context, userID uuid.UUID, updates map[string]any) error
+	Delete(ctx context.Context, ID uuid.UUID) (*bool, error)
+}
+
+type RoomRepository interface {
+	Create(ctx context.Context, dto *dtos.CreateRoomRepoDto) (*models.Room, error)
+	Find(ctx context.Context, dto *dtos.FindRoomDto) ([]models.Room, error)
+	FindShared(ctx context.Context, userID, otherID uuid.UUID, page dtos.PageDto) ([]models.Room, error)
+	FindByLibraryItem(ctx context.Context, itemID uuid.UUID) ([]models.Room, error)
+	FindOne(ctx context.Context, ID uuid.UUID) (*models.Room, error)
+	Exists(ctx context.Context, ID uuid.UUID) (bool, error)
+	Update(ctx context.Context, ID uuid.UUID, updates map[string]any) error
+	Delete(ctx context.Context, ID uuid.UUID) error
+	UpdateHostUsername(ctx context.Context, hostID uuid.UUID, username string) error
+}
+
+type RoomMessageRepository interface {
+	Create(ctx context.Context, dto *dtos.CreateRoomMessageDto) (*models.RoomMessage, error)
+	FindByRoom(ctx context.Context, roomID uuid.UUID, page dtos.PageDto) ([]*models.RoomMessage, error)
+	DeleteByRoom(ctx context.Context, roomID uuid.UUID) error
+	UpdateSenderUsername(ctx context.Context, senderID uuid.UUID, username string) error
+}
+
+type RoomUserRepository interface {
+	Add(ctx context.Context, roomID, userID uuid.UUID) error
+}
+
+type LibraryRepository interface {
+	Create(ctx context.Context, dto *dtos.CreateLibraryItemRepoDto) (*models.LibraryItem, error)
+	FindOne(ctx context.Context, ID uuid.UUID) (*models.LibraryItem, error)
+	FindByUser(ctx context.Context, userID uuid.UUID) ([]models.LibraryItem, error)
+	UsedBytes(ctx context.Context, userID uuid.UUID) (int64, error)
+	UpdateReceived(ctx context.Context, ID uuid.UUID, from, to int64) error
+	MarkReady(ctx context.Context, ID uuid.UUID, key string) error
+	InUse(ctx context.Context, ID uuid.UUID) (bool, error)
+	Delete(ctx context.Context, ID uuid.UUID) error
+	SetProbe(ctx context.Context, ID uuid.UUID, dto *dtos.LibraryItemProbeDto) error
+	SetManifest(ctx context.Context, ID uuid.UUID, key string) error
+}
+
+type MediaJobRepository interface {
+	Enqueue(ctx context.Context, itemID uuid.UUID) error
+	FindByItems(ctx context.Context, itemIDs []uuid.UUID) ([]models.MediaJob, error)
+	Claim(ctx context.Context, lease time.Duration) (*models.MediaJob, error)
+	Progress(ctx context.Context, ID uuid.UUID, stage string, progress int, lease time.Duration) error
+	Finish(ctx context.Context, ID uuid.UUID) error
+	Fail(ctx context.Context, ID uuid.UUID, reason string, retry bool) error
+	Release(ctx context.Context, ID uuid.UUID) error
+}
+
+type CountryRepository interface {
+	FindAll(ctx context.Context) ([]models.Country, error)
+	FindOne(ctx context.Context, ID uuid.UUID) (*models.Country, error)
+}
+
+type APITokenRepository interface {
+	Create(ctx context.Context, dto *dtos.CreateAPITokenRepoDto) (*models.APIToken, error)
+	FindByHash(ctx context.Context, hash string) (*models.APIToken, error)
+	FindByUser(ctx context.Context, userID uuid.UUID) ([]*models.APIToken, error)
+	Revoke(ctx context.Context, userID, ID uuid.UUID) error
+	UpdateLastUsed(ctx context.Context, ID uuid.UUID, at time.Time) error
+}
+
+type UserIdentityRepository interface {
+	Create(ctx context.Context, dto *dtos.CreateUserIdentityDto) (*models.UserIdentity, error)
+	FindBySubject(ctx context.Context, issuer, subject string) (*models.UserIdentity, error)
+}
+
+type RecoveryCodeRepository interface {
+	Replace(ctx context.Context, userID uuid.UUID, hashes []string) error
+	Use(ctx context.Context, userID uuid.UUID, hash string) error
+	CountUnused(ctx context.Context, userID uuid.UUID) (int64, error)
+	DeleteByUser(ctx context.Context, userID uuid.UUID) error
+}
+
+// Transactor runs fn as one unit of work, repository calls made with the
+// context passed to fn are committed or rolled back together
+type Transactor interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+var (
+	_ UserRepository         = (*repositories.UserRepository)(nil)
+	_ RoomRepository         = (*repositories.RoomRepository)(nil)
+	_ RoomMessageRepository  = (*repositories.RoomMessageRepository)(nil)
+	_ RoomUserRepository     = (*repositories.RoomUserRepository)(nil)
+	_ LibraryRepository      = (*repositories.LibraryRepository)(nil)
+	_ MediaJobRepository     = (*repositories.MediaJobRepository)(nil)
+	_ CountryRepository      = (*repositories.CountryRepository)(nil)
+	_ APITokenRepository     = (*repositories.APITokenRepository)(nil)
+	_ UserIdentityRepository = (*repositories.UserIdentityRepository)(nil)
+	_ RecoveryCodeRepository = (*repositories.RecoveryCodeRepository)(nil)
+	_ Transactor             = (*repositories.Transactor)(nil)
+)
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dliluashvili/cowatchit/internal/helpers"
+	"github.com/google/uuid"
+)
+
+var ErrResumeRejected = errors.New("resume rejected")
+
+type ResumeOptions struct {
+	// How long a dropped socket keeps its participant slot
+	Window time.Duration
+	// How many room events are kept for replay
+	ReplayBufferSize int
+}
+
+var DefaultResumeOptions = ResumeOptions{
+	Window:           30 * time.Second,
+	ReplayBufferSize: 256,
+}
+
+type ResumedSocket struct {
+	SocketID       string
+	RoomID         uuid.UUID
+	IsHost         bool
+	ResumeToken    string
+	ReplayComplete bool
+}
+
+type roomEvent struct {
+	seq             uint64
+	data            []byte
+	excludeSocketID string
+}
+
+// Ring of the latest room events, used to catch up resumed sockets
+type replayBuffer struct {
+	lastSeq uint64
+	events  []roomEvent
+	size    int
+}
+
+func newReplayBuffer(size int) *replayBuffer {
+	return &replayBuffer{
+		events: make([]roomEvent, 0, size),
+		size:   size,
+	}
+}
+
+func (b *replayBuffer) nextSeq() uint64 {
+	b.lastSeq++
+	return b.lastSeq
+}
+
+func (b *replayBuffer) append(event roomEvent) {
+	if len(b.events) >= b.size {
+		copy(b.events, b.events[1:])
+		b.events = b.events[:len(b.events)-1]
+	}
+
+	b.events = append(b.events, event)
+}
+
+// Events after seq, false if some of them were already evicted
+func (b *replayBuffer) since(seq uint64) ([]roomEvent, bool) {
+	if seq >= b.lastSeq {
+		return nil, true
+	}
+
+	complete := len(b.events) > 0 && b.events[0].seq <= seq+1
+
+	for i, event := range b.events {
+		if event.seq > seq {
+			return b.events[i:], complete
+		}
+	}
+
+	return nil, complete
+}
+
+type suspendedSocket struct {
+	timer *time.Timer
+}
+
+// Issue a resume token for the socket, it stays valid until the socket
+// leaves its room or its resume window expires
+func (sm *WebSocketManagerService) IssueResumeToken(socketID string) (string, error) {
+	token, err := helpers.GenerateSessionID()
+	if err != nil {
+		return "", err
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.setResumeToken(socketID, token)
+
+	return token, nil
+}
+
+// Suspend keeps a dropped socket in its room for the resume window.
+// onExpire runs if the socket is not resumed in time, after which the
+// socket is expected to be unregistered.
+// Returns false if the socket is not in a room.
+func (sm *WebSocketManagerService) Suspend(socketID string, onExpire func()) bool {
+	// Nothing to resume into once the process exits
+	if sm.draining.Load() {
+		return false
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	userID, exists := sm.socketIdToUserId[socketID]
+	if !exists {
+		return false
+	}
+
+	if _, hasToken := sm.socketResumeTokens[socketID]; !hasToken {
+		return false
+	}
+
+	// Kicked because the user joined another room
+	roomID := sm.getRoomIDFromSocket(socketID)
+	for otherSocketID := range sm.userIDSocketIDs[userID] {
+		if otherSocketID != socketID && sm.getRoomIDFromSocket(otherSocketID) != roomID {
+			return false
+		}
+	}
+
+	sm.suspended[socketID] = &suspendedSocket{
+		timer: time.AfterFunc(sm.resumeOptions.Window, func() {
+			if sm.expireSuspended(socketID) {
+				onExpire()
+			}
+		}),
+	}
+
+	return true
+}
+
+func (sm *WebSocketManagerService) expireSuspended(socketID string) bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if _, exists := sm.suspended[socketID]; !exists {
+		return false
+	}
+
+	delete(sm.suspended, socketID)
+	sm.deleteResumeToken(socketID)
+
+	return true
+}
+
+// Resume moves the connection attached as newSocketID into the participant
+// slot of the suspended socket the token was issued for. The message answer
+// builds for the outcome is queued first, then every room event after lastSeq
+// that the socket missed, before any later event can reach it.
+func (sm *WebSocketManagerService) Resume(
+	token, newSocketID string,
+	userID uuid.UUID,
+	lastSeq uint64,
+	answer func(resumed *ResumedSocket) ([]byte, error),
+) (*ResumedSocket, error) {
+	// Generated up front so a failure leaves the suspended socket untouched
+	newToken, err := helpers.GenerateSessionID()
+	if err != nil {
+		return nil, err
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	socketID, exists := sm.resumeTokens[token]
+	if !exists {
+		return nil, ErrResumeRejected
+	}
+
+	suspended, isSuspended := sm.suspended[socketID]
+	if !isSuspended || sm.socketIdToUserId[socketID] != userID {
+		return nil, ErrResumeRejected
+	}
+
+	writer, exists := sm.socketWriters[newSocketID]
+	if !exists {
+		return nil, ErrResumeRejected
+	}
+
+	roomID := sm.getRoomIDFromSocket(socketID)
+
+	resumed := &ResumedSocket{
+		SocketID:       socketID,
+		RoomID:         roomID,
+		ResumeToken:    newToken,
+		ReplayComplete: true,
+	}
+
+	if roomMeta, exists := sm.roomMetadata[roomID]; exists {
+		if userInfo, exists := roomMeta.Users[userID]; exists {
+			resumed.IsHost = userInfo.IsHost
+		}
+	}
+
+	var replay [][]byte
+
+	if buffer, exists := sm.roomEvents[roomID]; exists {
+		events, complete := buffer.since(lastSeq)
+		resumed.ReplayComplete = complete
+
+		for _, event := range events {
+			if event.excludeSocketID != socketID {
+				replay = append(replay, event.data)
+			}
+		}
+	}
+
+	// A replay that can't be queued whole is not sent, the client starts over
+	if writer.free() < len(replay)+1 {
+		resumed.ReplayComplete = false
+		replay = nil
+	}
+
+	data, err := answer(resumed)
+	if err != nil {
+		return nil, err
+	}
+
+	// Lost the race against expiry
+	if !suspended.timer.Stop() {
+		return nil, ErrResumeRejected
+	}
+
+	delete(sm.suspended, socketID)
+
+	// Reattach the connection under the old socket ID
+	delete(sm.socketWriters, newSocketID)
+	sm.socketWriters[socketID] = writer
+	sm.deleteResumeToken(newSocketID)
+
+	sm.deleteResumeToken(socketID)
+	sm.setResumeToken(socketID, newToken)
+
+	if err := writer.Enqueue(data); err != nil {
+		return resumed, err
+	}
+
+	for _, event := range replay {
+		if err := writer.Enqueue(event); err != nil {
+			return resumed, err
+		}
+	}
+
+	return resumed, nil
+}
+
+// Check if socket is waiting to be resumed
+func (sm *WebSocketManagerService) IsSuspended(socketID string) bool {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	_, exists := sm.suspended[socketID]
+	return exists
+}
+
+func (sm *WebSocketManagerService) setResumeToken(socketID, token string) {
+	sm.resumeTokens[token] = socketID
+	sm.socketResumeTokens[socketID] = token
+}
+
+func (sm *WebSocketManagerService) deleteResumeToken(socketID string) {
+	if token, exists := sm.socketResumeTokens[socketID]; exists {
+		delete(sm.resumeTokens, token)
+		delete(sm.socketResumeTokens, socketID)
+	}
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/dliluashvili/cowatchit/internal/protocol"
+	"github.com/dliluashvili/cowatchit/internal/types"
+	"github.com/google/uuid"
+)
+
+// Reads the next message the client got, failing after a second
+func readTestMessage(t *testing.T, client *websocket.Conn) *protocol.Message {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, data, err := client.Read(ctx)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	msg, err := protocol.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return msg
+}
+
+func TestReplayBufferSince(t *testing.T) {
+	buffer := newReplayBuffer(3)
+	for range 5 {
+		seq := buffer.nextSeq()
+		buffer.append(roomEvent{seq: seq})
+	}
+
+	tests := []struct {
+		name     string
+		seq      uint64
+		want     []uint64
+		complete bool
+	}{
+		{"up to date", 5, nil, true},
+		{"ahead", 9, nil, true},
+		{"missed some", 3, []uint64{4, 5}, true},
+		{"missed everything kept", 2, []uint64{3, 4, 5}, true},
+		{"missed an evicted event", 1, []uint64{3, 4, 5}, false},
+		{"never seen any", 0, []uint64{3, 4, 5}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, complete := buffer.since(tt.seq)
+
+			var seqs []uint64
+			for _, event := range events {
+				seqs = append(seqs, event.seq)
+			}
+
+			if !slices.Equal(seqs, tt.want) || complete != tt.complete {
+				t.Errorf("since(%d) = %v, %t, want %v, %t", tt.seq, seqs, complete, tt.want, tt.complete)
+			}
+		})
+	}
+}
+
+func TestReplayBufferEviction(t *testing.T) {
+	buffer := newReplayBuffer(2)
+	for range 4 {
+		seq := buffer.nextSeq()
+		buffer.append(roomEvent{seq: seq})
+	}
+
+	if len(buffer.events) != 2 || buffer.events[0].seq != 3 || buffer.events[1].seq != 4 {
+		t.Errorf("kept %+v, want seq 3 and 4", buffer.events)
+	}
+
+	if _, complete := buffer.since(0); complete {
+		t.Error("a replay from before the evicted events is complete")
+	}
+}
+
+type resumeFixture struct {
+	manager *WebSocketManagerService
+	room    *models.Room
+	host    *models.User
+	token   string
+}
+
+// A host socket that joined a room, got a resume token and then dropped
+func newResumeFixture(t *testing.T, window time.Duration, onExpire func()) *resumeFixture {
+	t.Helper()
+
+	opts := DefaultWebSocketManagerOptions
+	opts.Resume.Window = window
+
+	f := &resumeFixture{
+		manager: NewWebSocketManagerService(opts),
+		host:    &models.User{ID: uuid.New(), Username: "host"},
+	}
+	f.room = &models.Room{ID: uuid.New(), HostID: f.host.ID, Capacity: 4}
+
+	conn, _ := newTestConn(t)
+	f.manager.Attach("dropped", conn)
+
+	err := f.manager.Register(&types.WebSocketContext{ID: "dropped", User: f.host, RoomID: f.room.ID, IsHost: true}, f.room)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.token, err = f.manager.IssueResumeToken("dropped")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.manager.Detach("dropped")
+	if !f.manager.Suspend("dropped", onExpire) {
+		t.Fatal("socket was not suspended")
+	}
+
+	return f
+}
+
+func (f *resumeFixture) broadcast(t *testing.T, username string) {
+	t.Helper()
+
+	msg, err := protocol.NewEvent(protocol.EventUserLeft, protocol.UserLeft{Username: username})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.manager.BroadcastToRoom(context.Background(), f.room.ID, msg, ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func answerWith(data string) func(*ResumedSocket) ([]byte, error) {
+	return func(*ResumedSocket) ([]byte, error) {
+		return []byte(data), nil
+	}
+}
+
+func TestResumeAnswersBeforeReplay(t *testing.T) {
+	f := newResumeFixture(t, time.Minute, func() {})
+
+	f.broadcast(t, "first")
+	f.broadcast(t, "second")
+	f.broadcast(t, "third")
+
+	conn, client := newTestConn(t)
+	f.manager.Attach("new", conn)
+
+	var answered *ResumedSocket
+	resumed, err := f.manager.Resume(f.token, "new", f.host.ID, 1, func(resumed *ResumedSocket) ([]byte, error) {
+		answered = resumed
+		return protocol.Encode(&protocol.Message{Type: protocol.TypeEvent, Event: protocol.EventResumeAnswer})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if answered != resumed || resumed.SocketID != "dropped" || resumed.RoomID != f.room.ID || !resumed.IsHost || !resumed.ReplayComplete {
+		t.Errorf("resumed = %+v", resumed)
+	}
+
+	// A broadcast racing the resume must come after the replay
+	f.broadcast(t, "fourth")
+
+	if msg := readTestMessage(t, client); msg.Event != protocol.EventResumeAnswer {
+		t.Fatalf("first message is %s, want %s", msg.Event, protocol.EventResumeAnswer)
+	}
+
+	for _, want := range []uint64{2, 3, 4} {
+		if msg := readTestMessage(t, client); msg.Seq != want {
+			t.Errorf("got seq %d, want %d", msg.Seq, want)
+		}
+	}
+
+	if f.manager.IsSuspended("dropped") {
+		t.Error("socket is still suspended")
+	}
+
+	// The old token is spent
+	if _, err := f.manager.Resume(f.token, "new", f.host.ID, 0, answerWith("{}")); !errors.Is(err, ErrResumeRejected) {
+		t.Errorf("reused token: err = %v, want %v", err, ErrResumeRejected)
+	}
+}
+
+func TestResumeRejected(t *testing.T) {
+	tests := []struct {
+		name  string
+		token func(f *resumeFixture) string
+		user  func(f *resumeFixture) uuid.UUID
+	}{
+		{
+			name:  "unknown token",
+			token: func(*resumeFixture) string { return "unknown" },
+			user:  func(f *resumeFixture) uuid.UUID { return f.host.ID },
+		},
+		{
+			name:  "another user's token",
+			token: func(f *resumeFixture) string { return f.token },
+			user:  func(*resumeFixture) uuid.UUID { return uuid.New() },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newResumeFixture(t, time.Minute, func() {})
+
+			conn, _ := newTestConn(t)
+			f.manager.Attach("new", conn)
+
+			answered := false
+			_, err := f.manager.Resume(tt.token(f), "new", tt.user(f), 0, func(*ResumedSocket) ([]byte, error) {
+				answered = true
+				return nil, nil
+			})
+
+			if !errors.Is(err, ErrResumeRejected) || answered {
+				t.Errorf("err = %v and answered %t, want %v unanswered", err, answered, ErrResumeRejected)
+			}
+
+			if !f.manager.IsSuspended("dropped") {
+				t.Error("a rejected resume ended the suspension")
+			}
+		})
+	}
+}
+
+func TestResumeRejectsExpiredToken(t *testing.T) {
+	expired := make(chan struct{})
+	f := newResumeFixture(t, 10*time.Millisecond, func() { close(expired) })
+
+	select {
+	case <-expired:
+	case <-time.After(time.Second):
+		t.Fatal("resume window never expired")
+	}
+
+	conn, _ := newTestConn(t)
+	f.manager.Attach("new", conn)
+
+	if _, err := f.manager.Resume(f.token, "new", f.host.ID, 0, answerWith("{}")); !errors.Is(err, ErrResumeRejected) {
+		t.Errorf("err = %v, want %v", err, ErrResumeRejected)
+	}
+}
@@ -2,36 +2,130 @@ package services
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
 	"fmt"
+	"io"
+	"net/url"
+	"time"
 
 	"github.com/dliluashvili/cowatchit/internal/dtos"
+	"github.com/dliluashvili/cowatchit/internal/logging"
 	"github.com/dliluashvili/cowatchit/internal/models"
 	"github.com/dliluashvili/cowatchit/internal/repositories"
+	"github.com/dliluashvili/cowatchit/internal/storage"
+	"github.com/dliluashvili/cowatchit/internal/tracing"
 	"github.com/google/uuid"
 )
 
 var (
-	ErrRoomNotFound = errors.New("room not found")
-	ErrRoomFull     = errors.New("room is full")
+	ErrRoomNotFound         = errors.New("room not found")
+	ErrRoomFull             = errors.New("room is full")
+	ErrNotRoomHost          = errors.New("only the host can do this")
+	ErrRoomPasswordRequired = errors.New("private rooms need a password")
+	ErrRoomSourceRequired   = errors.New("a video link or a library video is required")
+	ErrMediaLinkInvalid     = errors.New("media link is invalid or expired")
+)
+
+// Where the server serves the library videos rooms play
+const MediaBaseURL = "/media"
+
+// Media is what a room's viewer plays
+type Media struct {
+	Src string
+	// Zero when unknown
+	DurationSeconds float64
+	// How long Src works, zero when it doesn't expire
+	ExpiresIn time.Duration
+}
+
+// How long compensating cleanup may take once the caller's context is gone
+const cleanupTimeout = 5 * time.Second
+
+// RoomHosts looks up the users who create rooms
+type RoomHosts interface {
+	FindByID(ctx context.Context, idStr string) (*models.User, error)
+}
+
+// RoomState keeps the live capacity and participants of rooms
+type RoomState interface {
+	CreateRoom(ctx context.Context, roomID, hostID uuid.UUID, src string, capacity int) error
+	UpdateRoom(ctx context.Context, roomID uuid.UUID, fields map[string]any) error
+	AddUser(ctx context.Context, roomID, userID uuid.UUID) error
+	RemoveUser(ctx context.Context, roomID, userID uuid.UUID) error
+	GetRoomCapacity(ctx context.Context, roomID uuid.UUID) (int, error)
+	CountRoomUsers(ctx context.Context, roomID uuid.UUID) (int64, error)
+	DeleteRoom(ctx context.Context, roomID uuid.UUID) error
+}
+
+// RoomImages stores room posters
+type RoomImages interface {
+	Save(ctx context.Context, kind ImageKind, owner uuid.UUID, r io.Reader) (string, error)
+	Remove(ctx context.Context, kind ImageKind, owner uuid.UUID, url string)
+}
+
+// RoomLibrary resolves the library videos rooms play
+type RoomLibrary interface {
+	Playable(ctx context.Context, userID, ID uuid.UUID) (*models.LibraryItem, error)
+	Playing(ctx context.Context, ID uuid.UUID) (*models.LibraryItem, error)
+	Entry(item *models.LibraryItem) string
+	Open(ctx context.Context, item *models.LibraryItem, name string, link func(name string) string) (*MediaFile, error)
+}
+
+var (
+	_ RoomHosts   = (*UserService)(nil)
+	_ RoomState   = (*RoomRedisService)(nil)
+	_ RoomImages  = (*ImageService)(nil)
+	_ RoomLibrary = (*LibraryService)(nil)
 )
 
 type RoomService struct {
-	roomRepository   *repositories.RoomRepository
-	userService      *UserService
-	roomRedisService *RoomRedisService
+	roomRepository        RoomRepository
+	roomMessageRepository RoomMessageRepository
+	roomUserRepository    RoomUserRepository
+	transactor            Transactor
+	userService           RoomHosts
+	roomRedisService      RoomState
+	imageService          RoomImages
+	libraryService        RoomLibrary
+	// Signs each participant's links to the room's library video
+	signer storage.Signer
+	// How long those links work before the socket hands out new ones
+	linkExpiry time.Duration
 }
 
-func NewRoomService(rp *repositories.RoomRepository, us *UserService, rrs *RoomRedisService) *RoomService {
+func NewRoomService(
+	rp RoomRepository,
+	rmp RoomMessageRepository,
+	rup RoomUserRepository,
+	tx Transactor,
+	us RoomHosts,
+	rrs RoomState,
+	is RoomImages,
+	ls RoomLibrary,
+	signer storage.Signer,
+	linkExpiry time.Duration,
+) *RoomService {
 	return &RoomService{
-		roomRepository:   rp,
-		userService:      us,
-		roomRedisService: rrs,
+		roomRepository:        rp,
+		roomMessageRepository: rmp,
+		roomUserRepository:    rup,
+		transactor:            tx,
+		userService:           us,
+		roomRedisService:      rrs,
+		imageService:          is,
+		libraryService:        ls,
+		signer:                signer,
+		linkExpiry:            linkExpiry,
 	}
 }
 
 func (rs *RoomService) Create(ctx context.Context, dto *dtos.CreateRoomServiceDto) (*models.Room, error) {
-	host, err := rs.userService.FindByID(dto.HostID.String())
+	if dto.Src == "" && dto.LibraryItemID == "" {
+		return nil, ErrRoomSourceRequired
+	}
+
+	host, err := rs.userService.FindByID(ctx, dto.HostID.String())
 
 	if err != nil {
 		return nil, err
@@ -42,44 +136,338 @@ func (rs *RoomService) Create(ctx context.Context, dto *dtos.CreateRoomServiceDt
 		CreateRoomServiceDto: dto,
 	}
 
-	// Create room in Postgres
-	room, err := rs.roomRepository.Create(repoDto)
+	if dto.LibraryItemID != "" {
+		ID, err := uuid.Parse(dto.LibraryItemID)
+		if err != nil {
+			return nil, ErrLibraryItemNotFound
+		}
+
+		item, err := rs.libraryService.Playable(ctx, dto.HostID, ID)
+		if err != nil {
+			return nil, err
+		}
+
+		repoDto.LibraryItemID = &item.ID
+	}
+
+	var room *models.Room
+	redisTouched := false
+
+	// All or nothing: the Postgres row is rolled back if any step fails,
+	// Redis has no rollback so its keys are removed afterwards
+	err = rs.transactor.Transaction(ctx, func(ctx context.Context) error {
+		// Create room in Postgres
+		created, err := rs.roomRepository.Create(ctx, repoDto)
+
+		if err != nil {
+			return fmt.Errorf("error creating room in db: %w", err)
+		}
+
+		room = created
+
+		// Create room in Redis
+		redisTouched = true
+
+		err = rs.roomRedisService.CreateRoom(
+			ctx,
+			room.ID,
+			room.HostID,
+			dto.Src,
+			dto.Capacity,
+		)
+		if err != nil {
+			return fmt.Errorf("error creating room in redis: %w", err)
+		}
+
+		// Add host to the room
+		err = rs.joinRoom(ctx, room.ID, room.HostID)
+		if err != nil {
+			return fmt.Errorf("error adding host to room: %w", err)
+		}
+
+		return nil
+	})
 
 	if err != nil {
-		return nil, fmt.Errorf("error creating room in db: %w", err)
+		if redisTouched {
+			rs.deleteRoomFromRedis(ctx, room.ID)
+		}
+
+		return nil, err
 	}
 
-	// Create room in Redis
-	err = rs.roomRedisService.CreateRoom(
-		ctx,
-		room.ID,
-		room.HostID,
-		dto.Src,
-		dto.Capacity,
-	)
+	return room, nil
+}
+
+// Remove the room's Redis keys, runs even if ctx was cancelled
+func (rs *RoomService) deleteRoomFromRedis(ctx context.Context, roomID uuid.UUID) {
+	cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+	defer cancel()
+
+	if err := rs.roomRedisService.DeleteRoom(cleanupCtx, roomID); err != nil {
+		logging.FromContext(ctx).Error("failed to clean up room in redis", "room_id", roomID, "err", err)
+	}
+}
+
+func (rs *RoomService) Find(ctx context.Context, dto *dtos.FindRoomDto) ([]models.Room, error) {
+	return rs.roomRepository.Find(ctx, dto)
+}
+
+func (rs *RoomService) FindOne(ctx context.Context, ID uuid.UUID) (*models.Room, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "RoomService.FindOne")
+	defer span.End()
+
+	room, err := rs.roomRepository.FindOne(ctx, ID)
+
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, ErrRoomNotFound
+	}
+
+	return room, err
+}
+
+// Update changes the set fields of a room, only its host or an admin may
+func (rs *RoomService) Update(ctx context.Context, actor *models.User, ID uuid.UUID, dto *dtos.UpdateRoomDto) (*models.Room, error) {
+	room, err := rs.FindOne(ctx, ID)
+
 	if err != nil {
-		return nil, fmt.Errorf("error creating room in redis: %w", err)
+		return nil, err
 	}
 
-	// Add host to the room
-	err = rs.joinRoom(ctx, room.ID, room.HostID)
+	if !canManageRoom(actor, room) {
+		return nil, ErrNotRoomHost
+	}
+
+	updates := map[string]any{}
+	redisFields := map[string]any{}
+
+	if dto.Title != nil {
+		updates["title"] = *dto.Title
+	}
+
+	if dto.Description != nil {
+		updates["description"] = *dto.Description
+	}
+
+	if dto.Capacity != nil {
+		updates["capacity"] = *dto.Capacity
+		redisFields["capacity"] = *dto.Capacity
+	}
+
+	if dto.Src != nil {
+		updates["src"] = *dto.Src
+		updates["library_item_id"] = nil
+		redisFields["src"] = *dto.Src
+	}
+
+	private := room.Private
+	if dto.Private != nil {
+		private = *dto.Private
+		updates["private"] = private
+		updates["hidden"] = private
+	}
+
+	switch {
+	case !private:
+		if room.Password != "" {
+			updates["password"] = ""
+		}
+	case dto.Password != nil:
+		updates["password"] = *dto.Password
+	case room.Password == "":
+		return nil, ErrRoomPasswordRequired
+	}
+
+	if err := rs.roomRepository.Update(ctx, ID, updates); err != nil {
+		return nil, fmt.Errorf("error updating room in db: %w", err)
+	}
+
+	if err := rs.roomRedisService.UpdateRoom(ctx, ID, redisFields); err != nil {
+		return nil, fmt.Errorf("error updating room in redis: %w", err)
+	}
+
+	return rs.FindOne(ctx, ID)
+}
+
+// Delete removes a room with its messages, only its host or an admin may
+func (rs *RoomService) Delete(ctx context.Context, actor *models.User, ID uuid.UUID) error {
+	room, err := rs.FindOne(ctx, ID)
+
 	if err != nil {
-		return nil, fmt.Errorf("error adding host to room: %w", err)
+		return err
+	}
+
+	if !canManageRoom(actor, room) {
+		return ErrNotRoomHost
 	}
 
+	err = rs.transactor.Transaction(ctx, func(ctx context.Context) error {
+		if err := rs.roomMessageRepository.DeleteByRoom(ctx, ID); err != nil {
+			return fmt.Errorf("error deleting room messages: %w", err)
+		}
+
+		if err := rs.roomRepository.Delete(ctx, ID); err != nil {
+			return fmt.Errorf("error deleting room in db: %w", err)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	// The row is gone, leftover keys only hold capacity and expire on their own
+	rs.deleteRoomFromRedis(ctx, ID)
+	rs.imageService.Remove(ctx, PosterImage, room.ID, room.Poster)
+
+	return nil
+}
+
+// SetPoster stores the uploaded image as the room's poster, only its host
+// or an admin may
+func (rs *RoomService) SetPoster(ctx context.Context, actor *models.User, ID uuid.UUID, r io.Reader) (*models.Room, error) {
+	room, err := rs.FindOne(ctx, ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !canManageRoom(actor, room) {
+		return nil, ErrNotRoomHost
+	}
+
+	poster, err := rs.imageService.Save(ctx, PosterImage, room.ID, r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := rs.roomRepository.Update(ctx, ID, map[string]any{"poster": poster}); err != nil {
+		rs.imageService.Remove(ctx, PosterImage, room.ID, poster)
+		return nil, fmt.Errorf("error updating room poster: %w", err)
+	}
+
+	rs.imageService.Remove(ctx, PosterImage, room.ID, room.Poster)
+	room.Poster = poster
+
 	return room, nil
 }
 
-func (rs *RoomService) Find(dto *dtos.FindRoomDto) ([]models.Room, error) {
-	return rs.roomRepository.Find(dto)
+func canManageRoom(actor *models.User, room *models.Room) bool {
+	return actor.IsAdmin || actor.ID == room.HostID
+}
+
+// CanEnter tells whether user may join the room with password. Private
+// rooms let in their host and admins, everyone else needs the password.
+func (rs *RoomService) CanEnter(room *models.Room, user *models.User, password string) bool {
+	if !room.Private || canManageRoom(user, room) {
+		return true
+	}
+
+	return room.Password != "" && subtle.ConstantTimeCompare([]byte(password), []byte(room.Password)) == 1
+}
+
+// Media is what userID loads the room's video from. A library video is
+// served by the server under a link signed for that user alone, which
+// stops working after the link expiry; linked videos are played as they are.
+func (rs *RoomService) Media(ctx context.Context, room *models.Room, userID uuid.UUID) (*Media, error) {
+	if room.LibraryItemID == nil {
+		return &Media{Src: room.Src}, nil
+	}
+
+	item, err := rs.libraryService.Playing(ctx, *room.LibraryItemID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	token := rs.mediaToken(room.ID, userID, time.Now().Add(rs.linkExpiry).Unix())
+
+	return &Media{
+		Src:             mediaURL(room.ID, rs.libraryService.Entry(item), token),
+		DurationSeconds: item.DurationSeconds,
+		ExpiresIn:       rs.linkExpiry,
+	}, nil
+}
+
+// MediaViewer returns the user a link to the room's media was signed for,
+// ErrMediaLinkInvalid when query doesn't carry a valid signature
+func (rs *RoomService) MediaViewer(roomID uuid.UUID, query url.Values) (uuid.UUID, error) {
+	userID, err := uuid.Parse(query.Get("user"))
+
+	if err != nil || !rs.signer.Verify(query, mediaKey(roomID, userID)) {
+		return uuid.Nil, ErrMediaLinkInvalid
+	}
+
+	return userID, nil
 }
 
-func (rs *RoomService) FindOne(ID uuid.UUID) (*models.Room, error) {
-	return rs.roomRepository.FindOne(ID)
+// OpenMedia reads a file of the library video the room plays for userID,
+// whose link MediaViewer accepted. The entry playlist signs the files it
+// lists until the whole video could have played, a player never reloads
+// them; those keep the signature they were opened with.
+func (rs *RoomService) OpenMedia(ctx context.Context, roomID, userID uuid.UUID, name string, query url.Values) (*MediaFile, error) {
+	room, err := rs.FindOne(ctx, roomID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if room.LibraryItemID == nil {
+		return nil, ErrLibraryItemNotFound
+	}
+
+	item, err := rs.libraryService.Playing(ctx, *room.LibraryItemID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	token := url.Values{}
+	for _, param := range []string{"user", "expires", "signature"} {
+		token.Set(param, query.Get(param))
+	}
+
+	if name == rs.libraryService.Entry(item) {
+		lifetime := rs.linkExpiry + time.Duration(item.DurationSeconds*float64(time.Second))
+		token = rs.mediaToken(roomID, userID, time.Now().Add(lifetime).Unix())
+	}
+
+	return rs.libraryService.Open(ctx, item, name, func(name string) string {
+		return mediaURL(roomID, name, token)
+	})
 }
 
-func (rs *RoomService) Exists(ID uuid.UUID) (bool, error) {
-	return rs.roomRepository.Exists(ID)
+func (rs *RoomService) mediaToken(roomID, userID uuid.UUID, expires int64) url.Values {
+	token := rs.signer.Sign(mediaKey(roomID, userID), expires)
+	token.Set("user", userID.String())
+
+	return token
+}
+
+// What a participant's media links sign, one signature covers every file
+func mediaKey(roomID, userID uuid.UUID) string {
+	return "rooms/" + roomID.String() + "/" + userID.String()
+}
+
+func mediaURL(roomID uuid.UUID, name string, token url.Values) string {
+	return MediaBaseURL + "/rooms/" + roomID.String() + "/" + name + "?" + token.Encode()
+}
+
+// The rooms playing a library video
+func (rs *RoomService) FindByLibraryItem(ctx context.Context, itemID uuid.UUID) ([]models.Room, error) {
+	rooms, err := rs.roomRepository.FindByLibraryItem(ctx, itemID)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find rooms by library item: %w", err)
+	}
+
+	return rooms, nil
+}
+
+func (rs *RoomService) Exists(ctx context.Context, ID uuid.UUID) (bool, error) {
+	return rs.roomRepository.Exists(ctx, ID)
 }
 
 func (rs *RoomService) Join(ctx context.Context, roomID, userID *uuid.UUID) error {
@@ -89,14 +477,12 @@ func (rs *RoomService) Join(ctx context.Context, roomID, userID *uuid.UUID) erro
 // Internal method to handle room joining logic
 func (rs *RoomService) joinRoom(ctx context.Context, roomID, userID uuid.UUID) error {
 	// Check if room exists
-	findDto := &dtos.FindRoomDto{ID: &roomID}
-
-	rooms, err := rs.roomRepository.Find(findDto)
+	exists, err := rs.roomRepository.Exists(ctx, roomID)
 
 	if err != nil {
 		return fmt.Errorf("error finding room: %w", err)
 	}
-	if len(rooms) == 0 {
+	if !exists {
 		return ErrRoomNotFound
 	}
 
@@ -124,12 +510,17 @@ func (rs *RoomService) joinRoom(ctx context.Context, roomID, userID uuid.UUID) e
 	}
 
 	// Add user to room in Postgres
-	// err = rs.roomRepository.AddUserToRoom(roomID, userID)
-	// if err != nil {
-	// Rollback Redis addition if Postgres fails
-	// 	_ = rs.roomRedisService.RemoveUser(ctx, roomID, userID)
-	// 	return fmt.Errorf("error adding user to room in Postgres: %w", err)
-	// }
+	err = rs.roomUserRepository.Add(ctx, roomID, userID)
+	if err != nil {
+		// Rollback Redis addition if Postgres fails
+		_ = rs.roomRedisService.RemoveUser(ctx, roomID, userID)
+		return fmt.Errorf("error adding user to room in Postgres: %w", err)
+	}
 
 	return nil
 }
+
+// RecordWatch adds the room to the user's watch history
+func (rs *RoomService) RecordWatch(ctx context.Context, roomID, userID uuid.UUID) error {
+	return rs.roomUserRepository.Add(ctx, roomID, userID)
+}
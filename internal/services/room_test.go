@@ -0,0 +1,377 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/dliluashvili/cowatchit/internal/dtos"
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/dliluashvili/cowatchit/internal/repositories"
+	"github.com/dliluashvili/cowatchit/internal/storage"
+	"github.com/google/uuid"
+)
+
+type memoryRooms struct {
+	rooms map[uuid.UUID]*models.Room
+}
+
+func (m *memoryRooms) Create(_ context.Context, dto *dtos.CreateRoomRepoDto) (*models.Room, error) {
+	room := &models.Room{
+		ID:            uuid.New(),
+		HostID:        dto.HostID,
+		HostUsername:  dto.HostUsername,
+		Title:         dto.Title,
+		Capacity:      dto.Capacity,
+		Src:           dto.Src,
+		LibraryItemID: dto.LibraryItemID,
+		Private:       dto.Private,
+		Password:      dto.Password,
+	}
+	m.rooms[room.ID] = room
+
+	return room, nil
+}
+
+func (m *memoryRooms) Find(context.Context, *dtos.FindRoomDto) ([]models.Room, error) {
+	return nil, nil
+}
+
+func (m *memoryRooms) FindShared(context.Context, uuid.UUID, uuid.UUID, dtos.PageDto) ([]models.Room, error) {
+	return nil, nil
+}
+
+func (m *memoryRooms) FindByLibraryItem(context.Context, uuid.UUID) ([]models.Room, error) {
+	return nil, nil
+}
+
+func (m *memoryRooms) FindOne(_ context.Context, ID uuid.UUID) (*models.Room, error) {
+	room, exists := m.rooms[ID]
+	if !exists {
+		return nil, repositories.ErrNotFound
+	}
+
+	copied := *room
+	return &copied, nil
+}
+
+func (m *memoryRooms) Exists(_ context.Context, ID uuid.UUID) (bool, error) {
+	_, exists := m.rooms[ID]
+	return exists, nil
+}
+
+func (m *memoryRooms) Update(_ context.Context, ID uuid.UUID, updates map[string]any) error {
+	room := m.rooms[ID]
+	for field, value := range updates {
+		switch field {
+		case "title":
+			room.Title = value.(string)
+		case "private":
+			room.Private = value.(bool)
+		case "hidden":
+			room.Hidden = value.(bool)
+		case "password":
+			room.Password = value.(string)
+		case "poster":
+			room.Poster = value.(string)
+		}
+	}
+
+	return nil
+}
+
+func (m *memoryRooms) Delete(_ context.Context, ID uuid.UUID) error {
+	delete(m.rooms, ID)
+	return nil
+}
+
+func (m *memoryRooms) UpdateHostUsername(context.Context, uuid.UUID, string) error {
+	return nil
+}
+
+type memoryRoomMessages struct {
+	deleted []uuid.UUID
+}
+
+func (m *memoryRoomMessages) Create(context.Context, *dtos.CreateRoomMessageDto) (*models.RoomMessage, error) {
+	return nil, nil
+}
+
+func (m *memoryRoomMessages) FindByRoom(context.Context, uuid.UUID, dtos.PageDto) ([]*models.RoomMessage, error) {
+	return nil, nil
+}
+
+func (m *memoryRoomMessages) DeleteByRoom(_ context.Context, roomID uuid.UUID) error {
+	m.deleted = append(m.deleted, roomID)
+	return nil
+}
+
+func (m *memoryRoomMessages) UpdateSenderUsername(context.Context, uuid.UUID, string) error {
+	return nil
+}
+
+type memoryRoomUsers struct {
+	err     error
+	watched map[uuid.UUID][]uuid.UUID
+}
+
+func (m *memoryRoomUsers) Add(_ context.Context, roomID, userID uuid.UUID) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	m.watched[roomID] = append(m.watched[roomID], userID)
+	return nil
+}
+
+// Runs fn directly, the fakes have nothing to roll back
+type directTransactor struct{}
+
+func (directTransactor) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+type memoryHosts struct {
+	users map[uuid.UUID]*models.User
+}
+
+func (m *memoryHosts) FindByID(_ context.Context, idStr string) (*models.User, error) {
+	user, exists := m.users[uuid.MustParse(idStr)]
+	if !exists {
+		return nil, ErrUserNotFound
+	}
+
+	return user, nil
+}
+
+type memoryRoomState struct {
+	capacity map[uuid.UUID]int
+	users    map[uuid.UUID]map[uuid.UUID]bool
+}
+
+func (m *memoryRoomState) CreateRoom(_ context.Context, roomID, _ uuid.UUID, _ string, capacity int) error {
+	m.capacity[roomID] = capacity
+	m.users[roomID] = map[uuid.UUID]bool{}
+	return nil
+}
+
+func (m *memoryRoomState) UpdateRoom(context.Context, uuid.UUID, map[string]any) error {
+	return nil
+}
+
+func (m *memoryRoomState) AddUser(_ context.Context, roomID, userID uuid.UUID) error {
+	m.users[roomID][userID] = true
+	return nil
+}
+
+func (m *memoryRoomState) RemoveUser(_ context.Context, roomID, userID uuid.UUID) error {
+	delete(m.users[roomID], userID)
+	return nil
+}
+
+func (m *memoryRoomState) GetRoomCapacity(_ context.Context, roomID uuid.UUID) (int, error) {
+	return m.capacity[roomID], nil
+}
+
+func (m *memoryRoomState) CountRoomUsers(_ context.Context, roomID uuid.UUID) (int64, error) {
+	return int64(len(m.users[roomID])), nil
+}
+
+func (m *memoryRoomState) DeleteRoom(_ context.Context, roomID uuid.UUID) error {
+	delete(m.capacity, roomID)
+	delete(m.users, roomID)
+	return nil
+}
+
+type memoryImages struct {
+	removed []string
+}
+
+func (m *memoryImages) Save(_ context.Context, kind ImageKind, owner uuid.UUID, _ io.Reader) (string, error) {
+	return "/" + kind.Prefix + "/" + owner.String() + ".webp", nil
+}
+
+func (m *memoryImages) Remove(_ context.Context, _ ImageKind, _ uuid.UUID, url string) {
+	m.removed = append(m.removed, url)
+}
+
+type memoryLibrary struct {
+	items map[uuid.UUID]*models.LibraryItem
+}
+
+func (m *memoryLibrary) Playable(_ context.Context, userID, ID uuid.UUID) (*models.LibraryItem, error) {
+	item, exists := m.items[ID]
+	if !exists || item.UserID != userID {
+		return nil, ErrLibraryItemNotFound
+	}
+
+	return item, nil
+}
+
+func (m *memoryLibrary) Playing(_ context.Context, ID uuid.UUID) (*models.LibraryItem, error) {
+	item, exists := m.items[ID]
+	if !exists {
+		return nil, ErrLibraryItemNotFound
+	}
+
+	return item, nil
+}
+
+func (m *memoryLibrary) Entry(*models.LibraryItem) string {
+	return "index.m3u8"
+}
+
+func (m *memoryLibrary) Open(context.Context, *models.LibraryItem, string, func(name string) string) (*MediaFile, error) {
+	return nil, ErrLibraryItemNotFound
+}
+
+type roomFixture struct {
+	service  *RoomService
+	rooms    *memoryRooms
+	messages *memoryRoomMessages
+	roomUser *memoryRoomUsers
+	state    *memoryRoomState
+	images   *memoryImages
+	library  *memoryLibrary
+	host     *models.User
+}
+
+func newRoomFixture() *roomFixture {
+	host := &models.User{ID: uuid.New(), Username: "host"}
+
+	f := &roomFixture{
+		rooms:    &memoryRooms{rooms: map[uuid.UUID]*models.Room{}},
+		messages: &memoryRoomMessages{},
+		roomUser: &memoryRoomUsers{watched: map[uuid.UUID][]uuid.UUID{}},
+		state:    &memoryRoomState{capacity: map[uuid.UUID]int{}, users: map[uuid.UUID]map[uuid.UUID]bool{}},
+		images:   &memoryImages{},
+		library:  &memoryLibrary{items: map[uuid.UUID]*models.LibraryItem{}},
+		host:     host,
+	}
+
+	f.service = NewRoomService(
+		f.rooms,
+		f.messages,
+		f.roomUser,
+		directTransactor{},
+		&memoryHosts{users: map[uuid.UUID]*models.User{host.ID: host}},
+		f.state,
+		f.images,
+		f.library,
+		storage.NewSigner([]byte("test-signing-key")),
+		time.Minute,
+	)
+
+	return f
+}
+
+func (f *roomFixture) create(t *testing.T, dto *dtos.CreateRoomDto) *models.Room {
+	t.Helper()
+
+	room, err := f.service.Create(context.Background(), &dtos.CreateRoomServiceDto{HostID: f.host.ID, CreateRoomDto: dto})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	return room
+}
+
+func TestRoomCreateJoinsHost(t *testing.T) {
+	f := newRoomFixture()
+
+	room := f.create(t, &dtos.CreateRoomDto{Title: "Movie night", Capacity: 2, Src: "https://example.com/a.mp4"})
+
+	if room.HostUsername != "host" {
+		t.Errorf("HostUsername = %q, want host", room.HostUsername)
+	}
+	if !f.state.users[room.ID][f.host.ID] {
+		t.Error("host was not added to the room")
+	}
+	if got := f.roomUser.watched[room.ID]; len(got) != 1 || got[0] != f.host.ID {
+		t.Errorf("watch history = %v, want the host", got)
+	}
+}
+
+func TestRoomCreateNeedsSource(t *testing.T) {
+	f := newRoomFixture()
+
+	_, err := f.service.Create(context.Background(), &dtos.CreateRoomServiceDto{
+		HostID:        f.host.ID,
+		CreateRoomDto: &dtos.CreateRoomDto{Title: "Nothing", Capacity: 2},
+	})
+	if !errors.Is(err, ErrRoomSourceRequired) {
+		t.Fatalf("err = %v, want ErrRoomSourceRequired", err)
+	}
+}
+
+func TestRoomCreateCleansUpStateOnFailure(t *testing.T) {
+	f := newRoomFixture()
+	f.roomUser.err = errors.New("insert failed")
+
+	_, err := f.service.Create(context.Background(), &dtos.CreateRoomServiceDto{
+		HostID:        f.host.ID,
+		CreateRoomDto: &dtos.CreateRoomDto{Title: "Movie night", Capacity: 2, Src: "https://example.com/a.mp4"},
+	})
+	if err == nil {
+		t.Fatal("Create succeeded, want an error")
+	}
+	if len(f.state.capacity) != 0 || len(f.state.users) != 0 {
+		t.Error("room state was left behind")
+	}
+}
+
+func TestRoomJoinRejectsFullRoom(t *testing.T) {
+	f := newRoomFixture()
+	room := f.create(t, &dtos.CreateRoomDto{Title: "Movie night", Capacity: 2, Src: "https://example.com/a.mp4"})
+
+	guest, late := uuid.New(), uuid.New()
+
+	if err := f.service.Join(context.Background(), &room.ID, &guest); err != nil {
+		t.Fatalf("Join: %v", err)
+	}
+	if err := f.service.Join(context.Background(), &room.ID, &late); !errors.Is(err, ErrRoomFull) {
+		t.Fatalf("err = %v, want ErrRoomFull", err)
+	}
+
+	missing := uuid.New()
+	if err := f.service.Join(context.Background(), &missing, &guest); !errors.Is(err, ErrRoomNotFound) {
+		t.Fatalf("err = %v, want ErrRoomNotFound", err)
+	}
+}
+
+func TestRoomUpdate(t *testing.T) {
+	f := newRoomFixture()
+	room := f.create(t, &dtos.CreateRoomDto{Title: "Movie night", Capacity: 2, Src: "https://example.com/a.mp4"})
+
+	stranger := &models.User{ID: uuid.New()}
+	title := "Renamed"
+
+	if _, err := f.service.Update(context.Background(), stranger, room.ID, &dtos.UpdateRoomDto{Title: &title}); !errors.Is(err, ErrNotRoomHost) {
+		t.Fatalf("err = %v, want ErrNotRoomHost", err)
+	}
+
+	private := true
+	if _, err := f.service.Update(context.Background(), f.host, room.ID, &dtos.UpdateRoomDto{Private: &private}); !errors.Is(err, ErrRoomPasswordRequired) {
+		t.Fatalf("err = %v, want ErrRoomPasswordRequired", err)
+	}
+
+	password := "secret"
+	updated, err := f.service.Update(context.Background(), f.host, room.ID, &dtos.UpdateRoomDto{Title: &title, Private: &private, Password: &password})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if updated.Title != title || !updated.Private || !updated.Hidden || updated.Password != password {
+		t.Errorf("updated room = %+v", updated)
+	}
+
+	public := false
+	updated, err = f.service.Update(context.Background(), &models.User{ID: uuid.New(), IsAdmin: true}, room.ID, &dtos.UpdateRoomDto{Private: &public})
+	if err != nil {
+		t.Fatalf("Update as admin: %v", err)
+	}
+	if updated.Private || updated.Password != "" {
+		t.Errorf("public room kept its password: %+v", updated)
+	}
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dliluashvili/cowatchit/internal/dtos"
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestRoomCanEnter(t *testing.T) {
+	f := newRoomFixture()
+	room := &models.Room{ID: uuid.New(), HostID: f.host.ID, Private: true, Password: "secret"}
+	guest := &models.User{ID: uuid.New()}
+
+	tests := []struct {
+		name     string
+		room     *models.Room
+		user     *models.User
+		password string
+		want     bool
+	}{
+		{"public room", &models.Room{HostID: f.host.ID}, guest, "", true},
+		{"host", room, f.host, "", true},
+		{"admin", room, &models.User{ID: uuid.New(), IsAdmin: true}, "", true},
+		{"right password", room, guest, "secret", true},
+		{"wrong password", room, guest, "guess", false},
+		{"no password", room, guest, "", false},
+		{"private without password", &models.Room{HostID: f.host.ID, Private: true}, guest, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := f.service.CanEnter(tt.room, tt.user, tt.password); got != tt.want {
+				t.Errorf("CanEnter = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoomMediaLinks(t *testing.T) {
+	f := newRoomFixture()
+	itemID := uuid.New()
+	f.library.items[itemID] = &models.LibraryItem{ID: itemID, UserID: f.host.ID, DurationSeconds: 90}
+
+	room := f.create(t, &dtos.CreateRoomDto{Title: "Movie night", Capacity: 2, LibraryItemID: itemID.String()})
+	viewer := uuid.New()
+
+	media, err := f.service.Media(context.Background(), room, viewer)
+	if err != nil {
+		t.Fatalf("Media: %v", err)
+	}
+	if media.DurationSeconds != 90 || media.ExpiresIn != time.Minute {
+		t.Errorf("media = %+v", media)
+	}
+
+	link, err := url.Parse(media.Src)
+	if err != nil {
+		t.Fatalf("parse %q: %v", media.Src, err)
+	}
+	if want := MediaBaseURL + "/rooms/" + room.ID.String() + "/index.m3u8"; link.Path != want {
+		t.Errorf("path = %q, want %q", link.Path, want)
+	}
+
+	got, err := f.service.MediaViewer(room.ID, link.Query())
+	if err != nil || got != viewer {
+		t.Fatalf("MediaViewer = %v, %v, want %v", got, err, viewer)
+	}
+
+	if _, err := f.service.MediaViewer(uuid.New(), link.Query()); !errors.Is(err, ErrMediaLinkInvalid) {
+		t.Errorf("other room: err = %v, want ErrMediaLinkInvalid", err)
+	}
+
+	forged := link.Query()
+	forged.Set("user", uuid.NewString())
+	if _, err := f.service.MediaViewer(room.ID, forged); !errors.Is(err, ErrMediaLinkInvalid) {
+		t.Errorf("other user: err = %v, want ErrMediaLinkInvalid", err)
+	}
+
+	linked := &models.Room{ID: uuid.New(), Src: "https://example.com/a.mp4"}
+	media, err = f.service.Media(context.Background(), linked, viewer)
+	if err != nil || media.Src != linked.Src || media.ExpiresIn != 0 {
+		t.Errorf("linked media = %+v, %v", media, err)
+	}
+}
@@ -1,26 +1,27 @@
 package services
 
 import (
+	"context"
+
 	"github.com/dliluashvili/cowatchit/internal/dtos"
 	"github.com/dliluashvili/cowatchit/internal/models"
-	"github.com/dliluashvili/cowatchit/internal/repositories"
 	"github.com/google/uuid"
 )
 
 type RoomMessageService struct {
-	roomMessageRepository *repositories.RoomMessageRepository
+	roomMessageRepository RoomMessageRepository
 }
 
-func NewRoomMessageService(rmp *repositories.RoomMessageRepository) *RoomMessageService {
+func NewRoomMessageService(rmp RoomMessageRepository) *RoomMessageService {
 	return &RoomMessageService{
 		roomMessageRepository: rmp,
 	}
 }
 
-func (roomMessageService *RoomMessageService) Create(createRoomMessageDto *dtos.CreateRoomMessageDto) (*models.RoomMessage, error) {
-	return roomMessageService.roomMessageRepository.Create(createRoomMessageDto)
+func (roomMessageService *RoomMessageService) Create(ctx context.Context, createRoomMessageDto *dtos.CreateRoomMessageDto) (*models.RoomMessage, error) {
+	return roomMessageService.roomMessageRepository.Create(ctx, createRoomMessageDto)
 }
 
-func (roomMessageService *RoomMessageService) GetRoomMessages(roomID uuid.UUID) ([]*models.RoomMessage, error) {
-	return roomMessageService.roomMessageRepository.FindByRoom(roomID)
+func (roomMessageService *RoomMessageService) GetRoomMessages(ctx context.Context, roomID uuid.UUID, page dtos.PageDto) ([]*models.RoomMessage, error) {
+	return roomMessageService.roomMessageRepository.FindByRoom(ctx, roomID, page)
 }
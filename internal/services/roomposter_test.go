@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dliluashvili/cowatchit/internal/dtos"
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestRoomDeleteRemovesMessagesAndPoster(t *testing.T) {
+	f := newRoomFixture()
+	room := f.create(t, &dtos.CreateRoomDto{Title: "Movie night", Capacity: 2, Src: "https://example.com/a.mp4"})
+
+	room, err := f.service.SetPoster(context.Background(), f.host, room.ID, strings.NewReader("image"))
+	if err != nil {
+		t.Fatalf("SetPoster: %v", err)
+	}
+
+	if err := f.service.Delete(context.Background(), &models.User{ID: uuid.New()}, room.ID); !errors.Is(err, ErrNotRoomHost) {
+		t.Fatalf("err = %v, want ErrNotRoomHost", err)
+	}
+	if err := f.service.Delete(context.Background(), f.host, room.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	if _, err := f.service.FindOne(context.Background(), room.ID); !errors.Is(err, ErrRoomNotFound) {
+		t.Errorf("err = %v, want ErrRoomNotFound", err)
+	}
+	if len(f.messages.deleted) != 1 || f.messages.deleted[0] != room.ID {
+		t.Errorf("deleted messages of %v, want %v", f.messages.deleted, room.ID)
+	}
+	if _, exists := f.state.capacity[room.ID]; exists {
+		t.Error("room state was left behind")
+	}
+	if !slices.Contains(f.images.removed, room.Poster) {
+		t.Errorf("removed images %v, want %q", f.images.removed, room.Poster)
+	}
+}
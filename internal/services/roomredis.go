@@ -62,6 +62,35 @@ func (r *RoomRedisService) CreateRoom(
 	return nil
 }
 
+// UpdateRoom overwrites fields of a room that is still in Redis
+func (r *RoomRedisService) UpdateRoom(
+	ctx context.Context,
+	roomID uuid.UUID,
+	fields map[string]any,
+) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	roomKey := fmt.Sprintf("%s%s", roomPrefix, roomID.String())
+
+	exists, err := r.client.Exists(ctx, roomKey).Result()
+	if err != nil {
+		return fmt.Errorf("failed to update room in Redis: %w", err)
+	}
+
+	// Expired rooms are not brought back without their TTL
+	if exists == 0 {
+		return nil
+	}
+
+	if err := r.client.HSet(ctx, roomKey, fields).Err(); err != nil {
+		return fmt.Errorf("failed to update room in Redis: %w", err)
+	}
+
+	return nil
+}
+
 // AddUser adds a user to a room's user set
 func (r *RoomRedisService) AddUser(
 	ctx context.Context,
@@ -6,31 +6,93 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/dliluashvili/cowatchit/internal/config"
 	"github.com/dliluashvili/cowatchit/internal/helpers"
 	"github.com/dliluashvili/cowatchit/internal/models"
-	"github.com/dliluashvili/cowatchit/internal/shared/constants"
 
 	"github.com/redis/go-redis/v9"
 )
 
 type SessionService struct {
 	redisClient *redis.Client
+	config      config.SessionConfig
 }
 
-func NewSessionService(rc *redis.Client) *SessionService {
+func NewSessionService(rc *redis.Client, cfg config.SessionConfig) *SessionService {
 	return &SessionService{
 		redisClient: rc,
+		config:      cfg,
 	}
 }
 
+// Name of the cookie that carries the session id
+func (s *SessionService) CookieName() string {
+	return s.config.SessionCookieName()
+}
+
+// Pending sessions wait this long for the second factor
+const PendingTwoFactorTTL = 5 * time.Minute
+
+// CreateAndSave starts a session for user, or only a pending one when the
+// user has two-factor authentication on. CompleteTwoFactor turns that into
+// a session once the code is verified.
 func (s *SessionService) CreateAndSave(ctx context.Context, user *models.User) (*models.Session, error) {
+	if user.TwoFactorEnabled() {
+		return s.createPending(ctx, user)
+	}
+
+	return s.create(ctx, user)
+}
+
+// CompleteTwoFactor replaces a pending session with a full one
+func (s *SessionService) CompleteTwoFactor(ctx context.Context, pending *models.Session) (*models.Session, error) {
+	if !pending.PendingTwoFactor {
+		return nil, fmt.Errorf("session is not pending two-factor")
+	}
+
+	if err := s.redisClient.Del(ctx, fmt.Sprintf("session:%s", pending.SessionID)).Err(); err != nil {
+		return nil, fmt.Errorf("failed to delete pending session: %w", err)
+	}
+
+	return s.create(ctx, pending.User)
+}
+
+// The user's current session, if any, stays until the second factor is in
+func (s *SessionService) createPending(ctx context.Context, user *models.User) (*models.Session, error) {
 	sessionId, err := helpers.GenerateSessionID()
 
 	if err != nil {
 		return nil, err
 	}
 
-	expiresAt := time.Now().Add(constants.SessionDuration)
+	session := &models.Session{
+		User:             user,
+		SessionID:        sessionId,
+		ExpiresAt:        time.Now().Add(PendingTwoFactorTTL),
+		PendingTwoFactor: true,
+	}
+
+	data, err := json.Marshal(session)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.redisClient.Set(ctx, fmt.Sprintf("session:%s", sessionId), data, PendingTwoFactorTTL).Err(); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func (s *SessionService) create(ctx context.Context, user *models.User) (*models.Session, error) {
+	sessionId, err := helpers.GenerateSessionID()
+
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(s.config.Duration)
 
 	session := &models.Session{
 		User:      user,
@@ -101,7 +163,38 @@ func (s *SessionService) GetUserBySession(ctx context.Context, sessionId string)
 	return &session, nil
 }
 
+// GetPending loads a session still waiting for its second factor
+func (s *SessionService) GetPending(ctx context.Context, sessionId string) (*models.Session, error) {
+	session, err := s.GetUserBySession(ctx, sessionId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !session.PendingTwoFactor {
+		return nil, fmt.Errorf("session is not pending two-factor")
+	}
+
+	return session, nil
+}
+
 func (s *SessionService) SetSocketId(ctx context.Context, sessionId string, socketId *string) error {
+	return s.update(ctx, sessionId, func(session *models.Session) {
+		session.SocketID = socketId
+	})
+}
+
+// RefreshUser copies the profile fields sessions carry from user, after
+// they changed
+func (s *SessionService) RefreshUser(ctx context.Context, sessionId string, user *models.User) error {
+	return s.update(ctx, sessionId, func(session *models.Session) {
+		session.User.Username = user.Username
+		session.User.Gender = user.Gender
+	})
+}
+
+// update rewrites the stored session with fn applied, keeping its TTL
+func (s *SessionService) update(ctx context.Context, sessionId string, fn func(session *models.Session)) error {
 	key := fmt.Sprintf("session:%s", sessionId)
 
 	val, err := s.redisClient.Get(ctx, key).Result()
@@ -116,8 +209,7 @@ func (s *SessionService) SetSocketId(ctx context.Context, sessionId string, sock
 		return fmt.Errorf("failed to unmarshal session: %w", err)
 	}
 
-	// Update socket ID
-	session.SocketID = socketId
+	fn(&session)
 
 	// Marshal and overwrite session
 	updated, err := json.Marshal(session)
@@ -137,3 +229,27 @@ func (s *SessionService) SetSocketId(ctx context.Context, sessionId string, sock
 
 	return nil
 }
+
+// Delete ends the session, the user's session mapping is only removed
+// while it still points at this session
+func (s *SessionService) Delete(ctx context.Context, session *models.Session) error {
+	sessionKey := fmt.Sprintf("session:%s", session.SessionID)
+	userSessionKey := fmt.Sprintf("user_session:%s", session.User.ID.String())
+
+	if err := s.redisClient.Del(ctx, sessionKey).Err(); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+
+	current, err := s.redisClient.Get(ctx, userSessionKey).Result()
+	if err != nil && err != redis.Nil {
+		return fmt.Errorf("failed to fetch user session: %w", err)
+	}
+
+	if current == session.SessionID {
+		if err := s.redisClient.Del(ctx, userSessionKey).Err(); err != nil {
+			return fmt.Errorf("failed to delete user session: %w", err)
+		}
+	}
+
+	return nil
+}
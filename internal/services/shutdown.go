@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/dliluashvili/cowatchit/internal/protocol"
+)
+
+// How often Shutdown checks whether every socket has gone
+const shutdownPollInterval = 50 * time.Millisecond
+
+// IsDraining reports whether Shutdown has started
+func (sm *WebSocketManagerService) IsDraining() bool {
+	return sm.draining.Load()
+}
+
+// Shutdown tells every socket the server is restarting, flushes what is
+// queued for it and closes it. It returns once every socket has detached,
+// or with ctx's error if that takes too long.
+func (sm *WebSocketManagerService) Shutdown(ctx context.Context, reconnectAfter time.Duration) error {
+	sm.draining.Store(true)
+
+	restartingMsg, err := protocol.NewEvent(protocol.EventServerRestarting, protocol.ServerRestarting{
+		ReconnectAfterMs: reconnectAfter.Milliseconds(),
+		Message:          "Server is restarting, reconnecting shortly",
+	})
+
+	if err != nil {
+		return err
+	}
+
+	data, err := protocol.Encode(restartingMsg)
+	if err != nil {
+		return err
+	}
+
+	sm.mu.Lock()
+
+	for socketID, suspended := range sm.suspended {
+		suspended.timer.Stop()
+		delete(sm.suspended, socketID)
+	}
+
+	writers := make([]*SocketWriter, 0, len(sm.socketWriters))
+	for _, writer := range sm.socketWriters {
+		writers = append(writers, writer)
+	}
+
+	sm.mu.Unlock()
+
+	slog.Info("closing websockets for shutdown", "sockets", len(writers))
+
+	for _, writer := range writers {
+		writer.Enqueue(data)
+		writer.CloseAfterFlush(websocket.StatusServiceRestart, "server restarting")
+	}
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+
+	for sm.GetConnectedSocketCount() > 0 {
+		select {
+		case <-ctx.Done():
+			slog.Warn("websockets still open at shutdown deadline", "sockets", sm.GetConnectedSocketCount())
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
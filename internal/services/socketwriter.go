@@ -0,0 +1,283 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+var (
+	ErrSendQueueFull = errors.New("send queue is full")
+	ErrSocketClosed  = errors.New("socket is closed")
+)
+
+// SlowConsumerPolicy decides what happens when a socket's outbound queue is full
+type SlowConsumerPolicy int
+
+const (
+	// Discard the new message
+	SlowConsumerDrop SlowConsumerPolicy = iota
+	// Replace pending messages with the same coalesce key, discard the rest
+	SlowConsumerCoalesce
+	// Close the socket
+	SlowConsumerDisconnect
+)
+
+var slowConsumerPolicies = map[string]SlowConsumerPolicy{
+	"drop":       SlowConsumerDrop,
+	"coalesce":   SlowConsumerCoalesce,
+	"disconnect": SlowConsumerDisconnect,
+}
+
+// ParseSlowConsumerPolicy maps drop, coalesce or disconnect to its policy
+func ParseSlowConsumerPolicy(name string) (SlowConsumerPolicy, error) {
+	policy, ok := slowConsumerPolicies[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown slow consumer policy %q", name)
+	}
+	return policy, nil
+}
+
+type SocketWriterOptions struct {
+	QueueSize    int
+	WriteTimeout time.Duration
+	Policy       SlowConsumerPolicy
+}
+
+var DefaultSocketWriterOptions = SocketWriterOptions{
+	QueueSize:    64,
+	WriteTimeout: 10 * time.Second,
+	Policy:       SlowConsumerCoalesce,
+}
+
+type outboundMessage struct {
+	data        []byte
+	coalesceKey string
+}
+
+// SocketWriter owns every write to a single websocket connection.
+// Messages are queued and written by one goroutine, so a slow client
+// never blocks the sender and frames are never interleaved.
+type SocketWriter struct {
+	conn *websocket.Conn
+	opts SocketWriterOptions
+
+	mu     sync.Mutex
+	queue  []outboundMessage
+	closed bool
+	// A message has left the queue but is still being written,
+	// it counts against QueueSize until the write returns
+	writing bool
+
+	// Set by CloseAfterFlush, the connection closes once the queue is empty
+	draining    bool
+	drainCode   websocket.StatusCode
+	drainReason string
+
+	notify chan struct{}
+	done   chan struct{}
+
+	// unix nanos of the last sign of life from the peer
+	lastSeen atomic.Int64
+}
+
+func NewSocketWriter(conn *websocket.Conn, opts SocketWriterOptions) *SocketWriter {
+	w := &SocketWriter{
+		conn:   conn,
+		opts:   opts,
+		queue:  make([]outboundMessage, 0, opts.QueueSize),
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+
+	w.Touch()
+
+	go w.run()
+
+	return w
+}
+
+// Enqueue a message for delivery
+func (w *SocketWriter) Enqueue(data []byte) error {
+	return w.enqueue(outboundMessage{data: data})
+}
+
+// Enqueue a message that may replace a pending one with the same key
+// (e.g. playback state, where only the latest value matters)
+func (w *SocketWriter) EnqueueCoalesced(key string, data []byte) error {
+	return w.enqueue(outboundMessage{data: data, coalesceKey: key})
+}
+
+func (w *SocketWriter) enqueue(msg outboundMessage) error {
+	w.mu.Lock()
+
+	if w.closed || w.draining {
+		w.mu.Unlock()
+		return ErrSocketClosed
+	}
+
+	if msg.coalesceKey != "" && w.opts.Policy == SlowConsumerCoalesce {
+		for i := range w.queue {
+			if w.queue[i].coalesceKey == msg.coalesceKey {
+				w.queue[i] = msg
+				w.mu.Unlock()
+				return nil
+			}
+		}
+	}
+
+	if w.pending() >= w.opts.QueueSize {
+		w.mu.Unlock()
+
+		if w.opts.Policy == SlowConsumerDisconnect {
+			w.CloseConn(websocket.StatusPolicyViolation, "slow consumer")
+		}
+
+		return ErrSendQueueFull
+	}
+
+	w.queue = append(w.queue, msg)
+	w.mu.Unlock()
+
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func (w *SocketWriter) run() {
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-w.notify:
+		}
+
+		for {
+			msg, ok := w.next()
+			if !ok {
+				break
+			}
+
+			if err := w.write(msg.data); err != nil {
+				w.CloseConn(websocket.StatusPolicyViolation, "write failed")
+				return
+			}
+		}
+
+		w.mu.Lock()
+		drained := w.draining && !w.closed && len(w.queue) == 0
+		w.mu.Unlock()
+
+		if drained {
+			w.CloseConn(w.drainCode, w.drainReason)
+			return
+		}
+	}
+}
+
+// Pop the oldest queued message, ok is false once the queue is empty
+// or the writer is closed
+func (w *SocketWriter) next() (outboundMessage, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed || len(w.queue) == 0 {
+		w.writing = false
+		return outboundMessage{}, false
+	}
+
+	msg := w.queue[0]
+	n := copy(w.queue, w.queue[1:])
+	w.queue[n] = outboundMessage{}
+	w.queue = w.queue[:n]
+	w.writing = true
+
+	return msg, true
+}
+
+// How many more messages fit in the queue
+func (w *SocketWriter) free() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.opts.QueueSize - w.pending()
+}
+
+// Messages queued or being written, the caller holds mu
+func (w *SocketWriter) pending() int {
+	if w.writing {
+		return len(w.queue) + 1
+	}
+	return len(w.queue)
+}
+
+func (w *SocketWriter) write(data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), w.opts.WriteTimeout)
+	defer cancel()
+
+	return w.conn.Write(ctx, websocket.MessageText, data)
+}
+
+// Stop the writer goroutine, pending messages are discarded
+func (w *SocketWriter) Close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return
+	}
+
+	w.closed = true
+	w.queue = nil
+	close(w.done)
+}
+
+// Stop the writer and close the underlying connection
+func (w *SocketWriter) CloseConn(code websocket.StatusCode, reason string) {
+	w.Close()
+	go w.conn.Close(code, reason)
+}
+
+// Write what is already queued, then close the connection.
+// Messages enqueued after this are rejected.
+func (w *SocketWriter) CloseAfterFlush(code websocket.StatusCode, reason string) {
+	w.mu.Lock()
+
+	if w.closed || w.draining {
+		w.mu.Unlock()
+		return
+	}
+
+	w.draining = true
+	w.drainCode = code
+	w.drainReason = reason
+	w.mu.Unlock()
+
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Touch records that the peer is alive
+func (w *SocketWriter) Touch() {
+	w.lastSeen.Store(time.Now().UnixNano())
+}
+
+// Last time the peer was seen alive
+func (w *SocketWriter) LastSeen() time.Time {
+	return time.Unix(0, w.lastSeen.Load())
+}
+
+// Done is closed once the writer has stopped
+func (w *SocketWriter) Done() <-chan struct{} {
+	return w.done
+}
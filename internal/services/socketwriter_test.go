@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+// Returns the server end of a websocket connection and the client dialed into it
+func newTestConn(t *testing.T) (*websocket.Conn, *websocket.Conn) {
+	t.Helper()
+
+	accepted := make(chan *websocket.Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		accepted <- conn
+	}))
+	t.Cleanup(server.Close)
+
+	client, _, err := websocket.Dial(context.Background(), "ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.CloseNow() })
+
+	conn := <-accepted
+	t.Cleanup(func() { conn.CloseNow() })
+
+	return conn, client
+}
+
+// A writer whose goroutine is not started yet, so messages stay queued
+// until start is called
+func newStalledWriter(t *testing.T, opts SocketWriterOptions) (w *SocketWriter, client *websocket.Conn, start func()) {
+	t.Helper()
+
+	conn, client := newTestConn(t)
+
+	w = &SocketWriter{
+		conn:   conn,
+		opts:   opts,
+		queue:  make([]outboundMessage, 0, opts.QueueSize),
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	t.Cleanup(w.Close)
+
+	start = func() {
+		go w.run()
+		select {
+		case w.notify <- struct{}{}:
+		default:
+		}
+	}
+
+	return w, client, start
+}
+
+// Reads what the client gets until the connection closes
+func readUntilClosed(t *testing.T, client *websocket.Conn) ([]string, websocket.StatusCode) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var got []string
+	for {
+		_, data, err := client.Read(ctx)
+		if err != nil {
+			return got, websocket.CloseStatus(err)
+		}
+		got = append(got, string(data))
+	}
+}
+
+func testWriterOptions(policy SlowConsumerPolicy) SocketWriterOptions {
+	return SocketWriterOptions{QueueSize: 2, WriteTimeout: time.Second, Policy: policy}
+}
+
+func TestSocketWriterDropsWhenFull(t *testing.T) {
+	w, client, start := newStalledWriter(t, testWriterOptions(SlowConsumerDrop))
+
+	for _, data := range []string{"a", "b"} {
+		if err := w.Enqueue([]byte(data)); err != nil {
+			t.Fatalf("Enqueue(%s): %v", data, err)
+		}
+	}
+
+	if err := w.Enqueue([]byte("c")); !errors.Is(err, ErrSendQueueFull) {
+		t.Errorf("Enqueue on a full queue: err = %v, want %v", err, ErrSendQueueFull)
+	}
+
+	// Only the coalesce policy merges keyed messages
+	if err := w.EnqueueCoalesced("b", []byte("b2")); !errors.Is(err, ErrSendQueueFull) {
+		t.Errorf("EnqueueCoalesced on a full queue: err = %v, want %v", err, ErrSendQueueFull)
+	}
+
+	w.CloseAfterFlush(websocket.StatusNormalClosure, "")
+	start()
+
+	if got, _ := readUntilClosed(t, client); strings.Join(got, ",") != "a,b" {
+		t.Errorf("client got %v, want [a b]", got)
+	}
+}
+
+func TestSocketWriterCoalescesByKey(t *testing.T) {
+	w, client, start := newStalledWriter(t, testWriterOptions(SlowConsumerCoalesce))
+
+	steps := []struct {
+		key  string
+		data string
+		err  error
+	}{
+		{"state", "state 1", nil},
+		{"", "chat", nil},
+		// Takes the place of state 1 instead of a new slot
+		{"state", "state 2", nil},
+		{"seek", "seek", ErrSendQueueFull},
+		{"", "chat 2", ErrSendQueueFull},
+	}
+
+	for _, step := range steps {
+		if err := w.EnqueueCoalesced(step.key, []byte(step.data)); !errors.Is(err, step.err) {
+			t.Errorf("enqueue %q: err = %v, want %v", step.data, err, step.err)
+		}
+	}
+
+	w.CloseAfterFlush(websocket.StatusNormalClosure, "")
+	start()
+
+	if got, _ := readUntilClosed(t, client); strings.Join(got, ",") != "state 2,chat" {
+		t.Errorf("client got %v, want [state 2 chat]", got)
+	}
+}
+
+func TestSocketWriterDisconnectsWhenFull(t *testing.T) {
+	w, client, _ := newStalledWriter(t, testWriterOptions(SlowConsumerDisconnect))
+
+	for _, data := range []string{"a", "b"} {
+		if err := w.Enqueue([]byte(data)); err != nil {
+			t.Fatalf("Enqueue(%s): %v", data, err)
+		}
+	}
+
+	if err := w.Enqueue([]byte("c")); !errors.Is(err, ErrSendQueueFull) {
+		t.Errorf("Enqueue on a full queue: err = %v, want %v", err, ErrSendQueueFull)
+	}
+
+	select {
+	case <-w.Done():
+	case <-time.After(time.Second):
+		t.Fatal("writer still running")
+	}
+
+	if err := w.Enqueue([]byte("d")); !errors.Is(err, ErrSocketClosed) {
+		t.Errorf("Enqueue after disconnect: err = %v, want %v", err, ErrSocketClosed)
+	}
+
+	// Queued messages are discarded with the connection
+	if got, code := readUntilClosed(t, client); len(got) != 0 || code != websocket.StatusPolicyViolation {
+		t.Errorf("client got %v and close %v, want nothing and %v", got, code, websocket.StatusPolicyViolation)
+	}
+}
+
+func TestSocketWriterCloseAfterFlush(t *testing.T) {
+	opts := testWriterOptions(SlowConsumerCoalesce)
+	opts.QueueSize = 3
+	w, client, start := newStalledWriter(t, opts)
+
+	for _, data := range []string{"a", "b", "c"} {
+		if err := w.Enqueue([]byte(data)); err != nil {
+			t.Fatalf("Enqueue(%s): %v", data, err)
+		}
+	}
+
+	w.CloseAfterFlush(websocket.StatusGoingAway, "restarting")
+
+	if err := w.Enqueue([]byte("d")); !errors.Is(err, ErrSocketClosed) {
+		t.Errorf("Enqueue while flushing: err = %v, want %v", err, ErrSocketClosed)
+	}
+
+	start()
+
+	got, code := readUntilClosed(t, client)
+	if strings.Join(got, ",") != "a,b,c" || code != websocket.StatusGoingAway {
+		t.Errorf("client got %v and close %v, want [a b c] and %v", got, code, websocket.StatusGoingAway)
+	}
+
+	select {
+	case <-w.Done():
+	case <-time.After(time.Second):
+		t.Error("writer still running after the flush")
+	}
+}
+
+func TestSocketWriterDeliversInOrder(t *testing.T) {
+	conn, client := newTestConn(t)
+	w := NewSocketWriter(conn, DefaultSocketWriterOptions)
+	t.Cleanup(w.Close)
+
+	want := []string{"1", "2", "3", "4", "5"}
+	for _, data := range want {
+		if err := w.Enqueue([]byte(data)); err != nil {
+			t.Fatalf("Enqueue(%s): %v", data, err)
+		}
+	}
+	w.CloseAfterFlush(websocket.StatusNormalClosure, "")
+
+	if got, _ := readUntilClosed(t, client); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("client got %v, want %v", got, want)
+	}
+}
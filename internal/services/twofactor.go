@@ -0,0 +1,387 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image/png"
+	"strings"
+	"time"
+
+	"github.com/dliluashvili/cowatchit/internal/logging"
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/dliluashvili/cowatchit/internal/repositories"
+	"github.com/google/uuid"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrTwoFactorEnabled           = errors.New("two-factor authentication is already on")
+	ErrTwoFactorNotEnabled        = errors.New("two-factor authentication is off")
+	ErrTwoFactorEnrollmentExpired = errors.New("two-factor setup expired, start again")
+	ErrTwoFactorCodeInvalid       = errors.New("invalid two-factor code")
+	ErrTwoFactorSessionInvalid    = errors.New("sign in expired, sign in again")
+)
+
+const (
+	// Shown as the account's issuer in authenticator apps
+	TOTPIssuer = "CoWatchIt"
+	// Time to scan the QR code and confirm a first code
+	TwoFactorEnrollmentTTL = 10 * time.Minute
+	RecoveryCodeCount      = 10
+	// Wrong codes a pending session takes before it is dropped
+	maxTwoFactorAttempts = 5
+	// Codes stay valid one period either side of now
+	totpSkew   = 1
+	totpPeriod = 30
+	qrCodeSize = 256
+	// Recovery codes read as two groups of five, without look-alike characters
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+	recoveryCodeLength   = 10
+)
+
+// TwoFactorEnrollment is what an authenticator app needs, the secret is
+// only kept once a code from the app confirms it
+type TwoFactorEnrollment struct {
+	Secret          string
+	ProvisioningURI string
+	// PNG of the provisioning URI
+	QRCode []byte
+}
+
+type TwoFactorService struct {
+	redisClient    *redis.Client
+	recoveryCodes  RecoveryCodeRepository
+	transactor     Transactor
+	userService    *UserService
+	sessionService *SessionService
+}
+
+func NewTwoFactorService(rc *redis.Client, recoveryCodes RecoveryCodeRepository, tx Transactor, us *UserService, ss *SessionService) *TwoFactorService {
+	return &TwoFactorService{
+		redisClient:    rc,
+		recoveryCodes:  recoveryCodes,
+		transactor:     tx,
+		userService:    us,
+		sessionService: ss,
+	}
+}
+
+// Enroll starts turning two-factor on with a new secret, it waits in Redis
+// for ConfirmEnrollment
+func (s *TwoFactorService) Enroll(ctx context.Context, userID uuid.UUID) (*TwoFactorEnrollment, error) {
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.TwoFactorEnabled() {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      TOTPIssuer,
+		AccountName: user.Username,
+		Period:      totpPeriod,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate totp secret: %w", err)
+	}
+
+	if err := s.redisClient.Set(ctx, enrollmentKey(userID), key.URL(), TwoFactorEnrollmentTTL).Err(); err != nil {
+		return nil, fmt.Errorf("failed to store totp enrollment: %w", err)
+	}
+
+	return newEnrollment(key)
+}
+
+// PendingEnrollment is the enrollment waiting for ConfirmEnrollment, so a
+// wrong code doesn't mean scanning a new QR code
+func (s *TwoFactorService) PendingEnrollment(ctx context.Context, userID uuid.UUID) (*TwoFactorEnrollment, error) {
+	key, err := s.pendingKey(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return newEnrollment(key)
+}
+
+// ConfirmEnrollment turns two-factor on when code matches the enrolled
+// secret and returns the recovery codes, the only time they are readable
+func (s *TwoFactorService) ConfirmEnrollment(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	key, err := s.pendingKey(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkTOTP(ctx, userID, key.Secret(), code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.transactor.Transaction(ctx, func(ctx context.Context) error {
+		if err := s.userService.SetTOTP(ctx, userID, key.Secret()); err != nil {
+			return fmt.Errorf("failed to enable totp: %w", err)
+		}
+
+		if err := s.recoveryCodes.Replace(ctx, userID, hashes); err != nil {
+			return fmt.Errorf("failed to store recovery codes: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.redisClient.Del(ctx, enrollmentKey(userID)).Err(); err != nil {
+		logging.FromContext(ctx).Warn("failed to delete totp enrollment", "err", err)
+	}
+
+	return codes, nil
+}
+
+// Disable turns two-factor off, code is a current code or a recovery code
+func (s *TwoFactorService) Disable(ctx context.Context, userID uuid.UUID, code string) error {
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if !user.TwoFactorEnabled() {
+		return ErrTwoFactorNotEnabled
+	}
+
+	if err := s.checkCode(ctx, user, code); err != nil {
+		return err
+	}
+
+	return s.transactor.Transaction(ctx, func(ctx context.Context) error {
+		if err := s.userService.SetTOTP(ctx, userID, ""); err != nil {
+			return fmt.Errorf("failed to disable totp: %w", err)
+		}
+
+		if err := s.recoveryCodes.DeleteByUser(ctx, userID); err != nil {
+			return fmt.Errorf("failed to delete recovery codes: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// Status tells whether two-factor is on and how many recovery codes are left
+func (s *TwoFactorService) Status(ctx context.Context, userID uuid.UUID) (bool, int64, error) {
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return false, 0, err
+	}
+
+	if !user.TwoFactorEnabled() {
+		return false, 0, nil
+	}
+
+	left, err := s.recoveryCodes.CountUnused(ctx, userID)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+
+	return true, left, nil
+}
+
+// Verify is the second step of signing in: a pending session and a current
+// or recovery code make a full session. Too many wrong codes end the
+// pending session.
+func (s *TwoFactorService) Verify(ctx context.Context, sessionID, code string) (*models.Session, error) {
+	pending, err := s.sessionService.GetPending(ctx, sessionID)
+	if err != nil {
+		return nil, ErrTwoFactorSessionInvalid
+	}
+
+	attemptsKey := fmt.Sprintf("two_factor_attempts:%s", sessionID)
+
+	attempts, err := s.redisClient.Incr(ctx, attemptsKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count two-factor attempts: %w", err)
+	}
+	s.redisClient.Expire(ctx, attemptsKey, PendingTwoFactorTTL)
+
+	if attempts > maxTwoFactorAttempts {
+		if err := s.sessionService.Delete(ctx, pending); err != nil {
+			logging.FromContext(ctx).Warn("failed to drop pending session", "err", err)
+		}
+		return nil, ErrTwoFactorSessionInvalid
+	}
+
+	user, err := s.user(ctx, pending.User.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkCode(ctx, user, code); err != nil {
+		return nil, err
+	}
+
+	session, err := s.sessionService.CompleteTwoFactor(ctx, pending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	s.redisClient.Del(ctx, attemptsKey)
+
+	return session, nil
+}
+
+// A six digit code is checked against the user's secret, anything else
+// as a recovery code
+func (s *TwoFactorService) checkCode(ctx context.Context, user *models.User, code string) error {
+	code = strings.TrimSpace(code)
+
+	if len(code) == 6 {
+		return s.checkTOTP(ctx, user.ID, user.TOTPSecret, code)
+	}
+
+	err := s.recoveryCodes.Use(ctx, user.ID, hashRecoveryCode(code))
+	if errors.Is(err, repositories.ErrNotFound) {
+		return ErrTwoFactorCodeInvalid
+	}
+	if err != nil {
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	logging.FromContext(ctx).Info("recovery code used", "user_id", user.ID)
+
+	return nil
+}
+
+// A code is taken once, so one seen over someone's shoulder can't be replayed
+func (s *TwoFactorService) checkTOTP(ctx context.Context, userID uuid.UUID, secret, code string) error {
+	valid, err := totp.ValidateCustom(code, secret, time.Now(), totp.ValidateOpts{
+		Period:    totpPeriod,
+		Skew:      totpSkew,
+		Digits:    otp.DigitsSix,
+		Algorithm: otp.AlgorithmSHA1,
+	})
+	if err != nil || !valid {
+		return ErrTwoFactorCodeInvalid
+	}
+
+	usedKey := fmt.Sprintf("totp_used:%s:%s", userID, code)
+	window := time.Duration(totpPeriod*(2*totpSkew+1)) * time.Second
+
+	fresh, err := s.redisClient.SetNX(ctx, usedKey, 1, window).Result()
+	if err != nil {
+		return fmt.Errorf("failed to record totp code: %w", err)
+	}
+
+	if !fresh {
+		return ErrTwoFactorCodeInvalid
+	}
+
+	return nil
+}
+
+func (s *TwoFactorService) user(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	user, err := s.userService.Me(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, fmt.Errorf("user %s not found", userID)
+	}
+
+	return user, nil
+}
+
+func (s *TwoFactorService) pendingKey(ctx context.Context, userID uuid.UUID) (*otp.Key, error) {
+	url, err := s.redisClient.Get(ctx, enrollmentKey(userID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrTwoFactorEnrollmentExpired
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load totp enrollment: %w", err)
+	}
+
+	key, err := otp.NewKeyFromURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse totp enrollment: %w", err)
+	}
+
+	return key, nil
+}
+
+func newEnrollment(key *otp.Key) (*TwoFactorEnrollment, error) {
+	image, err := key.Image(qrCodeSize, qrCodeSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render qr code: %w", err)
+	}
+
+	var qrCode bytes.Buffer
+	if err := png.Encode(&qrCode, image); err != nil {
+		return nil, fmt.Errorf("failed to encode qr code: %w", err)
+	}
+
+	return &TwoFactorEnrollment{
+		Secret:          key.Secret(),
+		ProvisioningURI: key.URL(),
+		QRCode:          qrCode.Bytes(),
+	}, nil
+}
+
+func enrollmentKey(userID uuid.UUID) string {
+	return fmt.Sprintf("totp_enroll:%s", userID)
+}
+
+// Codes are shown as xxxxx-xxxxx and stored as hashes
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, RecoveryCodeCount)
+	hashes := make([]string, 0, RecoveryCodeCount)
+
+	// Bytes past the last whole multiple of the alphabet are skipped, so
+	// every character is equally likely
+	limit := byte(256 - 256%len(recoveryCodeAlphabet))
+	random := make([]byte, 1)
+
+	for range RecoveryCodeCount {
+		var code strings.Builder
+
+		for written := 0; written < recoveryCodeLength; {
+			if _, err := rand.Read(random); err != nil {
+				return nil, nil, fmt.Errorf("failed to generate recovery codes: %w", err)
+			}
+
+			if random[0] >= limit {
+				continue
+			}
+
+			if written == recoveryCodeLength/2 {
+				code.WriteByte('-')
+			}
+			code.WriteByte(recoveryCodeAlphabet[int(random[0])%len(recoveryCodeAlphabet)])
+			written++
+		}
+
+		codes = append(codes, code.String())
+		hashes = append(hashes, hashRecoveryCode(code.String()))
+	}
+
+	return codes, hashes, nil
+}
+
+// Case, spaces and the dash don't matter when typing a code back
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+
+	return hex.EncodeToString(sum[:])
+}
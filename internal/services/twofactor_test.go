@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/dliluashvili/cowatchit/internal/config"
+	"github.com/dliluashvili/cowatchit/internal/dtos"
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/dliluashvili/cowatchit/internal/repositories"
+	"github.com/google/uuid"
+	"github.com/pquerna/otp/totp"
+	"github.com/redis/go-redis/v9"
+)
+
+type memoryUsers struct {
+	users map[uuid.UUID]*models.User
+}
+
+func (m *memoryUsers) Create(context.Context, *dtos.CreateUserDto) (*models.User, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *memoryUsers) FindByField(_ context.Context, params map[string]any) (*models.User, error) {
+	id, _ := params["id"].(uuid.UUID)
+	return m.users[id], nil
+}
+
+func (m *memoryUsers) Update(_ context.Context, userID uuid.UUID, updates map[string]any) error {
+	user := m.users[userID]
+	user.TOTPSecret = updates["totp_secret"].(string)
+	user.TOTPEnabledAt = updates["totp_enabled_at"].(*time.Time)
+	return nil
+}
+
+func (m *memoryUsers) Delete(context.Context, uuid.UUID) (*bool, error) {
+	return nil, errors.New("not implemented")
+}
+
+// hash -> used, per user
+type memoryRecoveryCodes struct {
+	codes map[uuid.UUID]map[string]bool
+}
+
+func (m *memoryRecoveryCodes) Replace(_ context.Context, userID uuid.UUID, hashes []string) error {
+	m.codes[userID] = map[string]bool{}
+	for _, hash := range hashes {
+		m.codes[userID][hash] = false
+	}
+	return nil
+}
+
+func (m *memoryRecoveryCodes) Use(_ context.Context, userID uuid.UUID, hash string) error {
+	used, exists := m.codes[userID][hash]
+	if !exists || used {
+		return repositories.ErrNotFound
+	}
+
+	m.codes[userID][hash] = true
+	return nil
+}
+
+func (m *memoryRecoveryCodes) CountUnused(_ context.Context, userID uuid.UUID) (int64, error) {
+	var left int64
+	for _, used := range m.codes[userID] {
+		if !used {
+			left++
+		}
+	}
+	return left, nil
+}
+
+func (m *memoryRecoveryCodes) DeleteByUser(_ context.Context, userID uuid.UUID) error {
+	delete(m.codes, userID)
+	return nil
+}
+
+type twoFactorFixture struct {
+	service       *TwoFactorService
+	sessions      *SessionService
+	recoveryCodes *memoryRecoveryCodes
+	user          *models.User
+}
+
+func newTwoFactorFixture(t *testing.T) *twoFactorFixture {
+	t.Helper()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { redisClient.Close() })
+
+	user := &models.User{ID: uuid.New(), Username: "alice"}
+	users := NewUserService(&memoryUsers{users: map[uuid.UUID]*models.User{user.ID: user}})
+
+	f := &twoFactorFixture{
+		sessions:      NewSessionService(redisClient, config.Default().Session),
+		recoveryCodes: &memoryRecoveryCodes{codes: map[uuid.UUID]map[string]bool{}},
+		user:          user,
+	}
+	f.service = NewTwoFactorService(redisClient, f.recoveryCodes, directTransactor{}, users, f.sessions)
+
+	return f
+}
+
+// Turns two-factor on and returns the recovery codes
+func (f *twoFactorFixture) enable(t *testing.T) []string {
+	t.Helper()
+
+	enrollment, err := f.service.Enroll(context.Background(), f.user.ID)
+	if err != nil {
+		t.Fatalf("Enroll: %v", err)
+	}
+
+	codes, err := f.service.ConfirmEnrollment(context.Background(), f.user.ID, totpCode(t, enrollment.Secret, time.Now()))
+	if err != nil {
+		t.Fatalf("ConfirmEnrollment: %v", err)
+	}
+
+	return codes
+}
+
+func totpCode(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+
+	code, err := totp.GenerateCode(secret, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return code
+}
+
+func TestTwoFactorConfirmEnrollment(t *testing.T) {
+	f := newTwoFactorFixture(t)
+	ctx := context.Background()
+
+	enrollment, err := f.service.Enroll(ctx, f.user.ID)
+	if err != nil {
+		t.Fatalf("Enroll: %v", err)
+	}
+	if !strings.HasPrefix(enrollment.ProvisioningURI, "otpauth://totp/") || len(enrollment.QRCode) == 0 {
+		t.Errorf("enrollment = %+v", enrollment)
+	}
+
+	if _, err := f.service.ConfirmEnrollment(ctx, f.user.ID, "000000x"); !errors.Is(err, ErrTwoFactorCodeInvalid) {
+		t.Fatalf("wrong code: err = %v, want %v", err, ErrTwoFactorCodeInvalid)
+	}
+	if f.user.TwoFactorEnabled() {
+		t.Fatal("a wrong code turned two-factor on")
+	}
+
+	// A wrong code keeps the enrollment for another try
+	pending, err := f.service.PendingEnrollment(ctx, f.user.ID)
+	if err != nil || pending.Secret != enrollment.Secret {
+		t.Fatalf("PendingEnrollment = %+v, %v", pending, err)
+	}
+
+	code := totpCode(t, enrollment.Secret, time.Now())
+	codes, err := f.service.ConfirmEnrollment(ctx, f.user.ID, code)
+	if err != nil {
+		t.Fatalf("ConfirmEnrollment: %v", err)
+	}
+
+	if !f.user.TwoFactorEnabled() || f.user.TOTPSecret != enrollment.Secret {
+		t.Errorf("user = %+v, want two-factor on with the enrolled secret", f.user)
+	}
+
+	if _, err := f.service.PendingEnrollment(ctx, f.user.ID); !errors.Is(err, ErrTwoFactorEnrollmentExpired) {
+		t.Errorf("enrollment kept after confirming: err = %v", err)
+	}
+
+	if _, err := f.service.Enroll(ctx, f.user.ID); !errors.Is(err, ErrTwoFactorEnabled) {
+		t.Errorf("Enroll again: err = %v, want %v", err, ErrTwoFactorEnabled)
+	}
+
+	// The code that confirmed the enrollment can't be used again
+	if err := f.service.Disable(ctx, f.user.ID, code); !errors.Is(err, ErrTwoFactorCodeInvalid) {
+		t.Errorf("replayed code: err = %v, want %v", err, ErrTwoFactorCodeInvalid)
+	}
+
+	if len(codes) != RecoveryCodeCount {
+		t.Fatalf("got %d recovery codes, want %d", len(codes), RecoveryCodeCount)
+	}
+
+	stored := f.recoveryCodes.codes[f.user.ID]
+	for _, code := range codes {
+		if _, plain := stored[code]; plain {
+			t.Errorf("recovery code %s stored in plain text", code)
+		}
+		if _, hashed := stored[hashRecoveryCode(code)]; !hashed {
+			t.Errorf("recovery code %s not stored", code)
+		}
+	}
+}
+
+func TestTwoFactorRecoveryCodesAreSingleUse(t *testing.T) {
+	f := newTwoFactorFixture(t)
+	ctx := context.Background()
+	codes := f.enable(t)
+
+	// Typed back in upper case without the dash
+	typed := strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))
+
+	if err := f.service.checkCode(ctx, f.user, typed); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+
+	if err := f.service.checkCode(ctx, f.user, codes[0]); !errors.Is(err, ErrTwoFactorCodeInvalid) {
+		t.Errorf("second use: err = %v, want %v", err, ErrTwoFactorCodeInvalid)
+	}
+
+	if err := f.service.checkCode(ctx, f.user, "abcde-fghjk"); !errors.Is(err, ErrTwoFactorCodeInvalid) {
+		t.Errorf("unknown code: err = %v, want %v", err, ErrTwoFactorCodeInvalid)
+	}
+
+	enabled, left, err := f.service.Status(ctx, f.user.ID)
+	if err != nil || !enabled || left != RecoveryCodeCount-1 {
+		t.Errorf("Status = %t, %d, %v, want on with %d codes left", enabled, left, err, RecoveryCodeCount-1)
+	}
+}
+
+func TestTwoFactorVerifyUpgradesPendingSession(t *testing.T) {
+	f := newTwoFactorFixture(t)
+	ctx := context.Background()
+	f.enable(t)
+
+	pending, err := f.sessions.CreateAndSave(ctx, f.user)
+	if err != nil {
+		t.Fatalf("CreateAndSave: %v", err)
+	}
+	if !pending.PendingTwoFactor {
+		t.Fatal("signing in with two-factor on made a full session")
+	}
+
+	if _, err := f.service.Verify(ctx, pending.SessionID, "123456x"); !errors.Is(err, ErrTwoFactorCodeInvalid) {
+		t.Fatalf("wrong code: err = %v, want %v", err, ErrTwoFactorCodeInvalid)
+	}
+
+	// The enrollment spent the current code, the next one is still in the window
+	code := totpCode(t, f.user.TOTPSecret, time.Now().Add(totpPeriod*time.Second))
+
+	session, err := f.service.Verify(ctx, pending.SessionID, code)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	if session.PendingTwoFactor || session.SessionID == pending.SessionID || session.User.ID != f.user.ID {
+		t.Errorf("session = %+v, want a new full session for the user", session)
+	}
+
+	if _, err := f.sessions.GetUserBySession(ctx, session.SessionID); err != nil {
+		t.Errorf("full session not saved: %v", err)
+	}
+
+	if _, err := f.service.Verify(ctx, pending.SessionID, code); !errors.Is(err, ErrTwoFactorSessionInvalid) {
+		t.Errorf("pending session reused: err = %v, want %v", err, ErrTwoFactorSessionInvalid)
+	}
+}
+
+func TestTwoFactorVerifyDropsSessionAfterTooManyAttempts(t *testing.T) {
+	f := newTwoFactorFixture(t)
+	ctx := context.Background()
+	f.enable(t)
+
+	pending, err := f.sessions.CreateAndSave(ctx, f.user)
+	if err != nil {
+		t.Fatalf("CreateAndSave: %v", err)
+	}
+
+	for range maxTwoFactorAttempts {
+		if _, err := f.service.Verify(ctx, pending.SessionID, "wrong-code"); !errors.Is(err, ErrTwoFactorCodeInvalid) {
+			t.Fatalf("err = %v, want %v", err, ErrTwoFactorCodeInvalid)
+		}
+	}
+
+	code := totpCode(t, f.user.TOTPSecret, time.Now().Add(totpPeriod*time.Second))
+	if _, err := f.service.Verify(ctx, pending.SessionID, code); !errors.Is(err, ErrTwoFactorSessionInvalid) {
+		t.Errorf("err = %v, want %v", err, ErrTwoFactorSessionInvalid)
+	}
+
+	if _, err := f.sessions.GetPending(ctx, pending.SessionID); err == nil {
+		t.Error("pending session kept after too many attempts")
+	}
+}
@@ -1,26 +1,27 @@
 package services
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/dliluashvili/cowatchit/internal/dtos"
 	"github.com/dliluashvili/cowatchit/internal/helpers"
 	"github.com/dliluashvili/cowatchit/internal/models"
-	"github.com/dliluashvili/cowatchit/internal/repositories"
 	"github.com/google/uuid"
 )
 
 type UserService struct {
-	repository *repositories.UserRepository
+	repository UserRepository
 }
 
-func NewUserService(r *repositories.UserRepository) *UserService {
+func NewUserService(r UserRepository) *UserService {
 	return &UserService{
 		repository: r,
 	}
 }
 
-func (s *UserService) Create(dto *dtos.CreateUserDto) (*models.User, error) {
+func (s *UserService) Create(ctx context.Context, dto *dtos.CreateUserDto) (*models.User, error) {
 	age := helpers.CalculateAge(dto.DateOfBirth)
 
 	if age < 18 {
@@ -35,11 +36,26 @@ func (s *UserService) Create(dto *dtos.CreateUserDto) (*models.User, error) {
 
 	dto.Age = &ageUint8
 
-	return s.repository.Create(dto)
+	return s.repository.Create(ctx, dto)
 }
 
-func (s *UserService) Me(userID uuid.UUID) (*models.User, error) {
-	user, err := s.repository.FindByField(map[string]any{
+// CreateExternal creates an account for a single sign-on identity. It has
+// no password, so it can only sign in through its provider.
+func (s *UserService) CreateExternal(ctx context.Context, username, email string) (*models.User, error) {
+	gender, password := "", ""
+	age := uint8(0)
+
+	return s.repository.Create(ctx, &dtos.CreateUserDto{
+		Username: &username,
+		Email:    &email,
+		Gender:   &gender,
+		Password: &password,
+		Age:      &age,
+	})
+}
+
+func (s *UserService) Me(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	user, err := s.repository.FindByField(ctx, map[string]any{
 		"id": userID,
 	})
 
@@ -50,26 +66,62 @@ func (s *UserService) Me(userID uuid.UUID) (*models.User, error) {
 	return user, nil
 }
 
-func (s *UserService) FindByUsername(username string) (*models.User, error) {
-	return s.repository.FindByField(map[string]any{"username": username})
+func (s *UserService) FindByUsername(ctx context.Context, username string) (*models.User, error) {
+	return s.repository.FindByField(ctx, map[string]any{"username": username})
+}
+
+func (s *UserService) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	return s.repository.FindByField(ctx, map[string]any{"email": email})
 }
 
-func (s *UserService) FindByID(idStr string) (*models.User, error) {
+func (s *UserService) FindByID(ctx context.Context, idStr string) (*models.User, error) {
 	id, err := uuid.Parse(idStr)
 
 	if err != nil {
 		return nil, fmt.Errorf("invalid userid")
 	}
 
-	return s.repository.FindByField(map[string]any{"id": id})
+	return s.repository.FindByField(ctx, map[string]any{"id": id})
 }
 
-func (s *UserService) UpdatePasswordHash(ID uuid.UUID, passwordHash string) error {
-	return s.repository.Update(ID, map[string]any{
+func (s *UserService) UpdatePasswordHash(ctx context.Context, ID uuid.UUID, passwordHash string) error {
+	return s.repository.Update(ctx, ID, map[string]any{
 		"password": passwordHash,
 	})
 }
 
-func (s *UserService) Delete(ID uuid.UUID) (*bool, error) {
-	return s.repository.Delete(ID)
+// UpdateProfile saves the username and profile fields of user, the
+// avatar only changes through SetAvatar
+func (s *UserService) UpdateProfile(ctx context.Context, user *models.User) error {
+	return s.repository.Update(ctx, user.ID, map[string]any{
+		"username":     user.Username,
+		"display_name": user.DisplayName,
+		"bio":          user.Bio,
+		"gender":       user.Gender,
+		"country_id":   user.CountryID,
+	})
+}
+
+func (s *UserService) SetAvatar(ctx context.Context, ID uuid.UUID, avatar string) error {
+	return s.repository.Update(ctx, ID, map[string]any{
+		"avatar": avatar,
+	})
+}
+
+func (s *UserService) Delete(ctx context.Context, ID uuid.UUID) (*bool, error) {
+	return s.repository.Delete(ctx, ID)
+}
+
+// SetTOTP stores the confirmed secret, an empty secret turns two-factor off
+func (s *UserService) SetTOTP(ctx context.Context, ID uuid.UUID, secret string) error {
+	var enabledAt *time.Time
+	if secret != "" {
+		now := time.Now()
+		enabledAt = &now
+	}
+
+	return s.repository.Update(ctx, ID, map[string]any{
+		"totp_secret":     secret,
+		"totp_enabled_at": enabledAt,
+	})
 }
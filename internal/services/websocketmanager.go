@@ -2,16 +2,45 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/coder/websocket"
+	"github.com/dliluashvili/cowatchit/internal/metrics"
 	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/dliluashvili/cowatchit/internal/protocol"
+	"github.com/dliluashvili/cowatchit/internal/tracing"
 	"github.com/dliluashvili/cowatchit/internal/types"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-const MaxConnectionsPerUser = 5
+var (
+	ErrTooManySockets = errors.New("user has reached maximum connections")
+	ErrRoomNotActive  = errors.New("room doesnt exist")
+	ErrRoomAtCapacity = errors.New("room has reached maximum capacity")
+)
+
+type WebSocketManagerOptions struct {
+	// Open sockets a single user may register
+	MaxSocketsPerUser int
+	Writer            SocketWriterOptions
+	Keepalive         KeepaliveOptions
+	Resume            ResumeOptions
+}
+
+var DefaultWebSocketManagerOptions = WebSocketManagerOptions{
+	MaxSocketsPerUser: 5,
+	Writer:            DefaultSocketWriterOptions,
+	Keepalive:         DefaultKeepaliveOptions,
+	Resume:            DefaultResumeOptions,
+}
 
 type WebSocketManagerService struct {
 	mu sync.RWMutex
@@ -22,19 +51,73 @@ type WebSocketManagerService struct {
 	// userId -> {socketIds}
 	userIDSocketIDs map[uuid.UUID]map[string]bool
 
-	// userId_socketId -> conn
-	userSocketConnections map[string]*websocket.Conn
+	// socketId -> writer
+	socketWriters map[string]*SocketWriter
 
 	// roomId -> RoomMetadata
 	roomMetadata map[uuid.UUID]*types.RoomMetadata
+
+	// roomId -> recent room events
+	roomEvents map[uuid.UUID]*replayBuffer
+
+	// resume token -> socketId
+	resumeTokens map[string]string
+
+	// socketId -> resume token
+	socketResumeTokens map[string]string
+
+	// socketId -> dropped socket awaiting RESUME
+	suspended map[string]*suspendedSocket
+
+	maxSocketsPerUser int
+	writerOptions     SocketWriterOptions
+	keepaliveOptions  KeepaliveOptions
+	resumeOptions     ResumeOptions
+
+	reapedSockets atomic.Int64
+
+	// Set by Shutdown, no new sockets are accepted
+	draining atomic.Bool
 }
 
-func NewWebSocketManagerService() *WebSocketManagerService {
+func NewWebSocketManagerService(opts WebSocketManagerOptions) *WebSocketManagerService {
 	return &WebSocketManagerService{
-		socketIdToUserId:      make(map[string]uuid.UUID),
-		userIDSocketIDs:       make(map[uuid.UUID]map[string]bool),
-		userSocketConnections: make(map[string]*websocket.Conn),
-		roomMetadata:          make(map[uuid.UUID]*types.RoomMetadata),
+		socketIdToUserId:   make(map[string]uuid.UUID),
+		userIDSocketIDs:    make(map[uuid.UUID]map[string]bool),
+		socketWriters:      make(map[string]*SocketWriter),
+		roomMetadata:       make(map[uuid.UUID]*types.RoomMetadata),
+		roomEvents:         make(map[uuid.UUID]*replayBuffer),
+		resumeTokens:       make(map[string]string),
+		socketResumeTokens: make(map[string]string),
+		suspended:          make(map[string]*suspendedSocket),
+		maxSocketsPerUser:  opts.MaxSocketsPerUser,
+		writerOptions:      opts.Writer,
+		keepaliveOptions:   opts.Keepalive,
+		resumeOptions:      opts.Resume,
+	}
+}
+
+// Attach a connection and start its writer, must be called before any send
+func (sm *WebSocketManagerService) Attach(socketID string, conn *websocket.Conn) *SocketWriter {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	writer := NewSocketWriter(conn, sm.writerOptions)
+	sm.socketWriters[socketID] = writer
+
+	return writer
+}
+
+// Detach a connection and stop its writer. The socket keeps its resume
+// token until it is unregistered, so it can still be suspended.
+func (sm *WebSocketManagerService) Detach(socketID string) {
+	sm.mu.Lock()
+	writer, exists := sm.socketWriters[socketID]
+	delete(sm.socketWriters, socketID)
+	sm.mu.Unlock()
+
+	if exists {
+		writer.Close()
 	}
 }
 
@@ -46,8 +129,8 @@ func (sm *WebSocketManagerService) Register(ctx *types.WebSocketContext, room *m
 	// Check if user already has max connections
 	userSocketCount := len(sm.userIDSocketIDs[ctx.User.ID])
 
-	if userSocketCount >= MaxConnectionsPerUser {
-		return fmt.Errorf("user has reached maximum connections (%d)", MaxConnectionsPerUser)
+	if userSocketCount >= sm.maxSocketsPerUser {
+		return fmt.Errorf("%w (%d)", ErrTooManySockets, sm.maxSocketsPerUser)
 	}
 
 	// If user is joining a different room, kick them from previous room
@@ -74,20 +157,20 @@ func (sm *WebSocketManagerService) Register(ctx *types.WebSocketContext, room *m
 				RoomID:             ctx.RoomID,
 				Capacity:           room.Capacity,
 				CurrentTimeSeconds: 0,
-				State:              types.StateStop,
+				State:              protocol.StateStop,
 				HostID:             room.HostID,
 				SocketIDs:          make(map[string]bool),
 				Users:              make(types.UserIDInfo),
 			}
 			sm.roomMetadata[ctx.RoomID] = roomMeta
 		} else {
-			return fmt.Errorf("room doesnt exist")
+			return ErrRoomNotActive
 		}
 	}
 
 	// Check room capacity
 	if len(roomMeta.Users) >= roomMeta.Capacity {
-		return fmt.Errorf("room has reached maximum capacity (%d)", roomMeta.Capacity)
+		return fmt.Errorf("%w (%d)", ErrRoomAtCapacity, roomMeta.Capacity)
 	}
 
 	// Add socket to room
@@ -107,9 +190,6 @@ func (sm *WebSocketManagerService) Register(ctx *types.WebSocketContext, room *m
 	}
 	sm.userIDSocketIDs[ctx.User.ID][ctx.ID] = true
 
-	compositeKey := sm.getCompositeKey(ctx.User.ID, ctx.ID)
-	sm.userSocketConnections[compositeKey] = ctx.Conn
-
 	return nil
 }
 
@@ -118,6 +198,13 @@ func (sm *WebSocketManagerService) Unregister(socketID string) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
+	// A socket that left can no longer be resumed
+	if suspended, exists := sm.suspended[socketID]; exists {
+		suspended.timer.Stop()
+		delete(sm.suspended, socketID)
+	}
+	sm.deleteResumeToken(socketID)
+
 	// Get userID from socketID
 	userID, userExists := sm.socketIdToUserId[socketID]
 
@@ -150,6 +237,7 @@ func (sm *WebSocketManagerService) Unregister(socketID string) {
 		// Remove room if empty
 		if len(roomMeta.SocketIDs) == 0 {
 			delete(sm.roomMetadata, roomID)
+			delete(sm.roomEvents, roomID)
 		}
 	}
 
@@ -163,8 +251,6 @@ func (sm *WebSocketManagerService) Unregister(socketID string) {
 
 	// Remove mappings
 	delete(sm.socketIdToUserId, socketID)
-	compositeKey := sm.getCompositeKey(userID, socketID)
-	delete(sm.userSocketConnections, compositeKey)
 }
 
 // Get room ID from socket (helper function)
@@ -178,6 +264,9 @@ func (sm *WebSocketManagerService) getRoomIDFromSocket(socketID string) uuid.UUI
 }
 
 func (sm *WebSocketManagerService) GetRoomMetadata(roomID uuid.UUID) *types.RoomMetadata {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
 	roomMeta, exists := sm.roomMetadata[roomID]
 
 	if !exists {
@@ -187,12 +276,72 @@ func (sm *WebSocketManagerService) GetRoomMetadata(roomID uuid.UUID) *types.Room
 	return roomMeta
 }
 
+// SetRoomDuration records how long the room's video is, playback positions
+// are kept within it. Zero lifts the limit.
+func (sm *WebSocketManagerService) SetRoomDuration(roomID uuid.UUID, seconds float64) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	roomMeta, exists := sm.roomMetadata[roomID]
+
+	if !exists {
+		return
+	}
+
+	roomMeta.DurationSeconds = seconds
+	roomMeta.CurrentTimeSeconds = clampPosition(roomMeta.CurrentTimeSeconds, seconds)
+}
+
+// SetPlayback records the host's playback state for sockets joining later,
+// and returns the position kept, clamped to the video's length
+func (sm *WebSocketManagerService) SetPlayback(roomID uuid.UUID, state string, seconds float64) float64 {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	roomMeta, exists := sm.roomMetadata[roomID]
+
+	if !exists {
+		return seconds
+	}
+
+	roomMeta.State = state
+	roomMeta.CurrentTimeSeconds = clampPosition(seconds, roomMeta.DurationSeconds)
+
+	return roomMeta.CurrentTimeSeconds
+}
+
+// GetPlayback returns the room's playback state and position, false when
+// the room is not active
+func (sm *WebSocketManagerService) GetPlayback(roomID uuid.UUID) (string, float64, bool) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	roomMeta, exists := sm.roomMetadata[roomID]
+
+	if !exists {
+		return "", 0, false
+	}
+
+	return roomMeta.State, roomMeta.CurrentTimeSeconds, true
+}
+
+func clampPosition(seconds, duration float64) float64 {
+	if duration > 0 {
+		seconds = min(seconds, duration)
+	}
+
+	return max(seconds, 0)
+}
+
 func (sm *WebSocketManagerService) IsUserAllowed(roomID, userID uuid.UUID, socketId string) bool {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
 
-	roomMetadata := sm.GetRoomMetadata(roomID)
+	// The lock isn't reentrant, GetRoomMetadata would take it again
+	roomMetadata, exists := sm.roomMetadata[roomID]
 
-	if roomMetadata == nil {
-		fmt.Println("room doesnt exist")
+	if !exists {
+		slog.Debug("room not active", "room_id", roomID, "socket_id", socketId)
 
 		return false
 	}
@@ -200,7 +349,7 @@ func (sm *WebSocketManagerService) IsUserAllowed(roomID, userID uuid.UUID, socke
 	_, userExistsInRoom := roomMetadata.Users[userID]
 
 	if !userExistsInRoom {
-		fmt.Println("not allowed room userid")
+		slog.Debug("user not in room", "room_id", roomID, "user_id", userID, "socket_id", socketId)
 
 		return false
 	}
@@ -208,7 +357,7 @@ func (sm *WebSocketManagerService) IsUserAllowed(roomID, userID uuid.UUID, socke
 	_, socketExistsInRoom := roomMetadata.SocketIDs[socketId]
 
 	if !socketExistsInRoom {
-		fmt.Println("not allowed room socket")
+		slog.Debug("socket not in room", "room_id", roomID, "user_id", userID, "socket_id", socketId)
 
 		return false
 	}
@@ -225,35 +374,23 @@ func (sm *WebSocketManagerService) kickUserFromRoom(userID uuid.UUID) {
 
 	// Close all connections for this user
 	for socketID := range socketIDs {
-		if conn, exists := sm.userSocketConnections[sm.getCompositeKey(userID, socketID)]; exists {
-			conn.Close(websocket.StatusGoingAway, "user joined another room")
+		if writer, exists := sm.socketWriters[socketID]; exists {
+			writer.CloseConn(websocket.StatusGoingAway, "user joined another room")
 		}
 	}
 }
 
-// Get composite key
-func (sm *WebSocketManagerService) getCompositeKey(userID uuid.UUID, socketID string) string {
-	return fmt.Sprintf("%s_%s", userID.String(), socketID)
-}
-
-// Get connection from socketID
-func (sm *WebSocketManagerService) GetConnFromSocket(socketID string) (*websocket.Conn, error) {
+// Get writer from socketID
+func (sm *WebSocketManagerService) GetSocketWriter(socketID string) (*SocketWriter, error) {
 	sm.mu.RLock()
-	userID, exists := sm.socketIdToUserId[socketID]
-	if !exists {
-		sm.mu.RUnlock()
-		return nil, fmt.Errorf("socket not found: %s", socketID)
-	}
-
-	compositeKey := sm.getCompositeKey(userID, socketID)
-	conn, connExists := sm.userSocketConnections[compositeKey]
+	writer, exists := sm.socketWriters[socketID]
 	sm.mu.RUnlock()
 
-	if !connExists {
-		return nil, fmt.Errorf("connection not found for socket: %s", socketID)
+	if !exists {
+		return nil, fmt.Errorf("socket not found: %s", socketID)
 	}
 
-	return conn, nil
+	return writer, nil
 }
 
 // Count room participants
@@ -267,11 +404,17 @@ func (sm *WebSocketManagerService) GetRoomParticipants(roomID uuid.UUID) (*types
 		return nil, fmt.Errorf("room not found: %s", roomID)
 	}
 
-	return &roomMeta.Users, nil
+	// Copy, the room's map keeps changing after the lock is released
+	users := make(types.UserIDInfo, len(roomMeta.Users))
+	for userID, userInfo := range roomMeta.Users {
+		users[userID] = userInfo
+	}
+
+	return &users, nil
 }
 
-// Get all connections for user
-func (sm *WebSocketManagerService) GetUserConnections(userID uuid.UUID) ([]*websocket.Conn, error) {
+// Get all writers for user
+func (sm *WebSocketManagerService) GetUserWriters(userID uuid.UUID) ([]*SocketWriter, error) {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
 
@@ -280,19 +423,18 @@ func (sm *WebSocketManagerService) GetUserConnections(userID uuid.UUID) ([]*webs
 		return nil, fmt.Errorf("user not found: %s", userID)
 	}
 
-	connections := make([]*websocket.Conn, 0, len(socketIDs))
+	writers := make([]*SocketWriter, 0, len(socketIDs))
 	for socketID := range socketIDs {
-		compositeKey := sm.getCompositeKey(userID, socketID)
-		if conn, exists := sm.userSocketConnections[compositeKey]; exists {
-			connections = append(connections, conn)
+		if writer, exists := sm.socketWriters[socketID]; exists {
+			writers = append(writers, writer)
 		}
 	}
 
-	if len(connections) == 0 {
+	if len(writers) == 0 {
 		return nil, fmt.Errorf("user not found: %s", userID)
 	}
 
-	return connections, nil
+	return writers, nil
 }
 
 // Get user's room
@@ -323,77 +465,92 @@ func (sm *WebSocketManagerService) GetUserRoom(userID uuid.UUID) (uuid.UUID, err
 }
 
 // Broadcast to room (exclude sender)
-func (sm *WebSocketManagerService) BroadcastToRoom(ctx context.Context, roomID uuid.UUID, message []byte, excludeSocketID string) error {
-	sm.mu.RLock()
+func (sm *WebSocketManagerService) BroadcastToRoom(ctx context.Context, roomID uuid.UUID, message *protocol.Message, excludeSocketID string) error {
+	return sm.broadcast(ctx, roomID, message, "", excludeSocketID)
+}
 
-	roomMetadata := sm.GetRoomMetadata(roomID)
+// Broadcast to room (include sender)
+func (sm *WebSocketManagerService) BroadcastToRoomIncludeSender(ctx context.Context, roomID uuid.UUID, message *protocol.Message) error {
+	return sm.broadcast(ctx, roomID, message, "", "")
+}
 
-	if roomMetadata == nil {
-		sm.mu.RUnlock()
-		return fmt.Errorf("room not found: %s", roomID)
-	}
+// Broadcast playback state to room (exclude sender), a pending state
+// update for a slow socket is replaced instead of queued behind
+func (sm *WebSocketManagerService) BroadcastStateToRoom(ctx context.Context, roomID uuid.UUID, message *protocol.Message, excludeSocketID string) error {
+	return sm.broadcast(ctx, roomID, message, protocol.EventHostStateReceived, excludeSocketID)
+}
 
-	var conns []*websocket.Conn
+// Stamp the message with the room's next sequence number, keep it for
+// replay and queue it on every socket in the room
+func (sm *WebSocketManagerService) broadcast(ctx context.Context, roomID uuid.UUID, message *protocol.Message, coalesceKey, excludeSocketID string) error {
+	_, span := tracing.Tracer.Start(ctx, "WebSocketManager.broadcast", trace.WithAttributes(
+		attribute.String("ws.event", message.Event),
+		attribute.String("ws.room_id", roomID.String()),
+	))
+	defer span.End()
 
-	for socketID := range roomMetadata.SocketIDs {
-		if socketID == excludeSocketID {
-			continue
-		}
+	start := time.Now()
+	defer func() {
+		metrics.BroadcastDuration.WithLabelValues(message.Event).Observe(time.Since(start).Seconds())
+	}()
 
-		userID := sm.socketIdToUserId[socketID]
-		compositeKey := sm.getCompositeKey(userID, socketID)
-		if conn, exists := sm.userSocketConnections[compositeKey]; exists {
-			conns = append(conns, conn)
-		}
-	}
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
 
-	sm.mu.RUnlock()
+	roomMeta, exists := sm.roomMetadata[roomID]
 
-	var errs []error
-	for _, conn := range conns {
-		if err := conn.Write(ctx, websocket.MessageText, message); err != nil {
-			errs = append(errs, err)
-		}
+	if !exists {
+		return fmt.Errorf("room not found: %s", roomID)
 	}
 
-	if len(errs) > 0 {
-		return fmt.Errorf("broadcast errors: %v", errs)
+	buffer, exists := sm.roomEvents[roomID]
+	if !exists {
+		buffer = newReplayBuffer(sm.resumeOptions.ReplayBufferSize)
+		sm.roomEvents[roomID] = buffer
 	}
 
-	return nil
-}
-
-// Broadcast to room (include sender)
-func (sm *WebSocketManagerService) BroadcastToRoomIncludeSender(ctx context.Context, roomID uuid.UUID, message []byte) error {
-	sm.mu.RLock()
+	message.Seq = buffer.nextSeq()
 
-	roomMeta, exists := sm.roomMetadata[roomID]
-
-	if !exists {
-		sm.mu.RUnlock()
-		return fmt.Errorf("room not found: %s", roomID)
+	data, err := protocol.Encode(message)
+	if err != nil {
+		return err
 	}
 
-	var conns []*websocket.Conn
+	buffer.append(roomEvent{
+		seq:             message.Seq,
+		data:            data,
+		excludeSocketID: excludeSocketID,
+	})
 
+	msg := outboundMessage{data: data, coalesceKey: coalesceKey}
+
+	sent := 0
+	var errs []error
 	for socketID := range roomMeta.SocketIDs {
-		userID := sm.socketIdToUserId[socketID]
-		compositeKey := sm.getCompositeKey(userID, socketID)
-		if conn, exists := sm.userSocketConnections[compositeKey]; exists {
-			conns = append(conns, conn)
+		if socketID == excludeSocketID {
+			continue
 		}
-	}
 
-	sm.mu.RUnlock()
+		// Suspended sockets catch up from the replay buffer
+		writer, exists := sm.socketWriters[socketID]
+		if !exists {
+			continue
+		}
 
-	var errs []error
-	for _, conn := range conns {
-		if err := conn.Write(ctx, websocket.MessageText, message); err != nil {
+		if err := writer.enqueue(msg); err != nil {
 			errs = append(errs, err)
+			continue
 		}
+
+		sent++
 	}
 
+	metrics.WSEventsOut.WithLabelValues(message.Event).Add(float64(sent))
+	span.SetAttributes(attribute.Int("ws.recipients", sent))
+
 	if len(errs) > 0 {
+		span.SetStatus(codes.Error, "broadcast errors")
+		metrics.BroadcastErrors.WithLabelValues(message.Event).Add(float64(len(errs)))
 		return fmt.Errorf("broadcast errors: %v", errs)
 	}
 
@@ -402,24 +559,24 @@ func (sm *WebSocketManagerService) BroadcastToRoomIncludeSender(ctx context.Cont
 
 // Send to specific socket
 func (sm *WebSocketManagerService) SendToSocket(ctx context.Context, socketID string, message []byte) error {
-	conn, err := sm.GetConnFromSocket(socketID)
+	writer, err := sm.GetSocketWriter(socketID)
 	if err != nil {
 		return err
 	}
 
-	return conn.Write(ctx, websocket.MessageText, message)
+	return writer.Enqueue(message)
 }
 
 // Send to all sockets of a user
 func (sm *WebSocketManagerService) SendToUser(ctx context.Context, userID uuid.UUID, message []byte) error {
-	conns, err := sm.GetUserConnections(userID)
+	writers, err := sm.GetUserWriters(userID)
 	if err != nil {
 		return err
 	}
 
 	var errs []error
-	for _, conn := range conns {
-		if err := conn.Write(ctx, websocket.MessageText, message); err != nil {
+	for _, writer := range writers {
+		if err := writer.Enqueue(message); err != nil {
 			errs = append(errs, err)
 		}
 	}
@@ -448,6 +605,64 @@ func (sm *WebSocketManagerService) GetUsersInRoom(roomID uuid.UUID) []uuid.UUID
 	return users
 }
 
+// IsUserInRoom tells whether the user has a socket in the room on this
+// instance, a suspended one awaiting resume included
+func (sm *WebSocketManagerService) IsUserInRoom(roomID, userID uuid.UUID) bool {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	roomMeta, exists := sm.roomMetadata[roomID]
+	if !exists {
+		return false
+	}
+
+	_, inRoom := roomMeta.Users[userID]
+	return inRoom
+}
+
+// The room's sockets with the user each belongs to
+func (sm *WebSocketManagerService) GetRoomSockets(roomID uuid.UUID) map[string]uuid.UUID {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	roomMeta, exists := sm.roomMetadata[roomID]
+	if !exists {
+		return map[string]uuid.UUID{}
+	}
+
+	sockets := make(map[string]uuid.UUID, len(roomMeta.SocketIDs))
+	for socketID := range roomMeta.SocketIDs {
+		sockets[socketID] = sm.socketIdToUserId[socketID]
+	}
+	return sockets
+}
+
+// IDs of the rooms that currently hold sockets
+func (sm *WebSocketManagerService) GetActiveRoomIDs() []uuid.UUID {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	roomIDs := make([]uuid.UUID, 0, len(sm.roomMetadata))
+	for roomID := range sm.roomMetadata {
+		roomIDs = append(roomIDs, roomID)
+	}
+	return roomIDs
+}
+
+// Number of open connections, in a room or not
+func (sm *WebSocketManagerService) GetConnectedSocketCount() int {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return len(sm.socketWriters)
+}
+
+// Number of dropped sockets waiting for RESUME
+func (sm *WebSocketManagerService) GetSuspendedSocketCount() int {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return len(sm.suspended)
+}
+
 // Get room user count
 func (sm *WebSocketManagerService) GetRoomUserCount(roomID uuid.UUID) int {
 	sm.mu.RLock()
@@ -1,7 +1,5 @@
 package constants
 
-import "time"
-
 type contextKey string
 
 const SessionContextKey contextKey = "session"
@@ -10,4 +8,10 @@ type validatedKey string
 
 const ValidatedContextKey validatedKey = "validated"
 
-var SessionDuration = 24 * time.Hour
+type requestIDKey string
+
+const RequestIDContextKey requestIDKey = "request_id"
+
+type csrfTokenKey string
+
+const CSRFTokenContextKey csrfTokenKey = "csrf_token"
@@ -1,26 +1,27 @@
 package validators
 
 import (
-	"fmt"
+	"context"
+	"log/slog"
 
 	"github.com/go-playground/validator/v10"
 )
 
 type Repository[T any] interface {
-	FindByField(fields map[string]any) (*T, error)
+	FindByField(ctx context.Context, fields map[string]any) (*T, error)
 }
 
 // @Todo more generic
-// It checks in a db
-func Unique[T any](repo Repository[T]) func(fl validator.FieldLevel) bool {
-	return func(fl validator.FieldLevel) bool {
+// It checks in a db, register it with RegisterValidationCtx
+func Unique[T any](repo Repository[T]) validator.FuncCtx {
+	return func(ctx context.Context, fl validator.FieldLevel) bool {
 
-		record, err := repo.FindByField(map[string]any{
+		record, err := repo.FindByField(ctx, map[string]any{
 			fl.FieldName(): fl.Field().String(),
 		})
 
 		if err != nil {
-			fmt.Println("err", err)
+			slog.Error("unique validation lookup failed", "field", fl.FieldName(), "err", err)
 			panic("error !")
 		}
 
@@ -32,7 +33,6 @@ func Unique[T any](repo Repository[T]) func(fl validator.FieldLevel) bool {
 func UniqueItem(fl validator.FieldLevel) bool {
 	items, ok := fl.Field().Interface().([]string)
 
-	fmt.Println(items)
 	if !ok {
 		return false
 	}
@@ -0,0 +1,27 @@
+package validators
+
+import (
+	"reflect"
+
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/go-playground/validator/v10"
+)
+
+// New returns the validator for request bodies and websocket payloads with
+// our custom tags, errors are reported under the fields' json names
+func New(users Repository[models.User]) *validator.Validate {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+
+	validate.RegisterTagNameFunc(func(fld reflect.StructField) string {
+		return fld.Tag.Get("json")
+	})
+
+	validate.RegisterValidationCtx("unique", Unique(users))
+	validate.RegisterValidation("gender", Gender)
+	validate.RegisterValidation("date", Date)
+	validate.RegisterValidation("dob", Dob)
+	validate.RegisterValidation("username", Username)
+	validate.RegisterValidation("roomtitle", RoomTitle)
+
+	return validate
+}
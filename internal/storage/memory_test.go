@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryGet(t *testing.T) {
+	store := NewMemory("/files", []byte("key"))
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "videos/a.txt", strings.NewReader("hello world"), 11, "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	r, err := store.Get(ctx, "videos/a.txt")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer r.Close()
+
+	seeker, ok := r.(io.Seeker)
+	if !ok {
+		t.Fatal("reader does not implement io.Seeker")
+	}
+
+	if _, err := seeker.Seek(6, io.SeekStart); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil || string(data) != "world" {
+		t.Errorf("read %q, %v, want world", data, err)
+	}
+
+	if _, err := store.Get(ctx, "videos/missing.txt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemoryDelete(t *testing.T) {
+	store := NewMemory("/files", []byte("key"))
+	ctx := context.Background()
+
+	for _, key := range []string{"videos/1/a", "videos/1/b", "videos/2/a"} {
+		if err := store.Put(ctx, key, strings.NewReader(key), int64(len(key)), "text/plain"); err != nil {
+			t.Fatalf("Put %s: %v", key, err)
+		}
+	}
+
+	if err := store.Delete(ctx, "videos/2/a"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Get(ctx, "videos/2/a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleted object: err = %v, want ErrNotFound", err)
+	}
+
+	// Deleting what isn't there is not an error
+	if err := store.Delete(ctx, "videos/2/a"); err != nil {
+		t.Errorf("Delete again: %v", err)
+	}
+
+	if err := store.DeletePrefix(ctx, "videos/1/"); err != nil {
+		t.Fatalf("DeletePrefix: %v", err)
+	}
+	for _, key := range []string{"videos/1/a", "videos/1/b"} {
+		if _, err := store.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: err = %v, want ErrNotFound", key, err)
+		}
+	}
+}
+
+func TestMemoryServeHTTP(t *testing.T) {
+	store := NewMemory("/files", []byte("key"))
+	ctx := context.Background()
+
+	if err := store.Put(ctx, PrivatePrefix+"a.txt", strings.NewReader("hello world"), 11, "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	signed, err := store.SignedURL(ctx, PrivatePrefix+"a.txt", time.Minute)
+	if err != nil {
+		t.Fatalf("SignedURL: %v", err)
+	}
+
+	serve := func(target string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", strings.TrimPrefix(target, "/files"), nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+
+		recorder := httptest.NewRecorder()
+		store.ServeHTTP(recorder, req)
+
+		return recorder
+	}
+
+	if got := serve("/files/"+PrivatePrefix+"a.txt", nil).Code; got != http.StatusForbidden {
+		t.Errorf("unsigned: got %d, want 403", got)
+	}
+
+	resp := serve(signed, http.Header{"Range": {"bytes=6-"}})
+	if resp.Code != http.StatusPartialContent || resp.Body.String() != "world" {
+		t.Errorf("signed range: got %d %q, want 206 world", resp.Code, resp.Body.String())
+	}
+
+	if got := serve("/files/missing.txt", nil).Code; got != http.StatusNotFound {
+		t.Errorf("missing: got %d, want 404", got)
+	}
+}
@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/dliluashvili/cowatchit/internal/config"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// Objects are never rewritten under the same key, browsers may keep them
+const s3CacheControl = "public, max-age=31536000, immutable"
+
+// S3 keeps objects in a bucket of any S3-compatible service. Everything
+// but PrivatePrefix must be readable by the public for URL to load.
+type S3 struct {
+	client    *minio.Client
+	bucket    string
+	publicURL string
+}
+
+func NewS3(cfg config.StorageConfig) (*S3, error) {
+	client, err := minio.New(cfg.S3Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.S3AccessKeyID, cfg.S3SecretAccessKey, ""),
+		Secure: cfg.S3UseSSL,
+		Region: cfg.S3Region,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 client: %w", err)
+	}
+
+	publicURL := cfg.S3PublicURL
+	if publicURL == "" {
+		scheme := "https"
+		if !cfg.S3UseSSL {
+			scheme = "http"
+		}
+		publicURL = fmt.Sprintf("%s://%s/%s", scheme, cfg.S3Endpoint, cfg.S3Bucket)
+	}
+
+	return &S3{
+		client:    client,
+		bucket:    cfg.S3Bucket,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+	}, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{
+		ContentType:  contentType,
+		CacheControl: s3CacheControl,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to upload object: %w", err)
+	}
+
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+
+	object, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+
+	// The request is only made on first use, Stat makes it now
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrNotFound
+		}
+
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+
+	return object, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+
+	if err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+
+	return nil
+}
+
+func (s *S3) DeletePrefix(ctx context.Context, prefix string) error {
+	if err := validPrefix(prefix); err != nil {
+		return err
+	}
+
+	objects := s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true})
+
+	// Listing errors arrive as objects, they are not removed but fail the call
+	var listErr error
+	keys := make(chan minio.ObjectInfo)
+
+	go func() {
+		defer close(keys)
+
+		for object := range objects {
+			if object.Err != nil {
+				listErr = object.Err
+				continue
+			}
+
+			keys <- object
+		}
+	}()
+
+	// The error channel is drained to the end, the removal stops when keys do
+	var err error
+
+	for removeErr := range s.client.RemoveObjects(ctx, s.bucket, keys, minio.RemoveObjectsOptions{}) {
+		if removeErr.Err != nil && err == nil {
+			err = fmt.Errorf("failed to delete object %q: %w", removeErr.ObjectName, removeErr.Err)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if listErr != nil {
+		return fmt.Errorf("failed to list objects: %w", listErr)
+	}
+
+	return nil
+}
+
+func (s *S3) URL(key string) string {
+	return s.publicURL + "/" + key
+}
+
+// SignedURL is presigned by the service, it works on private buckets too
+func (s *S3) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	if err := validKey(key); err != nil {
+		return "", err
+	}
+
+	signed, err := s.client.PresignedGetObject(ctx, s.bucket, key, expiry, nil)
+
+	if err != nil {
+		return "", fmt.Errorf("failed to presign object: %w", err)
+	}
+
+	return signed.String(), nil
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signer makes and checks expiring links. The local and memory stores sign
+// theirs with it, the S3 store presigns with the service instead.
+type Signer struct {
+	key []byte
+}
+
+// NewSigner signs with key, or with a random one when key is empty. Links
+// signed before a restart then stop working, which only matters when they
+// outlive it.
+func NewSigner(key []byte) Signer {
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+
+	return Signer{key: key}
+}
+
+func (s Signer) signature(key string, expires int64) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(key + "\n" + strconv.FormatInt(expires, 10)))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sign returns the query parameters of a signature of key good until
+// expires, a unix time
+func (s Signer) Sign(key string, expires int64) url.Values {
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", s.signature(key, expires))
+
+	return query
+}
+
+// SignedURL is baseURL/key with a signature good until expires
+func (s Signer) SignedURL(baseURL, key string, expires int64) string {
+	return baseURL + "/" + key + "?" + s.Sign(key, expires).Encode()
+}
+
+// Verify tells whether query carries a signature of key that hasn't expired
+func (s Signer) Verify(query url.Values, key string) bool {
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+
+	return hmac.Equal([]byte(query.Get("signature")), []byte(s.signature(key, expires)))
+}
+
+// allowed tells whether r may read key. Public keys need no signature, a
+// wrong or expired one is refused all the same.
+func (s Signer) allowed(r *http.Request, key string) bool {
+	query := r.URL.Query()
+
+	if !query.Has("signature") {
+		return !strings.HasPrefix(key, PrivatePrefix)
+	}
+
+	return s.Verify(query, key)
+}
+
+// serveKey is the key a store's handler was asked for, the router strips
+// the base URL
+func serveKey(w http.ResponseWriter, r *http.Request, s Signer) (string, bool) {
+	key := strings.TrimPrefix(r.URL.Path, "/")
+
+	if validKey(key) != nil {
+		http.NotFound(w, r)
+		return "", false
+	}
+
+	if !s.allowed(r, key) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return "", false
+	}
+
+	return key, true
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSignerVerify(t *testing.T) {
+	signer := NewSigner([]byte("key"))
+	expires := time.Now().Add(time.Minute).Unix()
+	query := signer.Sign("private/a.mp4", expires)
+
+	if !signer.Verify(query, "private/a.mp4") {
+		t.Error("a fresh signature was refused")
+	}
+
+	if signer.Verify(query, "private/b.mp4") {
+		t.Error("a signature was accepted for another key")
+	}
+
+	if NewSigner([]byte("other")).Verify(query, "private/a.mp4") {
+		t.Error("a signature was accepted under another signing key")
+	}
+
+	tampered := signer.Sign("private/a.mp4", expires)
+	tampered.Set("expires", "9999999999")
+	if signer.Verify(tampered, "private/a.mp4") {
+		t.Error("a signature was accepted with a changed expiry")
+	}
+}
+
+func TestSignerVerifyExpired(t *testing.T) {
+	signer := NewSigner([]byte("key"))
+	query := signer.Sign("private/a.mp4", time.Now().Add(-time.Second).Unix())
+
+	if signer.Verify(query, "private/a.mp4") {
+		t.Error("an expired signature was accepted")
+	}
+
+	query.Del("expires")
+	if signer.Verify(query, "private/a.mp4") {
+		t.Error("a signature without an expiry was accepted")
+	}
+}
+
+func TestSignerRandomKey(t *testing.T) {
+	query := NewSigner(nil).Sign("private/a.mp4", time.Now().Add(time.Minute).Unix())
+
+	if NewSigner(nil).Verify(query, "private/a.mp4") {
+		t.Error("two random keys signed alike")
+	}
+}
+
+func TestSignerAllowed(t *testing.T) {
+	signer := NewSigner([]byte("key"))
+	signed := signer.SignedURL("http://example.com", "private/a.mp4", time.Now().Add(time.Minute).Unix())
+
+	tests := []struct {
+		name string
+		url  string
+		key  string
+		want bool
+	}{
+		{"public without signature", "/avatars/a.webp", "avatars/a.webp", true},
+		{"private without signature", "/private/a.mp4", "private/a.mp4", false},
+		{"private with signature", signed, "private/a.mp4", true},
+		{"public with a wrong signature", "/avatars/a.webp?expires=9999999999&signature=00", "avatars/a.webp", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := signer.allowed(httptest.NewRequest("GET", tt.url, nil), tt.key); got != tt.want {
+				t.Errorf("allowed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
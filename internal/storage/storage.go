@@ -0,0 +1,89 @@
+// Package storage keeps uploaded files, on local disk, in an S3-compatible
+// bucket or in memory
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/dliluashvili/cowatchit/internal/config"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// Keys under this prefix are only served through signed URLs
+const PrivatePrefix = "private/"
+
+// Store holds objects under slash separated keys like avatars/<id>/<name>.webp
+type Store interface {
+	// Put stores size bytes read from r under key, replacing what was there
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens key for reading, ErrNotFound when it doesn't exist. Every
+	// driver's reader also implements io.Seeker.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes key, a missing key is not an error
+	Delete(ctx context.Context, key string) error
+	// DeletePrefix removes every key under prefix, which ends in a slash
+	DeletePrefix(ctx context.Context, prefix string) error
+	// URL is where browsers load public objects from
+	URL(key string) string
+	// SignedURL is a link to key, private or not, that stops working after expiry
+	SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+}
+
+// New opens the store cfg.Driver names
+func New(cfg config.StorageConfig) (Store, error) {
+	signingKey := []byte(cfg.SigningKey)
+
+	switch cfg.Driver {
+	case config.StorageLocal:
+		return NewLocal(cfg.LocalDir, cfg.LocalURL, signingKey)
+	case config.StorageMemory:
+		return NewMemory(cfg.LocalURL, signingKey), nil
+	case config.StorageS3:
+		return NewS3(cfg)
+	}
+
+	return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+}
+
+// KeyOf returns the key of an object of store from its URL, false when the
+// URL points elsewhere
+func KeyOf(store Store, url string) (string, bool) {
+	prefix := store.URL("")
+
+	if url == "" || !strings.HasPrefix(url, prefix) {
+		return "", false
+	}
+
+	return strings.TrimPrefix(url, prefix), true
+}
+
+// validPrefix refuses prefixes validKey would and ones that aren't a
+// whole path segment
+func validPrefix(prefix string) error {
+	if !strings.HasSuffix(prefix, "/") || validKey(strings.TrimSuffix(prefix, "/")) != nil {
+		return fmt.Errorf("invalid prefix %q", prefix)
+	}
+
+	return nil
+}
+
+// validKey refuses keys that could step out of the store's root
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return fmt.Errorf("invalid key %q", key)
+	}
+
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("invalid key %q", key)
+		}
+	}
+
+	return nil
+}
@@ -8,7 +8,9 @@ package templates
 import "github.com/a-h/templ"
 import templruntime "github.com/a-h/templ/runtime"
 
-func CreateRoomPage() templ.Component {
+import "github.com/dliluashvili/cowatchit/internal/models"
+
+func CreateRoomPage(videos []models.LibraryItem) templ.Component {
 	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
@@ -41,7 +43,7 @@ func CreateRoomPage() templ.Component {
 				}()
 			}
 			ctx = templ.InitializeContext(ctx)
-			templ_7745c5c3_Err = CreateRoom().Render(ctx, templ_7745c5c3_Buffer)
+			templ_7745c5c3_Err = CreateRoom(videos).Render(ctx, templ_7745c5c3_Buffer)
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
@@ -55,7 +57,8 @@ func CreateRoomPage() templ.Component {
 	})
 }
 
-func CreateRoom() templ.Component {
+// videos are the host's library items ready to play
+func CreateRoom(videos []models.LibraryItem) templ.Component {
 	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
@@ -76,7 +79,53 @@ func CreateRoom() templ.Component {
 			templ_7745c5c3_Var3 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<div class=\"min-h-screen p-6\"><div class=\"max-w-2xl mx-auto\"><!-- Header --><div class=\"flex items-center mb-8\"><button hx-get=\"/rooms\" hx-push-url=\"true\" hx-target=\"#rooms-container\" hx-swap=\"innerHTML\" class=\"btn btn-ghost text-white hover:bg-white/10 mr-4\"><svg class=\"w-4 h-4 mr-2\" xmlns=\"http://www.w3.org/2000/svg\" fill=\"none\" viewBox=\"0 0 24 24\" stroke=\"currentColor\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M10 19l-7-7m0 0l7-7m-7 7h18\"></path></svg> Back to Dashboard</button><div class=\"bg-white/10 backdrop-blur-sm rounded-full p-2 mr-4\"><svg class=\"h-8 w-8 text-purple-400\" xmlns=\"http://www.w3.org/2000/svg\" fill=\"none\" viewBox=\"0 0 24 24\" stroke=\"currentColor\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M12 4v16m8-8H4\"></path></svg></div><div><h1 class=\"text-white\">Create Room</h1><p class=\"text-white/70\">Set up a new room for watching together</p></div></div><div class=\"card glass-card\"><div class=\"card-body\"><h2 class=\"card-title text-white flex items-center\"><svg class=\"w-5 h-5 mr-2\" xmlns=\"http://www.w3.org/2000/svg\" fill=\"none\" viewBox=\"0 0 24 24\" stroke=\"currentColor\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M7 4v16M17 4v16M3 8h4m10 0h4M3 12h18M3 16h4m10 0h4M4 20h16a1 1 0 001-1V5a1 1 0 00-1-1H4a1 1 0 00-1 1v14a1 1 0 001 1z\"></path></svg> Room Details</h2><p class=\"text-white/70\">Fill in the details for your room</p><form id=\"create-room-form\"><!-- Title --><div class=\"grid grid-cols-1 md:grid-cols-12 gap-4\"><!-- Title (takes 8 columns) --><div class=\"form-control md:col-span-8\"><label class=\"label\" for=\"title\"><span class=\"label-text text-white\">Title *</span></label> <input type=\"text\" id=\"title\" name=\"title\" placeholder=\"Enter the movie title\" class=\"input input-sm glass-input w-full\"> <label class=\"label hidden px-1 py-0 mt-1\"><span class=\"label-text-alt text-error input-error\"></span></label></div><!-- Capacity (takes 4 columns) --><div class=\"form-control md:col-span-4\"><label class=\"label\" for=\"capacity\"><span class=\"label-text text-white flex items-center\"><svg class=\"w-4 h-4 mr-2\" xmlns=\"http://www.w3.org/2000/svg\" fill=\"none\" viewBox=\"0 0 24 24\" stroke=\"currentColor\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M12 4.354a4 4 0 110 5.292M15 21H3v-1a6 6 0 0112 0v1zm0 0h6v-1a6 6 0 00-9-5.197M13 7a4 4 0 11-8 0 4 4 0 018 0z\"></path></svg> Capacity *</span></label> <input type=\"number\" id=\"capacity\" name=\"capacity\" min=\"2\" max=\"10\" value=\"2\" class=\"input input-sm glass-input w-full\"> <label class=\"label hidden px-1 py-0 mt-1\"><span class=\"label-text-alt text-error input-error\"></span></label></div></div><!-- Video Link --><div class=\"form-control\"><label class=\"label\" for=\"src\"><span class=\"label-text text-white flex items-center\"><svg class=\"w-4 h-4 mr-2\" xmlns=\"http://www.w3.org/2000/svg\" fill=\"none\" viewBox=\"0 0 24 24\" stroke=\"currentColor\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M13.828 10.172a4 4 0 00-5.656 0l-4 4a4 4 0 105.656 5.656l1.102-1.101m-.758-4.899a4 4 0 005.656 0l4-4a4 4 0 00-5.656-5.656l-1.1 1.1\"></path></svg> Video Link *</span></label> <input type=\"url\" id=\"src\" name=\"src\" placeholder=\"https://example.com/movie-link\" class=\"input input-sm glass-input w-full\"> <label class=\"label hidden px-1 py-0 mt-1\"><span class=\"label-text-alt text-error input-error\"></span></label></div><!-- Poster --><div class=\"form-control\"><label class=\"label\" for=\"poster\"><span class=\"label-text text-white flex items-center\"><svg class=\"w-4 h-4 mr-2\" xmlns=\"http://www.w3.org/2000/svg\" fill=\"none\" viewBox=\"0 0 24 24\" stroke=\"currentColor\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M4 16l4.586-4.586a2 2 0 012.828 0L16 16m-2-2l1.586-1.586a2 2 0 012.828 0L20 14m-6-6h.01M6 20h12a2 2 0 002-2V6a2 2 0 00-2-2H6a2 2 0 00-2 2v12a2 2 0 002 2z\"></path></svg> Movie Poster URL</span></label> <input type=\"url\" id=\"poster\" name=\"poster\" placeholder=\"https://example.com/poster.jpg\" class=\"input input-sm glass-input w-full\" hx-get=\"/rooms/preview-poster\" hx-trigger=\"change\" hx-target=\"#poster-preview\" hx-include=\"[name='poster']\"> <label class=\"label hidden px-1 py-0 mt-1\"><span class=\"label-text-alt text-error input-error\"></span></label></div><!-- Description --><div class=\"form-control\"><label class=\"label\" for=\"description\"><span class=\"label-text text-white\">Description</span></label> <textarea id=\"description\" name=\"description\" placeholder=\"Describe the movie or add any notes for participants...\" class=\"textarea glass-input w-full min-h-[80px] py-1.5 leading-snug\" rows=\"2\"></textarea> <label class=\"label hidden px-1 py-0 mt-1\"><span class=\"label-text-alt text-error input-error\"></span></label></div><!-- Privacy Settings --><div class=\"space-y-4 mt-6\"><div class=\"flex items-center justify-between p-4 bg-white/5 rounded-lg border border-white/10\"><div class=\"flex items-center gap-3\"><!-- Public Icon (Globe) --><svg class=\"w-5 h-5 text-blue-400 flex-shrink-0\" id=\"privacy-icon-public\" xmlns=\"http://www.w3.org/2000/svg\" fill=\"none\" viewBox=\"0 0 24 24\" stroke=\"currentColor\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M3.055 11H5a2 2 0 012 2v1a2 2 0 002 2 2 2 0 012 2v2.945M8 3.935V5.5A2.5 2.5 0 0010.5 8h.5a2 2 0 012 2 2 2 0 104 0 2 2 0 012-2h1.064M15 20.488V18a2 2 0 012-2h3.064M21 12a9 9 0 11-18 0 9 9 0 0118 0z\"></path></svg><!-- Private Icon (Lock) --><svg class=\"w-5 h-5 text-yellow-400 hidden flex-shrink-0\" id=\"privacy-icon-private\" xmlns=\"http://www.w3.org/2000/svg\" fill=\"none\" viewBox=\"0 0 24 24\" stroke=\"currentColor\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M12 15v2m-6 4h12a2 2 0 002-2v-6a2 2 0 00-2-2H6a2 2 0 00-2 2v6a2 2 0 002 2zm10-10V7a4 4 0 00-8 0v4h8z\"></path></svg><div><label class=\"label cursor-pointer p-0 mb-1\"><span class=\"label-text text-white\" id=\"privacy-label\">Public Room</span></label><p class=\"text-sm text-white/70\" id=\"privacy-description\">Anyone can join this room</p></div></div><input type=\"checkbox\" name=\"private\" class=\"toggle toggle-primary flex-shrink-0\"></div><div id=\"password-section\" class=\"hidden\"><div class=\"form-control\"><label for=\"password\" class=\"label\"><span class=\"label-text text-white flex items-center\"><svg class=\"w-4 h-4 mr-2\" xmlns=\"http://www.w3.org/2000/svg\" fill=\"none\" viewBox=\"0 0 24 24\" stroke=\"currentColor\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M12 15v2m-6 4h12a2 2 0 002-2v-6a2 2 0 00-2-2H6a2 2 0 00-2 2v6a2 2 0 002 2zm10-10V7a4 4 0 00-8 0v4h8z\"></path></svg> Room Password *</span></label> <input id=\"password\" name=\"password\" type=\"password\" placeholder=\"Choose a password that participants will need to join\" class=\"input input-sm glass-input w-full\"> <label class=\"label hidden px-1 py-0 mt-1\"><span class=\"label-text-alt text-error input-error\"></span></label></div></div></div><!-- Poster Preview --><div id=\"poster-preview\"></div><!-- Submit Buttons --><div class=\"flex gap-4 pt-4\"><button type=\"button\" hx-get=\"/rooms\" hx-push-url=\"true\" hx-target=\"#rooms-container\" hx-swap=\"innerHTML\" class=\"btn btn-sm flex-1 border-white/20 text-white hover:bg-white/10 hover:text-white bg-transparent\">Cancel</button> <button type=\"submit\" class=\"btn btn-sm btn-primary glass-primary flex-1\"><svg class=\"w-4 h-4 mr-2\" xmlns=\"http://www.w3.org/2000/svg\" fill=\"none\" viewBox=\"0 0 24 24\" stroke=\"currentColor\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M7 4v16M17 4v16M3 8h4m10 0h4M3 12h18M3 16h4m10 0h4M4 20h16a1 1 0 001-1V5a1 1 0 00-1-1H4a1 1 0 00-1 1v14a1 1 0 001 1z\"></path></svg> <span id=\"submit-text\">Create Public Room</span></button></div></form></div></div></div></div>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<div class=\"min-h-screen p-6\"><div class=\"max-w-2xl mx-auto\"><!-- Header --><div class=\"flex items-center mb-8\"><button hx-get=\"/rooms\" hx-push-url=\"true\" hx-target=\"#rooms-container\" hx-swap=\"innerHTML\" class=\"btn btn-ghost text-white hover:bg-white/10 mr-4\"><svg class=\"w-4 h-4 mr-2\" xmlns=\"http://www.w3.org/2000/svg\" fill=\"none\" viewBox=\"0 0 24 24\" stroke=\"currentColor\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M10 19l-7-7m0 0l7-7m-7 7h18\"></path></svg> Back to Dashboard</button><div class=\"bg-white/10 backdrop-blur-sm rounded-full p-2 mr-4\"><svg class=\"h-8 w-8 text-purple-400\" xmlns=\"http://www.w3.org/2000/svg\" fill=\"none\" viewBox=\"0 0 24 24\" stroke=\"currentColor\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M12 4v16m8-8H4\"></path></svg></div><div><h1 class=\"text-white\">Create Room</h1><p class=\"text-white/70\">Set up a new room for watching together</p></div></div><div class=\"card glass-card\"><div class=\"card-body\"><h2 class=\"card-title text-white flex items-center\"><svg class=\"w-5 h-5 mr-2\" xmlns=\"http://www.w3.org/2000/svg\" fill=\"none\" viewBox=\"0 0 24 24\" stroke=\"currentColor\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M7 4v16M17 4v16M3 8h4m10 0h4M3 12h18M3 16h4m10 0h4M4 20h16a1 1 0 001-1V5a1 1 0 00-1-1H4a1 1 0 00-1 1v14a1 1 0 001 1z\"></path></svg> Room Details</h2><p class=\"text-white/70\">Fill in the details for your room</p><form id=\"create-room-form\"><!-- Title --><div class=\"grid grid-cols-1 md:grid-cols-12 gap-4\"><!-- Title (takes 8 columns) --><div class=\"form-control md:col-span-8\"><label class=\"label\" for=\"title\"><span class=\"label-text text-white\">Title *</span></label> <input type=\"text\" id=\"title\" name=\"title\" placeholder=\"Enter the movie title\" class=\"input input-sm glass-input w-full\"> <label class=\"label hidden px-1 py-0 mt-1\"><span class=\"label-text-alt text-error input-error\"></span></label></div><!-- Capacity (takes 4 columns) --><div class=\"form-control md:col-span-4\"><label class=\"label\" for=\"capacity\"><span class=\"label-text text-white flex items-center\"><svg class=\"w-4 h-4 mr-2\" xmlns=\"http://www.w3.org/2000/svg\" fill=\"none\" viewBox=\"0 0 24 24\" stroke=\"currentColor\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M12 4.354a4 4 0 110 5.292M15 21H3v-1a6 6 0 0112 0v1zm0 0h6v-1a6 6 0 00-9-5.197M13 7a4 4 0 11-8 0 4 4 0 018 0z\"></path></svg> Capacity *</span></label> <input type=\"number\" id=\"capacity\" name=\"capacity\" min=\"2\" max=\"10\" value=\"2\" class=\"input input-sm glass-input w-full\"> <label class=\"label hidden px-1 py-0 mt-1\"><span class=\"label-text-alt text-error input-error\"></span></label></div></div><!-- Video Link --><div class=\"form-control\"><label class=\"label\" for=\"src\"><span class=\"label-text text-white flex items-center\"><svg class=\"w-4 h-4 mr-2\" xmlns=\"http://www.w3.org/2000/svg\" fill=\"none\" viewBox=\"0 0 24 24\" stroke=\"currentColor\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M13.828 10.172a4 4 0 00-5.656 0l-4 4a4 4 0 105.656 5.656l1.102-1.101m-.758-4.899a4 4 0 005.656 0l4-4a4 4 0 00-5.656-5.656l-1.1 1.1\"></path></svg> Video Link *</span></label> <input type=\"url\" id=\"src\" name=\"src\" placeholder=\"https://example.com/movie-link\" class=\"input input-sm glass-input w-full\"> <label class=\"label hidden px-1 py-0 mt-1\"><span class=\"label-text-alt text-error input-error\"></span></label></div>")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		if len(videos) > 0 {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "<!-- Library Video --> <div class=\"form-control\"><label class=\"label\" for=\"library_item_id\"><span class=\"label-text text-white\">Or play a video from your library</span></label> <select id=\"library_item_id\" name=\"library_item_id\" class=\"select select-sm glass-input w-full\"><option value=\"\">None, use the link</option> ")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			for _, video := range videos {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "<option value=\"")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				var templ_7745c5c3_Var4 string
+				templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinStringErrs(video.ID.String())
+				if templ_7745c5c3_Err != nil {
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/templates/create_room.templ`, Line: 121, Col: 43}
+				}
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var4))
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "\">")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				var templ_7745c5c3_Var5 string
+				templ_7745c5c3_Var5, templ_7745c5c3_Err = templ.JoinStringErrs(video.Title)
+				if templ_7745c5c3_Err != nil {
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/templates/create_room.templ`, Line: 121, Col: 59}
+				}
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var5))
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "</option>")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "</select> <label class=\"label hidden px-1 py-0 mt-1\"><span class=\"label-text-alt text-error input-error\"></span></label></div>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "<!-- Poster --><div class=\"form-control\"><label class=\"label\" for=\"poster\"><span class=\"label-text text-white flex items-center\"><svg class=\"w-4 h-4 mr-2\" xmlns=\"http://www.w3.org/2000/svg\" fill=\"none\" viewBox=\"0 0 24 24\" stroke=\"currentColor\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M4 16l4.586-4.586a2 2 0 012.828 0L16 16m-2-2l1.586-1.586a2 2 0 012.828 0L20 14m-6-6h.01M6 20h12a2 2 0 002-2V6a2 2 0 00-2-2H6a2 2 0 00-2 2v12a2 2 0 002 2z\"></path></svg> Movie Poster</span></label> <input type=\"file\" id=\"poster\" name=\"poster\" accept=\"image/jpeg,image/png,image/webp\" class=\"file-input file-input-sm glass-input w-full\" hx-post=\"/rooms/preview-poster\" hx-encoding=\"multipart/form-data\" hx-trigger=\"change\" hx-target=\"#poster-preview\" hx-include=\"this\"> <label class=\"label hidden px-1 py-0 mt-1\"><span class=\"label-text-alt text-error input-error\"></span></label></div><!-- Description --><div class=\"form-control\"><label class=\"label\" for=\"description\"><span class=\"label-text text-white\">Description</span></label> <textarea id=\"description\" name=\"description\" placeholder=\"Describe the movie or add any notes for participants...\" class=\"textarea glass-input w-full min-h-[80px] py-1.5 leading-snug\" rows=\"2\"></textarea> <label class=\"label hidden px-1 py-0 mt-1\"><span class=\"label-text-alt text-error input-error\"></span></label></div><!-- Privacy Settings --><div class=\"space-y-4 mt-6\"><div class=\"flex items-center justify-between p-4 bg-white/5 rounded-lg border border-white/10\"><div class=\"flex items-center gap-3\"><!-- Public Icon (Globe) --><svg class=\"w-5 h-5 text-blue-400 flex-shrink-0\" id=\"privacy-icon-public\" xmlns=\"http://www.w3.org/2000/svg\" fill=\"none\" viewBox=\"0 0 24 24\" stroke=\"currentColor\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M3.055 11H5a2 2 0 012 2v1a2 2 0 002 2 2 2 0 012 2v2.945M8 3.935V5.5A2.5 2.5 0 0010.5 8h.5a2 2 0 012 2 2 2 0 104 0 2 2 0 012-2h1.064M15 20.488V18a2 2 0 012-2h3.064M21 12a9 9 0 11-18 0 9 9 0 0118 0z\"></path></svg><!-- Private Icon (Lock) --><svg class=\"w-5 h-5 text-yellow-400 hidden flex-shrink-0\" id=\"privacy-icon-private\" xmlns=\"http://www.w3.org/2000/svg\" fill=\"none\" viewBox=\"0 0 24 24\" stroke=\"currentColor\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M12 15v2m-6 4h12a2 2 0 002-2v-6a2 2 0 00-2-2H6a2 2 0 00-2 2v6a2 2 0 002 2zm10-10V7a4 4 0 00-8 0v4h8z\"></path></svg><div><label class=\"label cursor-pointer p-0 mb-1\"><span class=\"label-text text-white\" id=\"privacy-label\">Public Room</span></label><p class=\"text-sm text-white/70\" id=\"privacy-description\">Anyone can join this room</p></div></div><input type=\"checkbox\" name=\"private\" class=\"toggle toggle-primary flex-shrink-0\"></div><div id=\"password-section\" class=\"hidden\"><div class=\"form-control\"><label for=\"password\" class=\"label\"><span class=\"label-text text-white flex items-center\"><svg class=\"w-4 h-4 mr-2\" xmlns=\"http://www.w3.org/2000/svg\" fill=\"none\" viewBox=\"0 0 24 24\" stroke=\"currentColor\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M12 15v2m-6 4h12a2 2 0 002-2v-6a2 2 0 00-2-2H6a2 2 0 00-2 2v6a2 2 0 002 2zm10-10V7a4 4 0 00-8 0v4h8z\"></path></svg> Room Password *</span></label> <input id=\"password\" name=\"password\" type=\"password\" placeholder=\"Choose a password that participants will need to join\" class=\"input input-sm glass-input w-full\"> <label class=\"label hidden px-1 py-0 mt-1\"><span class=\"label-text-alt text-error input-error\"></span></label></div></div></div><!-- Poster Preview --><div id=\"poster-preview\"></div><!-- Submit Buttons --><div class=\"flex gap-4 pt-4\"><button type=\"button\" hx-get=\"/rooms\" hx-push-url=\"true\" hx-target=\"#rooms-container\" hx-swap=\"innerHTML\" class=\"btn btn-sm flex-1 border-white/20 text-white hover:bg-white/10 hover:text-white bg-transparent\">Cancel</button> <button type=\"submit\" class=\"btn btn-sm btn-primary glass-primary flex-1\"><svg class=\"w-4 h-4 mr-2\" xmlns=\"http://www.w3.org/2000/svg\" fill=\"none\" viewBox=\"0 0 24 24\" stroke=\"currentColor\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M7 4v16M17 4v16M3 8h4m10 0h4M3 12h18M3 16h4m10 0h4M4 20h16a1 1 0 001-1V5a1 1 0 00-1-1H4a1 1 0 00-1 1v14a1 1 0 001 1z\"></path></svg> <span id=\"submit-text\">Create Public Room</span></button></div></form></div></div></div></div>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -84,4 +133,67 @@ func CreateRoom() templ.Component {
 	})
 }
 
+// PosterPreview shows the poster as it will be stored, or why it can't be
+func PosterPreview(src string, message string) templ.Component {
+	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
+			return templ_7745c5c3_CtxErr
+		}
+		templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+		if !templ_7745c5c3_IsBuffer {
+			defer func() {
+				templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+				if templ_7745c5c3_Err == nil {
+					templ_7745c5c3_Err = templ_7745c5c3_BufErr
+				}
+			}()
+		}
+		ctx = templ.InitializeContext(ctx)
+		templ_7745c5c3_Var6 := templ.GetChildren(ctx)
+		if templ_7745c5c3_Var6 == nil {
+			templ_7745c5c3_Var6 = templ.NopComponent
+		}
+		ctx = templ.ClearChildren(ctx)
+		if message != "" {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 8, "<p class=\"text-sm text-error\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var7 string
+			templ_7745c5c3_Var7, templ_7745c5c3_Err = templ.JoinStringErrs(message)
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/templates/create_room.templ`, Line: 253, Col: 41}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var7))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 9, "</p>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		} else {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 10, "<img src=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var8 string
+			templ_7745c5c3_Var8, templ_7745c5c3_Err = templ.JoinStringErrs(src)
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/templates/create_room.templ`, Line: 255, Col: 16}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var8))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 11, "\" alt=\"Poster preview\" class=\"w-24 h-36 object-cover rounded-lg\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		return nil
+	})
+}
+
 var _ = templruntime.GeneratedTemplate
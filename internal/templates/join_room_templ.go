@@ -184,7 +184,7 @@ func JoinRoom(room *models.Room) templ.Component {
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 12, "</p></div><!-- Join Button --><button type=\"submit\" class=\"btn w-full glass-primary mb-3\" id=\"join-btn\"><svg xmlns=\"http://www.w3.org/2000/svg\" class=\"w-5 h-5 mr-2\" viewBox=\"0 0 24 24\" fill=\"none\" stroke=\"currentColor\" stroke-width=\"2\" stroke-linecap=\"round\" stroke-linejoin=\"round\"><polygon points=\"5 3 19 12 5 21 5 3\"></polygon></svg> Start Watching</button><!-- Loading State --><div id=\"loading-state\" class=\"hidden text-center\"><div class=\"loading loading-spinner loading-md mx-auto mb-3\"></div><p class=\"text-white/70 text-sm\">Connecting to room...</p></div><!-- Back Button --><button type=\"button\" class=\"btn btn-outline w-full border-white/20 text-white hover:bg-white/10 bg-transparent\" onclick=\"history.back()\">Back to Rooms</button></div></div><!-- Room Full Notice -->")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 12, "</p></div><!-- Join Button --><button type=\"submit\" class=\"btn w-full glass-primary mb-3\" id=\"join-btn\"><svg xmlns=\"http://www.w3.org/2000/svg\" class=\"w-5 h-5 mr-2\" viewBox=\"0 0 24 24\" fill=\"none\" stroke=\"currentColor\" stroke-width=\"2\" stroke-linecap=\"round\" stroke-linejoin=\"round\"><polygon points=\"5 3 19 12 5 21 5 3\"></polygon></svg> Start Watching</button><!-- Loading State --><div id=\"loading-state\" class=\"hidden text-center\"><div class=\"loading loading-spinner loading-md mx-auto mb-3\"></div><p class=\"text-white/70 text-sm\">Connecting to room...</p></div><!-- Back Button --><button type=\"button\" class=\"btn btn-outline w-full border-white/20 text-white hover:bg-white/10 bg-transparent\" data-action=\"back\">Back to Rooms</button></div></div><!-- Room Full Notice -->")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -99,7 +99,7 @@ func JoiningView(roomId string) templ.Component {
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "</span></p></div></div><div class=\"mt-4 w-full\"><div class=\"flex items-center justify-center gap-2\"><div class=\"w-2 h-2 rounded-full bg-purple-400 animate-pulse\"></div><span class=\"text-white/50 text-xs\">Connecting...</span></div></div></div></div></div></div>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "</span></p></div></div><div class=\"mt-4 w-full\"><div class=\"flex items-center justify-center gap-2\"><div class=\"w-2 h-2 rounded-full bg-purple-400 animate-pulse\"></div><span class=\"text-white/50 text-xs\">Connecting...</span></div></div><!-- Shown when the room is private --><form id=\"room-password-form\" class=\"hidden mt-6 w-full text-left\"><label class=\"label\" for=\"room-password\"><span class=\"label-text text-white/80\">This room is private, enter its password</span></label> <input type=\"password\" id=\"room-password\" name=\"password\" maxlength=\"20\" required class=\"input input-bordered w-full bg-white/10 border-white/20 text-white\"><p id=\"room-password-error\" class=\"hidden text-red-400 text-sm mt-2\">Wrong password</p><button type=\"submit\" class=\"btn w-full glass-primary mt-4\">Join</button></form></div></div></div></div>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -136,13 +136,13 @@ func ErrorView(errorMessage string) templ.Component {
 		var templ_7745c5c3_Var6 string
 		templ_7745c5c3_Var6, templ_7745c5c3_Err = templ.JoinStringErrs(errorMessage)
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/templates/room.templ`, Line: 51, Col: 49}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/templates/room.templ`, Line: 67, Col: 49}
 		}
 		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var6))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "</p><div class=\"flex gap-2 w-full\"><button onclick=\"window.history.back()\" class=\"btn btn-sm btn-ghost text-white hover:bg-white/10 flex-1\">Go Back</button> <button onclick=\"location.reload()\" class=\"btn btn-sm btn-primary bg-purple-600 hover:bg-purple-700 border-0 flex-1\">Retry</button></div></div></div></div></div>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "</p><div class=\"flex gap-2 w-full\"><button data-action=\"back\" class=\"btn btn-sm btn-ghost text-white hover:bg-white/10 flex-1\">Go Back</button> <button data-action=\"reload\" class=\"btn btn-sm btn-primary bg-purple-600 hover:bg-purple-700 border-0 flex-1\">Retry</button></div></div></div></div></div>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -1,55 +1,42 @@
 package types
 
 import (
-	"encoding/json"
+	"log/slog"
 
 	"github.com/coder/websocket"
 	"github.com/dliluashvili/cowatchit/internal/models"
 	"github.com/google/uuid"
+	"golang.org/x/time/rate"
 )
 
-const (
-	TypeEvent = "EVENT"
-	TypeError = "ERROR"
-)
-
-const (
-	StateStop    = "STOP"
-	StatePaused  = "PAUSED"
-	StatePlaying = "PLAYING"
-	StateEnd     = "END"
-)
-
-const (
-	EventHostStateSend       = "HOST_STATE_SEND"
-	EventHostStateReceived   = "HOST_STATE_RECEIVED"
-	EventUserStateSend       = "USER_STATE_SEND"
-	EventUserStateReceived   = "USER_STATE_RECEIVED"
-	EventVideoPaused         = "VIDEO_PAUSED"
-	EventVideoPlaying        = "VIDEO_PLAYING"
-	EventChatMessageSend     = "CHAT_MESSAGE_SEND"
-	EventChatMessageReceived = "CHAT_MESSAGE_RECEIVED"
-	EventUserJoinRequest     = "USER_JOIN_REQUEST"
-	EventUserJoinAnswer      = "USER_JOIN_ANSWER"
-	EventRoomMessagesRequest = "ROOM_MESSAGES_REQUEST"
-	EventRoomMessagesAnswer  = "ROOM_MESSAGES_ANSWER"
-	EventUserJoint           = "USER_JOINT"
-	EventUserLeft            = "USER_LEFT"
-	EventIdentify            = "IDENTIFY"
-)
-
-type WSMessage struct {
-	Type  string          `json:"type"`
-	Event string          `json:"event,omitempty"`
-	Data  json.RawMessage `json:"data,omitempty"`
-}
-
 type WebSocketContext struct {
 	ID     string // socketId
 	User   *models.User
 	RoomID uuid.UUID // roomID
 	IsHost bool
 	Conn   *websocket.Conn // WebSocket connection
+	// Negotiated protocol version
+	ProtocolVersion int
+	// Per event limiters, only touched by the socket's read loop
+	RateLimiters map[string]*rate.Limiter
+	// Carries the request id and user fields of the upgrade request
+	Logger *slog.Logger
+}
+
+// Log returns the socket's logger tagged with its current socket and room
+func (c *WebSocketContext) Log() *slog.Logger {
+	logger := c.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	logger = logger.With("socket_id", c.ID)
+
+	if c.RoomID != uuid.Nil {
+		logger = logger.With("room_id", c.RoomID, "is_host", c.IsHost)
+	}
+
+	return logger
 }
 
 type UserInfo struct {
@@ -64,8 +51,10 @@ type RoomMetadata struct {
 	Capacity           int
 	State              string
 	CurrentTimeSeconds float64
-	HostUsername       string
-	HostID             uuid.UUID
-	SocketIDs          map[string]bool // set of socket IDs
-	Users              UserIDInfo      // set of user IDs with their info
+	// Length of the video, zero when unknown
+	DurationSeconds float64
+	HostUsername    string
+	HostID          uuid.UUID
+	SocketIDs       map[string]bool // set of socket IDs
+	Users           UserIDInfo      // set of user IDs with their info
 }
@@ -0,0 +1,223 @@
+package wsrouter
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"github.com/dliluashvili/cowatchit/internal/metrics"
+	"github.com/dliluashvili/cowatchit/internal/protocol"
+	"github.com/dliluashvili/cowatchit/internal/tracing"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
+)
+
+// Recoverer turns a panicking handler into an INTERNAL error
+func Recoverer() Middleware {
+	return func(route *Route, next HandlerFunc) HandlerFunc {
+		return func(req *Request) (err error) {
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					req.Socket.Log().Error("panic handling event",
+						"event", route.Event,
+						"panic", recovered,
+						"stack", string(debug.Stack()),
+					)
+					err = fmt.Errorf("panic: %v", recovered)
+				}
+			}()
+
+			return next(req)
+		}
+	}
+}
+
+// Logger logs every handled event with its outcome and duration,
+// at debug level unless it failed
+func Logger() Middleware {
+	return func(route *Route, next HandlerFunc) HandlerFunc {
+		return func(req *Request) error {
+			start := time.Now()
+
+			err := next(req)
+
+			level := slog.LevelDebug
+			attrs := []any{
+				"event", route.Event,
+				"ws_request_id", req.Message.RequestID,
+				"duration", time.Since(start),
+			}
+
+			if err != nil {
+				level = slog.LevelWarn
+				attrs = append(attrs, "err", err)
+
+				var protocolErr *protocol.Error
+				if errors.As(err, &protocolErr) {
+					level = slog.LevelInfo
+					attrs = append(attrs, "code", protocolErr.Code)
+				}
+			}
+
+			req.Socket.Log().Log(req.Context, level, "ws event", attrs...)
+
+			return err
+		}
+	}
+}
+
+// Tracing gives every event its own trace, linked to the connection's,
+// so a long lived socket doesn't collect all its events under one span
+func Tracing() Middleware {
+	return func(route *Route, next HandlerFunc) HandlerFunc {
+		return func(req *Request) error {
+			parent := req.Context
+
+			ctx, span := tracing.Tracer.Start(parent, "ws "+route.Event,
+				trace.WithNewRoot(),
+				trace.WithLinks(trace.LinkFromContext(parent)),
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("ws.event", route.Event),
+					attribute.String("ws.socket_id", req.Socket.ID),
+					attribute.String("ws.room_id", req.Socket.RoomID.String()),
+					attribute.String("ws.request_id", req.Message.RequestID),
+					attribute.String("enduser.id", req.Session.User.ID.String()),
+				),
+			)
+			defer span.End()
+
+			req.Context = ctx
+			defer func() { req.Context = parent }()
+
+			err := next(req)
+
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+
+			return err
+		}
+	}
+}
+
+// Metrics counts handled events by the code they ended with
+func Metrics() Middleware {
+	return func(route *Route, next HandlerFunc) HandlerFunc {
+		return func(req *Request) error {
+			err := next(req)
+
+			code := "OK"
+			if err != nil {
+				code = string(protocol.CodeInternal)
+
+				var protocolErr *protocol.Error
+				if errors.As(err, &protocolErr) {
+					code = string(protocolErr.Code)
+				}
+			}
+
+			metrics.WSEventsIn.WithLabelValues(route.Event, code).Inc()
+
+			return err
+		}
+	}
+}
+
+// RateLimit applies the route's per socket rate
+func RateLimit() Middleware {
+	return func(route *Route, next HandlerFunc) HandlerFunc {
+		if route.Options.Rate == 0 {
+			return next
+		}
+
+		return func(req *Request) error {
+			// Only the socket's read loop touches its limiters
+			if req.Socket.RateLimiters == nil {
+				req.Socket.RateLimiters = make(map[string]*rate.Limiter)
+			}
+
+			limiter, exists := req.Socket.RateLimiters[route.Event]
+			if !exists {
+				limiter = rate.NewLimiter(route.Options.Rate, max(route.Options.Burst, 1))
+				req.Socket.RateLimiters[route.Event] = limiter
+			}
+
+			if !limiter.Allow() {
+				metrics.RateLimited.WithLabelValues("ws", route.Event).Inc()
+				return protocol.Errorf(protocol.CodeRateLimited, "too many %s events", route.Event)
+			}
+
+			return next(req)
+		}
+	}
+}
+
+// Validate decodes the payload into the route's type and runs the validator on it
+func Validate(validate *validator.Validate) Middleware {
+	return func(route *Route, next HandlerFunc) HandlerFunc {
+		return func(req *Request) error {
+			payload := route.NewPayload()
+
+			if err := req.Message.Decode(payload); err != nil {
+				return protocol.Errorf(protocol.CodeInvalidPayload, "invalid payload")
+			}
+
+			if err := validate.StructCtx(req.Context, payload); err != nil {
+				return protocol.Errorf(protocol.CodeValidationFailed, "validation failed")
+			}
+
+			req.Payload = payload
+
+			return next(req)
+		}
+	}
+}
+
+type MembershipChecker interface {
+	IsUserAllowed(roomID, userID uuid.UUID, socketID string) bool
+}
+
+// Authorize enforces the route's access level and token scope, room scoped
+// payloads must target the socket's own room. Runs after Validate.
+func Authorize(membership MembershipChecker) Middleware {
+	return func(route *Route, next HandlerFunc) HandlerFunc {
+		return func(req *Request) error {
+			if route.Options.Scope != "" && !req.Session.HasScope(route.Options.Scope) {
+				return protocol.Errorf(protocol.CodeForbidden, "API token lacks the %s scope", route.Options.Scope)
+			}
+
+			switch route.Options.Access {
+			case AccessAdmin:
+				if !req.Session.User.IsAdmin {
+					return protocol.Errorf(protocol.CodeForbidden, "admins only")
+				}
+			case AccessMember, AccessHost:
+				if req.Socket.RoomID == uuid.Nil ||
+					!membership.IsUserAllowed(req.Socket.RoomID, req.Session.User.ID, req.Socket.ID) {
+					return protocol.Errorf(protocol.CodeNotInRoom, "not a member of this room")
+				}
+
+				if route.Options.Access == AccessHost && !req.Socket.IsHost {
+					return protocol.Errorf(protocol.CodeForbidden, "only the host can do this")
+				}
+			}
+
+			if scoped, ok := req.Payload.(protocol.RoomScoped); ok && route.Options.Access != AccessAdmin {
+				roomID, err := uuid.Parse(scoped.TargetRoomID())
+				if err != nil || roomID != req.Socket.RoomID {
+					return protocol.Errorf(protocol.CodeNotInRoom, "not a member of this room")
+				}
+			}
+
+			return next(req)
+		}
+	}
+}
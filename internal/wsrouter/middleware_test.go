@@ -0,0 +1,199 @@
+package wsrouter
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/dliluashvili/cowatchit/internal/protocol"
+	"github.com/dliluashvili/cowatchit/internal/types"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+type membership map[uuid.UUID]bool
+
+func (m membership) IsUserAllowed(roomID, userID uuid.UUID, socketID string) bool {
+	return m[userID]
+}
+
+type routerFixture struct {
+	router  *Router
+	members membership
+	// Codes of the errors sent back, in order
+	replies []protocol.ErrorCode
+	handled int
+}
+
+// A router with the middlewares the websocket handler uses, minus the
+// ones that only observe
+func newRouterFixture() *routerFixture {
+	f := &routerFixture{members: membership{}}
+
+	f.router = New(func(req *Request, msg *protocol.Message) error {
+		var protocolErr protocol.Error
+		if err := msg.Decode(&protocolErr); err != nil {
+			return err
+		}
+		f.replies = append(f.replies, protocolErr.Code)
+		return nil
+	})
+	f.router.Use(Recoverer(), RateLimit(), Validate(validator.New()), Authorize(f.members))
+
+	ok := func(*Request, *struct{}) error {
+		f.handled++
+		return nil
+	}
+
+	On(f.router, "ANY", Options{}, ok)
+	On(f.router, "ADMIN", Options{Access: AccessAdmin}, ok)
+	On(f.router, "SCOPED", Options{Scope: models.ScopeChatWrite}, ok)
+	On(f.router, "LIMITED", Options{Rate: 1, Burst: 2}, ok)
+	On(f.router, "CHAT", Options{Access: AccessMember}, func(*Request, *protocol.ChatMessageSend) error {
+		f.handled++
+		return nil
+	})
+	On(f.router, "STATE", Options{Access: AccessHost}, func(*Request, *protocol.HostStateSend) error {
+		f.handled++
+		return nil
+	})
+	On(f.router, "PANIC", Options{}, func(*Request, *struct{}) error {
+		panic("handler bug")
+	})
+
+	return f
+}
+
+// Dispatches the event and returns the error code it was answered with,
+// empty when the handler ran
+func (f *routerFixture) dispatch(t *testing.T, socket *types.WebSocketContext, session *models.Session, event string, data any) protocol.ErrorCode {
+	t.Helper()
+
+	raw, ok := data.(json.RawMessage)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	replies, handled := len(f.replies), f.handled
+
+	f.router.Dispatch(&Request{
+		Context: context.Background(),
+		Session: session,
+		Socket:  socket,
+		Message: &protocol.Message{Type: protocol.TypeEvent, Event: event, Data: raw},
+	})
+
+	switch {
+	case len(f.replies) > replies:
+		return f.replies[len(f.replies)-1]
+	case f.handled == handled:
+		t.Fatalf("%s was neither handled nor answered", event)
+	}
+
+	return ""
+}
+
+func testSocket(roomID uuid.UUID, isHost bool) *types.WebSocketContext {
+	return &types.WebSocketContext{
+		ID:     uuid.NewString(),
+		RoomID: roomID,
+		IsHost: isHost,
+		Logger: slog.New(slog.DiscardHandler),
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	roomID := uuid.New()
+	member := &models.Session{User: &models.User{ID: uuid.New()}}
+	outsider := &models.Session{User: &models.User{ID: uuid.New()}}
+	admin := &models.Session{User: &models.User{ID: uuid.New(), IsAdmin: true}}
+
+	tokenID := uuid.New()
+	readOnlyToken := &models.Session{User: member.User, APITokenID: &tokenID, Scopes: []string{models.ScopeChatRead}}
+	writeToken := &models.Session{User: member.User, APITokenID: &tokenID, Scopes: []string{models.ScopeChatWrite}}
+
+	chat := func(roomID string) protocol.ChatMessageSend {
+		return protocol.ChatMessageSend{RoomID: roomID, Content: "hi"}
+	}
+	state := protocol.HostStateSend{RoomID: roomID.String(), State: protocol.StatePlaying}
+
+	tests := []struct {
+		name    string
+		socket  *types.WebSocketContext
+		session *models.Session
+		event   string
+		data    any
+		want    protocol.ErrorCode
+	}{
+		{"member in their room", testSocket(roomID, false), member, "CHAT", chat(roomID.String()), ""},
+		{"payload for another room", testSocket(roomID, false), member, "CHAT", chat(uuid.NewString()), protocol.CodeNotInRoom},
+		{"not a member", testSocket(roomID, false), outsider, "CHAT", chat(roomID.String()), protocol.CodeNotInRoom},
+		{"socket in no room", testSocket(uuid.Nil, false), member, "CHAT", chat(uuid.Nil.String()), protocol.CodeNotInRoom},
+		{"host event from a guest", testSocket(roomID, false), member, "STATE", state, protocol.CodeForbidden},
+		{"host event from the host", testSocket(roomID, true), member, "STATE", state, ""},
+		{"admin event from a user", testSocket(uuid.Nil, false), member, "ADMIN", struct{}{}, protocol.CodeForbidden},
+		{"admin event from an admin", testSocket(uuid.Nil, false), admin, "ADMIN", struct{}{}, ""},
+		{"token without the scope", testSocket(uuid.Nil, false), readOnlyToken, "SCOPED", struct{}{}, protocol.CodeForbidden},
+		{"token with the scope", testSocket(uuid.Nil, false), writeToken, "SCOPED", struct{}{}, ""},
+		{"password session", testSocket(uuid.Nil, false), member, "SCOPED", struct{}{}, ""},
+		{"malformed payload", testSocket(roomID, false), member, "CHAT", json.RawMessage(`"hi"`), protocol.CodeInvalidPayload},
+		{"invalid payload", testSocket(roomID, false), member, "CHAT", chat("lobby"), protocol.CodeValidationFailed},
+		{"unknown event", testSocket(roomID, false), member, "NOPE", struct{}{}, protocol.CodeUnknownEvent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newRouterFixture()
+			f.members[member.User.ID] = true
+
+			if got := f.dispatch(t, tt.socket, tt.session, tt.event, tt.data); got != tt.want {
+				t.Errorf("answered %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	f := newRouterFixture()
+	session := &models.Session{User: &models.User{ID: uuid.New()}}
+	socket := testSocket(uuid.Nil, false)
+
+	steps := []struct {
+		name   string
+		socket *types.WebSocketContext
+		event  string
+		want   protocol.ErrorCode
+	}{
+		{"first of the burst", socket, "LIMITED", ""},
+		{"second of the burst", socket, "LIMITED", ""},
+		{"over the burst", socket, "LIMITED", protocol.CodeRateLimited},
+		{"unlimited event", socket, "ANY", ""},
+		{"another socket", testSocket(uuid.Nil, false), "LIMITED", ""},
+	}
+
+	for _, step := range steps {
+		if got := f.dispatch(t, step.socket, session, step.event, struct{}{}); got != step.want {
+			t.Errorf("%s: answered %q, want %q", step.name, got, step.want)
+		}
+	}
+}
+
+func TestRecoverer(t *testing.T) {
+	f := newRouterFixture()
+	session := &models.Session{User: &models.User{ID: uuid.New()}}
+	socket := testSocket(uuid.Nil, false)
+
+	if got := f.dispatch(t, socket, session, "PANIC", struct{}{}); got != protocol.CodeInternal {
+		t.Errorf("answered %q, want %q", got, protocol.CodeInternal)
+	}
+
+	// The socket keeps being served
+	if got := f.dispatch(t, socket, session, "ANY", struct{}{}); got != "" {
+		t.Errorf("after the panic: answered %q, want the event handled", got)
+	}
+}
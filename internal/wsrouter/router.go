@@ -0,0 +1,150 @@
+// Package wsrouter dispatches websocket events to the handlers registered for them
+package wsrouter
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/dliluashvili/cowatchit/internal/protocol"
+	"github.com/dliluashvili/cowatchit/internal/types"
+	"golang.org/x/time/rate"
+)
+
+// Access is who may send an event
+type Access int
+
+const (
+	// Any authenticated socket, in a room or not
+	AccessAny Access = iota
+	// Sockets registered in the room they act on
+	AccessMember
+	// The host of the room, through a registered socket
+	AccessHost
+	// Users flagged as admin
+	AccessAdmin
+)
+
+func (a Access) String() string {
+	switch a {
+	case AccessMember:
+		return "member"
+	case AccessHost:
+		return "host"
+	case AccessAdmin:
+		return "admin"
+	default:
+		return "any"
+	}
+}
+
+type Options struct {
+	Access Access
+	// Scope a personal API token needs, empty when any token may send the event
+	Scope string
+	// Events per second allowed per socket, zero means unlimited
+	Rate  rate.Limit
+	Burst int
+}
+
+type Request struct {
+	Context context.Context
+	Session *models.Session
+	Socket  *types.WebSocketContext
+	Message *protocol.Message
+	// Decoded and validated payload, set before the handler runs
+	Payload any
+}
+
+type HandlerFunc func(req *Request) error
+
+// Middleware wraps the handler of a route
+type Middleware func(route *Route, next HandlerFunc) HandlerFunc
+
+type Route struct {
+	Event   string
+	Options Options
+	// Allocates an empty payload to decode into
+	NewPayload func() any
+	handle     HandlerFunc
+}
+
+// Sends a message back to the socket a request came from
+type ReplyFunc func(req *Request, msg *protocol.Message) error
+
+type Router struct {
+	routes      map[string]*Route
+	middlewares []Middleware
+	reply       ReplyFunc
+}
+
+func New(reply ReplyFunc) *Router {
+	return &Router{
+		routes: make(map[string]*Route),
+		reply:  reply,
+	}
+}
+
+// Use appends middlewares, the first one added runs first
+func (r *Router) Use(middlewares ...Middleware) {
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+// On registers the handler of an event with a payload of type T
+func On[T any](r *Router, event string, opts Options, handle func(req *Request, payload *T) error) {
+	if _, exists := r.routes[event]; exists {
+		panic("wsrouter: duplicate route for " + event)
+	}
+
+	r.routes[event] = &Route{
+		Event:      event,
+		Options:    opts,
+		NewPayload: func() any { return new(T) },
+		handle: func(req *Request) error {
+			return handle(req, req.Payload.(*T))
+		},
+	}
+}
+
+// Routes lists the registered events
+func (r *Router) Routes() []*Route {
+	routes := make([]*Route, 0, len(r.routes))
+	for _, route := range r.routes {
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+// Dispatch runs the event through the middlewares and its handler.
+// A *protocol.Error returned anywhere on the way is sent back to the client,
+// any other error is logged and answered as INTERNAL.
+func (r *Router) Dispatch(req *Request) {
+	route, exists := r.routes[req.Message.Event]
+
+	var err error
+
+	if !exists {
+		err = protocol.Errorf(protocol.CodeUnknownEvent, "unknown room action: %s", req.Message.Event)
+	} else {
+		handle := route.handle
+		for i := len(r.middlewares) - 1; i >= 0; i-- {
+			handle = r.middlewares[i](route, handle)
+		}
+
+		err = handle(req)
+	}
+
+	if err == nil {
+		return
+	}
+
+	var protocolErr *protocol.Error
+	if !errors.As(err, &protocolErr) {
+		req.Socket.Log().Error("ws event failed", "event", req.Message.Event, "err", err)
+		protocolErr = protocol.Errorf(protocol.CodeInternal, "failed to process room action")
+	}
+
+	if err := r.reply(req, protocol.NewError(protocolErr.Code, protocolErr.Message).ReplyTo(req.Message)); err != nil {
+		req.Socket.Log().Warn("failed to send error", "event", req.Message.Event, "err", err)
+	}
+}
@@ -0,0 +1,135 @@
+// Package apiclient is a Go client for the CoWatchIt /api/v1 JSON API.
+//
+// The types and methods in client_gen.go are generated from internal/openapi,
+// run go generate ./internal/openapi after changing the API.
+package apiclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	token      string
+}
+
+type Option func(*Client)
+
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithToken authenticates every call with a session token
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// New creates a client for the server at baseURL, e.g. "https://cowatchit.example"
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// SetToken authenticates later calls, e.g. with the token of CreateSession
+func (c *Client) SetToken(token string) {
+	c.token = token
+}
+
+// Error is the error envelope of a failed call
+type Error struct {
+	StatusCode int                 `json:"-"`
+	Code       string              `json:"code"`
+	Message    string              `json:"message"`
+	Fields     map[string][]string `json:"fields,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("api: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+type envelope struct {
+	Data  json.RawMessage `json:"data"`
+	Meta  *PageMeta       `json:"meta"`
+	Error *Error          `json:"error"`
+}
+
+// do sends the request and decodes the envelope's data into out
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) (*PageMeta, error) {
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+
+	var result envelope
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, &Error{StatusCode: resp.StatusCode, Code: "invalid_response", Message: err.Error()}
+	}
+
+	if resp.StatusCode >= 400 || result.Error != nil {
+		if result.Error == nil {
+			result.Error = &Error{Code: "unknown", Message: resp.Status}
+		}
+		result.Error.StatusCode = resp.StatusCode
+		return nil, result.Error
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(result.Data, out); err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+
+	return result.Meta, nil
+}
@@ -0,0 +1,353 @@
+// Code generated by cmd/apigen from internal/openapi. DO NOT EDIT.
+
+package apiclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type CreateRoomDto struct {
+	Title         string `json:"title"`
+	Capacity      int    `json:"capacity"`
+	Description   string `json:"description"`
+	Src           string `json:"src"`
+	Private       bool   `json:"private"`
+	Password      string `json:"password"`
+	LibraryItemID string `json:"library_item_id"`
+}
+
+type CreateRoomMessageRequestDto struct {
+	Content string `json:"content"`
+}
+
+type MeResponse struct {
+	ID               uuid.UUID `json:"id"`
+	Username         string    `json:"username"`
+	Email            string    `json:"email"`
+	Gender           string    `json:"gender"`
+	Age              uint8     `json:"age"`
+	DateOfBirth      time.Time `json:"date_of_birth"`
+	IsAdmin          bool      `json:"is_admin"`
+	CreatedAt        time.Time `json:"created_at"`
+	TwoFactorEnabled bool      `json:"two_factor_enabled"`
+}
+
+type PageMeta struct {
+	Page    int  `json:"page"`
+	PerPage int  `json:"per_page"`
+	HasMore bool `json:"has_more"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type RoomMessageResponse struct {
+	ID             uuid.UUID `json:"id"`
+	RoomID         uuid.UUID `json:"room_id"`
+	SenderID       uuid.UUID `json:"sender_id"`
+	SenderUsername string    `json:"sender_username"`
+	IsHost         bool      `json:"is_host"`
+	Content        string    `json:"content"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type RoomResponse struct {
+	ID            uuid.UUID  `json:"id"`
+	HostID        uuid.UUID  `json:"host_id"`
+	HostUsername  string     `json:"host_username"`
+	Title         string     `json:"title"`
+	Description   string     `json:"description"`
+	Capacity      int        `json:"capacity"`
+	Src           string     `json:"src"`
+	LibraryItemID *uuid.UUID `json:"library_item_id,omitempty"`
+	Poster        string     `json:"poster"`
+	Private       bool       `json:"private"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+type SessionResponse struct {
+	Token             string        `json:"token"`
+	ExpiresAt         time.Time     `json:"expires_at"`
+	User              *UserResponse `json:"user"`
+	TwoFactorRequired bool          `json:"two_factor_required"`
+}
+
+type SignInDto struct {
+	Username *string `json:"username"`
+	Password *string `json:"password"`
+}
+
+type SignUpDto struct {
+	Username             *string `json:"username"`
+	Email                *string `json:"email"`
+	Gender               *string `json:"gender"`
+	Password             *string `json:"password"`
+	PasswordConfirmation *string `json:"password_confirmation"`
+	DateOfBirth          *string `json:"date_of_birth"`
+}
+
+type TwoFactorCodeDto struct {
+	Code string `json:"code"`
+}
+
+type TwoFactorEnrollmentResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+	QRCode          string `json:"qr_code"`
+}
+
+type UpdateRoomDto struct {
+	Title       *string `json:"title"`
+	Capacity    *int    `json:"capacity"`
+	Description *string `json:"description"`
+	Src         *string `json:"src"`
+	Private     *bool   `json:"private"`
+	Password    *string `json:"password"`
+}
+
+type UserResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Username  string    `json:"username"`
+	Gender    string    `json:"gender"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
+}
+
+type VerifyTwoFactorDto struct {
+	Token string `json:"token"`
+	Code  string `json:"code"`
+}
+
+// CreateSession sends POST /api/v1/sessions
+//
+// Sign in, the token is also set as the session cookie. With two-factor on it only works for VerifyTwoFactor.
+func (c *Client) CreateSession(ctx context.Context, body *SignInDto) (*SessionResponse, error) {
+	var data SessionResponse
+	if _, err := c.do(ctx, http.MethodPost, "/api/v1/sessions", nil, body, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// VerifyTwoFactor sends POST /api/v1/sessions/two-factor
+//
+// Finish signing in with a two-factor or recovery code
+func (c *Client) VerifyTwoFactor(ctx context.Context, body *VerifyTwoFactorDto) (*SessionResponse, error) {
+	var data SessionResponse
+	if _, err := c.do(ctx, http.MethodPost, "/api/v1/sessions/two-factor", nil, body, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// DeleteSession sends DELETE /api/v1/sessions/current
+//
+// Sign out, called with a personal API token it revokes the token
+func (c *Client) DeleteSession(ctx context.Context) error {
+	_, err := c.do(ctx, http.MethodDelete, "/api/v1/sessions/current", nil, nil, nil)
+	return err
+}
+
+// CreateUser sends POST /api/v1/users
+//
+// Sign up and start a session
+func (c *Client) CreateUser(ctx context.Context, body *SignUpDto) (*SessionResponse, error) {
+	var data SessionResponse
+	if _, err := c.do(ctx, http.MethodPost, "/api/v1/users", nil, body, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// GetMe sends GET /api/v1/users/me
+//
+// The signed in user
+func (c *Client) GetMe(ctx context.Context) (*MeResponse, error) {
+	var data MeResponse
+	if _, err := c.do(ctx, http.MethodGet, "/api/v1/users/me", nil, nil, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// EnableTwoFactor sends POST /api/v1/users/me/two-factor
+//
+// Start turning two-factor on, returns the secret for an authenticator app
+func (c *Client) EnableTwoFactor(ctx context.Context) (*TwoFactorEnrollmentResponse, error) {
+	var data TwoFactorEnrollmentResponse
+	if _, err := c.do(ctx, http.MethodPost, "/api/v1/users/me/two-factor", nil, nil, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// ConfirmTwoFactor sends POST /api/v1/users/me/two-factor/confirm
+//
+// Turn two-factor on with a first code, returns the recovery codes
+func (c *Client) ConfirmTwoFactor(ctx context.Context, body *TwoFactorCodeDto) (*RecoveryCodesResponse, error) {
+	var data RecoveryCodesResponse
+	if _, err := c.do(ctx, http.MethodPost, "/api/v1/users/me/two-factor/confirm", nil, body, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// DisableTwoFactor sends POST /api/v1/users/me/two-factor/disable
+//
+// Turn two-factor off with a current or recovery code
+func (c *Client) DisableTwoFactor(ctx context.Context, body *TwoFactorCodeDto) error {
+	_, err := c.do(ctx, http.MethodPost, "/api/v1/users/me/two-factor/disable", nil, body, nil)
+	return err
+}
+
+// GetUser sends GET /api/v1/users/{username}
+//
+// A user's public profile
+func (c *Client) GetUser(ctx context.Context, username string) (*UserResponse, error) {
+	var data UserResponse
+	if _, err := c.do(ctx, http.MethodGet, "/api/v1/users/"+url.PathEscape(fmt.Sprint(username)), nil, nil, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+type ListRoomsParams struct {
+	// all, public or private
+	Filter string
+	// Part of the title
+	Keyword string
+	// Only rooms hosted by the signed in user
+	My *bool
+	// Page number, from 1
+	Page int
+	// Items per page, at most 100 (default 20)
+	PerPage int
+}
+
+func (p *ListRoomsParams) values() url.Values {
+	values := url.Values{}
+	if p == nil {
+		return values
+	}
+	if p.Filter != "" {
+		values.Set("filter", p.Filter)
+	}
+	if p.Keyword != "" {
+		values.Set("keyword", p.Keyword)
+	}
+	if p.My != nil {
+		values.Set("my", strconv.FormatBool(*p.My))
+	}
+	if p.Page != 0 {
+		values.Set("page", strconv.Itoa(p.Page))
+	}
+	if p.PerPage != 0 {
+		values.Set("per_page", strconv.Itoa(p.PerPage))
+	}
+	return values
+}
+
+// ListRooms sends GET /api/v1/rooms
+//
+// List rooms, newest first
+func (c *Client) ListRooms(ctx context.Context, params *ListRoomsParams) ([]RoomResponse, *PageMeta, error) {
+	var data []RoomResponse
+	meta, err := c.do(ctx, http.MethodGet, "/api/v1/rooms", params.values(), nil, &data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, meta, nil
+}
+
+// CreateRoom sends POST /api/v1/rooms
+//
+// Create a room hosted by the signed in user
+func (c *Client) CreateRoom(ctx context.Context, body *CreateRoomDto) (*RoomResponse, error) {
+	var data RoomResponse
+	if _, err := c.do(ctx, http.MethodPost, "/api/v1/rooms", nil, body, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// GetRoom sends GET /api/v1/rooms/{id}
+//
+// Get a room
+func (c *Client) GetRoom(ctx context.Context, id uuid.UUID) (*RoomResponse, error) {
+	var data RoomResponse
+	if _, err := c.do(ctx, http.MethodGet, "/api/v1/rooms/"+url.PathEscape(fmt.Sprint(id)), nil, nil, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// UpdateRoom sends PATCH /api/v1/rooms/{id}
+//
+// Change the given fields of a room, host or admin only
+func (c *Client) UpdateRoom(ctx context.Context, id uuid.UUID, body *UpdateRoomDto) (*RoomResponse, error) {
+	var data RoomResponse
+	if _, err := c.do(ctx, http.MethodPatch, "/api/v1/rooms/"+url.PathEscape(fmt.Sprint(id)), nil, body, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// DeleteRoom sends DELETE /api/v1/rooms/{id}
+//
+// Delete a room and its messages, host or admin only
+func (c *Client) DeleteRoom(ctx context.Context, id uuid.UUID) error {
+	_, err := c.do(ctx, http.MethodDelete, "/api/v1/rooms/"+url.PathEscape(fmt.Sprint(id)), nil, nil, nil)
+	return err
+}
+
+type ListRoomMessagesParams struct {
+	// Page number, from 1
+	Page int
+	// Items per page, at most 100 (default 20)
+	PerPage int
+}
+
+func (p *ListRoomMessagesParams) values() url.Values {
+	values := url.Values{}
+	if p == nil {
+		return values
+	}
+	if p.Page != 0 {
+		values.Set("page", strconv.Itoa(p.Page))
+	}
+	if p.PerPage != 0 {
+		values.Set("per_page", strconv.Itoa(p.PerPage))
+	}
+	return values
+}
+
+// ListRoomMessages sends GET /api/v1/rooms/{id}/messages
+//
+// A room's chat, oldest first
+func (c *Client) ListRoomMessages(ctx context.Context, id uuid.UUID, params *ListRoomMessagesParams) ([]RoomMessageResponse, *PageMeta, error) {
+	var data []RoomMessageResponse
+	meta, err := c.do(ctx, http.MethodGet, "/api/v1/rooms/"+url.PathEscape(fmt.Sprint(id))+"/messages", params.values(), nil, &data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, meta, nil
+}
+
+// CreateRoomMessage sends POST /api/v1/rooms/{id}/messages
+//
+// Post to a room's chat, connected sockets receive it
+func (c *Client) CreateRoomMessage(ctx context.Context, id uuid.UUID, body *CreateRoomMessageRequestDto) (*RoomMessageResponse, error) {
+	var data RoomMessageResponse
+	if _, err := c.do(ctx, http.MethodPost, "/api/v1/rooms/"+url.PathEscape(fmt.Sprint(id))+"/messages", nil, body, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
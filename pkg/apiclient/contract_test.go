@@ -0,0 +1,288 @@
+package apiclient_test
+
+// The /api/v1 router, its request validation and the generated client must
+// agree with internal/openapi. No database is needed: handlers are never
+// reached with real services.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dliluashvili/cowatchit/internal/config"
+	"github.com/dliluashvili/cowatchit/internal/handlers"
+	"github.com/dliluashvili/cowatchit/internal/models"
+	"github.com/dliluashvili/cowatchit/internal/openapi"
+	"github.com/dliluashvili/cowatchit/internal/shared/constants"
+	"github.com/dliluashvili/cowatchit/internal/shared/validators"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+// Reported instead of a response when a request got past validation
+const reachedHandler = 599
+
+type noUsers struct{}
+
+func (noUsers) FindByField(context.Context, map[string]any) (*models.User, error) {
+	return nil, nil
+}
+
+type contract struct {
+	router     chi.Router
+	spec       map[string]any
+	authCalled bool
+	// Scopes of the API token the stub authenticates with, nil for a password session
+	tokenScopes []string
+}
+
+func newContract(t *testing.T) *contract {
+	t.Helper()
+
+	c := &contract{spec: specJSON(t)}
+
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c.authCalled = true
+			session := &models.Session{User: &models.User{ID: uuid.New(), Username: "contract"}}
+			if c.tokenScopes != nil {
+				tokenID := uuid.New()
+				session.APITokenID = &tokenID
+				session.Scopes = c.tokenScopes
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), constants.SessionContextKey, session)))
+		})
+	}
+
+	api := handlers.NewAPIHandler(nil, nil, nil, nil, nil, nil, nil, nil, config.Default().Session, true)
+	c.router = api.Routes(validators.New(noUsers{}), auth)
+
+	return c
+}
+
+// Every route has an operation and every operation a route
+func TestRoutesMatchOperations(t *testing.T) {
+	c := newContract(t)
+	routes := []string{}
+
+	chi.Walk(c.router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		routes = append(routes, method+" "+openapi.BasePath+strings.TrimSuffix(route, "/"))
+		return nil
+	})
+
+	documented := []string{}
+	for _, op := range openapi.Operations {
+		documented = append(documented, op.Method+" "+op.Path)
+	}
+
+	for _, route := range routes {
+		if !slices.Contains(documented, route) {
+			t.Errorf("%s is routed but not in openapi.Operations", route)
+		}
+	}
+
+	for _, op := range documented {
+		if !slices.Contains(routes, op) {
+			t.Errorf("%s is in openapi.Operations but not routed", op)
+		}
+	}
+}
+
+// An empty body must fail validation with exactly the spec's required
+// fields, and the session and scope checks must match the operation's security
+func TestOperationsMatchValidation(t *testing.T) {
+	c := newContract(t)
+
+	for _, op := range openapi.Operations {
+		t.Run(op.Method+" "+op.Path, func(t *testing.T) {
+			c.checkOperation(t, op)
+		})
+	}
+}
+
+func (c *contract) checkOperation(t *testing.T, op openapi.Operation) {
+	path := op.Path
+	for _, param := range op.Params {
+		value := "contract"
+		if param.Type == (uuid.UUID{}) {
+			value = uuid.NewString()
+		}
+		path = strings.ReplaceAll(path, "{"+param.Name+"}", value)
+	}
+
+	body := []byte{}
+	required := []string{}
+
+	if op.Request != nil {
+		body = []byte("{}")
+		required = c.requiredFields(reflect.TypeOf(op.Request).Name())
+	}
+
+	c.authCalled = false
+	status, fields := c.send(op.Method, path, body)
+
+	if c.authCalled == op.Public {
+		t.Errorf("session required is %t, spec says %t", c.authCalled, !op.Public)
+	}
+
+	if !op.Public {
+		c.checkScope(t, op, path, body)
+	}
+
+	if len(required) == 0 {
+		if status != reachedHandler {
+			t.Errorf("an empty body should pass validation, got %d", status)
+		}
+		return
+	}
+
+	if status != http.StatusUnprocessableEntity {
+		t.Errorf("an empty body should fail validation, got %d", status)
+		return
+	}
+
+	slices.Sort(required)
+	slices.Sort(fields)
+
+	if !slices.Equal(required, fields) {
+		t.Errorf("spec requires %v, validation requires %v", required, fields)
+	}
+}
+
+// A token holding every scope but the operation's is turned away
+// exactly when the operation has a scope or takes password sessions only
+func (c *contract) checkScope(t *testing.T, op openapi.Operation, path string, body []byte) {
+	scopes := []string{}
+	for _, scope := range models.Scopes {
+		if scope != op.Scope {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	c.tokenScopes = scopes
+	status, _ := c.send(op.Method, path, body)
+	c.tokenScopes = nil
+
+	if forbidden := status == http.StatusForbidden; forbidden != (op.Scope != "" || op.PasswordSession) {
+		t.Errorf("spec requires scope %q and password session %t, a token without the scope got %d", op.Scope, op.PasswordSession, status)
+	}
+}
+
+func (c *contract) send(method, path string, body []byte) (status int, fields []string) {
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(method, strings.TrimPrefix(path, openapi.BasePath), bytes.NewReader(body))
+
+	func() {
+		// Handlers have no services to call
+		defer func() {
+			if recover() != nil {
+				recorder.Code = reachedHandler
+			}
+		}()
+		c.router.ServeHTTP(recorder, req)
+	}()
+
+	var response struct {
+		Error *struct {
+			Fields map[string][]string `json:"fields"`
+		} `json:"error"`
+	}
+	json.Unmarshal(recorder.Body.Bytes(), &response)
+
+	if response.Error != nil {
+		for field := range response.Error.Fields {
+			fields = append(fields, field)
+		}
+	}
+
+	return recorder.Code, fields
+}
+
+func (c *contract) requiredFields(schema string) []string {
+	schemas := c.spec["components"].(map[string]any)["schemas"].(map[string]any)
+
+	required := []string{}
+	if def, ok := schemas[schema].(map[string]any); ok {
+		for _, name := range def["required"].([]any) {
+			required = append(required, name.(string))
+		}
+	}
+
+	return required
+}
+
+// Every $ref points at a component
+func TestSpecRefsResolve(t *testing.T) {
+	spec := specJSON(t)
+
+	var walk func(node any)
+	walk = func(node any) {
+		switch value := node.(type) {
+		case map[string]any:
+			if ref, ok := value["$ref"].(string); ok {
+				parts := strings.Split(strings.TrimPrefix(ref, "#/"), "/")
+
+				var target any = spec
+				for _, part := range parts {
+					object, _ := target.(map[string]any)
+					target = object[part]
+				}
+
+				if target == nil {
+					t.Errorf("dangling $ref %s", ref)
+				}
+			}
+
+			for _, child := range value {
+				walk(child)
+			}
+		case []any:
+			for _, child := range value {
+				walk(child)
+			}
+		}
+	}
+
+	walk(spec)
+}
+
+// The committed client is what go generate would write
+func TestClientIsGenerated(t *testing.T) {
+	generated, err := openapi.GenerateClient("apiclient")
+	if err != nil {
+		t.Fatalf("failed to generate client: %v", err)
+	}
+
+	committed, err := os.ReadFile("client_gen.go")
+	if err != nil {
+		t.Fatalf("failed to read client: %v", err)
+	}
+
+	if !bytes.Equal(generated, committed) {
+		t.Error("client_gen.go is stale, run go generate ./internal/openapi")
+	}
+}
+
+// The spec as clients decode it
+func specJSON(t *testing.T) map[string]any {
+	t.Helper()
+
+	data, err := json.Marshal(openapi.Spec())
+	if err != nil {
+		t.Fatalf("failed to encode spec: %v", err)
+	}
+
+	var spec map[string]any
+	if err := json.Unmarshal(data, &spec); err != nil {
+		t.Fatalf("failed to decode spec: %v", err)
+	}
+
+	return spec
+}
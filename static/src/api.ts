@@ -1,10 +1,13 @@
 import {
+    type CreateLibraryItemBody,
     type CreateRoomBody,
+    type HttpLibraryItemResponse,
     type HttpGetMeResponse,
     type HttpSuccessResponse,
     type SignInBody,
     type SignUpBody,
 } from './types'
+import { getCSRFToken } from './helpers'
 
 export const signIn = async (
     url: string,
@@ -14,6 +17,7 @@ export const signIn = async (
         method: 'POST',
         headers: {
             'Content-Type': 'application/json',
+            'X-CSRF-Token': getCSRFToken(),
         },
         body: JSON.stringify(body),
     })
@@ -31,6 +35,7 @@ export const signUp = async (
         method: 'POST',
         headers: {
             'Content-Type': 'application/json',
+            'X-CSRF-Token': getCSRFToken(),
         },
         body: JSON.stringify(body),
     })
@@ -64,6 +69,7 @@ export const createRoom = async (
         method: 'POST',
         headers: {
             'Content-Type': 'application/json',
+            'X-CSRF-Token': getCSRFToken(),
         },
         body: JSON.stringify(body),
     })
@@ -72,3 +78,83 @@ export const createRoom = async (
 
     return data
 }
+
+export const uploadPoster = async (
+    roomID: string,
+    poster: File
+): Promise<HttpSuccessResponse> => {
+    const url = `/rooms/${roomID}/poster`
+
+    const body = new FormData()
+    body.append('poster', poster)
+
+    const response = await fetch(url, {
+        method: 'POST',
+        headers: {
+            'X-CSRF-Token': getCSRFToken(),
+        },
+        body,
+    })
+
+    const data = await response.json()
+
+    return data
+}
+
+export const createLibraryItem = async (
+    body: CreateLibraryItemBody
+): Promise<HttpLibraryItemResponse> => {
+    const url = '/library'
+
+    const response = await fetch(url, {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json',
+            'X-CSRF-Token': getCSRFToken(),
+        },
+        body: JSON.stringify(body),
+    })
+
+    const data = await response.json()
+
+    return data
+}
+
+export const getLibraryUpload = async (
+    itemID: string
+): Promise<HttpLibraryItemResponse> => {
+    const url = `/library/${itemID}/upload`
+
+    const response = await fetch(url, {
+        method: 'GET',
+        headers: {
+            'Content-Type': 'application/json',
+        },
+    })
+
+    const data = await response.json()
+
+    return data
+}
+
+export const uploadLibraryChunk = async (
+    itemID: string,
+    offset: number,
+    chunk: Blob
+): Promise<HttpLibraryItemResponse> => {
+    const url = `/library/${itemID}/upload`
+
+    const response = await fetch(url, {
+        method: 'PATCH',
+        headers: {
+            'Content-Type': 'application/offset+octet-stream',
+            'Upload-Offset': String(offset),
+            'X-CSRF-Token': getCSRFToken(),
+        },
+        body: chunk,
+    })
+
+    const data = await response.json()
+
+    return data
+}
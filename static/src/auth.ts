@@ -93,7 +93,9 @@ document.addEventListener('htmx:load', async function () {
                 const response = await signIn(action, body)
 
                 if (isAuthSuccess(response)) {
-                    window.location.href = '/rooms'
+                    window.location.href = response.data.two_factor
+                        ? '/auth/two-factor'
+                        : '/rooms'
                 } else if (isAuthFailure(response)) {
                     if (response.status === 422) {
                         const { data: errors } = response
@@ -57,4 +57,11 @@ export function escapeHtml(text: string): string {
 export function formatTime(timestamp: string): string {
     const date = new Date(timestamp)
     return date.toLocaleTimeString([], { hour: '2-digit', minute: '2-digit' })
-}
\ No newline at end of file
+}
+export const getCSRFToken = (): string => {
+    const meta = document.querySelector(
+        'meta[name="csrf-token"]'
+    ) as HTMLMetaElement
+
+    return meta?.content ?? ''
+}
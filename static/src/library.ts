@@ -0,0 +1,170 @@
+import { getValueByInputName } from './helpers'
+import { drawFormErrors, generalError, resetErrors } from './errors'
+import {
+    createLibraryItem,
+    getLibraryUpload,
+    uploadLibraryChunk,
+} from './api'
+import {
+    isLibraryItem,
+    type HttpLibraryItemResponse,
+    type LibraryItem,
+} from './types'
+
+const CHUNK_SIZE = 8 * 1024 * 1024
+const MAX_RETRIES = 5
+
+// The same file picked again resumes the upload it started
+const resumeKey = (file: File): string =>
+    `library-upload:${file.name}:${file.size}:${file.lastModified}`
+
+const sleep = (ms: number) => new Promise((resolve) => setTimeout(resolve, ms))
+
+async function resumable(file: File): Promise<LibraryItem | null> {
+    const itemID = localStorage.getItem(resumeKey(file))
+    if (!itemID) {
+        return null
+    }
+
+    try {
+        const response = await getLibraryUpload(itemID)
+
+        if (response.status === 200 && isLibraryItem(response)) {
+            return response.data
+        }
+    } catch (exception) {}
+
+    localStorage.removeItem(resumeKey(file))
+    return null
+}
+
+async function sendChunks(
+    item: LibraryItem,
+    file: File,
+    onProgress: (received: number) => void
+) {
+    let offset = item.received
+    let failures = 0
+
+    while (offset < file.size) {
+        onProgress(offset)
+
+        const chunk = file.slice(offset, offset + CHUNK_SIZE)
+
+        let response: HttpLibraryItemResponse | null = null
+        try {
+            response = await uploadLibraryChunk(item.id, offset, chunk)
+        } catch (exception) {}
+
+        if (
+            (response?.status === 200 || response?.status === 409) &&
+            isLibraryItem(response)
+        ) {
+            // A conflict means the server has a different offset,
+            // carry on from there
+            offset = response.data.received
+            failures = 0
+
+            if (response.data.status === 'ready') {
+                break
+            }
+            continue
+        }
+
+        // Network errors and server faults are retried, the rest is final
+        if (response && response.status < 500) {
+            throw new Error(response.message)
+        }
+
+        failures++
+        if (failures > MAX_RETRIES) {
+            throw new Error('Upload interrupted, choose the file again to resume')
+        }
+        await sleep(1000 * 2 ** failures)
+    }
+
+    onProgress(file.size)
+}
+
+document.addEventListener('htmx:load', function () {
+    const form = document.querySelector(
+        '#library-upload-form'
+    ) as HTMLFormElement | null
+
+    if (!form || form.dataset.bound) {
+        return
+    }
+    form.dataset.bound = 'true'
+
+    form.addEventListener('submit', async function (e: Event) {
+        e.preventDefault()
+
+        resetErrors(form)
+
+        const title = getValueByInputName(form, 'title').trim()
+        const file = (form.querySelector('input[name="file"]') as HTMLInputElement)
+            .files?.[0]
+
+        const errors: Record<string, string> = {}
+        if (!title) {
+            errors.title = 'Title is required'
+        }
+        if (!file) {
+            errors.file = 'Choose a video'
+        } else if (!file.type.startsWith('video/')) {
+            errors.file = 'Only video files can be uploaded'
+        }
+        if (Object.keys(errors).length > 0) {
+            drawFormErrors(form, errors)
+            return
+        }
+
+        const submit = form.querySelector(
+            'button[type="submit"]'
+        ) as HTMLButtonElement
+        const progress = form.querySelector(
+            '#library-upload-progress'
+        ) as HTMLProgressElement
+
+        submit.disabled = true
+        progress.classList.remove('hidden')
+
+        try {
+            let item = await resumable(file)
+
+            if (!item) {
+                const response = await createLibraryItem({
+                    title,
+                    filename: file.name,
+                    content_type: file.type,
+                    size: file.size,
+                })
+
+                if (response.status === 422 && !isLibraryItem(response)) {
+                    drawFormErrors(form, response.data ?? {})
+                    return
+                }
+                if (response.status !== 201 || !isLibraryItem(response)) {
+                    throw new Error(response.message)
+                }
+
+                item = response.data
+                localStorage.setItem(resumeKey(file), item.id)
+            }
+
+            await sendChunks(item, file, (received) => {
+                progress.value = Math.floor((received / file.size) * 100)
+            })
+
+            localStorage.removeItem(resumeKey(file))
+            window.location.href = '/library'
+        } catch (exception) {
+            generalError(
+                form,
+                exception instanceof Error ? exception.message : null
+            )
+        } finally {
+            submit.disabled = false
+        }
+    })
+})
@@ -10,7 +10,22 @@ import {
     isResponseSuccess,
     type CreateRoomBody,
 } from './types'
-import { createRoom } from './api'
+import { createRoom, uploadPoster } from './api'
+import './library'
+
+// Inline handlers are blocked by the content security policy
+document.addEventListener('click', function (e: Event) {
+    const target = (e.target as HTMLElement).closest('[data-action]')
+
+    switch (target?.getAttribute('data-action')) {
+        case 'back':
+            history.back()
+            break
+        case 'reload':
+            location.reload()
+            break
+    }
+})
 
 document.addEventListener('htmx:load', async function () {
     const createRoomForm = document.querySelector('#create-room-form')
@@ -38,7 +53,11 @@ document.addEventListener('htmx:load', async function () {
                     v.maxValue(10, 'Capacity must not exceed 10')
                 ),
                 description: v.pipe(v.string(), v.trim()),
-                src: v.pipe(v.string(), v.url('Must be a valid URL')),
+                src: v.union([
+                    v.literal(''),
+                    v.pipe(v.string(), v.url('Must be a valid URL')),
+                ]),
+                library_item_id: v.optional(v.string()),
                 private: v.pipe(v.boolean()),
                 password: v.optional(
                     v.pipe(
@@ -63,6 +82,12 @@ document.addEventListener('htmx:load', async function () {
                     )
                 }, 'Password is required for private rooms'),
                 ['password']
+            ),
+            v.forward(
+                v.check((data) => {
+                    return data.src !== '' || !!data.library_item_id
+                }, 'Add a video link or pick a video from your library'),
+                ['src']
             )
         )
 
@@ -113,14 +138,42 @@ document.addEventListener('htmx:load', async function () {
                 body.password = getValueByInputName(form, 'password')
             }
 
+            const libraryItem = form.querySelector(
+                'select[name="library_item_id"]'
+            ) as HTMLSelectElement | null
+            if (libraryItem?.value) {
+                body.library_item_id = libraryItem.value
+                body.src = ''
+            }
+
             const result = v.safeParse(createRoomSchema, body)
 
             if (result.success) {
                 try {
                     const response = await createRoom(body)
 
-                    if (isResponseSuccess) {
-                    } else if (isResponseFailure) {
+                    if (isResponseSuccess(response)) {
+                        const poster = (
+                            form.querySelector(
+                                'input[name="poster"]'
+                            ) as HTMLInputElement
+                        ).files?.[0]
+
+                        // The room exists either way, a rejected poster
+                        // only leaves it without one
+                        if (poster && response.data.id) {
+                            const upload = await uploadPoster(
+                                response.data.id,
+                                poster
+                            )
+
+                            if (isResponseFailure(upload)) {
+                                console.error('poster upload failed', upload)
+                            }
+                        }
+
+                        window.location.href = '/rooms'
+                    } else if (isResponseFailure(response)) {
                         if (response.status === 422) {
                             const { data: errors } = response
                             drawFormErrors(form, errors)
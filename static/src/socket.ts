@@ -4,6 +4,7 @@ import type {
     Participant,
     Participants,
     WSMessage,
+    WSError,
     State,
 } from './types'
 import {
@@ -50,6 +51,23 @@ interface HTMXWebSocketErrorEvent extends Event {
     }
 }
 
+// HLS playlists go through the player's streaming engine, anything else
+// plays as a file
+const mediaSource = (src: string) => ({
+    src,
+    type: new URL(src, location.href).pathname.endsWith('.m3u8')
+        ? 'application/x-mpegURL'
+        : 'video/mp4',
+})
+
+// Library videos are served under links signed for this viewer, the socket
+// hands out a new one once this share of the old one's lifetime has passed
+const MEDIA_LINK_REFRESH = 0.8
+
+// Whether two links point to the same file, signatures aside
+const sameMedia = (a: string, b: string) =>
+    new URL(a, location.href).pathname === new URL(b, location.href).pathname
+
 document.addEventListener('htmx:load', async function () {
     let socketId: null | string = null
     let authUserId: null | string = null
@@ -58,6 +76,18 @@ document.addEventListener('htmx:load', async function () {
     let socketWrapper: null | WebSocket = null
     let player: null | Player = null
 
+    // The current link to the video and the timer renewing it
+    let mediaSrc: null | string = null
+    let mediaLinkTimer: null | ReturnType<typeof setTimeout> = null
+
+    // Resume state, survives reconnects of the same page
+    let joined = false
+    let resumeToken: null | string = null
+    let lastSeq = 0
+
+    // Set by SERVER_RESTARTING, the first reconnect waits at least this long
+    let restartDelay = 0
+
     const messagesDiv = document.querySelector('#messages') as HTMLDivElement
     const participantsDiv = document.querySelector(
         '#participants-list'
@@ -69,7 +99,18 @@ document.addEventListener('htmx:load', async function () {
         window.htmx.config.wsReconnectDelay = function (retryCount: number) {
             const baseDelay = 1000
             const maxDelay = 30000
-            return Math.min(baseDelay * retryCount, maxDelay)
+            const delay = Math.min(baseDelay * retryCount, maxDelay)
+
+            if (restartDelay > 0) {
+                // Spread reconnects so the new instance isn't hit all at once
+                const jitter = Math.random() * restartDelay
+                const restartWait = restartDelay + jitter
+                restartDelay = 0
+
+                return Math.max(delay, restartWait)
+            }
+
+            return delay
         }
     }
 
@@ -80,17 +121,25 @@ document.addEventListener('htmx:load', async function () {
             if (roomId) {
                 socketWrapper = event.detail.socketWrapper
 
-                setTimeout(function () {
-                    const wsMessage: WSMessage = {
-                        type: 'EVENT',
-                        event: 'USER_JOIN_REQUEST',
-                        data: {
-                            socket_id: socketId,
-                            room_id: roomId,
-                        },
-                    }
+                if (joined && resumeToken) {
+                    setTimeout(function () {
+                        const wsMessage: WSMessage = {
+                            type: 'EVENT',
+                            event: 'RESUME',
+                            data: {
+                                resume_token: resumeToken,
+                                last_seq: lastSeq,
+                            },
+                        }
+
+                        socketWrapper.send(JSON.stringify(wsMessage))
+                    }, 200)
+
+                    return
+                }
 
-                    socketWrapper.send(JSON.stringify(wsMessage))
+                setTimeout(function () {
+                    requestJoin(sessionStorage.getItem(passwordKey()))
                 }, 200)
 
                 setTimeout(function () {
@@ -112,6 +161,9 @@ document.addEventListener('htmx:load', async function () {
                 reason: event.detail.reason,
                 code: event.detail.code,
             })
+
+            // Wait for RESUME_ANSWER before using a socket id again
+            socketId = null
         }
     )
 
@@ -133,14 +185,51 @@ document.addEventListener('htmx:load', async function () {
 
                 console.log('msgg', msg)
 
+                if (msg.seq) {
+                    lastSeq = msg.seq
+                }
+
+                if (
+                    msg.type === 'ERROR' &&
+                    (msg.data as WSError).code === 'RESUME_REJECTED'
+                ) {
+                    // Resume was rejected, the slot is gone
+                    window.location.reload()
+                    return
+                }
+
+                if (
+                    msg.type === 'ERROR' &&
+                    (msg.data as WSError).code === 'ROOM_LOCKED'
+                ) {
+                    askPassword()
+                    return
+                }
+
                 if (msg.type === 'EVENT') {
                     switch (msg.event) {
                         case 'IDENTIFY':
-                            socketId = msg.data.socket_id
                             authUserId = msg.data.auth_id
                             authUsername = msg.data.auth_username
+
+                            if (!joined) {
+                                socketId = msg.data.socket_id
+                                resumeToken = msg.data.resume_token
+                            }
+                            break
+                        case 'RESUME_ANSWER':
+                            socketId = msg.data.socket_id
+                            resumeToken = msg.data.resume_token
+
+                            if (!msg.data.replay_complete) {
+                                window.location.reload()
+                            }
+                            break
+                        case 'SERVER_RESTARTING':
+                            restartDelay = msg.data.reconnect_after_ms
                             break
                         case 'USER_JOIN_ANSWER':
+                            joined = true
                             isRoomHost = msg.data.is_host
                             document.querySelector('.room-title').textContent =
                                 msg.data.title
@@ -206,10 +295,17 @@ document.addEventListener('htmx:load', async function () {
                                 }
 
                                 // Set source FIRST
-                                player.src({
-                                    src: msg.data.src,
-                                    type: 'video/mp4',
-                                })
+                                setMedia(
+                                    msg.data.src,
+                                    msg.data.src_expires_in_seconds
+                                )
+
+                                const joinedAt = msg.data.current_time_seconds
+                                if (joinedAt > 0) {
+                                    player.one('loadedmetadata', function () {
+                                        player.currentTime(joinedAt)
+                                    })
+                                }
 
                                 player.on('play', function () {
                                     if (!player.seeking()) {
@@ -327,6 +423,45 @@ document.addEventListener('htmx:load', async function () {
 
                             player.currentTime(msg.data.current_time_seconds)
 
+                            break
+                        case 'ROOM_MEDIA_CHANGED':
+                            if (!player) {
+                                break
+                            }
+
+                            switchMedia(
+                                msg.data.src,
+                                msg.data.src_expires_in_seconds
+                            )
+
+                            break
+                        case 'MEDIA_LINK_ANSWER':
+                            if (!player) {
+                                break
+                            }
+
+                            // A playing stream's files were signed to last
+                            // the whole video, it is only reloaded while
+                            // paused where that goes unnoticed. Files have
+                            // to be reopened.
+                            if (
+                                sameMedia(mediaSrc, msg.data.src) &&
+                                mediaSource(msg.data.src).type !==
+                                    'video/mp4' &&
+                                !player.paused()
+                            ) {
+                                mediaSrc = msg.data.src
+                                scheduleMediaLink(
+                                    msg.data.src_expires_in_seconds
+                                )
+                                break
+                            }
+
+                            switchMedia(
+                                msg.data.src,
+                                msg.data.src_expires_in_seconds
+                            )
+
                             break
                         case 'USER_LEFT':
                             setTimeout(() => {
@@ -352,6 +487,106 @@ document.addEventListener('htmx:load', async function () {
         }
     )
 
+    // Private rooms remember their password for the tab, so reloading
+    // doesn't ask again
+    function passwordKey() {
+        return `room-password:${roomId}`
+    }
+
+    function requestJoin(password: null | string) {
+        const wsMessage: WSMessage = {
+            type: 'EVENT',
+            event: 'USER_JOIN_REQUEST',
+            data: {
+                socket_id: socketId,
+                room_id: roomId,
+                ...(password ? { password } : {}),
+            },
+        }
+
+        socketWrapper.send(JSON.stringify(wsMessage))
+    }
+
+    function askPassword() {
+        const form = document.querySelector(
+            '#room-password-form'
+        ) as HTMLFormElement
+
+        // A remembered password that no longer works was wrong
+        if (sessionStorage.getItem(passwordKey()) !== null) {
+            sessionStorage.removeItem(passwordKey())
+            document
+                .querySelector('#room-password-error')
+                .classList.remove('hidden')
+        }
+
+        form.classList.remove('hidden')
+
+        form.onsubmit = function (e) {
+            e.preventDefault()
+
+            const input = form.querySelector(
+                '#room-password'
+            ) as HTMLInputElement
+
+            sessionStorage.setItem(passwordKey(), input.value)
+            form.classList.add('hidden')
+            requestJoin(input.value)
+
+            setTimeout(function () {
+                requestChatMessages()
+            }, 300)
+        }
+    }
+
+    function setMedia(src: string, expiresIn: number) {
+        mediaSrc = src
+        player.src(mediaSource(src))
+        scheduleMediaLink(expiresIn)
+    }
+
+    // Moves the player to another source and carries on where it was
+    function switchMedia(src: string, expiresIn: number) {
+        const resumeAt = player.currentTime()
+        const wasPaused = player.paused()
+
+        setMedia(src, expiresIn)
+        player.one('loadedmetadata', function () {
+            player.currentTime(resumeAt)
+
+            if (!wasPaused) {
+                player.play()
+            }
+        })
+    }
+
+    // Linked videos don't expire, they come without a lifetime
+    function scheduleMediaLink(expiresIn: number) {
+        clearTimeout(mediaLinkTimer)
+        mediaLinkTimer = null
+
+        if (!expiresIn) {
+            return
+        }
+
+        mediaLinkTimer = setTimeout(
+            requestMediaLink,
+            expiresIn * MEDIA_LINK_REFRESH * 1000
+        )
+    }
+
+    function requestMediaLink() {
+        const wsMessage: WSMessage = {
+            type: 'EVENT',
+            event: 'MEDIA_LINK_REQUEST',
+            data: {
+                room_id: roomId,
+            },
+        }
+
+        socketWrapper.send(JSON.stringify(wsMessage))
+    }
+
     function sendStateEvent(event: WSEvent, state: State) {
         const wsMessage: WSMessage = {
             type: 'EVENT',
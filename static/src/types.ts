@@ -17,6 +17,10 @@ export interface User {
 
 export interface SuccessResponse {
     success: boolean
+    // Sign in still needs the two-factor code
+    two_factor?: boolean
+    // The room just created
+    id?: string
 }
 
 export type HttpError = Record<string, Array<string>>
@@ -42,6 +46,38 @@ export interface CreateRoomBody {
     src: string
     private: boolean
     password?: string
+    library_item_id?: string
+}
+
+export interface CreateLibraryItemBody {
+    title: string
+    filename: string
+    content_type: string
+    size: number
+}
+
+export interface LibraryItem {
+    id: string
+    title: string
+    filename: string
+    content_type: string
+    size: number
+    // Bytes received so far, where the next chunk starts
+    received: number
+    status: 'uploading' | 'ready'
+}
+
+export interface HttpLibraryItemResponse
+    extends IHttpResponse<LibraryItem | HttpError | null> {}
+
+export function isLibraryItem(
+    response: HttpLibraryItemResponse
+): response is IHttpResponse<LibraryItem> {
+    return (
+        response.data !== null &&
+        typeof response.data === 'object' &&
+        'received' in response.data
+    )
 }
 
 export interface HttpSuccessResponse
@@ -108,6 +144,9 @@ export type State = 'STOP' | 'PAUSED' | 'PLAYING' | 'END'
 export type WSEvent =
     | 'HOST_STATE_SEND'
     | 'HOST_STATE_RECEIVED'
+    | 'ROOM_MEDIA_CHANGED'
+    | 'MEDIA_LINK_REQUEST'
+    | 'MEDIA_LINK_ANSWER'
     | 'USER_STATE_SEND'
     | 'USER_STATE_RECEIVED'
     | 'CHAT_MESSAGE_SEND'
@@ -118,11 +157,41 @@ export type WSEvent =
     | 'USER_JOIN_ANSWER'
     | 'USER_JOINT'
     | 'USER_LEFT'
+    | 'USER_LEAVE_REQUEST'
+    | 'USER_LEAVE_ANSWER'
     | 'IDENTIFY'
+    | 'RESUME'
+    | 'RESUME_ANSWER'
+    | 'SERVER_RESTARTING'
+    | 'ERROR'
+
+// Mirrors ErrorCode in protocol.schema.json
+export type WSErrorCode =
+    | 'INVALID_MESSAGE'
+    | 'INVALID_PAYLOAD'
+    | 'VALIDATION_FAILED'
+    | 'UNKNOWN_EVENT'
+    | 'NOT_IN_ROOM'
+    | 'ALREADY_IN_ROOM'
+    | 'ROOM_NOT_FOUND'
+    | 'ROOM_FULL'
+    | 'ROOM_LOCKED'
+    | 'TOO_MANY_SOCKETS'
+    | 'FORBIDDEN'
+    | 'RESUME_REJECTED'
+    | 'RATE_LIMITED'
+    | 'INTERNAL'
+
+export interface WSError {
+    code: WSErrorCode
+    message: string
+}
 
 export interface WSMessage {
     type: Type
     event?: WSEvent | null
+    request_id?: string
+    seq?: number
     data?: Record<string, any> | null
 }
 